@@ -1,14 +0,0 @@
-// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
-// Use of this source code is governed by a BSD-style
-// license that can be found in the LICENSE file.
-
-package metric
-
-const (
-	errorNullRegisterStore      = "Invalid register store : (null)"
-	errorInvalidMetric          = "Invalid metric : %v"
-	errorStoreCouldNotOpen      = "Couldn't open : %s"
-	errorStoreCouldNotClose     = "Couldn't close : %s"
-	errorStoreCouldNotAddMetric = "Couldn't add a metric : %s"
-	errorStoreInvalidQuery      = "Invalid query : %s"
-)
@@ -16,19 +16,27 @@ package server
 
 import (
 	"github.com/cybergarage/go-graphite/net/graphite"
+	"github.com/cybergarage/go-graphite/net/graphite/memory"
 )
 
 // Server represents an example server.
 type Server struct {
 	*graphite.Server
+	store *memory.Store
 }
 
 // NewServer returns an example server instance.
 func NewServer() *Server {
 	server := &Server{
 		Server: graphite.NewServer(),
+		store:  memory.NewStore(),
 	}
-	server.SetCarbonListener(server)
-	server.SetRenderListener(server)
+	server.SetStore(server.store)
+	server.SetMetricIndex(server.store.GetMetricIndex())
 	return server
 }
+
+// GetStore returns the in-memory store of the server.
+func (server *Server) GetStore() *memory.Store {
+	return server.store
+}
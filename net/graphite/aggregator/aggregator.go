@@ -0,0 +1,268 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package aggregator provides a carbon-aggregator compatible aggregator with the rules of aggregation-rules.conf.
+package aggregator
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cybergarage/go-graphite/net/graphite"
+)
+
+const (
+	// DefaultFlushInterval is the default interval to emit the aggregated metrics.
+	DefaultFlushInterval = time.Second
+	// DefaultMaxAggregationIntervals is the default number of the past intervals which accept the late datapoints, which is MAX_AGGREGATION_INTERVALS of carbon.
+	DefaultMaxAggregationIntervals = 5
+)
+
+// Stats represents the statistics of an aggregator.
+type Stats struct {
+	BufferCount int
+	Emitted     int64
+	LateDropped int64
+}
+
+// Aggregator is a graphite.CarbonListener which buffers the metrics matched with the aggregation rules per interval,
+// and emits the aggregated metrics into the output listener, which is a local store or a relay to the downstream Carbon.
+// The intervals are emitted after their end, and emitted again with all buffered datapoints when late datapoints arrive.
+type Aggregator struct {
+	sync.Mutex
+	rules         []*Rule
+	output        graphite.CarbonListener
+	forwardAll    bool
+	maxIntervals  int
+	flushInterval time.Duration
+	buffers       map[string]*metricBuffer
+	emitted       atomic.Int64
+	lateDropped   atomic.Int64
+	done          chan struct{}
+	wg            sync.WaitGroup
+}
+
+// NewAggregator returns a new aggregator with the specified rules and output listener.
+func NewAggregator(rules []*Rule, output graphite.CarbonListener) *Aggregator {
+	return &Aggregator{
+		Mutex:         sync.Mutex{},
+		rules:         rules,
+		output:        output,
+		forwardAll:    true,
+		maxIntervals:  DefaultMaxAggregationIntervals,
+		flushInterval: DefaultFlushInterval,
+		buffers:       map[string]*metricBuffer{},
+		emitted:       atomic.Int64{},
+		lateDropped:   atomic.Int64{},
+		done:          nil,
+		wg:            sync.WaitGroup{},
+	}
+}
+
+// SetRules sets the aggregation rules. The buffers of the current aggregate metrics are kept.
+func (agg *Aggregator) SetRules(rules []*Rule) {
+	agg.Lock()
+	defer agg.Unlock()
+	agg.rules = rules
+}
+
+// GetRules returns the aggregation rules.
+func (agg *Aggregator) GetRules() []*Rule {
+	agg.Lock()
+	defer agg.Unlock()
+	return agg.rules
+}
+
+// SetCarbonListener sets the output listener of the aggregated and forwarded metrics.
+func (agg *Aggregator) SetCarbonListener(output graphite.CarbonListener) {
+	agg.Lock()
+	defer agg.Unlock()
+	agg.output = output
+}
+
+// SetForwardAll sets whether the input metrics are also passed to the output listener, which is FORWARD_ALL of carbon.
+func (agg *Aggregator) SetForwardAll(flag bool) {
+	agg.Lock()
+	defer agg.Unlock()
+	agg.forwardAll = flag
+}
+
+// SetMaxAggregationIntervals sets the number of the past intervals which accept the late datapoints.
+func (agg *Aggregator) SetMaxAggregationIntervals(n int) {
+	agg.Lock()
+	defer agg.Unlock()
+	agg.maxIntervals = max(n, 1)
+}
+
+// SetFlushInterval sets the interval to emit the aggregated metrics. Set it before Start.
+func (agg *Aggregator) SetFlushInterval(d time.Duration) {
+	agg.Lock()
+	defer agg.Unlock()
+	agg.flushInterval = d
+}
+
+// Stats returns the current statistics of the aggregator.
+func (agg *Aggregator) Stats() *Stats {
+	agg.Lock()
+	defer agg.Unlock()
+	return &Stats{
+		BufferCount: len(agg.buffers),
+		Emitted:     agg.emitted.Load(),
+		LateDropped: agg.lateDropped.Load(),
+	}
+}
+
+// Start starts emitting the aggregated metrics periodically.
+func (agg *Aggregator) Start() error {
+	err := agg.Stop()
+	if err != nil {
+		return err
+	}
+
+	agg.Lock()
+	defer agg.Unlock()
+
+	agg.done = make(chan struct{})
+	agg.wg.Add(1)
+	go agg.run(agg.done, agg.flushInterval)
+
+	return nil
+}
+
+// Stop stops emitting periodically, and emits all buffered intervals including the current incomplete intervals.
+func (agg *Aggregator) Stop() error {
+	agg.Lock()
+	done := agg.done
+	agg.done = nil
+	agg.Unlock()
+
+	if done == nil {
+		return nil
+	}
+
+	close(done)
+	agg.wg.Wait()
+
+	agg.Flush()
+
+	return nil
+}
+
+func (agg *Aggregator) run(done chan struct{}, interval time.Duration) {
+	defer agg.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			agg.flush(time.Now().Unix(), false)
+		}
+	}
+}
+
+// Flush emits all active intervals including the current incomplete intervals.
+func (agg *Aggregator) Flush() {
+	agg.flush(time.Now().Unix(), true)
+}
+
+// flush emits the active intervals which end before the specified time, or all active intervals.
+func (agg *Aggregator) flush(now int64, all bool) {
+	until := now
+	if all {
+		until = math.MaxInt64
+	}
+
+	agg.Lock()
+	output := agg.output
+	ms := []*graphite.Metrics{}
+	for name, buf := range agg.buffers {
+		current := buf.align(now)
+		expire := current - (int64(agg.maxIntervals) * buf.frequency)
+		for _, ib := range buf.compute(until, expire) {
+			m := graphite.NewMetrics()
+			m.SetName(buf.name)
+			dp := graphite.NewDataPoint()
+			dp.SetTimestamp(time.Unix(ib.interval, 0))
+			dp.SetValue(Aggregate(buf.method, ib.values))
+			m.AddDataPoint(dp)
+			ms = append(ms, m)
+		}
+		if len(buf.intervals) == 0 {
+			delete(agg.buffers, name)
+		}
+	}
+	agg.Unlock()
+
+	if len(ms) == 0 {
+		return
+	}
+	agg.emitted.Add(int64(len(ms)))
+	if output != nil {
+		output.InsertMetricsRequestReceived(ms, nil)
+	}
+}
+
+// InsertMetricsRequestReceived buffers the datapoints of the metrics which match the aggregation rules,
+// and passes the input metrics to the output listener when forwarding all metrics.
+func (agg *Aggregator) InsertMetricsRequestReceived(ms []*graphite.Metrics, err error) {
+	agg.insert(ms, err, time.Now().Unix())
+}
+
+func (agg *Aggregator) insert(ms []*graphite.Metrics, err error, now int64) {
+	agg.Lock()
+	output := agg.output
+	forwarded := []*graphite.Metrics{}
+	for _, m := range ms {
+		if m == nil || len(m.Name) == 0 {
+			continue
+		}
+		isAggregate := false
+		for _, rule := range agg.rules {
+			name, ok := rule.AggregateMetric(m.Name)
+			if !ok {
+				continue
+			}
+			if name == m.Name {
+				isAggregate = true
+			}
+			agg.input(rule, name, m.DataPoints, now)
+		}
+		if agg.forwardAll && !isAggregate {
+			forwarded = append(forwarded, m)
+		}
+	}
+	agg.Unlock()
+
+	if output != nil && (0 < len(forwarded) || err != nil) {
+		output.InsertMetricsRequestReceived(forwarded, err)
+	}
+}
+
+// input adds the specified datapoints into the buffer of the aggregate metric, and drops the datapoints which are too late.
+func (agg *Aggregator) input(rule *Rule, name string, dps []*graphite.DataPoint, now int64) {
+	buf, ok := agg.buffers[name]
+	if !ok {
+		buf = newMetricBuffer(name, int64(rule.Frequency/time.Second), rule.Method)
+		agg.buffers[name] = buf
+	}
+	expire := buf.align(now) - (int64(agg.maxIntervals) * buf.frequency)
+	for _, dp := range dps {
+		if dp == nil {
+			continue
+		}
+		ts := dp.UnixTimestamp()
+		if buf.align(ts) < expire {
+			agg.lateDropped.Add(1)
+			continue
+		}
+		buf.input(ts, dp.Value)
+	}
+	if len(buf.intervals) == 0 {
+		delete(agg.buffers, name)
+	}
+}
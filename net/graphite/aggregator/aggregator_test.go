@@ -0,0 +1,175 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package aggregator
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cybergarage/go-graphite/net/graphite"
+)
+
+type testListener struct {
+	sync.Mutex
+	metrics []*graphite.Metrics
+}
+
+func (l *testListener) InsertMetricsRequestReceived(ms []*graphite.Metrics, err error) {
+	l.Lock()
+	defer l.Unlock()
+	l.metrics = append(l.metrics, ms...)
+}
+
+func (l *testListener) find(name string) []*graphite.Metrics {
+	l.Lock()
+	defer l.Unlock()
+	found := []*graphite.Metrics{}
+	for _, m := range l.metrics {
+		if m.Name == name {
+			found = append(found, m)
+		}
+	}
+	return found
+}
+
+func newTestMetrics(name string, ts int64, value float64) []*graphite.Metrics {
+	m := graphite.NewMetrics()
+	m.SetName(name)
+	dp := graphite.NewDataPoint()
+	dp.SetTimestamp(time.Unix(ts, 0))
+	dp.SetValue(value)
+	m.AddDataPoint(dp)
+	return []*graphite.Metrics{m}
+}
+
+func TestAggregate(t *testing.T) {
+	values := []float64{4, 1, 3, 2, 5}
+	testCases := []struct {
+		method   string
+		expected float64
+	}{
+		{"sum", 15},
+		{"avg", 3},
+		{"min", 1},
+		{"max", 5},
+		{"count", 5},
+		{"p50", 3},
+		{"p75", 4},
+		{"p90", 4.6},
+	}
+	for _, tc := range testCases {
+		v := Aggregate(tc.method, values)
+		if math.Abs(v-tc.expected) > 1e-9 {
+			t.Error(fmt.Errorf("%s : %f != %f", tc.method, v, tc.expected))
+		}
+	}
+	if !math.IsNaN(Aggregate("sum", []float64{})) {
+		t.Error(fmt.Errorf("no values are aggregated"))
+	}
+}
+
+func TestAggregator(t *testing.T) {
+	rules, err := ParseRules(strings.NewReader("<env>.applications.<app>.all.requests (60) = sum <env>.applications.<app>.*.requests\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output := &testListener{}
+	agg := NewAggregator(rules, output)
+
+	start := int64(1500000000 - (1500000000 % 60))
+	allName := "prod.applications.apache.all.requests"
+
+	agg.insert(newTestMetrics("prod.applications.apache.www01.requests", start+1, 1), nil, start+1)
+	agg.insert(newTestMetrics("prod.applications.apache.www02.requests", start+30, 2), nil, start+30)
+	agg.insert(newTestMetrics("servers.host1.cpu", start+30, 10), nil, start+30)
+
+	// All input metrics are forwarded
+
+	if len(output.metrics) != 3 {
+		t.Error(fmt.Errorf("%d != %d", len(output.metrics), 3))
+	}
+
+	// The interval is emitted after its end
+
+	agg.flush(start+59, false)
+	if len(output.find(allName)) != 0 {
+		t.Error(fmt.Errorf("an incomplete interval is emitted"))
+	}
+	agg.flush(start+60, false)
+	ms := output.find(allName)
+	if len(ms) != 1 || ms[0].DataPoints[0].Value != 3 || ms[0].DataPoints[0].UnixTimestamp() != start {
+		t.Fatal(fmt.Errorf("%v", ms))
+	}
+	agg.flush(start+61, false)
+	if len(output.find(allName)) != 1 {
+		t.Error(fmt.Errorf("an inactive interval is emitted again"))
+	}
+
+	// A late datapoint is aggregated again with the buffered datapoints
+
+	agg.insert(newTestMetrics("prod.applications.apache.www03.requests", start+10, 5), nil, start+70)
+	agg.flush(start+71, false)
+	ms = output.find(allName)
+	if len(ms) != 2 || ms[1].DataPoints[0].Value != 8 || ms[1].DataPoints[0].UnixTimestamp() != start {
+		t.Error(fmt.Errorf("%v", ms))
+	}
+
+	// A too late datapoint is dropped
+
+	agg.insert(newTestMetrics("prod.applications.apache.www03.requests", start-600, 5), nil, start+70)
+	if agg.Stats().LateDropped != 1 {
+		t.Error(fmt.Errorf("%v", agg.Stats()))
+	}
+
+	// The expired intervals are removed
+
+	agg.flush(start+600, false)
+	if agg.Stats().BufferCount != 0 {
+		t.Error(fmt.Errorf("%v", agg.Stats()))
+	}
+}
+
+func TestAggregatorShutdown(t *testing.T) {
+	rules, err := ParseRules(strings.NewReader("servers.all.cpu (60) = avg servers.*.cpu\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output := &testListener{}
+	agg := NewAggregator(rules, output)
+	agg.SetForwardAll(false)
+	err = agg.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().Unix()
+	server := graphite.NewServer()
+	server.SetCarbonListener(agg)
+	_, err = server.FeedPlainTextString(fmt.Sprintf("servers.host1.cpu 1 %d\nservers.host2.cpu 3 %d\n", now, now))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The current incomplete interval is emitted in the graceful shutdown
+
+	err = agg.Stop()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ms := output.find("servers.all.cpu")
+	if len(ms) != 1 || ms[0].DataPoints[0].Value != 2 {
+		t.Error(fmt.Errorf("%v", ms))
+	}
+	if len(output.metrics) != 1 {
+		t.Error(fmt.Errorf("%d != %d", len(output.metrics), 1))
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package aggregator
+
+import (
+	"sort"
+)
+
+// intervalBuffer holds the input values of an aggregation interval.
+// The buffer is active while it has the values which aren't aggregated yet.
+type intervalBuffer struct {
+	interval int64
+	values   []float64
+	active   bool
+}
+
+// metricBuffer holds the interval buffers of an aggregate metric.
+type metricBuffer struct {
+	name      string
+	frequency int64
+	method    string
+	intervals map[int64]*intervalBuffer
+}
+
+func newMetricBuffer(name string, frequency int64, method string) *metricBuffer {
+	return &metricBuffer{
+		name:      name,
+		frequency: max(frequency, 1),
+		method:    method,
+		intervals: map[int64]*intervalBuffer{},
+	}
+}
+
+func (buf *metricBuffer) align(ts int64) int64 {
+	aligned := ts - (ts % buf.frequency)
+	if ts < 0 && aligned != ts {
+		aligned -= buf.frequency
+	}
+	return aligned
+}
+
+// input adds the specified value into the buffer of its interval.
+func (buf *metricBuffer) input(ts int64, value float64) {
+	interval := buf.align(ts)
+	ib, ok := buf.intervals[interval]
+	if !ok {
+		ib = &intervalBuffer{
+			interval: interval,
+			values:   []float64{},
+			active:   false,
+		}
+		buf.intervals[interval] = ib
+	}
+	ib.values = append(ib.values, value)
+	ib.active = true
+}
+
+// compute aggregates the active intervals which end before the specified time, and removes the intervals which are older than the specified time.
+// The aggregated intervals are kept so that the late values are aggregated together with the earlier values again.
+func (buf *metricBuffer) compute(until int64, expire int64) []*intervalBuffer {
+	computed := []*intervalBuffer{}
+	for interval, ib := range buf.intervals {
+		if interval < expire {
+			delete(buf.intervals, interval)
+			continue
+		}
+		if !ib.active || until < (interval+buf.frequency) {
+			continue
+		}
+		computed = append(computed, ib)
+		ib.active = false
+	}
+	sort.Slice(computed, func(i, j int) bool {
+		return computed[i].interval < computed[j].interval
+	})
+	return computed
+}
@@ -0,0 +1,11 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package aggregator
+
+const (
+	errorInvalidRuleLine   = "invalid rule (%d) : %s"
+	errorInvalidRuleMethod = "invalid aggregation method : %s"
+	errorInvalidRuleFreq   = "invalid frequency : %s"
+)
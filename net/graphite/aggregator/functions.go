@@ -0,0 +1,77 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package aggregator
+
+import (
+	"math"
+	"sort"
+)
+
+var percentileFactors = map[string]float64{
+	"p50":  0.5,
+	"p75":  0.75,
+	"p80":  0.8,
+	"p90":  0.9,
+	"p95":  0.95,
+	"p99":  0.99,
+	"p999": 0.999,
+}
+
+// Aggregate consolidates the specified values with the aggregation method in the same way as carbon-aggregator.
+// Aggregate returns NaN for no values or unknown methods.
+func Aggregate(method string, values []float64) float64 {
+	if len(values) == 0 {
+		return math.NaN()
+	}
+
+	switch method {
+	case "sum":
+		return sumValues(values)
+	case "avg":
+		return sumValues(values) / float64(len(values))
+	case "min":
+		v := values[0]
+		for _, value := range values[1:] {
+			v = math.Min(v, value)
+		}
+		return v
+	case "max":
+		v := values[0]
+		for _, value := range values[1:] {
+			v = math.Max(v, value)
+		}
+		return v
+	case "count":
+		return float64(len(values))
+	}
+
+	factor, ok := percentileFactors[method]
+	if ok {
+		return percentile(values, factor)
+	}
+
+	return math.NaN()
+}
+
+func sumValues(values []float64) float64 {
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum
+}
+
+// percentile returns the percentile of the values with the linear interpolation between the closest ranks.
+func percentile(values []float64, factor float64) float64 {
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	rank := factor * float64(len(sorted)-1)
+	left := int(math.Floor(rank))
+	right := int(math.Ceil(rank))
+	if left == right {
+		return sorted[left]
+	}
+	return sorted[left]*(float64(right)-rank) + sorted[right]*(rank-float64(left))
+}
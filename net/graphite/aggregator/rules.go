@@ -0,0 +1,197 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package aggregator
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	rulePathSep = "."
+)
+
+// ruleLineRegex is the rule syntax of aggregation-rules.conf, 'output_template (frequency) = method input_pattern'.
+var ruleLineRegex = regexp.MustCompile(`^(\S+)\s+\((\d+)\)\s+=\s+(\S+)\s+(\S+)$`)
+
+// ruleFieldRegex is the field of the output templates such as '<env>'.
+var ruleFieldRegex = regexp.MustCompile(`<<?([^<>]+)>>?`)
+
+// AggregationMethods is the list of the aggregation methods of carbon-aggregator.
+var AggregationMethods = []string{
+	"sum", "avg", "min", "max", "count",
+	"p50", "p75", "p80", "p90", "p95", "p99", "p999",
+}
+
+// Rule represents an aggregation rule such as '<env>.applications.<app>.all.requests (60) = sum <env>.applications.<app>.*.requests'.
+type Rule struct {
+	OutputTemplate string
+	Frequency      time.Duration
+	Method         string
+	InputPattern   string
+	regex          *regexp.Regexp
+	cache          sync.Map
+}
+
+// ParseRule parses the specified rule line.
+func ParseRule(line string) (*Rule, error) {
+	match := ruleLineRegex.FindStringSubmatch(strings.TrimSpace(line))
+	if match == nil {
+		return nil, fmt.Errorf(errorInvalidRuleLine, 0, line)
+	}
+
+	freq, err := strconv.Atoi(match[2])
+	if err != nil || freq <= 0 {
+		return nil, fmt.Errorf(errorInvalidRuleFreq, match[2])
+	}
+
+	method := match[3]
+	if !isAggregationMethod(method) {
+		return nil, fmt.Errorf(errorInvalidRuleMethod, method)
+	}
+
+	regex, err := regexp.Compile(inputPatternRegex(match[4]))
+	if err != nil {
+		return nil, err
+	}
+
+	rule := &Rule{
+		OutputTemplate: match[1],
+		Frequency:      time.Duration(freq) * time.Second,
+		Method:         method,
+		InputPattern:   match[4],
+		regex:          regex,
+		cache:          sync.Map{},
+	}
+	return rule, nil
+}
+
+// ParseRules parses the rules of aggregation-rules.conf in the order of appearance.
+func ParseRules(reader io.Reader) ([]*Rule, error) {
+	rules := []*Rule{}
+	scanner := bufio.NewScanner(reader)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule, err := ParseRule(line)
+		if err != nil {
+			return nil, fmt.Errorf(errorInvalidRuleLine, lineNo, line)
+		}
+		rules = append(rules, rule)
+	}
+	err := scanner.Err()
+	if err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// LoadRules parses the specified aggregation-rules.conf.
+func LoadRules(path string) ([]*Rule, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ParseRules(file)
+}
+
+func isAggregationMethod(method string) bool {
+	for _, m := range AggregationMethods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// inputPatternRegex translates the specified input pattern into a regular expression in the same way as carbon-aggregator.
+// '<field>' matches a path node, '<<field>>' matches one or more path nodes, and '*' matches any characters in a path node.
+func inputPatternRegex(pattern string) string {
+	parts := []string{}
+	for part := range strings.SplitSeq(pattern, rulePathSep) {
+		i := strings.Index(part, "<<")
+		j := strings.Index(part, ">>")
+		if 0 <= i && i < j {
+			parts = append(parts, fmt.Sprintf("%s(?P<%s>.+?)%s", part[:i], part[i+2:j], part[j+2:]))
+			continue
+		}
+		i = strings.Index(part, "<")
+		j = strings.Index(part, ">")
+		switch {
+		case 0 <= i && i < j:
+			parts = append(parts, fmt.Sprintf("%s(?P<%s>[^.]+?)%s", part[:i], part[i+1:j], part[j+1:]))
+		case part == "*":
+			parts = append(parts, "[^.]+")
+		default:
+			parts = append(parts, strings.ReplaceAll(part, "*", "[^.]*"))
+		}
+	}
+	return "^" + strings.Join(parts, "\\.") + "$"
+}
+
+// AggregateMetric returns the aggregate metric name of the specified metric name, and returns false when the rule doesn't match the name.
+func (rule *Rule) AggregateMetric(name string) (string, bool) {
+	cached, ok := rule.cache.Load(name)
+	if ok {
+		result, _ := cached.(string)
+		return result, 0 < len(result)
+	}
+
+	result := ""
+	match := rule.regex.FindStringSubmatch(name)
+	if match != nil {
+		fields := map[string]string{}
+		for n, field := range rule.regex.SubexpNames() {
+			if 0 < len(field) {
+				fields[field] = match[n]
+			}
+		}
+		resolved := true
+		result = ruleFieldRegex.ReplaceAllStringFunc(rule.OutputTemplate, func(s string) string {
+			field := ruleFieldRegex.FindStringSubmatch(s)[1]
+			value, ok := fields[field]
+			if !ok {
+				resolved = false
+			}
+			return value
+		})
+		if !resolved {
+			result = ""
+		}
+	}
+
+	rule.cache.Store(name, result)
+
+	return result, 0 < len(result)
+}
+
+// String returns the rule definition.
+func (rule *Rule) String() string {
+	return fmt.Sprintf("%s (%d) = %s %s", rule.OutputTemplate, int(rule.Frequency/time.Second), rule.Method, rule.InputPattern)
+}
+
+// AggregateMetrics returns the aggregate metric names of the specified metric name by all rules.
+func AggregateMetrics(rules []*Rule, name string) []string {
+	names := []string{}
+	for _, rule := range rules {
+		aggregated, ok := rule.AggregateMetric(name)
+		if ok {
+			names = append(names, aggregated)
+		}
+	}
+	return names
+}
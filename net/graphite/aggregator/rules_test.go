@@ -0,0 +1,61 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package aggregator
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRules(t *testing.T) {
+	conf := `
+# aggregation-rules.conf
+<env>.applications.<app>.all.requests (60) = sum <env>.applications.<app>.*.requests
+<env>.applications.<app>.all.latency (10) = avg <env>.applications.<app>.*.latency
+servers.all.<<metric>> (30) = max servers.*.<<metric>>
+`
+	rules, err := ParseRules(strings.NewReader(conf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 3 {
+		t.Fatal(fmt.Errorf("%d != %d", len(rules), 3))
+	}
+	if rules[0].Frequency != time.Minute || rules[0].Method != "sum" {
+		t.Error(fmt.Errorf("%s", rules[0]))
+	}
+
+	testCases := []struct {
+		name     string
+		expected []string
+	}{
+		{"prod.applications.apache.www01.requests", []string{"prod.applications.apache.all.requests"}},
+		{"prod.applications.apache.www01.latency", []string{"prod.applications.apache.all.latency"}},
+		{"servers.host1.cpu.user", []string{"servers.all.cpu.user"}},
+		{"prod.applications.apache.www01.requests.total", []string{}},
+		{"servers.host1", []string{}},
+	}
+	for _, tc := range testCases {
+		names := AggregateMetrics(rules, tc.name)
+		if strings.Join(names, ",") != strings.Join(tc.expected, ",") {
+			t.Error(fmt.Errorf("%s : %v != %v", tc.name, names, tc.expected))
+		}
+	}
+
+	badRules := []string{
+		"a.all (60) = sum",
+		"a.all (x) = sum a.*",
+		"a.all (60) = median a.*",
+		"a.all 60 = sum a.*",
+	}
+	for _, line := range badRules {
+		_, err := ParseRule(line)
+		if err == nil {
+			t.Error(fmt.Errorf("%s is parsed", line))
+		}
+	}
+}
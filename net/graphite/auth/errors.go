@@ -0,0 +1,11 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package auth
+
+const (
+	errorInvalidHtpasswdLine = "invalid htpasswd line : %s:%d %s"
+	errorInvalidHtpasswdUser = "invalid htpasswd user : %s"
+	errorInvalidToken        = "invalid token : %s"
+)
@@ -0,0 +1,218 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package auth provides the authenticators of the Render API such as HTTP basic authentication with htpasswd files and static bearer tokens.
+package auth
+
+import (
+	"bufio"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	htpasswdSep     = ":"
+	htpasswdComment = "#"
+)
+
+// Htpasswd is an authenticator of HTTP basic authentication with the bcrypt hashes of an htpasswd file such as 'htpasswd -B'.
+// The passwords of the unknown users are also compared with a dummy hash not to reveal the existing users by the response times.
+type Htpasswd struct {
+	sync.RWMutex
+	path      string
+	modTime   time.Time
+	size      int64
+	hashes    map[string]string
+	dummyHash string
+}
+
+// NewHtpasswd returns a new empty authenticator which isn't bound to a file.
+func NewHtpasswd() *Htpasswd {
+	h := &Htpasswd{
+		RWMutex:   sync.RWMutex{},
+		path:      "",
+		modTime:   time.Time{},
+		size:      0,
+		hashes:    map[string]string{},
+		dummyHash: "",
+	}
+	return h
+}
+
+// LoadHtpasswd returns a new authenticator of the specified htpasswd file.
+func LoadHtpasswd(path string) (*Htpasswd, error) {
+	h := NewHtpasswd()
+	h.path = path
+	_, err := h.Reload()
+	if err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func parseHtpasswd(reader io.Reader, path string) (map[string]string, error) {
+	hashes := map[string]string{}
+	scanner := bufio.NewScanner(reader)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, htpasswdComment) {
+			continue
+		}
+		user, hash, ok := strings.Cut(line, htpasswdSep)
+		if !ok || len(user) == 0 || !isBcryptHash(hash) {
+			return nil, fmt.Errorf(errorInvalidHtpasswdLine, path, lineNo, user)
+		}
+		hashes[user] = hash
+	}
+	err := scanner.Err()
+	if err != nil {
+		return nil, err
+	}
+	return hashes, nil
+}
+
+// isBcryptHash returns true when the specified string is a bcrypt hash such as '$2y$05$...'.
+func isBcryptHash(hash string) bool {
+	_, err := bcrypt.Cost([]byte(hash))
+	return err == nil
+}
+
+// bcryptCost returns the cost of the specified bcrypt hash, or zero for the invalid hash.
+func bcryptCost(hash string) int {
+	cost, err := bcrypt.Cost([]byte(hash))
+	if err != nil {
+		return 0
+	}
+	return cost
+}
+
+// newDummyHash returns a bcrypt hash of a random password with the highest cost of the specified hashes,
+// so that the unknown users take as long as the known users to be rejected.
+func newDummyHash(hashes map[string]string) (string, error) {
+	if len(hashes) == 0 {
+		return "", nil
+	}
+	cost := bcrypt.MinCost
+	for _, hash := range hashes {
+		cost = max(cost, bcryptCost(hash))
+	}
+	password := make([]byte, 16)
+	_, err := rand.Read(password)
+	if err != nil {
+		return "", err
+	}
+	hash, err := bcrypt.GenerateFromPassword(password, cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// GetPath returns the path of the htpasswd file.
+func (h *Htpasswd) GetPath() string {
+	return h.path
+}
+
+// Reload loads the htpasswd file again when its modification time or size is changed, and returns true when it's loaded.
+// The current users are kept when the file has invalid lines, which aren't the bcrypt hashes.
+func (h *Htpasswd) Reload() (bool, error) {
+	if len(h.path) == 0 {
+		return false, nil
+	}
+
+	info, err := os.Stat(h.path)
+	if err != nil {
+		return false, err
+	}
+
+	h.RLock()
+	unchanged := info.ModTime().Equal(h.modTime) && info.Size() == h.size
+	h.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	file, err := os.Open(h.path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+	hashes, err := parseHtpasswd(file, h.path)
+	if err != nil {
+		return false, err
+	}
+
+	dummyHash, err := newDummyHash(hashes)
+	if err != nil {
+		return false, err
+	}
+
+	h.Lock()
+	defer h.Unlock()
+	h.hashes = hashes
+	h.dummyHash = dummyHash
+	h.modTime = info.ModTime()
+	h.size = info.Size()
+
+	return true, nil
+}
+
+// SetHash sets the bcrypt hash of the specified user.
+func (h *Htpasswd) SetHash(user string, hash string) error {
+	if len(user) == 0 || strings.Contains(user, htpasswdSep) || !isBcryptHash(hash) {
+		return fmt.Errorf(errorInvalidHtpasswdUser, user)
+	}
+	h.Lock()
+	defer h.Unlock()
+	h.hashes[user] = hash
+	if len(h.dummyHash) == 0 || bcryptCost(h.dummyHash) < bcryptCost(hash) {
+		dummyHash, err := newDummyHash(map[string]string{user: hash})
+		if err != nil {
+			return err
+		}
+		h.dummyHash = dummyHash
+	}
+	return nil
+}
+
+// Len returns the number of the users.
+func (h *Htpasswd) Len() int {
+	h.RLock()
+	defer h.RUnlock()
+	return len(h.hashes)
+}
+
+// Authenticate returns true when the specified password matches the hash of the user.
+func (h *Htpasswd) Authenticate(user string, password string) bool {
+	h.RLock()
+	hash, ok := h.hashes[user]
+	dummyHash := h.dummyHash
+	h.RUnlock()
+	if !ok {
+		if 0 < len(dummyHash) {
+			bcrypt.CompareHashAndPassword([]byte(dummyHash), []byte(password))
+		}
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// AuthenticateRequest returns the user of the HTTP basic authentication, so Htpasswd can be added to graphite.Render as an authenticator.
+func (h *Htpasswd) AuthenticateRequest(r *http.Request) (string, bool) {
+	user, password, ok := r.BasicAuth()
+	if !ok || !h.Authenticate(user, password) {
+		return "", false
+	}
+	return user, true
+}
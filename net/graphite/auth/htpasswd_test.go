@@ -0,0 +1,98 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestHtpasswd(t *testing.T) {
+	aliceHash, err := bcrypt.GenerateFromPassword([]byte("alice-pass"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobHash, err := bcrypt.GenerateFromPassword([]byte("bob-pass"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), ".htpasswd")
+	err = os.WriteFile(path, []byte("# users\nalice:"+string(aliceHash)+"\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := LoadHtpasswd(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Len() != 1 {
+		t.Error(fmt.Errorf("%d != %d", h.Len(), 1))
+	}
+
+	req := httptest.NewRequest("GET", "/render", nil)
+	req.SetBasicAuth("alice", "alice-pass")
+	user, ok := h.AuthenticateRequest(req)
+	if !ok || user != "alice" {
+		t.Error(fmt.Errorf("alice isn't authenticated : %s", user))
+	}
+	if h.Authenticate("alice", "bob-pass") {
+		t.Error(fmt.Errorf("alice is authenticated with invalid password"))
+	}
+	if h.Authenticate("bob", "bob-pass") {
+		t.Error(fmt.Errorf("unknown user is authenticated"))
+	}
+	_, ok = h.AuthenticateRequest(httptest.NewRequest("GET", "/render", nil))
+	if ok {
+		t.Error(fmt.Errorf("request without credentials is authenticated"))
+	}
+
+	// The changed file is reloaded, and the invalid file is ignored.
+	err = os.WriteFile(path, []byte("bob:"+string(bobHash)+"\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(path, time.Now().Add(time.Minute), time.Now().Add(time.Minute))
+	loaded, err := h.Reload()
+	if err != nil || !loaded {
+		t.Error(fmt.Errorf("%s isn't reloaded : %v", path, err))
+	}
+	if h.Authenticate("alice", "alice-pass") || !h.Authenticate("bob", "bob-pass") {
+		t.Error(fmt.Errorf("%s isn't reloaded", path))
+	}
+
+	err = os.WriteFile(path, []byte("carol:{SHA}W6ph5Mm5Pz8GgiULbPgzG37mj9g=\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(path, time.Now().Add(time.Hour), time.Now().Add(time.Hour))
+	_, err = h.Reload()
+	if err == nil {
+		t.Error(fmt.Errorf("non bcrypt hash is loaded"))
+	}
+	if !h.Authenticate("bob", "bob-pass") {
+		t.Error(fmt.Errorf("current users aren't kept"))
+	}
+
+	// The unknown users are compared with the dummy hash of the same cost.
+	err = h.SetHash("carol", "{SHA}W6ph5Mm5Pz8GgiULbPgzG37mj9g=")
+	if err == nil {
+		t.Error(fmt.Errorf("non bcrypt hash is set"))
+	}
+	cost, err := bcrypt.Cost([]byte(h.dummyHash))
+	if err != nil || cost != bcrypt.MinCost {
+		t.Error(fmt.Errorf("%d : %v", cost, err))
+	}
+	if h.Authenticate("carol", "") {
+		t.Error(fmt.Errorf("unknown user is authenticated"))
+	}
+}
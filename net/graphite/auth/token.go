@@ -0,0 +1,79 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const (
+	httpHeaderAuthorization = "Authorization"
+	bearerScheme            = "Bearer "
+)
+
+// TokenAuthenticator is an authenticator of the static bearer tokens such as 'Authorization: Bearer <token>'.
+// The tokens are kept as the SHA-256 digests, and each token is bound to a principal name.
+type TokenAuthenticator struct {
+	sync.RWMutex
+	principals map[[sha256.Size]byte]string
+}
+
+// NewTokenAuthenticator returns a new authenticator which has no tokens.
+func NewTokenAuthenticator() *TokenAuthenticator {
+	a := &TokenAuthenticator{
+		RWMutex:    sync.RWMutex{},
+		principals: map[[sha256.Size]byte]string{},
+	}
+	return a
+}
+
+// AddToken adds the specified token of the principal.
+func (a *TokenAuthenticator) AddToken(token string, principal string) error {
+	if len(token) == 0 {
+		return fmt.Errorf(errorInvalidToken, principal)
+	}
+	a.Lock()
+	defer a.Unlock()
+	a.principals[sha256.Sum256([]byte(token))] = principal
+	return nil
+}
+
+// RemoveToken removes the specified token.
+func (a *TokenAuthenticator) RemoveToken(token string) {
+	a.Lock()
+	defer a.Unlock()
+	delete(a.principals, sha256.Sum256([]byte(token)))
+}
+
+// Len returns the number of the tokens.
+func (a *TokenAuthenticator) Len() int {
+	a.RLock()
+	defer a.RUnlock()
+	return len(a.principals)
+}
+
+// Authenticate returns the principal of the specified token.
+func (a *TokenAuthenticator) Authenticate(token string) (string, bool) {
+	if len(token) == 0 {
+		return "", false
+	}
+	a.RLock()
+	defer a.RUnlock()
+	principal, ok := a.principals[sha256.Sum256([]byte(token))]
+	return principal, ok
+}
+
+// AuthenticateRequest returns the principal of the bearer token, so TokenAuthenticator can be added to graphite.Render as an authenticator.
+func (a *TokenAuthenticator) AuthenticateRequest(r *http.Request) (string, bool) {
+	header := r.Header.Get(httpHeaderAuthorization)
+	if len(header) < len(bearerScheme) || !strings.EqualFold(header[:len(bearerScheme)], bearerScheme) {
+		return "", false
+	}
+	return a.Authenticate(strings.TrimSpace(header[len(bearerScheme):]))
+}
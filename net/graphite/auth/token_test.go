@@ -0,0 +1,48 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTokenAuthenticator(t *testing.T) {
+	a := NewTokenAuthenticator()
+	err := a.AddToken("team-x-token", "team-x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = a.AddToken("", "team-y")
+	if err == nil {
+		t.Error(fmt.Errorf("empty token is added"))
+	}
+
+	testCases := []struct {
+		header    string
+		principal string
+		ok        bool
+	}{
+		{"Bearer team-x-token", "team-x", true},
+		{"bearer team-x-token", "team-x", true},
+		{"Bearer other-token", "", false},
+		{"Basic dGVhbS14OnRva2Vu", "", false},
+		{"", "", false},
+	}
+	for _, tc := range testCases {
+		req := httptest.NewRequest("GET", "/render", nil)
+		req.Header.Set("Authorization", tc.header)
+		principal, ok := a.AuthenticateRequest(req)
+		if principal != tc.principal || ok != tc.ok {
+			t.Error(fmt.Errorf("%s : %s %t != %s %t", tc.header, principal, ok, tc.principal, tc.ok))
+		}
+	}
+
+	a.RemoveToken("team-x-token")
+	if a.Len() != 0 {
+		t.Error(fmt.Errorf("%d != %d", a.Len(), 0))
+	}
+}
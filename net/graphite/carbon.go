@@ -5,10 +5,15 @@
 package graphite
 
 import (
+	"bytes"
+	"context"
+	"crypto/tls"
 	"errors"
+	"fmt"
 	"io"
 	"net"
 	"strconv"
+	"sync/atomic"
 	"time"
 )
 
@@ -31,7 +36,13 @@ type Carbon struct {
 	port                  int
 	connectionWaitTimeout time.Duration
 	carbonListener        CarbonListener
+	carbonProcessors      []CarbonProcessor
+	metricIndex           *MetricIndex
+	tlsConfig             *TLSConfig
+	tlsServerConfig       atomic.Pointer[tlsServerConfig]
+	tenancy               *Tenancy
 	tcpListener           net.Listener
+	tenantListeners       []net.Listener
 }
 
 // NewCarbon returns a new Carbon.
@@ -41,7 +52,13 @@ func NewCarbon() *Carbon {
 		port:                  DefaultCarbonPort,
 		connectionWaitTimeout: DefaultCarbonConnectionWaitTimeout,
 		carbonListener:        nil,
+		carbonProcessors:      []CarbonProcessor{},
+		metricIndex:           nil,
+		tlsConfig:             nil,
+		tlsServerConfig:       atomic.Pointer[tlsServerConfig]{},
+		tenancy:               nil,
 		tcpListener:           nil,
+		tenantListeners:       []net.Listener{},
 	}
 	return carbon
 }
@@ -81,21 +98,122 @@ func (carbon *Carbon) SetCarbonListener(listener CarbonListener) {
 	carbon.carbonListener = listener
 }
 
+// AddCarbonProcessor appends a processor to the ingest path. The processors run in the added order before the index and the listener are updated.
+func (carbon *Carbon) AddCarbonProcessor(processor CarbonProcessor) {
+	carbon.carbonProcessors = append(carbon.carbonProcessors, processor)
+}
+
+// SetCarbonProcessors sets the processors of the ingest path.
+func (carbon *Carbon) SetCarbonProcessors(processors []CarbonProcessor) {
+	carbon.carbonProcessors = processors
+}
+
+// GetCarbonProcessors returns the processors of the ingest path.
+func (carbon *Carbon) GetCarbonProcessors() []CarbonProcessor {
+	return carbon.carbonProcessors
+}
+
+// SetMetricIndex sets an index which is updated with the names of all ingested metrics.
+func (carbon *Carbon) SetMetricIndex(idx *MetricIndex) {
+	carbon.metricIndex = idx
+}
+
+// GetMetricIndex returns the index of the ingested metrics.
+func (carbon *Carbon) GetMetricIndex() *MetricIndex {
+	return carbon.metricIndex
+}
+
+// SetTLSConfig sets the TLS configuration of the listener. The nil configuration disables TLS.
+func (carbon *Carbon) SetTLSConfig(conf *TLSConfig) {
+	carbon.tlsConfig = conf
+}
+
+// GetTLSConfig returns the TLS configuration of the listener.
+func (carbon *Carbon) GetTLSConfig() *TLSConfig {
+	return carbon.tlsConfig
+}
+
+// SetTenancy sets the tenancy of the connections, and Carbon also listens on the ports of the tenants. The nil tenancy disables it.
+func (carbon *Carbon) SetTenancy(tenancy *Tenancy) {
+	carbon.tenancy = tenancy
+}
+
+// GetTenancy returns the tenancy of the connections.
+func (carbon *Carbon) GetTenancy() *Tenancy {
+	return carbon.tenancy
+}
+
 // FeedPlainTextString returns a metrics of the specified text.
 func (carbon *Carbon) FeedPlainTextString(reqString string) ([]*Metrics, error) {
 	ms, err := NewMetricsWithPlainText(reqString)
 	if err != nil {
 		return []*Metrics{}, err
 	}
+	return carbon.FeedMetrics(ms)
+}
+
+// FeedMetrics passes the specified metrics through the ingest path, and returns the processed metrics.
+// FeedMetrics is used by the other protocols such as StatsD to share the processors, the index and the listener with Carbon.
+func (carbon *Carbon) FeedMetrics(ms []*Metrics) ([]*Metrics, error) {
+	return carbon.FeedTenantMetrics("", ms)
+}
+
+// FeedTenantPlainTextBytes returns a metrics of the specified bytes of the tenant, whose names are mapped by the tenancy.
+func (carbon *Carbon) FeedTenantPlainTextBytes(tenant string, reqBytes []byte) ([]*Metrics, error) {
+	return carbon.feedTenantPlainTextBytes(context.Background(), tenant, reqBytes)
+}
+
+// feedTenantPlainTextBytes returns a metrics of the specified bytes of the tenant, and the context is passed to the context-aware listener.
+func (carbon *Carbon) feedTenantPlainTextBytes(ctx context.Context, tenant string, reqBytes []byte) ([]*Metrics, error) {
+	ms, err := NewMetricsWithPlainText(string(reqBytes))
+	if err != nil {
+		return []*Metrics{}, err
+	}
+	if carbon.tenancy != nil && 0 < len(tenant) {
+		ms = carbon.tenancy.TenantMetrics(tenant, ms)
+	}
+	return carbon.feedTenantMetrics(ctx, tenant, ms)
+}
+
+// FeedTenantMetrics passes the specified metrics of the tenant through the ingest path, and returns the processed metrics.
+// The names must be mapped by the tenancy, and the tenant-aware listener receives the tenant.
+// The metrics of the isolated tenants aren't inserted into the shared index, and they are rejected when the listener isn't tenant-aware.
+func (carbon *Carbon) FeedTenantMetrics(tenant string, ms []*Metrics) ([]*Metrics, error) {
+	return carbon.feedTenantMetrics(context.Background(), tenant, ms)
+}
+
+// feedTenantMetrics passes the specified metrics of the tenant through the ingest path, and the context is passed to the context-aware listener.
+func (carbon *Carbon) feedTenantMetrics(ctx context.Context, tenant string, ms []*Metrics) ([]*Metrics, error) {
+	isolated := carbon.tenancy != nil && carbon.tenancy.GetMode() == TenantIsolate && 0 < len(tenant)
+	if isolated && carbon.carbonListener != nil {
+		if !isTenantCarbonListener(carbon.carbonListener) {
+			return []*Metrics{}, fmt.Errorf(errorTenantListenerRequired, tenant)
+		}
+	}
+
+	for _, processor := range carbon.carbonProcessors {
+		if len(ms) == 0 {
+			break
+		}
+		ms = processor.ProcessMetrics(ms)
+	}
 	if len(ms) == 0 {
 		return []*Metrics{}, nil
 	}
+	if carbon.metricIndex != nil && !isolated {
+		carbon.metricIndex.InsertMetrics(ms)
+	}
 	if carbon.carbonListener != nil {
-		carbon.carbonListener.InsertMetricsRequestReceived(ms, err)
+		insertListenerMetrics(ctx, carbon.carbonListener, tenant, ms)
 	}
 	return ms, nil
 }
 
+// GetFeedListener returns a listener which feeds the metrics into the ingest path, so that Carbon can be set as a CarbonListener of the other protocols.
+func (carbon *Carbon) GetFeedListener() CarbonListener {
+	return &carbonIngestListener{carbon}
+}
+
 // FeedPlainTextBytes returns a metrics of the specified bytes.
 func (carbon *Carbon) FeedPlainTextBytes(reqBytes []byte) ([]*Metrics, error) {
 	return carbon.FeedPlainTextString(string(reqBytes))
@@ -113,8 +231,26 @@ func (carbon *Carbon) Start() error {
 		return err
 	}
 
-	go carbon.serve()
+	for _, l := range carbon.tenantListeners {
+		go carbon.serve(l)
+	}
+	go carbon.serve(carbon.tcpListener)
+
+	return nil
+}
 
+// ReloadTLS reloads the certificate files of the TLS configuration for the new connections without closing the listeners.
+// The current certificates are kept when the new files are invalid, and the listeners which are opened without TLS aren't changed until restarted.
+func (carbon *Carbon) ReloadTLS() error {
+	serverConf := carbon.tlsServerConfig.Load()
+	if serverConf == nil || !carbon.tlsConfig.IsEnabled() {
+		return nil
+	}
+	tlsConf, err := carbon.tlsConfig.ServerConfig()
+	if err != nil {
+		return err
+	}
+	serverConf.Store(tlsConf)
 	return nil
 }
 
@@ -130,12 +266,47 @@ func (carbon *Carbon) Stop() error {
 
 // open opens a socket for the Carbon server.
 func (carbon *Carbon) open() error {
-	var err error
-	addr := net.JoinHostPort(carbon.addr, strconv.Itoa(carbon.port))
-	carbon.tcpListener, err = net.Listen("tcp", addr)
+	var tlsConf *tls.Config
+	carbon.tlsServerConfig.Store(nil)
+	if carbon.tlsConfig.IsEnabled() {
+		serverConf, err := carbon.tlsConfig.ServerConfig()
+		if err != nil {
+			return err
+		}
+		tlsServerConf := newTLSServerConfig(serverConf)
+		carbon.tlsServerConfig.Store(tlsServerConf)
+		tlsConf = tlsServerConf.ListenerConfig()
+	}
+
+	listen := func(port int) (net.Listener, error) {
+		addr := net.JoinHostPort(carbon.addr, strconv.Itoa(port))
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			return nil, err
+		}
+		if tlsConf != nil {
+			l = tls.NewListener(l, tlsConf)
+		}
+		return l, nil
+	}
+
+	l, err := listen(carbon.port)
 	if err != nil {
 		return err
 	}
+	carbon.tcpListener = l
+
+	if carbon.tenancy != nil {
+		for port := range carbon.tenancy.GetPortTenants() {
+			l, err := listen(port)
+			if err != nil {
+				carbon.close()
+				return err
+			}
+			carbon.tenantListeners = append(carbon.tenantListeners, l)
+		}
+	}
+
 	return nil
 }
 
@@ -150,14 +321,22 @@ func (carbon *Carbon) close() error {
 
 	carbon.tcpListener = nil
 
+	for _, l := range carbon.tenantListeners {
+		l.Close()
+	}
+	carbon.tenantListeners = []net.Listener{}
+
 	return nil
 }
 
-// serve handles client requests.
-func (carbon *Carbon) serve() error {
-	defer carbon.close()
+// serve handles client requests of the specified listener, and closes only the listener not to close the listeners of the restarted server.
+func (carbon *Carbon) serve(l net.Listener) error {
+	defer l.Close()
+	return carbon.accept(l)
+}
 
-	l := carbon.tcpListener
+// accept handles client requests of the specified listener.
+func (carbon *Carbon) accept(l net.Listener) error {
 	for l != nil {
 		conn, err := l.Accept()
 		if err != nil {
@@ -173,6 +352,15 @@ func (carbon *Carbon) serve() error {
 func (carbon *Carbon) receive(conn net.Conn) error {
 	defer conn.Close()
 
+	// The context-aware listener can stop the pending work of the connection after it is closed.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tenant, err := carbon.connTenant(conn)
+	if err != nil {
+		return err
+	}
+
 	reqBytes := make([]byte, 0)
 	readBytes := make([]byte, 1024)
 	for {
@@ -181,11 +369,18 @@ func (carbon *Carbon) receive(conn net.Conn) error {
 		n, err := conn.Read(readBytes)
 		if err == nil {
 			reqBytes = append(reqBytes, readBytes[:n]...)
+			// Feed the complete lines as soon as possible for the persistent connections such as relays.
+			lastSep := bytes.LastIndex(reqBytes, []byte(carbonPlainTextLineSep))
+			if 0 <= lastSep {
+				carbon.feedTenantPlainTextBytes(ctx, tenant, reqBytes[:lastSep+1])
+				reqBytes = append(reqBytes[:0], reqBytes[lastSep+1:]...)
+			}
 			continue
 		}
 
 		if 0 < len(reqBytes) {
-			carbon.FeedPlainTextBytes(reqBytes)
+			carbon.feedTenantPlainTextBytes(ctx, tenant, reqBytes)
+			reqBytes = reqBytes[:0]
 		}
 
 		if errors.Is(err, io.EOF) {
@@ -203,3 +398,28 @@ func (carbon *Carbon) receive(conn net.Conn) error {
 
 	return nil
 }
+
+// connTenant returns the tenant of the specified connection, and the empty tenant without the tenancy.
+func (carbon *Carbon) connTenant(conn net.Conn) (string, error) {
+	if carbon.tenancy == nil {
+		return "", nil
+	}
+
+	// The client certificates are available after the handshake.
+	tlsConn, ok := conn.(*tls.Conn)
+	if ok {
+		tlsConn.SetDeadline(time.Now().Add(carbon.connectionWaitTimeout))
+		err := tlsConn.Handshake()
+		if err != nil {
+			return "", err
+		}
+		tlsConn.SetDeadline(time.Time{})
+	}
+
+	tenant, ok := carbon.tenancy.ConnTenant(conn)
+	if !ok {
+		return "", fmt.Errorf(errorUnknownTenant, conn.RemoteAddr())
+	}
+
+	return tenant, nil
+}
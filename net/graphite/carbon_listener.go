@@ -4,6 +4,8 @@
 
 package graphite
 
+import "context"
+
 // PlainTextRequestListener represents a listener for plain text protocol of Carbon.
 // See : Feeding In Your Data (http://graphite.readthedocs.io/en/latest/feeding-carbon.html)
 type PlainTextRequestListener interface {
@@ -14,3 +16,70 @@ type PlainTextRequestListener interface {
 type CarbonListener interface {
 	PlainTextRequestListener
 }
+
+// CarbonProcessor represents a stage of the Carbon ingest path, which rewrites or drops the metrics before the listener is called.
+type CarbonProcessor interface {
+	ProcessMetrics([]*Metrics) []*Metrics
+}
+
+// ContextCarbonListener represents a listener for Carbon protocol which also receives the contexts of the requests.
+// The context-aware method is called instead of the other methods, and the tenant of the metrics is available using TenantFromContext().
+type ContextCarbonListener interface {
+	CarbonListener
+	InsertMetricsRequestReceivedContext(ctx context.Context, ms []*Metrics, err error)
+}
+
+// carbonIngestListener is a listener which feeds the metrics into the ingest path of Carbon.
+// Carbon doesn't implement CarbonListener itself not to make the types which embed it such as Server listeners of their own.
+type carbonIngestListener struct {
+	carbon *Carbon
+}
+
+// InsertMetricsRequestReceived feeds the specified metrics into the ingest path.
+func (l *carbonIngestListener) InsertMetricsRequestReceived(ms []*Metrics, err error) {
+	if err != nil {
+		return
+	}
+	l.carbon.FeedMetrics(ms)
+}
+
+// InsertTenantMetricsRequestReceived feeds the specified metrics of the tenant into the ingest path.
+func (l *carbonIngestListener) InsertTenantMetricsRequestReceived(tenant string, ms []*Metrics, err error) {
+	if err != nil {
+		return
+	}
+	l.carbon.FeedTenantMetrics(tenant, ms)
+}
+
+// isTenantCarbonListener returns true when the specified listener receives the tenants of the metrics.
+func isTenantCarbonListener(listener CarbonListener) bool {
+	switch listener.(type) {
+	case ContextCarbonListener, TenantCarbonListener:
+		return true
+	}
+	return false
+}
+
+// insertListenerMetrics calls the most specific method of the specified listener for the metrics of the tenant.
+// The ingest path of Carbon is fed directly with the context.
+func insertListenerMetrics(ctx context.Context, listener CarbonListener, tenant string, ms []*Metrics) {
+	ingestListener, ok := listener.(*carbonIngestListener)
+	if ok {
+		ingestListener.carbon.feedTenantMetrics(ctx, tenant, ms)
+		return
+	}
+	ctxListener, ok := listener.(ContextCarbonListener)
+	if ok {
+		if 0 < len(tenant) {
+			ctx = WithTenantContext(ctx, tenant)
+		}
+		ctxListener.InsertMetricsRequestReceivedContext(ctx, ms, nil)
+		return
+	}
+	tenantListener, ok := listener.(TenantCarbonListener)
+	if ok && 0 < len(tenant) {
+		tenantListener.InsertTenantMetricsRequestReceived(tenant, ms, nil)
+		return
+	}
+	listener.InsertMetricsRequestReceived(ms, nil)
+}
@@ -187,3 +187,54 @@ func TestCarbonMultipleFeedWithKeepConnection(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestCarbonFeedMetrics(t *testing.T) {
+	carbon := NewTestCarbon()
+
+	ms := []*Metrics{}
+	for n := range 3 {
+		m := NewMetrics()
+		m.SetName(fmt.Sprintf("path%d", n))
+		ms = append(ms, m)
+	}
+
+	listener := carbon.GetFeedListener()
+	listener.InsertMetricsRequestReceived(ms, nil)
+
+	if carbon.MetricsCount != len(ms) {
+		t.Error(fmt.Errorf("%d != %d", carbon.MetricsCount, len(ms)))
+	}
+
+	// The servers which embed Carbon aren't listeners of their own.
+	if _, ok := any(NewServer()).(CarbonListener); ok {
+		t.Error(fmt.Errorf("server is a carbon listener"))
+	}
+}
+
+func TestCarbonRestart(t *testing.T) {
+	carbon := NewTestCarbon()
+	for range 2 {
+		err := carbon.Start()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer carbon.Stop()
+
+	// The listeners of the restarted server aren't closed by the previous serving.
+	time.Sleep(time.Millisecond * 100)
+	cli := NewClient()
+	err := cli.FeedString("path 1 1500000000\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 50 {
+		if carbon.MetricsCount == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	if carbon.MetricsCount != 1 {
+		t.Error(fmt.Errorf("%d != %d", carbon.MetricsCount, 1))
+	}
+}
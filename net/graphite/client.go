@@ -6,6 +6,7 @@ package graphite
 
 import (
 	"bufio"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -13,6 +14,7 @@ import (
 	"net"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -35,6 +37,7 @@ type Client struct {
 	CarbonPort int
 	RenderPort int
 	Timeout    time.Duration
+	TLSConfig  *tls.Config
 	conn       net.Conn
 }
 
@@ -45,6 +48,7 @@ func NewClient() *Client {
 		CarbonPort: DefaultCarbonPort,
 		RenderPort: DefaultRenderPort,
 		Timeout:    (time.Second * DefaultTimeoutSecond),
+		TLSConfig:  nil,
 	}
 
 	return client
@@ -90,10 +94,23 @@ func (client *Client) GetTimeout() time.Duration {
 	return client.Timeout
 }
 
+// SetTLSConfig sets a TLS configuration to connect to Carbon and Render. The nil configuration disables TLS.
+func (client *Client) SetTLSConfig(conf *tls.Config) {
+	client.TLSConfig = conf
+}
+
+// GetTLSConfig returns the TLS configuration to connect to Carbon and Render.
+func (client *Client) GetTLSConfig() *tls.Config {
+	return client.TLSConfig
+}
+
 // Open connects to the specified host.
 func (client *Client) Open() (net.Conn, error) {
 	addr := net.JoinHostPort(client.Host, strconv.Itoa(client.CarbonPort))
-	dialer := net.Dialer{Timeout: client.Timeout}
+	dialer := &net.Dialer{Timeout: client.Timeout}
+	if client.TLSConfig != nil {
+		return tls.DialWithDialer(dialer, "tcp", addr, client.TLSConfig)
+	}
 	conn, err := dialer.Dial("tcp", addr)
 	if err != nil {
 		return nil, err
@@ -160,10 +177,7 @@ func (client *Client) FindMetrics(q *Query) ([]*Metrics, error) {
 		return nil, err
 	}
 
-	httpClient := http.Client{
-		Timeout: client.Timeout,
-	}
-	resp, err := httpClient.Get(url)
+	resp, err := client.httpGet(url)
 	if err != nil {
 		return nil, err
 	}
@@ -195,6 +209,18 @@ func (client *Client) FindMetrics(q *Query) ([]*Metrics, error) {
 	return ms, nil
 }
 
+// httpGet requests the specified URL of Render with HTTPS when the TLS configuration is set.
+func (client *Client) httpGet(url string) (*http.Response, error) {
+	httpClient := http.Client{
+		Timeout: client.Timeout,
+	}
+	if client.TLSConfig != nil {
+		httpClient.Transport = &http.Transport{TLSClientConfig: client.TLSConfig}
+		url = "https://" + strings.TrimPrefix(url, "http://")
+	}
+	return httpClient.Get(url)
+}
+
 // GetAllMetrics returns all metrics.
 // Graphite - The Metrics API
 // https://graphite-api.readthedocs.io/en/latest/api.html#the-metrics-api
@@ -202,10 +228,7 @@ func (client *Client) GetAllMetrics() ([]*Metrics, error) {
 	hostPort := net.JoinHostPort(client.Host, strconv.Itoa(client.RenderPort))
 	url := fmt.Sprintf("http://%s%s", hostPort, renderDefaultIndexRequestPath)
 
-	httpClient := http.Client{
-		Timeout: client.Timeout,
-	}
-	resp, err := httpClient.Get(url)
+	resp, err := client.httpGet(url)
 	if err != nil {
 		return nil, err
 	}
@@ -249,10 +272,7 @@ func (client *Client) QueryRender(q *Query) ([]*Metrics, error) {
 		return nil, err
 	}
 
-	httpClient := http.Client{
-		Timeout: client.Timeout,
-	}
-	resp, err := httpClient.Get(url)
+	resp, err := client.httpGet(url)
 	if err != nil {
 		return nil, err
 	}
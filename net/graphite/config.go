@@ -16,6 +16,10 @@ const (
 	DefaultConnectionTimeout = time.Second * 60
 	// DefaultConnectionWaitTimeout is a default wait timeout for Render and Carbon server.
 	DefaultConnectionWaitTimeout = time.Second * 10
+	// DefaultRequestTimeout is a default timeout of the listener calls for Render server. The zero timeout disables it.
+	DefaultRequestTimeout = time.Duration(0)
+	// DefaultMaxIngestSize is a default maximum byte size of the posted metrics for Render server, which is checked after the decompression.
+	DefaultMaxIngestSize = int64(32 << 20)
 )
 
 // Config represents a cofiguration for extended specifications.
@@ -28,6 +32,10 @@ type Config struct {
 	RenderPort                  int
 	ConnectionTimeout           time.Duration
 	ConnectionWaitTimeout       time.Duration
+	RequestTimeout              time.Duration
+	MaxSeries                   int
+	MaxIngestSize               int64
+	TLSConfig                   *TLSConfig
 }
 
 // NewDefaultConfig returns a default configuration.
@@ -41,6 +49,10 @@ func NewDefaultConfig() *Config {
 		BindingRetryCount:           DefaultBindingRetryCount,
 		ConnectionTimeout:           DefaultConnectionTimeout,
 		ConnectionWaitTimeout:       DefaultConnectionWaitTimeout,
+		RequestTimeout:              DefaultRequestTimeout,
+		MaxSeries:                   0,
+		MaxIngestSize:               DefaultMaxIngestSize,
+		TLSConfig:                   nil,
 	}
 	return conf
 }
@@ -52,6 +64,10 @@ func (conf *Config) SetConfig(newConfig *Config) {
 	conf.CarbonPort = newConfig.CarbonPort
 	conf.RenderPort = newConfig.RenderPort
 	conf.BindingRetryCount = newConfig.BindingRetryCount
+	conf.RequestTimeout = newConfig.RequestTimeout
+	conf.MaxSeries = newConfig.MaxSeries
+	conf.MaxIngestSize = newConfig.MaxIngestSize
+	conf.TLSConfig = newConfig.TLSConfig
 }
 
 // SetAddress sets a configuration address.
@@ -134,6 +150,46 @@ func (conf *Config) GetConnectionWaitTimeout() time.Duration {
 	return conf.ConnectionWaitTimeout
 }
 
+// SetRequestTimeout sets the timeout of the listener calls for each request of the render server.
+func (conf *Config) SetRequestTimeout(d time.Duration) {
+	conf.RequestTimeout = d
+}
+
+// GetRequestTimeout returns the timeout of the listener calls for each request of the render server.
+func (conf *Config) GetRequestTimeout() time.Duration {
+	return conf.RequestTimeout
+}
+
+// SetMaxSeries sets the maximum number of the series of each request of the render server. The zero number disables the limit.
+func (conf *Config) SetMaxSeries(n int) {
+	conf.MaxSeries = n
+}
+
+// GetMaxSeries returns the maximum number of the series of each request of the render server.
+func (conf *Config) GetMaxSeries() int {
+	return conf.MaxSeries
+}
+
+// SetMaxIngestSize sets the maximum byte size of the posted metrics of the render server. The zero size disables the limit.
+func (conf *Config) SetMaxIngestSize(n int64) {
+	conf.MaxIngestSize = n
+}
+
+// GetMaxIngestSize returns the maximum byte size of the posted metrics of the render server.
+func (conf *Config) GetMaxIngestSize() int64 {
+	return conf.MaxIngestSize
+}
+
+// SetTLSConfig sets the TLS configuration for the carbon and the render server. The nil configuration disables TLS.
+func (conf *Config) SetTLSConfig(tlsConf *TLSConfig) {
+	conf.TLSConfig = tlsConf
+}
+
+// GetTLSConfig returns the TLS configuration of the carbon and the render server.
+func (conf *Config) GetTLSConfig() *TLSConfig {
+	return conf.TLSConfig
+}
+
 // Equals returns true whether the specified other class is same, otherwise false.
 func (conf *Config) Equals(otherConf *Config) bool {
 	return reflect.DeepEqual(conf, otherConf)
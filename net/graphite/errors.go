@@ -10,4 +10,31 @@ const (
 	errorQueryInvalidTimeFormat = "invalid time format : %s"
 
 	errorInvalidHTTPRequestListener = "invalid HTTPRequestListener : %s %v"
+
+	errorInvalidTagQuery      = "invalid tag query : %s"
+	errorInvalidTagExpression = "invalid tag expression : %s"
+
+	errorIngestMissingName  = "missing metric name : %s"
+	errorIngestMissingValue = "missing metric value : %s"
+	errorIngestTooLarge     = "posted metrics are larger than %d bytes"
+
+	errorInvalidTLSVersion     = "invalid TLS version : %s"
+	errorInvalidTLSCipherSuite = "invalid TLS cipher suite : %s"
+	errorInvalidTLSCertificate = "invalid TLS certificate : %s"
+	errorReloadTLS             = "failed to reload TLS certificates : %s"
+
+	errorInvalidTenant           = "invalid tenant : %s"
+	errorUnknownTenant           = "unknown tenant : %v"
+	errorUnauthorizedRequest     = "no valid credentials : %s"
+	errorForbiddenListener       = "listener isn't authorized with the path rules or the tenancy : %s"
+	errorForbiddenPath           = "path isn't allowed to be written : %s"
+	errorUnsupportedTenantTarget = "unsupported target of tenant : %s"
+	errorTenantListenerRequired  = "tenant-aware listener is required : %s"
+
+	errorMissingParameter       = "missing parameter : %s"
+	errorUnsupportedFormat      = "unsupported format : %s"
+	errorUnknownFunction        = "unknown function : %s"
+	errorMetricsNotFound        = "metrics not found : %s"
+	errorTooManySeries          = "too many series : %d > %d"
+	errorRenderListenerNotFound = "render listener is not set : %s"
 )
@@ -2,13 +2,8 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-// Package metric provides query interfaces for metric store.
-package metric
+package filter
 
-import (
-	"testing"
+const (
+	errorInvalidPattern = "invalid pattern (%s:%d) : %s"
 )
-
-func TestNewMetrics(t *testing.T) {
-	NewMetrics()
-}
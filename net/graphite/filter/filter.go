@@ -0,0 +1,236 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package filter provides an allowlist and blocklist filter of the metric names in the Carbon ingest path like USE_WHITELIST of carbon.
+package filter
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cybergarage/go-graphite/net/graphite"
+)
+
+// DropReason represents why a metric is dropped.
+type DropReason string
+
+const (
+	// DropReasonBlocked is the reason of the metrics which match the blocklist.
+	DropReasonBlocked DropReason = "blocked"
+	// DropReasonNotAllowed is the reason of the metrics which don't match the non-empty allowlist.
+	DropReasonNotAllowed DropReason = "not_allowed"
+)
+
+const (
+	// DefaultReloadInterval is the default interval to check the pattern files.
+	DefaultReloadInterval = time.Minute
+	// DefaultSampleLogInterval is the default minimum interval of the sample logs of each drop reason.
+	DefaultSampleLogInterval = time.Second * 10
+)
+
+// LogFunc represents a function to output the logs such as log.Printf.
+type LogFunc func(format string, args ...any)
+
+// Stats represents the statistics of a filter.
+type Stats struct {
+	Passed  int64
+	Dropped map[DropReason]int64
+}
+
+type dropCounter struct {
+	count      atomic.Int64
+	lastLogged atomic.Int64
+	lastCount  atomic.Int64
+}
+
+// Filter is a graphite.CarbonProcessor which drops the metrics matched with the blocklist or not matched with the allowlist.
+// The empty or missing allowlist allows all metrics like carbon.
+type Filter struct {
+	sync.Mutex
+	allowlist      *PatternList
+	blocklist      *PatternList
+	passed         atomic.Int64
+	drops          map[DropReason]*dropCounter
+	logFunc        LogFunc
+	logInterval    time.Duration
+	reloadInterval time.Duration
+	done           chan struct{}
+	wg             sync.WaitGroup
+}
+
+// NewFilter returns a new filter with the specified lists, and the nil list is ignored.
+func NewFilter(allowlist *PatternList, blocklist *PatternList) *Filter {
+	return &Filter{
+		Mutex:     sync.Mutex{},
+		allowlist: allowlist,
+		blocklist: blocklist,
+		passed:    atomic.Int64{},
+		drops: map[DropReason]*dropCounter{
+			DropReasonBlocked:    {},
+			DropReasonNotAllowed: {},
+		},
+		logFunc:        log.Printf,
+		logInterval:    DefaultSampleLogInterval,
+		reloadInterval: DefaultReloadInterval,
+		done:           nil,
+		wg:             sync.WaitGroup{},
+	}
+}
+
+// SetLogFunc sets the function to output the sample logs of the dropped metrics, and nil disables them.
+func (filter *Filter) SetLogFunc(fn LogFunc) {
+	filter.Lock()
+	defer filter.Unlock()
+	filter.logFunc = fn
+}
+
+// SetSampleLogInterval sets the minimum interval of the sample logs of each drop reason.
+func (filter *Filter) SetSampleLogInterval(d time.Duration) {
+	filter.Lock()
+	defer filter.Unlock()
+	filter.logInterval = d
+}
+
+// SetReloadInterval sets the interval to check the pattern files. Set it before Start.
+func (filter *Filter) SetReloadInterval(d time.Duration) {
+	filter.Lock()
+	defer filter.Unlock()
+	filter.reloadInterval = d
+}
+
+// Reload loads the pattern files again when they are changed.
+func (filter *Filter) Reload() error {
+	for _, list := range []*PatternList{filter.allowlist, filter.blocklist} {
+		if list == nil {
+			continue
+		}
+		_, err := list.Reload()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Start starts checking the pattern files periodically.
+func (filter *Filter) Start() error {
+	err := filter.Stop()
+	if err != nil {
+		return err
+	}
+
+	filter.Lock()
+	defer filter.Unlock()
+
+	filter.done = make(chan struct{})
+	filter.wg.Add(1)
+	go filter.run(filter.done, filter.reloadInterval)
+
+	return nil
+}
+
+// Stop stops checking the pattern files.
+func (filter *Filter) Stop() error {
+	filter.Lock()
+	done := filter.done
+	filter.done = nil
+	filter.Unlock()
+
+	if done != nil {
+		close(done)
+		filter.wg.Wait()
+	}
+
+	return nil
+}
+
+func (filter *Filter) run(done chan struct{}, interval time.Duration) {
+	defer filter.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			err := filter.Reload()
+			if err != nil {
+				filter.logf("filter couldn't reload the patterns (%s)", err.Error())
+			}
+		}
+	}
+}
+
+func (filter *Filter) logf(format string, args ...any) {
+	filter.Lock()
+	fn := filter.logFunc
+	filter.Unlock()
+	if fn != nil {
+		fn(format, args...)
+	}
+}
+
+// Stats returns the current statistics of the filter.
+func (filter *Filter) Stats() *Stats {
+	stats := &Stats{
+		Passed:  filter.passed.Load(),
+		Dropped: map[DropReason]int64{},
+	}
+	for reason, counter := range filter.drops {
+		stats.Dropped[reason] = counter.count.Load()
+	}
+	return stats
+}
+
+// Check returns true when the specified name passes the filter, otherwise false with the drop reason.
+func (filter *Filter) Check(name string) (bool, DropReason) {
+	if filter.blocklist != nil && filter.blocklist.Match(name) {
+		return false, DropReasonBlocked
+	}
+	if filter.allowlist != nil && 0 < filter.allowlist.Len() && !filter.allowlist.Match(name) {
+		return false, DropReasonNotAllowed
+	}
+	return true, ""
+}
+
+// drop counts the dropped metric, and logs it as a sample when the last sample of the reason is older than the log interval.
+func (filter *Filter) drop(name string, reason DropReason) {
+	counter := filter.drops[reason]
+	count := counter.count.Add(1)
+
+	filter.Lock()
+	interval := filter.logInterval
+	filter.Unlock()
+
+	now := time.Now().UnixNano()
+	last := counter.lastLogged.Load()
+	if last != 0 && now < last+int64(interval) {
+		return
+	}
+	if !counter.lastLogged.CompareAndSwap(last, now) {
+		return
+	}
+	since := count - counter.lastCount.Swap(count)
+	filter.logf("filter dropped %d metrics (%s) such as %s", since, reason, name)
+}
+
+// ProcessMetrics returns the metrics which pass the filter.
+func (filter *Filter) ProcessMetrics(ms []*graphite.Metrics) []*graphite.Metrics {
+	passed := make([]*graphite.Metrics, 0, len(ms))
+	for _, m := range ms {
+		if m == nil {
+			continue
+		}
+		ok, reason := filter.Check(m.Name)
+		if !ok {
+			filter.drop(m.Name, reason)
+			continue
+		}
+		passed = append(passed, m)
+	}
+	filter.passed.Add(int64(len(passed)))
+	return passed
+}
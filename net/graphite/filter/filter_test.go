@@ -0,0 +1,142 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package filter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cybergarage/go-graphite/net/graphite"
+)
+
+func newTestMetrics(names ...string) []*graphite.Metrics {
+	ms := []*graphite.Metrics{}
+	for _, name := range names {
+		m := graphite.NewMetrics()
+		m.SetName(name)
+		ms = append(ms, m)
+	}
+	return ms
+}
+
+func TestFilter(t *testing.T) {
+	allowlist, err := NewPatternList([]string{"# servers only", `^servers\.`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocklist, err := NewPatternList([]string{`\.debug\.`})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logs := []string{}
+	var logsMutex sync.Mutex
+	filter := NewFilter(allowlist, blocklist)
+	filter.SetSampleLogInterval(time.Hour)
+	filter.SetLogFunc(func(format string, args ...any) {
+		logsMutex.Lock()
+		defer logsMutex.Unlock()
+		logs = append(logs, fmt.Sprintf(format, args...))
+	})
+
+	ms := filter.ProcessMetrics(newTestMetrics(
+		"servers.host1.cpu",
+		"servers.host1.debug.trace",
+		"apps.web.requests",
+		"apps.db.requests",
+		"servers.host2.cpu"))
+	if len(ms) != 2 {
+		t.Fatal(fmt.Errorf("%d != %d", len(ms), 2))
+	}
+
+	stats := filter.Stats()
+	if stats.Passed != 2 {
+		t.Error(fmt.Errorf("%d != %d", stats.Passed, 2))
+	}
+	if stats.Dropped[DropReasonBlocked] != 1 {
+		t.Error(fmt.Errorf("%d != %d", stats.Dropped[DropReasonBlocked], 1))
+	}
+	if stats.Dropped[DropReasonNotAllowed] != 2 {
+		t.Error(fmt.Errorf("%d != %d", stats.Dropped[DropReasonNotAllowed], 2))
+	}
+
+	// Only the first sample of each reason is logged in the interval.
+	if len(logs) != 2 {
+		t.Error(fmt.Errorf("%d != %d : %v", len(logs), 2, logs))
+	}
+}
+
+func TestFilterEmptyAllowlist(t *testing.T) {
+	allowlist, _ := NewPatternList([]string{})
+	filter := NewFilter(allowlist, nil)
+	filter.SetLogFunc(nil)
+	ms := filter.ProcessMetrics(newTestMetrics("a.b.c", "d.e.f"))
+	if len(ms) != 2 {
+		t.Error(fmt.Errorf("%d != %d", len(ms), 2))
+	}
+}
+
+func TestPatternListInvalidPattern(t *testing.T) {
+	_, err := NewPatternList([]string{`servers\.(`})
+	if err == nil {
+		t.Error(fmt.Errorf("invalid pattern is accepted"))
+	}
+}
+
+func TestPatternListReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blacklist.conf")
+
+	list, err := LoadPatternList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list.Len() != 0 {
+		t.Error(fmt.Errorf("%d != %d", list.Len(), 0))
+	}
+
+	filter := NewFilter(nil, list)
+	filter.SetLogFunc(nil)
+
+	err = os.WriteFile(path, []byte("^tmp\\.\n"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = filter.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := filter.Check("tmp.value"); ok {
+		t.Error(fmt.Errorf("tmp.value is passed"))
+	}
+
+	// The current patterns are kept when the changed file has invalid patterns.
+	err = os.WriteFile(path, []byte("^tmp\\.\n^(\n"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = filter.Reload()
+	if err == nil {
+		t.Error(fmt.Errorf("invalid pattern is loaded"))
+	}
+	if list.Len() != 1 {
+		t.Error(fmt.Errorf("%d != %d", list.Len(), 1))
+	}
+
+	err = os.Remove(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed, err := list.Reload()
+	if err != nil || !changed {
+		t.Error(fmt.Errorf("removed file isn't reloaded (%v)", err))
+	}
+	if ok, _ := filter.Check("tmp.value"); !ok {
+		t.Error(fmt.Errorf("tmp.value is dropped"))
+	}
+}
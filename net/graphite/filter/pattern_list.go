@@ -0,0 +1,156 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package filter
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PatternList is a list of the regular expressions of a pattern file such as whitelist.conf and blacklist.conf of carbon,
+// which has a pattern per line and the comment lines starting with '#'.
+type PatternList struct {
+	sync.RWMutex
+	path     string
+	modTime  time.Time
+	size     int64
+	patterns []*regexp.Regexp
+}
+
+// NewPatternList returns a new list of the specified patterns which isn't bound to a file.
+func NewPatternList(patterns []string) (*PatternList, error) {
+	list := &PatternList{
+		RWMutex:  sync.RWMutex{},
+		path:     "",
+		modTime:  time.Time{},
+		size:     0,
+		patterns: []*regexp.Regexp{},
+	}
+	regexes, err := parsePatterns(strings.NewReader(strings.Join(patterns, "\n")), "")
+	if err != nil {
+		return nil, err
+	}
+	list.patterns = regexes
+	return list, nil
+}
+
+// LoadPatternList returns a new list of the specified pattern file.
+// The missing file is loaded as an empty list, and loaded again when it's created.
+func LoadPatternList(path string) (*PatternList, error) {
+	list := &PatternList{
+		RWMutex:  sync.RWMutex{},
+		path:     path,
+		modTime:  time.Time{},
+		size:     0,
+		patterns: []*regexp.Regexp{},
+	}
+	_, err := list.Reload()
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func parsePatterns(reader io.Reader, path string) ([]*regexp.Regexp, error) {
+	regexes := []*regexp.Regexp{}
+	scanner := bufio.NewScanner(reader)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		regex, err := regexp.Compile(line)
+		if err != nil {
+			return nil, fmt.Errorf(errorInvalidPattern, path, lineNo, line)
+		}
+		regexes = append(regexes, regex)
+	}
+	err := scanner.Err()
+	if err != nil {
+		return nil, err
+	}
+	return regexes, nil
+}
+
+// GetPath returns the path of the pattern file.
+func (list *PatternList) GetPath() string {
+	return list.path
+}
+
+// Reload loads the pattern file again when its modification time or size is changed, and returns true when it's loaded.
+// The current patterns are kept when the file has invalid patterns.
+func (list *PatternList) Reload() (bool, error) {
+	if len(list.path) == 0 {
+		return false, nil
+	}
+
+	info, err := os.Stat(list.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		list.Lock()
+		defer list.Unlock()
+		changed := 0 < len(list.patterns) || !list.modTime.IsZero()
+		list.patterns = []*regexp.Regexp{}
+		list.modTime = time.Time{}
+		list.size = 0
+		return changed, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	list.RLock()
+	unchanged := info.ModTime().Equal(list.modTime) && info.Size() == list.size
+	list.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	file, err := os.Open(list.path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+	regexes, err := parsePatterns(file, list.path)
+	if err != nil {
+		return false, err
+	}
+
+	list.Lock()
+	defer list.Unlock()
+	list.patterns = regexes
+	list.modTime = info.ModTime()
+	list.size = info.Size()
+
+	return true, nil
+}
+
+// Len returns the number of the patterns.
+func (list *PatternList) Len() int {
+	list.RLock()
+	defer list.RUnlock()
+	return len(list.patterns)
+}
+
+// Match returns true when any pattern matches the specified name.
+func (list *PatternList) Match(name string) bool {
+	list.RLock()
+	defer list.RUnlock()
+	for _, regex := range list.patterns {
+		if regex.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
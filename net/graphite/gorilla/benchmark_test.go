@@ -0,0 +1,121 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gorilla
+
+import (
+	"fmt"
+	"math/rand"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/cybergarage/go-graphite/net/graphite"
+)
+
+const (
+	benchmarkSeriesCount = 1000
+	benchmarkPointCount  = 720
+	benchmarkInterval    = 10
+)
+
+// benchmarkMetrics returns Carbon like gauges which are reported every 10 seconds and change slowly.
+func benchmarkMetrics() []*graphite.Metrics {
+	r := rand.New(rand.NewSource(1))
+	start := time.Now().Unix() - (benchmarkPointCount * benchmarkInterval)
+	ms := make([]*graphite.Metrics, benchmarkSeriesCount)
+	for n := range ms {
+		m := graphite.NewMetrics()
+		m.SetName(fmt.Sprintf("servers.host%d.cpu.user", n))
+		value := float64(r.Intn(100))
+		for i := range benchmarkPointCount {
+			if r.Intn(4) == 0 {
+				value += float64(r.Intn(3) - 1)
+			}
+			dp := graphite.NewDataPoint()
+			dp.SetTimestamp(time.Unix(start+int64(i*benchmarkInterval), 0))
+			dp.SetValue(value)
+			m.AddDataPoint(dp)
+		}
+		ms[n] = m
+	}
+	return ms
+}
+
+// heapAlloc returns the live heap size as a signed value so that the difference stays negative when the heap shrinks.
+func heapAlloc() int64 {
+	runtime.GC()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return int64(stats.HeapAlloc)
+}
+
+func BenchmarkGorillaStoreIngest(b *testing.B) {
+	ms := benchmarkMetrics()
+	points := benchmarkSeriesCount * benchmarkPointCount
+
+	// The heap growth is reported as B/point like BenchmarkDataPointsIngest, and the encoded payload is reported separately.
+	var store *Store
+	var bytesPerPoint float64
+	b.ResetTimer()
+	b.StopTimer()
+	for range b.N {
+		store = nil
+		before := heapAlloc()
+		b.StartTimer()
+		store = NewStore()
+		store.InsertMetricsRequestReceived(ms, nil)
+		b.StopTimer()
+		bytesPerPoint = float64(heapAlloc()-before) / float64(points)
+	}
+
+	b.ReportMetric(float64(points*b.N)/b.Elapsed().Seconds(), "points/s")
+	b.ReportMetric(bytesPerPoint, "B/point")
+	b.ReportMetric(store.Stats().BytesPerPoint(), "payload-B/point")
+	runtime.KeepAlive(store)
+}
+
+func BenchmarkDataPointsIngest(b *testing.B) {
+	ms := benchmarkMetrics()
+	points := benchmarkSeriesCount * benchmarkPointCount
+
+	var series map[string]graphite.DataPoints
+	var bytesPerPoint float64
+	b.ResetTimer()
+	b.StopTimer()
+	for range b.N {
+		series = nil
+		before := heapAlloc()
+		b.StartTimer()
+		series = map[string]graphite.DataPoints{}
+		for _, m := range ms {
+			dps := series[m.Name]
+			for _, dp := range m.DataPoints {
+				copied := graphite.NewDataPoint()
+				copied.SetTimestamp(dp.GetTimestamp())
+				copied.SetValue(dp.GetValue())
+				dps = append(dps, copied)
+			}
+			series[m.Name] = dps
+		}
+		b.StopTimer()
+		bytesPerPoint = float64(heapAlloc()-before) / float64(points)
+	}
+
+	b.ReportMetric(float64(points*b.N)/b.Elapsed().Seconds(), "points/s")
+	b.ReportMetric(bytesPerPoint, "B/point")
+	runtime.KeepAlive(series)
+}
+
+func BenchmarkGorillaStoreFetch(b *testing.B) {
+	store := NewStore()
+	store.InsertMetricsRequestReceived(benchmarkMetrics(), nil)
+	now := time.Now().Unix()
+
+	b.ResetTimer()
+	for n := range b.N {
+		s, _ := store.GetSeries(fmt.Sprintf("servers.host%d.cpu.user", n%benchmarkSeriesCount))
+		s.Fetch(now-(benchmarkPointCount*benchmarkInterval), now)
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gorilla
+
+import (
+	"errors"
+)
+
+var errBitStreamEOF = errors.New("end of bit stream")
+
+// bitWriter appends bits to a byte slice from the most significant bit.
+type bitWriter struct {
+	buf   []byte
+	nbits uint64
+}
+
+func newBitWriter(size int) *bitWriter {
+	return &bitWriter{
+		buf:   make([]byte, 0, size),
+		nbits: 0,
+	}
+}
+
+func (w *bitWriter) writeBit(bit bool) {
+	if w.nbits%8 == 0 {
+		w.buf = append(w.buf, 0)
+	}
+	if bit {
+		w.buf[len(w.buf)-1] |= 1 << (7 - (w.nbits % 8))
+	}
+	w.nbits++
+}
+
+// writeBits writes the lower n bits of the specified value.
+func (w *bitWriter) writeBits(value uint64, n int) {
+	for n > 0 {
+		free := int(8 - (w.nbits % 8))
+		if free == 8 {
+			w.buf = append(w.buf, 0)
+		}
+		chunk := min(free, n)
+		bits := byte((value >> uint(n-chunk)) & ((uint64(1) << uint(chunk)) - 1))
+		w.buf[len(w.buf)-1] |= bits << uint(free-chunk)
+		w.nbits += uint64(chunk)
+		n -= chunk
+	}
+}
+
+func (w *bitWriter) bytes() []byte {
+	return w.buf
+}
+
+func (w *bitWriter) bitCount() uint64 {
+	return w.nbits
+}
+
+// bitReader reads bits from a byte slice from the most significant bit.
+type bitReader struct {
+	buf   []byte
+	pos   uint64
+	nbits uint64
+}
+
+func newBitReader(buf []byte, nbits uint64) *bitReader {
+	return &bitReader{
+		buf:   buf,
+		pos:   0,
+		nbits: nbits,
+	}
+}
+
+func (r *bitReader) readBit() (bool, error) {
+	if r.nbits <= r.pos {
+		return false, errBitStreamEOF
+	}
+	bit := (r.buf[r.pos/8] >> (7 - (r.pos % 8))) & 1
+	r.pos++
+	return bit == 1, nil
+}
+
+func (r *bitReader) readBits(n int) (uint64, error) {
+	if r.nbits < r.pos+uint64(n) {
+		return 0, errBitStreamEOF
+	}
+	var value uint64
+	for n > 0 {
+		avail := int(8 - (r.pos % 8))
+		chunk := min(avail, n)
+		b := r.buf[r.pos/8] >> uint(avail-chunk)
+		b &= byte((uint64(1) << uint(chunk)) - 1)
+		value = (value << uint(chunk)) | uint64(b)
+		r.pos += uint64(chunk)
+		n -= chunk
+	}
+	return value, nil
+}
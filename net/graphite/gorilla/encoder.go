@@ -0,0 +1,190 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package gorilla provides the Gorilla time series compression and an in-memory store using it.
+// See : Gorilla: A Fast, Scalable, In-Memory Time Series Database (http://www.vldb.org/pvldb/vol8/p1816-teller.pdf)
+package gorilla
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+)
+
+const (
+	firstDeltaBits = 27
+	leadingBits    = 5
+	sigBits        = 6
+	maxLeading     = (1 << leadingBits) - 1
+)
+
+const (
+	errorOutOfOrderTimestamp = "timestamp %d is older than the last timestamp %d"
+	errorOutOfBlockTimestamp = "timestamp %d is out of the block [%d, %d)"
+)
+
+// Block is a compressed chunk of datapoints which start from the same block time.
+type Block struct {
+	Start int64
+	Count int
+	Bits  uint64
+	Data  []byte
+}
+
+// Size returns the byte size of the compressed data.
+func (block *Block) Size() int {
+	return len(block.Data)
+}
+
+// Encoder compresses datapoints into a block with the delta-of-delta timestamps and the XOR values.
+// The timestamps must be appended in the ascending order.
+type Encoder struct {
+	start        int64
+	duration     int64
+	count        int
+	writer       *bitWriter
+	lastTs       int64
+	lastDelta    int64
+	lastValue    uint64
+	lastLeading  int
+	lastTrailing int
+}
+
+// NewEncoder returns a new encoder of the block which starts at the specified unix time and has the specified seconds.
+func NewEncoder(start int64, duration int64) *Encoder {
+	return &Encoder{
+		start:        start,
+		duration:     min(duration, (1<<firstDeltaBits)-1),
+		count:        0,
+		writer:       newBitWriter(64),
+		lastTs:       start,
+		lastDelta:    0,
+		lastValue:    0,
+		lastLeading:  math.MaxInt,
+		lastTrailing: 0,
+	}
+}
+
+// Start returns the start time of the block.
+func (enc *Encoder) Start() int64 {
+	return enc.start
+}
+
+// End returns the end time of the block, which is exclusive.
+func (enc *Encoder) End() int64 {
+	return enc.start + enc.duration
+}
+
+// Count returns the number of the encoded datapoints.
+func (enc *Encoder) Count() int {
+	return enc.count
+}
+
+// LastTimestamp returns the timestamp of the last encoded datapoint.
+func (enc *Encoder) LastTimestamp() int64 {
+	return enc.lastTs
+}
+
+// Size returns the byte size of the compressed data.
+func (enc *Encoder) Size() int {
+	return len(enc.writer.bytes())
+}
+
+// Append encodes the specified datapoint.
+func (enc *Encoder) Append(ts int64, value float64) error {
+	if ts < enc.start || enc.End() <= ts {
+		return fmt.Errorf(errorOutOfBlockTimestamp, ts, enc.start, enc.End())
+	}
+	if 0 < enc.count && ts < enc.lastTs {
+		return fmt.Errorf(errorOutOfOrderTimestamp, ts, enc.lastTs)
+	}
+
+	w := enc.writer
+	v := math.Float64bits(value)
+
+	if enc.count == 0 {
+		delta := ts - enc.start
+		w.writeBits(uint64(delta), firstDeltaBits)
+		w.writeBits(v, 64)
+		enc.lastTs = ts
+		enc.lastDelta = delta
+		enc.lastValue = v
+		enc.count++
+		return nil
+	}
+
+	delta := ts - enc.lastTs
+	enc.writeDeltaOfDelta(delta - enc.lastDelta)
+	enc.writeValue(v)
+
+	enc.lastTs = ts
+	enc.lastDelta = delta
+	enc.lastValue = v
+	enc.count++
+
+	return nil
+}
+
+// writeDeltaOfDelta writes the delta of delta into the variable length buckets of the two's complement.
+func (enc *Encoder) writeDeltaOfDelta(dod int64) {
+	w := enc.writer
+	switch {
+	case dod == 0:
+		w.writeBit(false)
+	case -64 <= dod && dod <= 63:
+		w.writeBits(0b10, 2)
+		w.writeBits(uint64(dod), 7)
+	case -256 <= dod && dod <= 255:
+		w.writeBits(0b110, 3)
+		w.writeBits(uint64(dod), 9)
+	case -2048 <= dod && dod <= 2047:
+		w.writeBits(0b1110, 4)
+		w.writeBits(uint64(dod), 12)
+	default:
+		w.writeBits(0b1111, 4)
+		w.writeBits(uint64(dod), 32)
+	}
+}
+
+func (enc *Encoder) writeValue(v uint64) {
+	w := enc.writer
+
+	xor := v ^ enc.lastValue
+	if xor == 0 {
+		w.writeBit(false)
+		return
+	}
+	w.writeBit(true)
+
+	leading := min(bits.LeadingZeros64(xor), maxLeading)
+	trailing := bits.TrailingZeros64(xor)
+
+	if enc.lastLeading <= leading && enc.lastTrailing <= trailing {
+		w.writeBit(false)
+		w.writeBits(xor>>uint(enc.lastTrailing), 64-enc.lastLeading-enc.lastTrailing)
+		return
+	}
+
+	sig := 64 - leading - trailing
+	w.writeBit(true)
+	w.writeBits(uint64(leading), leadingBits)
+	// 64 significant bits are written as 0 because it doesn't fit in 6 bits
+	w.writeBits(uint64(sig&((1<<sigBits)-1)), sigBits)
+	w.writeBits(xor>>uint(trailing), sig)
+
+	enc.lastLeading = leading
+	enc.lastTrailing = trailing
+}
+
+// Block returns a snapshot of the encoded datapoints.
+func (enc *Encoder) Block() *Block {
+	data := make([]byte, len(enc.writer.bytes()))
+	copy(data, enc.writer.bytes())
+	return &Block{
+		Start: enc.start,
+		Count: enc.count,
+		Bits:  enc.writer.bitCount(),
+		Data:  data,
+	}
+}
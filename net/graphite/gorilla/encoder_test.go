@@ -0,0 +1,125 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gorilla
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func testEncodeDecode(t *testing.T, start int64, tss []int64, values []float64) *Block {
+	t.Helper()
+
+	enc := NewEncoder(start, 7200)
+	for n := range tss {
+		err := enc.Append(tss[n], values[n])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	block := enc.Block()
+	it := NewIterator(block)
+	n := 0
+	for it.Next() {
+		ts, v := it.At()
+		if ts != tss[n] {
+			t.Errorf("[%d] %d != %d", n, ts, tss[n])
+		}
+		if math.Float64bits(v) != math.Float64bits(values[n]) {
+			t.Errorf("[%d] %f != %f", n, v, values[n])
+		}
+		n++
+	}
+	if it.Err() != nil {
+		t.Error(it.Err())
+	}
+	if n != len(tss) {
+		t.Errorf("%d != %d", n, len(tss))
+	}
+
+	return block
+}
+
+func TestEncoderRoundTrip(t *testing.T) {
+	start := int64(1500000000)
+
+	tss := []int64{}
+	values := []float64{}
+	ts := start
+	for n := range 700 {
+		tss = append(tss, ts)
+		values = append(values, float64(n%7))
+		ts += 10
+	}
+	testEncodeDecode(t, start, tss, values)
+
+	// Irregular intervals and values including the special floats
+	r := rand.New(rand.NewSource(1))
+	tss = []int64{start + 3}
+	values = []float64{math.NaN()}
+	specials := []float64{0, -0.0, math.Inf(1), math.Inf(-1), math.MaxFloat64, math.SmallestNonzeroFloat64, math.NaN()}
+	ts = start + 3
+	for n := range 500 {
+		switch n % 5 {
+		case 0:
+			ts += 0
+		case 1:
+			ts += int64(r.Intn(60))
+		case 2:
+			ts += int64(r.Intn(500))
+		case 3:
+			ts += int64(r.Intn(3000))
+		default:
+			ts++
+		}
+		if start+7200 <= ts {
+			break
+		}
+		tss = append(tss, ts)
+		if n%3 == 0 {
+			values = append(values, specials[n%len(specials)])
+		} else {
+			values = append(values, r.NormFloat64()*1000)
+		}
+	}
+	testEncodeDecode(t, start, tss, values)
+}
+
+func TestEncoderErrors(t *testing.T) {
+	enc := NewEncoder(1000, 100)
+	if enc.Append(999, 0) == nil || enc.Append(1100, 0) == nil {
+		t.Error(fmt.Errorf("an out of block timestamp is appended"))
+	}
+	if enc.Append(1050, 0) != nil {
+		t.Error(fmt.Errorf("a valid timestamp isn't appended"))
+	}
+	if enc.Append(1049, 0) == nil {
+		t.Error(fmt.Errorf("an out of order timestamp is appended"))
+	}
+}
+
+func TestEncoderCompression(t *testing.T) {
+	start := int64(1500000000)
+	tss := []int64{}
+	values := []float64{}
+	value := 100.0
+	r := rand.New(rand.NewSource(1))
+	for n := range 720 {
+		tss = append(tss, start+int64(n*10))
+		if r.Intn(4) == 0 {
+			value += float64(r.Intn(3) - 1)
+		}
+		values = append(values, value)
+	}
+
+	block := testEncodeDecode(t, start, tss, values)
+	bytesPerPoint := float64(block.Size()) / float64(block.Count)
+	if 2 < bytesPerPoint {
+		t.Errorf("%f bytes per point", bytesPerPoint)
+	}
+}
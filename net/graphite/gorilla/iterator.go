@@ -0,0 +1,178 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gorilla
+
+import (
+	"math"
+)
+
+// Iterator decodes the datapoints of a block in the timestamp order.
+type Iterator struct {
+	start        int64
+	count        int
+	read         int
+	reader       *bitReader
+	ts           int64
+	delta        int64
+	value        uint64
+	lastLeading  int
+	lastTrailing int
+	err          error
+}
+
+// NewIterator returns a new iterator of the specified block.
+func NewIterator(block *Block) *Iterator {
+	return &Iterator{
+		start:        block.Start,
+		count:        block.Count,
+		read:         0,
+		reader:       newBitReader(block.Data, block.Bits),
+		ts:           0,
+		delta:        0,
+		value:        0,
+		lastLeading:  0,
+		lastTrailing: 0,
+		err:          nil,
+	}
+}
+
+// Next decodes the next datapoint, and returns false at the end of the block or on an error.
+func (it *Iterator) Next() bool {
+	if it.err != nil || it.count <= it.read {
+		return false
+	}
+
+	if it.read == 0 {
+		it.err = it.readFirst()
+	} else {
+		it.err = it.readNext()
+	}
+	if it.err != nil {
+		return false
+	}
+
+	it.read++
+	return true
+}
+
+// At returns the current datapoint.
+func (it *Iterator) At() (int64, float64) {
+	return it.ts, math.Float64frombits(it.value)
+}
+
+// Err returns the error which stopped the iteration.
+func (it *Iterator) Err() error {
+	return it.err
+}
+
+func (it *Iterator) readFirst() error {
+	r := it.reader
+	delta, err := r.readBits(firstDeltaBits)
+	if err != nil {
+		return err
+	}
+	value, err := r.readBits(64)
+	if err != nil {
+		return err
+	}
+	it.delta = int64(delta)
+	it.ts = it.start + it.delta
+	it.value = value
+	return nil
+}
+
+func (it *Iterator) readNext() error {
+	dod, err := it.readDeltaOfDelta()
+	if err != nil {
+		return err
+	}
+	it.delta += dod
+	it.ts += it.delta
+
+	return it.readValue()
+}
+
+func (it *Iterator) readDeltaOfDelta() (int64, error) {
+	r := it.reader
+
+	prefix := 0
+	for prefix < 4 {
+		bit, err := r.readBit()
+		if err != nil {
+			return 0, err
+		}
+		if !bit {
+			break
+		}
+		prefix++
+	}
+
+	var n int
+	switch prefix {
+	case 0:
+		return 0, nil
+	case 1:
+		n = 7
+	case 2:
+		n = 9
+	case 3:
+		n = 12
+	default:
+		n = 32
+	}
+
+	bits, err := r.readBits(n)
+	if err != nil {
+		return 0, err
+	}
+
+	// Sign extension of the n bits two's complement
+	dod := int64(bits)
+	if bits&(1<<uint(n-1)) != 0 {
+		dod -= 1 << uint(n)
+	}
+
+	return dod, nil
+}
+
+func (it *Iterator) readValue() error {
+	r := it.reader
+
+	bit, err := r.readBit()
+	if err != nil {
+		return err
+	}
+	if !bit {
+		return nil
+	}
+
+	bit, err = r.readBit()
+	if err != nil {
+		return err
+	}
+	if bit {
+		leading, err := r.readBits(leadingBits)
+		if err != nil {
+			return err
+		}
+		sig, err := r.readBits(sigBits)
+		if err != nil {
+			return err
+		}
+		if sig == 0 {
+			sig = 64
+		}
+		it.lastLeading = int(leading)
+		it.lastTrailing = 64 - int(leading) - int(sig)
+	}
+
+	xor, err := r.readBits(64 - it.lastLeading - it.lastTrailing)
+	if err != nil {
+		return err
+	}
+	it.value ^= xor << uint(it.lastTrailing)
+
+	return nil
+}
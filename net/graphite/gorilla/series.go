@@ -0,0 +1,142 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gorilla
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cybergarage/go-graphite/net/graphite"
+)
+
+// Series is a compressed series which consists of the sealed blocks and the open block.
+type Series struct {
+	sync.RWMutex
+	name      string
+	blockSize int64
+	retention int64
+	blocks    []*Block
+	enc       *Encoder
+}
+
+// NewSeries returns a new series with the specified block size and retention.
+// The sealed blocks are expired when they are older than the retention from the latest block.
+func NewSeries(name string, blockSize time.Duration, retention time.Duration) *Series {
+	return &Series{
+		RWMutex:   sync.RWMutex{},
+		name:      name,
+		blockSize: max(int64(blockSize/time.Second), 1),
+		retention: int64(retention / time.Second),
+		blocks:    []*Block{},
+		enc:       nil,
+	}
+}
+
+// GetName returns the series name.
+func (s *Series) GetName() string {
+	return s.name
+}
+
+// Append appends the specified datapoint.
+// Append returns an error when the timestamp is older than the last datapoint.
+func (s *Series) Append(ts int64, value float64) error {
+	s.Lock()
+	defer s.Unlock()
+
+	blockStart := ts - (ts % s.blockSize)
+	if ts < 0 && blockStart != ts {
+		blockStart -= s.blockSize
+	}
+
+	if s.enc != nil && s.enc.End() <= ts {
+		s.blocks = append(s.blocks, s.enc.Block())
+		s.enc = nil
+		s.expire(blockStart - s.retention)
+	}
+
+	if s.enc == nil {
+		s.enc = NewEncoder(blockStart, s.blockSize)
+	}
+
+	return s.enc.Append(ts, value)
+}
+
+// expire removes the sealed blocks which end before the specified time.
+func (s *Series) expire(before int64) {
+	n := 0
+	for n < len(s.blocks) && (s.blocks[n].Start+s.blockSize) <= before {
+		n++
+	}
+	if 0 < n {
+		s.blocks = append([]*Block{}, s.blocks[n:]...)
+	}
+}
+
+// Fetch returns the datapoints between the specified times inclusively.
+// The last value wins for the duplicated timestamps.
+func (s *Series) Fetch(from int64, until int64) []*graphite.DataPoint {
+	s.RLock()
+	defer s.RUnlock()
+
+	dps := graphite.NewDataPoints(0)
+	fetch := func(block *Block) {
+		if until < block.Start || (block.Start+s.blockSize) <= from {
+			return
+		}
+		it := NewIterator(block)
+		for it.Next() {
+			ts, v := it.At()
+			if ts < from || until < ts {
+				continue
+			}
+			last := len(dps) - 1
+			if 0 <= last && dps[last].UnixTimestamp() == ts {
+				dps[last].SetValue(v)
+				continue
+			}
+			dp := graphite.NewDataPoint()
+			dp.SetTimestamp(time.Unix(ts, 0))
+			dp.SetValue(v)
+			dps = append(dps, dp)
+		}
+	}
+
+	for _, block := range s.blocks {
+		fetch(block)
+	}
+	if s.enc != nil {
+		fetch(s.enc.Block())
+	}
+
+	return dps
+}
+
+// Count returns the number of the stored datapoints.
+func (s *Series) Count() int {
+	s.RLock()
+	defer s.RUnlock()
+	count := 0
+	for _, block := range s.blocks {
+		count += block.Count
+	}
+	if s.enc != nil {
+		count += s.enc.Count()
+	}
+	return count
+}
+
+// Size returns the byte size of the compressed datapoints.
+func (s *Series) Size() int {
+	s.RLock()
+	defer s.RUnlock()
+	size := 0
+	for _, block := range s.blocks {
+		size += block.Size()
+	}
+	if s.enc != nil {
+		size += s.enc.Size()
+	}
+	return size
+}
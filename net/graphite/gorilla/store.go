@@ -0,0 +1,195 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gorilla
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cybergarage/go-graphite/net/graphite"
+)
+
+const (
+	// DefaultBlockSize is the default time span of the compressed blocks as the Gorilla paper.
+	DefaultBlockSize = time.Hour * 2
+	// DefaultRetention is the default retention period of the series.
+	DefaultRetention = time.Hour * 24
+	// DefaultQueryRange is the default range of the queries which have no from or until times.
+	DefaultQueryRange = time.Hour * 24
+)
+
+// Stats represents the statistics of a store.
+type Stats struct {
+	SeriesCount  int
+	PointCount   int
+	ByteSize     int
+	DroppedCount int64
+}
+
+// BytesPerPoint returns the average compressed byte size of a datapoint.
+func (stats *Stats) BytesPerPoint() float64 {
+	if stats.PointCount == 0 {
+		return 0
+	}
+	return float64(stats.ByteSize) / float64(stats.PointCount)
+}
+
+// Store is an in-memory store of the Gorilla compressed series, and implements graphite.CarbonListener and graphite.RenderRequestListener.
+// The datapoints which are older than the last datapoint of the series are dropped.
+type Store struct {
+	sync.RWMutex
+	blockSize time.Duration
+	retention time.Duration
+	series    map[string]*Series
+	index     *graphite.MetricIndex
+	dropped   atomic.Int64
+}
+
+// NewStore returns a new empty store with the default block size and retention.
+func NewStore() *Store {
+	return &Store{
+		RWMutex:   sync.RWMutex{},
+		blockSize: DefaultBlockSize,
+		retention: DefaultRetention,
+		series:    map[string]*Series{},
+		index:     graphite.NewMetricIndex(),
+		dropped:   atomic.Int64{},
+	}
+}
+
+// SetBlockSize sets the time span of the blocks of the new series.
+func (store *Store) SetBlockSize(d time.Duration) {
+	store.Lock()
+	defer store.Unlock()
+	store.blockSize = d
+}
+
+// SetRetention sets the retention period of the new series.
+func (store *Store) SetRetention(d time.Duration) {
+	store.Lock()
+	defer store.Unlock()
+	store.retention = d
+}
+
+// GetMetricIndex returns the index of the stored series names.
+func (store *Store) GetMetricIndex() *graphite.MetricIndex {
+	return store.index
+}
+
+// GetSeries returns the series of the specified name.
+func (store *Store) GetSeries(name string) (*Series, bool) {
+	store.RLock()
+	defer store.RUnlock()
+	s, ok := store.series[name]
+	return s, ok
+}
+
+func (store *Store) getOrCreateSeries(name string) *Series {
+	s, ok := store.GetSeries(name)
+	if ok {
+		return s
+	}
+
+	store.Lock()
+	defer store.Unlock()
+
+	s, ok = store.series[name]
+	if ok {
+		return s
+	}
+
+	s = NewSeries(name, store.blockSize, store.retention)
+	store.series[name] = s
+	store.index.Insert(name)
+
+	return s
+}
+
+// AddMetrics stores all datapoints of the specified metrics.
+func (store *Store) AddMetrics(m *graphite.Metrics) {
+	s := store.getOrCreateSeries(m.Name)
+	for _, dp := range m.DataPoints {
+		if dp == nil {
+			continue
+		}
+		err := s.Append(dp.UnixTimestamp(), dp.Value)
+		if err != nil {
+			store.dropped.Add(1)
+		}
+	}
+}
+
+// Stats returns the current statistics of the store.
+func (store *Store) Stats() *Stats {
+	store.RLock()
+	defer store.RUnlock()
+
+	stats := &Stats{
+		SeriesCount:  len(store.series),
+		PointCount:   0,
+		ByteSize:     0,
+		DroppedCount: store.dropped.Load(),
+	}
+	for _, s := range store.series {
+		stats.PointCount += s.Count()
+		stats.ByteSize += s.Size()
+	}
+
+	return stats
+}
+
+// InsertMetricsRequestReceived stores the ingested metrics.
+func (store *Store) InsertMetricsRequestReceived(ms []*graphite.Metrics, err error) {
+	for _, m := range ms {
+		if m == nil || len(m.Name) == 0 {
+			continue
+		}
+		store.AddMetrics(m)
+	}
+}
+
+// FindMetricsRequestReceived returns the series names which match the query target.
+func (store *Store) FindMetricsRequestReceived(query *graphite.Query, err error) ([]*graphite.Metrics, error) {
+	if err != nil {
+		return nil, err
+	}
+	return store.index.FindMetrics(query.Target)
+}
+
+// QueryMetricsRequestReceived returns the datapoints of the series which match the query target.
+func (store *Store) QueryMetricsRequestReceived(query *graphite.Query, err error) ([]*graphite.Metrics, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	until := time.Now()
+	if query.Until != nil {
+		until = *query.Until
+	}
+	from := until.Add(-DefaultQueryRange)
+	if query.From != nil {
+		from = *query.From
+	}
+
+	names, err := store.index.FindMetrics(query.Target)
+	if err != nil {
+		return nil, err
+	}
+
+	ms := []*graphite.Metrics{}
+	for _, name := range names {
+		s, ok := store.GetSeries(name.Name)
+		if !ok {
+			continue
+		}
+		m := graphite.NewMetrics()
+		m.SetName(s.GetName())
+		m.DataPoints = s.Fetch(from.Unix(), until.Unix())
+		ms = append(ms, m)
+	}
+
+	return ms, nil
+}
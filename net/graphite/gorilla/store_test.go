@@ -0,0 +1,83 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gorilla
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cybergarage/go-graphite/net/graphite"
+)
+
+func TestSeries(t *testing.T) {
+	s := NewSeries("a.b", time.Minute, time.Minute*5)
+
+	start := int64(1500000000 - (1500000000 % 60))
+	for n := range 600 {
+		err := s.Append(start+int64(n), float64(n))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if s.Append(start+100, 0) == nil {
+		t.Error(fmt.Errorf("an out of order datapoint is appended"))
+	}
+
+	// 5 minutes of the sealed blocks and the open block are retained
+	if s.Count() != 360 {
+		t.Error(fmt.Errorf("%d != %d", s.Count(), 360))
+	}
+
+	err := s.Append(start+599, -1)
+	if err != nil {
+		t.Error(err)
+	}
+
+	dps := s.Fetch(start+590, start+1000)
+	if len(dps) != 10 {
+		t.Fatal(fmt.Errorf("%d != %d", len(dps), 10))
+	}
+	if dps[0].Value != 590 || dps[9].Value != -1 {
+		t.Error(fmt.Errorf("%f %f", dps[0].Value, dps[9].Value))
+	}
+}
+
+func TestStore(t *testing.T) {
+	store := NewStore()
+
+	server := graphite.NewServer()
+	server.SetStore(store)
+
+	now := time.Now().Unix()
+	for n := range 10 {
+		line := fmt.Sprintf("servers.host%d.cpu %d %d\nservers.host%d.cpu %d %d\n", n, n, now-10, n, n, now)
+		_, err := server.FeedPlainTextString(line)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	stats := store.Stats()
+	if stats.SeriesCount != 10 || stats.PointCount != 20 {
+		t.Error(fmt.Errorf("%v", stats))
+	}
+
+	q := graphite.NewQuery()
+	q.Target = "servers.host[12].cpu"
+	ms, err := store.QueryMetricsRequestReceived(q, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ms) != 2 {
+		t.Fatal(fmt.Errorf("%d != %d", len(ms), 2))
+	}
+	for n, m := range ms {
+		if m.GetDataPointCount() != 2 || m.DataPoints[1].Value != float64(n+1) {
+			t.Error(fmt.Errorf("%s : %v", m.Name, m.DataPoints))
+		}
+	}
+}
@@ -0,0 +1,18 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package influx
+
+const (
+	errorInvalidLine      = "unable to parse '%s' : %s"
+	errorMissingFields    = "missing fields"
+	errorMissingMeasure   = "missing measurement"
+	errorInvalidTag       = "invalid tag (%s)"
+	errorInvalidField     = "invalid field (%s)"
+	errorInvalidTimestamp = "invalid timestamp (%s)"
+	errorInvalidPrecision = "invalid precision : %s"
+	errorInvalidTemplate  = "invalid template : %s"
+	errorPartialWrite     = "partial write: %s dropped=%d"
+	errorBodyTooLarge     = "request body is larger than %d bytes"
+)
@@ -0,0 +1,235 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package influx
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	lineSep     = "\n"
+	lineTrim    = " \r\t"
+	lineComment = "#"
+)
+
+// Tag represents a tag of a point.
+type Tag struct {
+	Key   string
+	Value string
+}
+
+// Field represents a field of a point. The value is float64, int64, uint64, bool or string.
+type Field struct {
+	Key   string
+	Value any
+}
+
+// Float returns the numeric value of the field, and false for the string fields.
+// The booleans are converted to 1 or 0.
+func (field *Field) Float() (float64, bool) {
+	switch v := field.Value.(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// Point represents a line of the InfluxDB line protocol.
+type Point struct {
+	Measurement string
+	Tags        []Tag
+	Fields      []Field
+	Timestamp   time.Time
+}
+
+// ParsePrecision returns the unit of the timestamps of the specified precision such as 'ns', 'u', 'ms', 's', 'm' and 'h'.
+// The empty precision is nanoseconds.
+func ParsePrecision(precision string) (time.Duration, error) {
+	switch precision {
+	case "", "n", "ns":
+		return time.Nanosecond, nil
+	case "u", "us", "µ":
+		return time.Microsecond, nil
+	case "ms":
+		return time.Millisecond, nil
+	case "s":
+		return time.Second, nil
+	case "m":
+		return time.Minute, nil
+	case "h":
+		return time.Hour, nil
+	}
+	return 0, fmt.Errorf(errorInvalidPrecision, precision)
+}
+
+// splitUnescaped splits the string by the separator which isn't escaped by a backslash.
+// The separators in double quotes are also ignored when quoted is true.
+func splitUnescaped(s string, sep byte, quoted bool) []string {
+	parts := []string{}
+	inQuote := false
+	start := 0
+	for n := 0; n < len(s); n++ {
+		switch {
+		case s[n] == '\\':
+			n++
+		case quoted && s[n] == '"':
+			inQuote = !inQuote
+		case s[n] == sep && !inQuote:
+			parts = append(parts, s[start:n])
+			start = n + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// cutUnescaped slices the string around the first separator which isn't escaped by a backslash.
+func cutUnescaped(s string, sep byte) (string, string, bool) {
+	for n := 0; n < len(s); n++ {
+		switch s[n] {
+		case '\\':
+			n++
+		case sep:
+			return s[:n], s[n+1:], true
+		}
+	}
+	return s, "", false
+}
+
+// unescape removes the backslashes of the escaped commas, equal signs and spaces.
+func unescape(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+	var b strings.Builder
+	for n := 0; n < len(s); n++ {
+		if s[n] == '\\' && n+1 < len(s) && strings.IndexByte(", =\\\"", s[n+1]) >= 0 {
+			n++
+		}
+		b.WriteByte(s[n])
+	}
+	return b.String()
+}
+
+func parseFieldValue(value string) (any, bool) {
+	if len(value) == 0 {
+		return nil, false
+	}
+	if value[0] == '"' {
+		if len(value) < 2 || value[len(value)-1] != '"' {
+			return nil, false
+		}
+		return unescape(value[1 : len(value)-1]), true
+	}
+	switch value {
+	case "t", "T", "true", "True", "TRUE":
+		return true, true
+	case "f", "F", "false", "False", "FALSE":
+		return false, true
+	}
+	switch value[len(value)-1] {
+	case 'i':
+		v, err := strconv.ParseInt(value[:len(value)-1], 10, 64)
+		return v, err == nil
+	case 'u':
+		v, err := strconv.ParseUint(value[:len(value)-1], 10, 64)
+		return v, err == nil
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	return v, err == nil
+}
+
+// ParsePoint returns a point of the specified line such as 'cpu,host=server01 usage_idle=92.5,usage_user=3i 1465839830100400200'.
+// The timestamp is multiplied by the precision, and the point which has no timestamp is at the specified time.
+func ParsePoint(line string, precision time.Duration, now time.Time) (*Point, error) {
+	line = strings.Trim(line, lineTrim)
+
+	sections := []string{}
+	for _, section := range splitUnescaped(line, ' ', true) {
+		if len(section) == 0 {
+			continue
+		}
+		sections = append(sections, section)
+	}
+	if len(sections) < 2 || 3 < len(sections) {
+		return nil, fmt.Errorf(errorInvalidLine, line, errorMissingFields)
+	}
+
+	point := &Point{
+		Measurement: "",
+		Tags:        []Tag{},
+		Fields:      []Field{},
+		Timestamp:   now,
+	}
+
+	keys := splitUnescaped(sections[0], ',', false)
+	point.Measurement = unescape(keys[0])
+	if len(point.Measurement) == 0 {
+		return nil, fmt.Errorf(errorInvalidLine, line, errorMissingMeasure)
+	}
+	for _, tag := range keys[1:] {
+		key, value, ok := cutUnescaped(tag, '=')
+		if !ok || len(key) == 0 || len(value) == 0 {
+			return nil, fmt.Errorf(errorInvalidLine, line, fmt.Sprintf(errorInvalidTag, tag))
+		}
+		point.Tags = append(point.Tags, Tag{Key: unescape(key), Value: unescape(value)})
+	}
+	sort.SliceStable(point.Tags, func(i, j int) bool {
+		return point.Tags[i].Key < point.Tags[j].Key
+	})
+
+	for _, field := range splitUnescaped(sections[1], ',', true) {
+		key, value, ok := cutUnescaped(field, '=')
+		if !ok || len(key) == 0 {
+			return nil, fmt.Errorf(errorInvalidLine, line, fmt.Sprintf(errorInvalidField, field))
+		}
+		v, ok := parseFieldValue(value)
+		if !ok {
+			return nil, fmt.Errorf(errorInvalidLine, line, fmt.Sprintf(errorInvalidField, field))
+		}
+		point.Fields = append(point.Fields, Field{Key: unescape(key), Value: v})
+	}
+
+	if len(sections) == 3 {
+		ts, err := strconv.ParseInt(sections[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf(errorInvalidLine, line, fmt.Sprintf(errorInvalidTimestamp, sections[2]))
+		}
+		point.Timestamp = time.Unix(0, 0).Add(time.Duration(ts) * precision)
+	}
+
+	return point, nil
+}
+
+// ParsePoints returns the points of the specified lines, and the errors of the invalid lines which are skipped.
+func ParsePoints(text string, precision time.Duration, now time.Time) ([]*Point, []error) {
+	points := []*Point{}
+	errs := []error{}
+	for _, line := range strings.Split(text, lineSep) {
+		line = strings.Trim(line, lineTrim)
+		if len(line) == 0 || strings.HasPrefix(line, lineComment) {
+			continue
+		}
+		point, err := ParsePoint(line, precision, now)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		points = append(points, point)
+	}
+	return points, errs
+}
@@ -0,0 +1,94 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package influx
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestParsePoint(t *testing.T) {
+	line := `cpu\ load,host=server\,01,region=us\ west idle=92.5,user=3i,busy=t,count=7u,msg="hello, \"world\"" 1465839830100400200`
+	point, err := ParsePoint(line, time.Nanosecond, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if point.Measurement != "cpu load" {
+		t.Error(fmt.Errorf("%s != %s", point.Measurement, "cpu load"))
+	}
+
+	expectedTags := []Tag{{"host", "server,01"}, {"region", "us west"}}
+	if len(point.Tags) != len(expectedTags) {
+		t.Fatal(fmt.Errorf("%d != %d", len(point.Tags), len(expectedTags)))
+	}
+	for n, tag := range expectedTags {
+		if point.Tags[n] != tag {
+			t.Error(fmt.Errorf("%v != %v", point.Tags[n], tag))
+		}
+	}
+
+	expectedFields := []Field{
+		{"idle", 92.5},
+		{"user", int64(3)},
+		{"busy", true},
+		{"count", uint64(7)},
+		{"msg", `hello, "world"`},
+	}
+	if len(point.Fields) != len(expectedFields) {
+		t.Fatal(fmt.Errorf("%d != %d", len(point.Fields), len(expectedFields)))
+	}
+	for n, field := range expectedFields {
+		if point.Fields[n] != field {
+			t.Error(fmt.Errorf("%v != %v", point.Fields[n], field))
+		}
+	}
+
+	if point.Timestamp.UnixNano() != 1465839830100400200 {
+		t.Error(fmt.Errorf("%d != %d", point.Timestamp.UnixNano(), 1465839830100400200))
+	}
+}
+
+func TestParsePointPrecision(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	point, err := ParsePoint("mem used=1", time.Second, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !point.Timestamp.Equal(now) {
+		t.Error(fmt.Errorf("%v != %v", point.Timestamp, now))
+	}
+
+	point, err = ParsePoint("mem used=1 1465839830", time.Second, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if point.Timestamp.Unix() != 1465839830 {
+		t.Error(fmt.Errorf("%d != %d", point.Timestamp.Unix(), 1465839830))
+	}
+
+	_, err = ParsePrecision("x")
+	if err == nil {
+		t.Error(fmt.Errorf("invalid precision is accepted"))
+	}
+}
+
+func TestParseInvalidPoints(t *testing.T) {
+	text := "# comment\n" +
+		"cpu\n" +
+		"cpu,host value=1\n" +
+		"cpu value=abc\n" +
+		"cpu value=1 abc\n" +
+		"cpu value=1\n"
+	points, errs := ParsePoints(text, time.Nanosecond, time.Now())
+	if len(points) != 1 {
+		t.Error(fmt.Errorf("%d != %d", len(points), 1))
+	}
+	if len(errs) != 4 {
+		t.Error(fmt.Errorf("%d != %d : %v", len(errs), 4, errs))
+	}
+}
@@ -0,0 +1,380 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package influx provides the InfluxDB line protocol listeners which convert the points to Graphite metrics
+// with the graphite templates of Telegraf, and deliver them to a graphite.CarbonListener.
+package influx
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cybergarage/go-graphite/net/graphite"
+)
+
+const (
+	// DefaultPort is the default port number of the UDP and TCP line listeners.
+	DefaultPort int = 8089
+	// DefaultWriteRequestPath is the default path of the HTTP write endpoint of InfluxDB v1.
+	DefaultWriteRequestPath = "/write"
+	// DefaultConnectionWaitTimeout is the default read timeout of the TCP connections.
+	DefaultConnectionWaitTimeout = time.Second * 60
+	// MaxPacketSize is the maximum size of the UDP packets.
+	MaxPacketSize = 65535
+	// DefaultMaxBodySize is the default maximum byte size of the HTTP write requests, which is checked after the decompression.
+	DefaultMaxBodySize = graphite.DefaultMaxIngestSize
+)
+
+const (
+	writePrecisionParam     = "precision"
+	httpHeaderContentEncode = "Content-Encoding"
+	httpContentEncodingGzip = "gzip"
+)
+
+// Server receives the InfluxDB line protocol with UDP and TCP, and with HTTP as a graphite.RenderHTTPRequestListener of '/write'.
+type Server struct {
+	sync.Mutex
+	addr                  string
+	port                  int
+	precision             time.Duration
+	connectionWaitTimeout time.Duration
+	maxBodySize           int64
+	mapper                *Mapper
+	carbonListener        graphite.CarbonListener
+	udpConn               net.PacketConn
+	tcpListener           net.Listener
+	wg                    sync.WaitGroup
+}
+
+// NewServer returns a new server with the default template.
+func NewServer() *Server {
+	return &Server{
+		Mutex:                 sync.Mutex{},
+		addr:                  "",
+		port:                  DefaultPort,
+		precision:             time.Nanosecond,
+		connectionWaitTimeout: DefaultConnectionWaitTimeout,
+		maxBodySize:           DefaultMaxBodySize,
+		mapper:                NewMapper(),
+		carbonListener:        nil,
+		udpConn:               nil,
+		tcpListener:           nil,
+		wg:                    sync.WaitGroup{},
+	}
+}
+
+// SetAddress sets a bind address to the server.
+func (server *Server) SetAddress(addr string) {
+	server.Lock()
+	defer server.Unlock()
+	server.addr = addr
+}
+
+// GetAddress returns a bound address.
+func (server *Server) GetAddress() string {
+	server.Lock()
+	defer server.Unlock()
+	return server.addr
+}
+
+// SetPort sets a bind port of UDP and TCP to the server.
+func (server *Server) SetPort(port int) {
+	server.Lock()
+	defer server.Unlock()
+	server.port = port
+}
+
+// GetPort returns a bound port.
+func (server *Server) GetPort() int {
+	server.Lock()
+	defer server.Unlock()
+	return server.port
+}
+
+// SetPrecision sets the precision of the timestamps of the UDP and TCP listeners such as 's' and 'ms'.
+func (server *Server) SetPrecision(precision string) error {
+	d, err := ParsePrecision(precision)
+	if err != nil {
+		return err
+	}
+	server.Lock()
+	defer server.Unlock()
+	server.precision = d
+	return nil
+}
+
+// SetConnectionWaitTimeout sets the read timeout of the TCP connections.
+func (server *Server) SetConnectionWaitTimeout(d time.Duration) {
+	server.Lock()
+	defer server.Unlock()
+	server.connectionWaitTimeout = d
+}
+
+// SetMaxBodySize sets the maximum byte size of the HTTP write requests, which is checked for both of the compressed and the decompressed bodies.
+// The zero size disables the limit.
+func (server *Server) SetMaxBodySize(n int64) {
+	server.Lock()
+	defer server.Unlock()
+	server.maxBodySize = n
+}
+
+// GetMaxBodySize returns the maximum byte size of the HTTP write requests.
+func (server *Server) GetMaxBodySize() int64 {
+	server.Lock()
+	defer server.Unlock()
+	return server.maxBodySize
+}
+
+// SetMapper sets the mapper from the points to the Graphite metrics.
+func (server *Server) SetMapper(mapper *Mapper) {
+	server.Lock()
+	defer server.Unlock()
+	server.mapper = mapper
+}
+
+// GetMapper returns the mapper from the points to the Graphite metrics.
+func (server *Server) GetMapper() *Mapper {
+	server.Lock()
+	defer server.Unlock()
+	return server.mapper
+}
+
+// SetCarbonListener sets the listener of the metrics converted from the points with the templates.
+// Set the listener of Carbon.GetFeedListener() to write the points through the ingest path of the Carbon server.
+func (server *Server) SetCarbonListener(listener graphite.CarbonListener) {
+	server.Lock()
+	defer server.Unlock()
+	server.carbonListener = listener
+}
+
+// FeedString converts the points of the specified lines with the specified precision, and passes them to the listener.
+// FeedString returns the converted metrics and the errors of the invalid lines which are skipped.
+func (server *Server) FeedString(text string, precision time.Duration) ([]*graphite.Metrics, []error) {
+	ms, errs, listener := server.convertString(text, precision)
+	if listener != nil && 0 < len(ms) {
+		listener.InsertMetricsRequestReceived(ms, nil)
+	}
+	return ms, errs
+}
+
+// convertString converts the points of the specified lines with the specified precision, and returns them with the listener.
+func (server *Server) convertString(text string, precision time.Duration) ([]*graphite.Metrics, []error, graphite.CarbonListener) {
+	points, errs := ParsePoints(text, precision, time.Now())
+
+	server.Lock()
+	mapper := server.mapper
+	listener := server.carbonListener
+	server.Unlock()
+
+	return mapper.Metrics(points), errs, listener
+}
+
+func (server *Server) feedBytes(b []byte) {
+	server.Lock()
+	precision := server.precision
+	server.Unlock()
+	server.FeedString(string(b), precision)
+}
+
+// HTTPRequestReceived handles the write requests of InfluxDB v1 such as 'POST /write?db=telegraf&precision=s'.
+// The valid lines are written even if the request has invalid lines, and the first error is returned with 400 like InfluxDB.
+func (server *Server) HTTPRequestReceived(r *http.Request, w http.ResponseWriter) {
+	server.AuthorizedHTTPRequestReceived(r, w, nil)
+}
+
+// AuthorizedHTTPRequestReceived handles the write requests with the path rules and the tenancy of Render.
+// The metrics are prefixed with the tenant of the request, and no lines are written with 403 when the principal can't write one of them.
+func (server *Server) AuthorizedHTTPRequestReceived(r *http.Request, w http.ResponseWriter, auth graphite.RenderAuthorizer) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, http.StatusText(http.StatusMethodNotAllowed))
+		return
+	}
+
+	precision, err := ParsePrecision(r.URL.Query().Get(writePrecisionParam))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	limit := server.GetMaxBodySize()
+	var body io.Reader = r.Body
+	if 0 < limit {
+		body = http.MaxBytesReader(w, r.Body, limit)
+	}
+	if r.Header.Get(httpHeaderContentEncode) == httpContentEncodingGzip {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			writeReadError(w, err)
+			return
+		}
+		defer gz.Close()
+		body = gz
+	}
+	if 0 < limit {
+		body = io.LimitReader(body, limit+1)
+	}
+
+	b, err := io.ReadAll(body)
+	if err == nil && 0 < limit && limit < int64(len(b)) {
+		err = &http.MaxBytesError{Limit: limit}
+	}
+	if err != nil {
+		writeReadError(w, err)
+		return
+	}
+
+	ms, errs, listener := server.convertString(string(b), precision)
+	if listener != nil && 0 < len(ms) {
+		err := graphite.InsertRequestMetrics(r, auth, listener, ms)
+		if err != nil {
+			writeError(w, graphite.AsRenderError(err).StatusCode(), err.Error())
+			return
+		}
+	}
+	if 0 < len(errs) {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf(errorPartialWrite, errs[0].Error(), len(errs)))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeReadError writes the error of the request body, and the bodies which are larger than the limit are rejected with 413 like InfluxDB.
+func writeReadError(w http.ResponseWriter, err error) {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf(errorBodyTooLarge, maxErr.Limit))
+		return
+	}
+	writeError(w, http.StatusBadRequest, err.Error())
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", graphite.QueryContentTypeJSON)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
+
+// Start starts the UDP and TCP listeners.
+func (server *Server) Start() error {
+	err := server.Stop()
+	if err != nil {
+		return err
+	}
+
+	server.Lock()
+	defer server.Unlock()
+
+	addr := net.JoinHostPort(server.addr, strconv.Itoa(server.port))
+	server.udpConn, err = net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+	server.tcpListener, err = net.Listen("tcp", addr)
+	if err != nil {
+		server.udpConn.Close()
+		server.udpConn = nil
+		return err
+	}
+
+	server.wg.Add(2)
+	go server.serveUDP(server.udpConn)
+	go server.serveTCP(server.tcpListener)
+
+	return nil
+}
+
+// Stop stops the UDP and TCP listeners.
+func (server *Server) Stop() error {
+	server.Lock()
+	udpConn := server.udpConn
+	tcpListener := server.tcpListener
+	server.udpConn = nil
+	server.tcpListener = nil
+	server.Unlock()
+
+	var lastErr error
+	if udpConn != nil {
+		err := udpConn.Close()
+		if err != nil {
+			lastErr = err
+		}
+	}
+	if tcpListener != nil {
+		err := tcpListener.Close()
+		if err != nil {
+			lastErr = err
+		}
+	}
+	server.wg.Wait()
+
+	return lastErr
+}
+
+func (server *Server) serveUDP(conn net.PacketConn) {
+	defer server.wg.Done()
+	buf := make([]byte, MaxPacketSize)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		server.feedBytes(buf[:n])
+	}
+}
+
+func (server *Server) serveTCP(l net.Listener) {
+	defer server.wg.Done()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go server.receive(conn)
+	}
+}
+
+func (server *Server) receive(conn net.Conn) error {
+	defer conn.Close()
+
+	server.Lock()
+	timeout := server.connectionWaitTimeout
+	server.Unlock()
+
+	reqBytes := make([]byte, 0)
+	readBytes := make([]byte, 1024)
+	for {
+		conn.SetReadDeadline(time.Now().Add(timeout))
+
+		n, err := conn.Read(readBytes)
+		if err == nil {
+			reqBytes = append(reqBytes, readBytes[:n]...)
+			lastSep := bytes.LastIndex(reqBytes, []byte(lineSep))
+			if 0 <= lastSep {
+				server.feedBytes(reqBytes[:lastSep+1])
+				reqBytes = append(reqBytes[:0], reqBytes[lastSep+1:]...)
+			}
+			continue
+		}
+
+		if 0 < len(reqBytes) {
+			server.feedBytes(reqBytes)
+		}
+
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		return err
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package influx
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cybergarage/go-graphite/net/graphite"
+)
+
+type testListener struct {
+	sync.Mutex
+	metrics map[string]*graphite.Metrics
+}
+
+func newTestListener() *testListener {
+	return &testListener{metrics: map[string]*graphite.Metrics{}}
+}
+
+func (l *testListener) InsertMetricsRequestReceived(ms []*graphite.Metrics, err error) {
+	l.Lock()
+	defer l.Unlock()
+	for _, m := range ms {
+		l.metrics[m.Name] = m
+	}
+}
+
+func (l *testListener) get(name string) (*graphite.Metrics, bool) {
+	l.Lock()
+	defer l.Unlock()
+	m, ok := l.metrics[name]
+	return m, ok
+}
+
+func TestServerHTTPWrite(t *testing.T) {
+	listener := newTestListener()
+	server := NewServer()
+	server.SetCarbonListener(listener)
+
+	body := "cpu,host=web01 usage_idle=90 1465839830\n"
+	r := httptest.NewRequest(http.MethodPost, DefaultWriteRequestPath+"?db=telegraf&precision=s", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	server.HTTPRequestReceived(r, w)
+	if w.Code != http.StatusNoContent {
+		t.Error(fmt.Errorf("%d != %d", w.Code, http.StatusNoContent))
+	}
+	m, ok := listener.get("web01.cpu.usage_idle")
+	if !ok {
+		t.Fatal(fmt.Errorf("web01.cpu.usage_idle is not found"))
+	}
+	if m.DataPoints[0].UnixTimestamp() != 1465839830 || m.DataPoints[0].Value != 90 {
+		t.Error(fmt.Errorf("%d %f", m.DataPoints[0].UnixTimestamp(), m.DataPoints[0].Value))
+	}
+
+	// The valid lines are written even if the request has invalid lines.
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte("mem,host=web01 used=1\nmem,host=web01 used=\n"))
+	zw.Close()
+	r = httptest.NewRequest(http.MethodPost, DefaultWriteRequestPath, &gz)
+	r.Header.Set("Content-Encoding", "gzip")
+	w = httptest.NewRecorder()
+	server.HTTPRequestReceived(r, w)
+	if w.Code != http.StatusBadRequest {
+		t.Error(fmt.Errorf("%d != %d", w.Code, http.StatusBadRequest))
+	}
+	if !strings.Contains(w.Body.String(), "partial write") {
+		t.Error(fmt.Errorf("%s", w.Body.String()))
+	}
+	if _, ok := listener.get("web01.mem.used"); !ok {
+		t.Error(fmt.Errorf("web01.mem.used is not found"))
+	}
+
+	r = httptest.NewRequest(http.MethodPost, DefaultWriteRequestPath+"?precision=x", strings.NewReader(body))
+	w = httptest.NewRecorder()
+	server.HTTPRequestReceived(r, w)
+	if w.Code != http.StatusBadRequest {
+		t.Error(fmt.Errorf("%d != %d", w.Code, http.StatusBadRequest))
+	}
+}
+
+func TestServerHTTPWriteLimit(t *testing.T) {
+	listener := newTestListener()
+	server := NewServer()
+	server.SetCarbonListener(listener)
+	server.SetMaxBodySize(128)
+
+	line := "cpu,host=web01 usage_idle=90 1465839830\n"
+	large := strings.Repeat(line, 100)
+
+	// The compressed body is small, but it is larger than the limit after the decompression.
+	var bomb bytes.Buffer
+	zw := gzip.NewWriter(&bomb)
+	zw.Write([]byte(large))
+	zw.Close()
+	if 128 < bomb.Len() {
+		t.Fatal(fmt.Errorf("compressed body is too large : %d", bomb.Len()))
+	}
+
+	testCases := []struct {
+		body     *bytes.Buffer
+		encoding string
+		code     int
+	}{
+		{bytes.NewBufferString(line), "", http.StatusNoContent},
+		{bytes.NewBufferString(large), "", http.StatusRequestEntityTooLarge},
+		{&bomb, "gzip", http.StatusRequestEntityTooLarge},
+	}
+	for _, tc := range testCases {
+		r := httptest.NewRequest(http.MethodPost, DefaultWriteRequestPath+"?precision=s", tc.body)
+		if 0 < len(tc.encoding) {
+			r.Header.Set("Content-Encoding", tc.encoding)
+		}
+		w := httptest.NewRecorder()
+		server.HTTPRequestReceived(r, w)
+		if w.Code != tc.code {
+			t.Error(fmt.Errorf("%d != %d : %s", w.Code, tc.code, w.Body.String()))
+		}
+	}
+}
+
+func TestServerLineListeners(t *testing.T) {
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	listener := newTestListener()
+	server := NewServer()
+	server.SetAddress("localhost")
+	server.SetPort(port)
+	server.SetPrecision("s")
+	server.SetCarbonListener(listener)
+	err = server.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Stop()
+
+	addr := net.JoinHostPort("localhost", strconv.Itoa(port))
+	for _, network := range []string{"udp", "tcp"} {
+		conn, err := net.Dial(network, addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintf(conn, "net,host=%s bytes=1 1465839830\n", network)
+		conn.Close()
+	}
+
+	for _, name := range []string{"udp.net.bytes", "tcp.net.bytes"} {
+		deadline := time.Now().Add(time.Second * 5)
+		_, ok := listener.get(name)
+		for !ok && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond * 10)
+			_, ok = listener.get(name)
+		}
+		if !ok {
+			t.Error(fmt.Errorf("%s is not found", name))
+		}
+	}
+}
+
+func TestServerHTTPWriteAuthorization(t *testing.T) {
+	listener := newTestListener()
+	server := NewServer()
+	server.SetCarbonListener(listener)
+
+	rules := graphite.NewPathRules()
+	rules.AddRule("", "web01")
+	tenancy := graphite.NewTenancy(graphite.TenantPrefix)
+	tenancy.SetHeader(graphite.DefaultTenantHeader)
+	render := graphite.NewRender()
+	render.SetPathRules(rules)
+	render.SetTenancy(tenancy)
+	render.SetHTTPRequestListener(DefaultWriteRequestPath, server)
+
+	write := func(body string) int {
+		r := httptest.NewRequest(http.MethodPost, DefaultWriteRequestPath+"?precision=s", strings.NewReader(body))
+		r.Header.Set(graphite.DefaultTenantHeader, "team-a")
+		w := httptest.NewRecorder()
+		render.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	// The allowed metrics are written with the prefix of the tenant.
+	if code := write("cpu,host=web01 usage_idle=90 1465839830\n"); code != http.StatusNoContent {
+		t.Error(fmt.Errorf("%d != %d", code, http.StatusNoContent))
+	}
+	if _, ok := listener.get("team-a.web01.cpu.usage_idle"); !ok {
+		t.Error(fmt.Errorf("team-a.web01.cpu.usage_idle is not found"))
+	}
+
+	// No lines are written when one of them isn't allowed.
+	if code := write("mem,host=web01 used=1\nmem,host=web02 used=1\n"); code != http.StatusForbidden {
+		t.Error(fmt.Errorf("%d != %d", code, http.StatusForbidden))
+	}
+	if _, ok := listener.get("team-a.web01.mem.used"); ok {
+		t.Error(fmt.Errorf("team-a.web01.mem.used is written"))
+	}
+}
@@ -0,0 +1,241 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package influx
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/cybergarage/go-graphite/net/graphite"
+)
+
+const (
+	// DefaultTemplate is the default template of Telegraf's graphite serializer.
+	DefaultTemplate = "host.tags.measurement.field"
+)
+
+const (
+	templateSep         = "."
+	templateMeasurement = "measurement"
+	templateField       = "field"
+	templateTags        = "tags"
+	// The field named 'value' is omitted from the names like Telegraf.
+	templateValueField = "value"
+	graphiteTagSep     = ";"
+	graphiteTagValue   = "="
+)
+
+var (
+	templateInvalidRegex = regexp.MustCompile(`[^a-zA-Z0-9\-_:#@%]`)
+	tagValueInvalidRegex = regexp.MustCompile(`[^a-zA-Z0-9\-_:#@%\.]`)
+)
+
+// Template maps the measurement, tags and fields of the points to the Graphite names like the graphite templates of Telegraf
+// such as 'host.measurement.tags.field'. The template which has a filter such as 'cpu* host.measurement.field' is applied
+// only to the measurements which match the filter.
+type Template struct {
+	filter *graphite.PathPattern
+	parts  []string
+	source string
+}
+
+// NewTemplate returns a new template of the specified string.
+func NewTemplate(s string) (*Template, error) {
+	fields := strings.Fields(s)
+	t := &Template{
+		filter: nil,
+		parts:  []string{},
+		source: s,
+	}
+	switch len(fields) {
+	case 1:
+		t.parts = strings.Split(fields[0], templateSep)
+	case 2:
+		filter, err := graphite.NewPathPattern(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		t.filter = filter
+		t.parts = strings.Split(fields[1], templateSep)
+	default:
+		return nil, fmt.Errorf(errorInvalidTemplate, s)
+	}
+	return t, nil
+}
+
+// Match returns true when the template is applied to the specified measurement.
+func (t *Template) Match(measurement string) bool {
+	return t.filter == nil || t.filter.Match(measurement)
+}
+
+// String returns the source string of the template.
+func (t *Template) String() string {
+	return t.source
+}
+
+// sanitizeNode replaces the characters which can't be used in a node of the Graphite paths.
+func sanitizeNode(s string) string {
+	return templateInvalidRegex.ReplaceAllString(s, "_")
+}
+
+// sanitizeTagValue replaces the characters which can't be used in a value of the Graphite tags.
+func sanitizeTagValue(s string) string {
+	return tagValueInvalidRegex.ReplaceAllString(s, "_")
+}
+
+// Name returns the Graphite name of the specified field of the point.
+// The tags which aren't referred in the template are inserted at 'tags' in the order of the keys.
+func (t *Template) Name(point *Point, field string) string {
+	tags := map[string]string{}
+	for _, tag := range point.Tags {
+		tags[tag.Key] = tag.Value
+	}
+
+	nodes := []string{}
+	tagsIdx := -1
+	for _, part := range t.parts {
+		switch part {
+		case templateMeasurement:
+			nodes = append(nodes, sanitizeNode(point.Measurement))
+		case templateField:
+			if field != templateValueField {
+				nodes = append(nodes, sanitizeNode(field))
+			}
+		case templateTags:
+			tagsIdx = len(nodes)
+		default:
+			value, ok := tags[part]
+			if ok {
+				nodes = append(nodes, sanitizeNode(value))
+				delete(tags, part)
+			}
+		}
+	}
+
+	if 0 <= tagsIdx {
+		keys := make([]string, 0, len(tags))
+		for key := range tags {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		values := make([]string, 0, len(keys))
+		for _, key := range keys {
+			values = append(values, sanitizeNode(tags[key]))
+		}
+		nodes = append(nodes[:tagsIdx], append(values, nodes[tagsIdx:]...)...)
+	}
+
+	return strings.Join(nodes, templateSep)
+}
+
+// Mapper converts the points to the Graphite metrics with the templates, or to the tagged series
+// such as 'cpu.usage_idle;host=server01' when the tag support is enabled.
+type Mapper struct {
+	prefix     string
+	templates  []*Template
+	defaultTpl *Template
+	tagSupport bool
+}
+
+// NewMapper returns a new mapper with the default template.
+func NewMapper() *Mapper {
+	defaultTpl, _ := NewTemplate(DefaultTemplate)
+	return &Mapper{
+		prefix:     "",
+		templates:  []*Template{},
+		defaultTpl: defaultTpl,
+		tagSupport: false,
+	}
+}
+
+// SetPrefix sets the prefix of all names.
+func (mapper *Mapper) SetPrefix(prefix string) {
+	mapper.prefix = prefix
+}
+
+// SetTemplates sets the templates which are tried in order. The last template which has no filter is used as the default template.
+func (mapper *Mapper) SetTemplates(templates []string) error {
+	tpls := []*Template{}
+	for _, s := range templates {
+		t, err := NewTemplate(s)
+		if err != nil {
+			return err
+		}
+		if t.filter == nil {
+			mapper.defaultTpl = t
+			continue
+		}
+		tpls = append(tpls, t)
+	}
+	mapper.templates = tpls
+	return nil
+}
+
+// SetTagSupport sets whether the points are converted to the tagged series instead of the templates.
+func (mapper *Mapper) SetTagSupport(flag bool) {
+	mapper.tagSupport = flag
+}
+
+// Template returns the template of the specified measurement.
+func (mapper *Mapper) Template(measurement string) *Template {
+	for _, t := range mapper.templates {
+		if t.Match(measurement) {
+			return t
+		}
+	}
+	return mapper.defaultTpl
+}
+
+func (mapper *Mapper) taggedName(point *Point, field string) string {
+	name := sanitizeNode(point.Measurement)
+	if field != templateValueField {
+		name += templateSep + sanitizeNode(field)
+	}
+	for _, tag := range point.Tags {
+		name += graphiteTagSep + sanitizeNode(tag.Key) + graphiteTagValue + sanitizeTagValue(tag.Value)
+	}
+	return name
+}
+
+// Name returns the Graphite name of the specified field of the point.
+func (mapper *Mapper) Name(point *Point, field string) string {
+	var name string
+	if mapper.tagSupport {
+		name = mapper.taggedName(point, field)
+	} else {
+		name = mapper.Template(point.Measurement).Name(point, field)
+	}
+	if len(mapper.prefix) == 0 {
+		return name
+	}
+	return mapper.prefix + templateSep + name
+}
+
+// Metrics returns the Graphite metrics of the numeric fields of the specified points. The string fields are skipped.
+func (mapper *Mapper) Metrics(points []*Point) []*graphite.Metrics {
+	ms := []*graphite.Metrics{}
+	for _, point := range points {
+		for _, field := range point.Fields {
+			value, ok := field.Float()
+			if !ok {
+				continue
+			}
+			name := mapper.Name(point, field.Key)
+			if len(name) == 0 {
+				continue
+			}
+			m := graphite.NewMetrics()
+			m.SetName(name)
+			dp := graphite.NewDataPoint()
+			dp.SetTimestamp(point.Timestamp)
+			dp.SetValue(value)
+			m.AddDataPoint(dp)
+			ms = append(ms, m)
+		}
+	}
+	return ms
+}
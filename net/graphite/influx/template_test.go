@@ -0,0 +1,72 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package influx
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMapperTemplates(t *testing.T) {
+	point, err := ParsePoint("cpu,cpu=cpu0,datacenter=us-west,host=server01.example.com usage_idle=92.5,value=1", time.Nanosecond, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		templates []string
+		expected  []string
+	}{
+		{
+			[]string{},
+			[]string{"server01_example_com.cpu0.us-west.cpu.usage_idle", "server01_example_com.cpu0.us-west.cpu"},
+		},
+		{
+			[]string{"cpu host.measurement.tags.field", "measurement.field"},
+			[]string{"server01_example_com.cpu.cpu0.us-west.usage_idle", "server01_example_com.cpu.cpu0.us-west"},
+		},
+		{
+			[]string{"mem* host.measurement.field", "datacenter.measurement.field"},
+			[]string{"us-west.cpu.usage_idle", "us-west.cpu"},
+		},
+	}
+
+	for _, tc := range testCases {
+		mapper := NewMapper()
+		err := mapper.SetTemplates(tc.templates)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ms := mapper.Metrics([]*Point{point})
+		if len(ms) != len(tc.expected) {
+			t.Fatal(fmt.Errorf("%d != %d", len(ms), len(tc.expected)))
+		}
+		for n, name := range tc.expected {
+			if ms[n].Name != name {
+				t.Error(fmt.Errorf("%v : %s != %s", tc.templates, ms[n].Name, name))
+			}
+		}
+	}
+}
+
+func TestMapperTagSupport(t *testing.T) {
+	point, err := ParsePoint(`disk,host=server01.example.com,path=/var used=10,msg="full"`, time.Nanosecond, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mapper := NewMapper()
+	mapper.SetPrefix("telegraf")
+	mapper.SetTagSupport(true)
+	ms := mapper.Metrics([]*Point{point})
+	if len(ms) != 1 {
+		t.Fatal(fmt.Errorf("%d != %d", len(ms), 1))
+	}
+	expected := "telegraf.disk.used;host=server01.example.com;path=_var"
+	if ms[0].Name != expected {
+		t.Error(fmt.Errorf("%s != %s", ms[0].Name, expected))
+	}
+}
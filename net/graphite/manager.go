@@ -18,9 +18,14 @@ const (
 type Manager struct {
 	*Config
 
-	httpListeners  map[string]RenderHTTPRequestListener
-	CarbonListener CarbonListener
-	RenderListener RenderRequestListener
+	httpListeners    map[string]RenderHTTPRequestListener
+	CarbonListener   CarbonListener
+	CarbonProcessors []CarbonProcessor
+	RenderListener   RenderRequestListener
+	MetricIndex      *MetricIndex
+	Authenticators   []RenderAuthenticator
+	PathRules        *PathRules
+	Tenancy          *Tenancy
 
 	Servers []*Server
 }
@@ -30,9 +35,14 @@ func NewManager() *Manager {
 	mgr := &Manager{
 		Config: NewDefaultConfig(),
 
-		httpListeners:  map[string]RenderHTTPRequestListener{},
-		CarbonListener: nil,
-		RenderListener: nil,
+		httpListeners:    map[string]RenderHTTPRequestListener{},
+		CarbonListener:   nil,
+		CarbonProcessors: []CarbonProcessor{},
+		RenderListener:   nil,
+		MetricIndex:      nil,
+		Authenticators:   []RenderAuthenticator{},
+		PathRules:        nil,
+		Tenancy:          nil,
 
 		Servers: make([]*Server, 0),
 	}
@@ -64,6 +74,17 @@ func (mgr *Manager) SetCarbonListener(l CarbonListener) error {
 	return nil
 }
 
+// AddCarbonProcessor appends a processor to the ingest path of all servers.
+func (mgr *Manager) AddCarbonProcessor(processor CarbonProcessor) error {
+	mgr.CarbonProcessors = append(mgr.CarbonProcessors, processor)
+
+	for _, server := range mgr.Servers {
+		server.SetCarbonProcessors(mgr.CarbonProcessors)
+	}
+
+	return nil
+}
+
 // SetRenderListener sets a default listener.
 func (mgr *Manager) SetRenderListener(l RenderRequestListener) error {
 	mgr.RenderListener = l
@@ -75,6 +96,50 @@ func (mgr *Manager) SetRenderListener(l RenderRequestListener) error {
 	return nil
 }
 
+// SetMetricIndex sets an index which is shared by all servers.
+func (mgr *Manager) SetMetricIndex(idx *MetricIndex) error {
+	mgr.MetricIndex = idx
+
+	for _, server := range mgr.Servers {
+		server.SetMetricIndex(idx)
+	}
+
+	return nil
+}
+
+// AddAuthenticator appends an authenticator of the Render requests to all servers.
+func (mgr *Manager) AddAuthenticator(authenticator RenderAuthenticator) error {
+	mgr.Authenticators = append(mgr.Authenticators, authenticator)
+
+	for _, server := range mgr.Servers {
+		server.SetAuthenticators(mgr.Authenticators)
+	}
+
+	return nil
+}
+
+// SetPathRules sets the authorization rules of the metrics which are shared by all servers.
+func (mgr *Manager) SetPathRules(rules *PathRules) error {
+	mgr.PathRules = rules
+
+	for _, server := range mgr.Servers {
+		server.SetPathRules(rules)
+	}
+
+	return nil
+}
+
+// SetTenancy sets the tenancy which is shared by all servers. The new tenant ports are opened when the servers are restarted.
+func (mgr *Manager) SetTenancy(tenancy *Tenancy) error {
+	mgr.Tenancy = tenancy
+
+	for _, server := range mgr.Servers {
+		server.SetTenancy(tenancy)
+	}
+
+	return nil
+}
+
 // GetBoundAddress returns a listen address.
 func (mgr *Manager) GetBoundAddress() (string, error) {
 	// FIXME : Return an appropriate address instead of addrs[0]
@@ -140,7 +205,12 @@ func (mgr *Manager) StartWithInterface(ifi *net.Interface) (*Server, error) {
 	server.SetConfig(mgr.Config)
 	server.SetHTTPRequestListeners(mgr.httpListeners)
 	server.SetCarbonListener(mgr.CarbonListener)
+	server.SetCarbonProcessors(mgr.CarbonProcessors)
 	server.SetRenderListener(mgr.RenderListener)
+	server.SetMetricIndex(mgr.MetricIndex)
+	server.SetAuthenticators(mgr.Authenticators)
+	server.SetPathRules(mgr.PathRules)
+	server.SetTenancy(mgr.Tenancy)
 
 	startupError := fmt.Errorf(errorManagerNotRunning)
 	for n := 0; n <= mgr.BindingRetryCount; n++ {
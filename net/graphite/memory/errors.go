@@ -2,13 +2,9 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-// Package metric provides query interfaces for metric store.
-package metric
+package memory
 
-import (
-	"testing"
+const (
+	errorInvalidXFilesFactor = "invalid xFilesFactor : %f"
+	errorInvalidSchema       = "schema [%s] has invalid retentions : %s"
 )
-
-func TestNewDataPoint(t *testing.T) {
-	NewDataPoint()
-}
@@ -0,0 +1,127 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package memory
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cybergarage/go-graphite/net/graphite"
+	"github.com/cybergarage/go-graphite/net/graphite/storage"
+	"github.com/cybergarage/go-graphite/net/graphite/whisper"
+)
+
+// Rollup is a set of series from the highest precision, which aggregates the ingested datapoints into the lower precision series continuously like the Whisper archives.
+type Rollup struct {
+	sync.Mutex
+	name              string
+	archives          []*Series
+	xFilesFactor      float64
+	aggregationMethod whisper.AggregationMethod
+}
+
+// NewRollup returns a new rollup of the specified retentions, which must be validated by storage.ValidateRetentions.
+func NewRollup(name string, retentions []*storage.Retention, xff float64, method whisper.AggregationMethod) *Rollup {
+	archives := make([]*Series, len(retentions))
+	for n, r := range retentions {
+		archives[n] = NewSeries(name, r.Precision(), r.Points)
+	}
+	return &Rollup{
+		Mutex:             sync.Mutex{},
+		name:              name,
+		archives:          archives,
+		xFilesFactor:      xff,
+		aggregationMethod: method,
+	}
+}
+
+// GetName returns the series name.
+func (r *Rollup) GetName() string {
+	return r.name
+}
+
+// GetArchives returns the series from the highest precision.
+func (r *Rollup) GetArchives() []*Series {
+	return r.archives
+}
+
+// GetXFilesFactor returns the ratio of the known values to aggregate them into a lower precision.
+func (r *Rollup) GetXFilesFactor() float64 {
+	return r.xFilesFactor
+}
+
+// GetAggregationMethod returns the method to aggregate the values into the lower precisions.
+func (r *Rollup) GetAggregationMethod() whisper.AggregationMethod {
+	return r.aggregationMethod
+}
+
+// Add sets the value into the highest precision series, and aggregates it into the lower precision series.
+// Add returns false when the slot of the highest precision series holds a newer datapoint.
+func (r *Rollup) Add(ts int64, value float64) bool {
+	r.Lock()
+	defer r.Unlock()
+
+	if !r.archives[0].Add(ts, value) {
+		return false
+	}
+
+	higher := r.archives[0]
+	for _, lower := range r.archives[1:] {
+		if !r.propagate(ts, higher, lower) {
+			break
+		}
+		higher = lower
+	}
+
+	return true
+}
+
+// propagate aggregates the values of the higher precision series in the lower interval of the specified timestamp,
+// and returns true when the aggregated value is set into the lower precision series.
+func (r *Rollup) propagate(ts int64, higher *Series, lower *Series) bool {
+	lowerStart := lower.Align(ts)
+	values, known := higher.rangeValues(lowerStart, int(lower.resolution/higher.resolution))
+	if known == 0 {
+		return false
+	}
+	if float64(known)/float64(len(values)) < r.xFilesFactor {
+		return false
+	}
+	return lower.Add(lowerStart, r.aggregationMethod.Aggregate(values))
+}
+
+// AddDataPoints sets all the specified datapoints, and returns the number of the stored datapoints.
+func (r *Rollup) AddDataPoints(dps []*graphite.DataPoint) int {
+	count := 0
+	for _, dp := range dps {
+		if dp == nil {
+			continue
+		}
+		if r.Add(dp.UnixTimestamp(), dp.Value) {
+			count++
+		}
+	}
+	return count
+}
+
+// SelectArchive returns the highest precision series whose retention covers the specified from time in the same way as Whisper.
+func (r *Rollup) SelectArchive(from int64, now int64) *Series {
+	age := now - from
+	for _, s := range r.archives {
+		if age <= s.resolution*int64(len(s.timestamps)) {
+			return s
+		}
+	}
+	return r.archives[len(r.archives)-1]
+}
+
+// Fetch returns the datapoints in the specified range from the highest precision series which covers the range.
+func (r *Rollup) Fetch(from int64, until int64) []*graphite.DataPoint {
+	return r.fetch(from, until, time.Now().Unix())
+}
+
+func (r *Rollup) fetch(from int64, until int64, now int64) []*graphite.DataPoint {
+	return r.SelectArchive(from, now).Fetch(from, until)
+}
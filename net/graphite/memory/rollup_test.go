@@ -0,0 +1,173 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package memory
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/cybergarage/go-graphite/net/graphite"
+	"github.com/cybergarage/go-graphite/net/graphite/storage"
+	"github.com/cybergarage/go-graphite/net/graphite/whisper"
+)
+
+func TestRollup(t *testing.T) {
+	retentions, err := storage.ParseRetentions("1s:1m,10s:10m,1m:1h")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		method   whisper.AggregationMethod
+		expected float64
+	}{
+		{whisper.Average, 4.5},
+		{whisper.Sum, 45},
+		{whisper.Min, 0},
+		{whisper.Max, 9},
+		{whisper.Last, 9},
+	}
+
+	start := int64(1500000000 - (1500000000 % 60))
+	for _, tc := range testCases {
+		r := NewRollup("a.b", retentions, 0.5, tc.method)
+		for n := range 10 {
+			if !r.Add(start+int64(n), float64(n)) {
+				t.Error(fmt.Errorf("%s : %d is not added", tc.method, n))
+			}
+		}
+
+		dps := r.GetArchives()[1].Fetch(start, start)
+		if len(dps) != 1 || dps[0].Value != tc.expected {
+			t.Error(fmt.Errorf("%s : %v", tc.method, dps))
+		}
+
+		// 1 of the 6 intervals is known, and it doesn't satisfy the xFilesFactor
+		dps = r.GetArchives()[2].Fetch(start, start)
+		if len(dps) != 1 || !math.IsNaN(dps[0].Value) {
+			t.Error(fmt.Errorf("%s : %v", tc.method, dps))
+		}
+	}
+
+	// Rolled up into the minute when the half of the intervals are known
+
+	r := NewRollup("a.b", retentions, 0.5, whisper.Sum)
+	for n := range 30 {
+		r.Add(start+int64(n), 1)
+	}
+	dps := r.GetArchives()[2].Fetch(start, start)
+	if len(dps) != 1 || dps[0].Value != 30 {
+		t.Error(fmt.Errorf("%v", dps))
+	}
+
+	// The queries use the highest precision which covers the range
+
+	now := start + 30
+	selectCases := []struct {
+		from       int64
+		resolution time.Duration
+	}{
+		{now - 30, time.Second},
+		{now - 60, time.Second},
+		{now - 61, time.Second * 10},
+		{now - 600, time.Second * 10},
+		{now - 3600, time.Minute},
+		{now - 7200, time.Minute},
+	}
+	for _, sc := range selectCases {
+		s := r.SelectArchive(sc.from, now)
+		if s.GetResolution() != sc.resolution {
+			t.Error(fmt.Errorf("%d : %s != %s", now-sc.from, s.GetResolution(), sc.resolution))
+		}
+	}
+
+	dps = r.fetch(now-3600, now, now)
+	if len(dps) != 60 || dps[59].Value != 30 {
+		t.Error(fmt.Errorf("%d : %v", len(dps), dps[len(dps)-1]))
+	}
+}
+
+func TestStoreRollups(t *testing.T) {
+	store := NewStore()
+	store.SetResolution(time.Second)
+	store.SetRetention(time.Minute)
+
+	err := store.SetRollups([]*storage.Retention{storage.NewRetention(10, 60), storage.NewRetention(7, 60)})
+	if err == nil {
+		t.Error(fmt.Errorf("invalid rollups are set"))
+	}
+	err = store.SetRollups([]*storage.Retention{storage.NewRetention(10, 60)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.SetAggregationMethod("max")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().Unix()
+	now -= now % 10
+	line := ""
+	for n := range 10 {
+		line += fmt.Sprintf("a.b %d %d\n", n, now+int64(n))
+	}
+	server := graphite.NewServer()
+	server.SetStore(store)
+	_, err = server.FeedPlainTextString(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	from := time.Unix(now-300, 0)
+	until := time.Unix(now+10, 0)
+	q := graphite.NewQuery()
+	q.Target = "a.b"
+	q.From = &from
+	q.Until = &until
+	ms, err := store.QueryMetricsRequestReceived(q, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ms) != 1 {
+		t.Fatal(fmt.Errorf("%d != %d", len(ms), 1))
+	}
+	dps := ms[0].DataPoints
+	if len(dps) != 32 || dps[30].Value != 9 {
+		t.Error(fmt.Errorf("%d : %v", len(dps), dps[30]))
+	}
+}
+
+func TestStoreResolver(t *testing.T) {
+	store := NewStore()
+	store.SetResolution(time.Second)
+	store.SetRetention(time.Minute)
+
+	for _, retentions := range [][]*storage.Retention{
+		{},
+		{storage.NewRetention(10, 60), storage.NewRetention(7, 60)},
+	} {
+		resolver := storage.NewResolver()
+		resolver.Schemas = append(resolver.Schemas, storage.NewSchema("invalid", retentions))
+		err := store.SetResolver(resolver)
+		if err == nil {
+			t.Error(fmt.Errorf("invalid retentions are set : %v", retentions))
+		}
+	}
+
+	// The rules changed after SetResolver fall back to the base retention of the store
+	resolver := storage.NewResolver()
+	err := store.SetResolver(resolver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolver.Schemas = append(resolver.Schemas, storage.NewSchema("empty", []*storage.Retention{}))
+	r := store.getOrCreateRollup("a.b")
+	archives := r.GetArchives()
+	if len(archives) != 1 || archives[0].GetResolution() != time.Second || archives[0].GetSlotCount() != 60 {
+		t.Error(fmt.Errorf("%v", archives))
+	}
+}
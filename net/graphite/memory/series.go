@@ -0,0 +1,177 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package memory
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/cybergarage/go-graphite/net/graphite"
+)
+
+const (
+	seriesEmptySlot int64 = math.MinInt64
+)
+
+// Series is a ring buffer of datapoints at a fixed resolution.
+type Series struct {
+	sync.RWMutex
+	name       string
+	resolution int64
+	timestamps []int64
+	values     []float64
+}
+
+// NewSeries returns a new series which keeps the specified number of slots at the specified resolution.
+func NewSeries(name string, resolution time.Duration, slots int) *Series {
+	s := &Series{
+		RWMutex:    sync.RWMutex{},
+		name:       name,
+		resolution: max(int64(resolution/time.Second), 1),
+		timestamps: make([]int64, max(slots, 1)),
+		values:     make([]float64, max(slots, 1)),
+	}
+	for n := range s.timestamps {
+		s.timestamps[n] = seriesEmptySlot
+	}
+	return s
+}
+
+// GetName returns the series name.
+func (s *Series) GetName() string {
+	return s.name
+}
+
+// GetResolution returns the resolution of the series.
+func (s *Series) GetResolution() time.Duration {
+	return time.Duration(s.resolution) * time.Second
+}
+
+// GetSlotCount returns the number of the slots.
+func (s *Series) GetSlotCount() int {
+	return len(s.timestamps)
+}
+
+// Align returns the slot timestamp of the specified unix time.
+func (s *Series) Align(ts int64) int64 {
+	aligned := ts - (ts % s.resolution)
+	if ts < 0 && aligned != ts {
+		aligned -= s.resolution
+	}
+	return aligned
+}
+
+func (s *Series) slotIndex(aligned int64) int {
+	idx := (aligned / s.resolution) % int64(len(s.timestamps))
+	if idx < 0 {
+		idx += int64(len(s.timestamps))
+	}
+	return int(idx)
+}
+
+// Add sets the value into the slot of the specified timestamp, and returns false when the slot holds a newer datapoint.
+func (s *Series) Add(ts int64, value float64) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	aligned := s.Align(ts)
+	idx := s.slotIndex(aligned)
+	if aligned < s.timestamps[idx] {
+		return false
+	}
+	s.timestamps[idx] = aligned
+	s.values[idx] = value
+
+	return true
+}
+
+// AddDataPoints sets all the specified datapoints, and returns the number of the stored datapoints.
+func (s *Series) AddDataPoints(dps []*graphite.DataPoint) int {
+	count := 0
+	for _, dp := range dps {
+		if dp == nil {
+			continue
+		}
+		if s.Add(dp.UnixTimestamp(), dp.Value) {
+			count++
+		}
+	}
+	return count
+}
+
+// rangeValues returns the values of the specified number of steps from the specified timestamp, and the number of the known values.
+// The steps which have no datapoint are NaN.
+func (s *Series) rangeValues(start int64, count int) ([]float64, int) {
+	s.RLock()
+	defer s.RUnlock()
+
+	values := make([]float64, count)
+	known := 0
+	ts := s.Align(start)
+	for n := range values {
+		values[n] = math.NaN()
+		idx := s.slotIndex(ts)
+		if s.timestamps[idx] == ts {
+			values[n] = s.values[idx]
+			known++
+		}
+		ts += s.resolution
+	}
+
+	return values, known
+}
+
+// Fetch returns the datapoints of each resolution step in the specified range.
+// The steps which have no datapoint are filled with NaN.
+func (s *Series) Fetch(from, until int64) []*graphite.DataPoint {
+	s.RLock()
+	defer s.RUnlock()
+
+	start := s.Align(from)
+	end := s.Align(until)
+	// Like Whisper, the range is clamped to the retention of the series.
+	oldest := end - (int64(len(s.timestamps)-1) * s.resolution)
+	if start < oldest {
+		start = oldest
+	}
+	dps := graphite.NewDataPoints(0)
+	for ts := start; ts <= end; ts += s.resolution {
+		dp := graphite.NewDataPoint()
+		dp.SetTimestamp(time.Unix(ts, 0))
+		dp.SetValue(math.NaN())
+		idx := s.slotIndex(ts)
+		if s.timestamps[idx] == ts {
+			dp.SetValue(s.values[idx])
+		}
+		dps = append(dps, dp)
+	}
+
+	return dps
+}
+
+// Last returns the latest datapoint, or nil when the series has no datapoint.
+func (s *Series) Last() *graphite.DataPoint {
+	s.RLock()
+	defer s.RUnlock()
+
+	last := -1
+	for n, ts := range s.timestamps {
+		if ts == seriesEmptySlot {
+			continue
+		}
+		if last < 0 || s.timestamps[last] < ts {
+			last = n
+		}
+	}
+	if last < 0 {
+		return nil
+	}
+
+	dp := graphite.NewDataPoint()
+	dp.SetTimestamp(time.Unix(s.timestamps[last], 0))
+	dp.SetValue(s.values[last])
+	return dp
+}
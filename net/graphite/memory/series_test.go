@@ -0,0 +1,54 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package memory
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSeries(t *testing.T) {
+	s := NewSeries("a.b", time.Second*10, 6)
+
+	now := s.Align(time.Now().Unix())
+	for n := range 10 {
+		ts := now - int64((9-n)*10)
+		if !s.Add(ts+3, float64(n)) {
+			t.Error(fmt.Errorf("%d", ts))
+		}
+	}
+
+	if s.Add(now-90, 0) {
+		t.Error(fmt.Errorf("an expired datapoint is stored"))
+	}
+
+	dps := s.Fetch(now-200, now)
+	if len(dps) != 6 {
+		t.Error(fmt.Errorf("%d != %d", len(dps), 6))
+		return
+	}
+
+	for n, dp := range dps {
+		expected := float64(n + 4)
+		if dp.Value != expected {
+			t.Error(fmt.Errorf("[%d] %f != %f", n, dp.Value, expected))
+		}
+		if dp.UnixTimestamp() != now-int64((5-n)*10) {
+			t.Error(fmt.Errorf("[%d] %d", n, dp.UnixTimestamp()))
+		}
+	}
+
+	dps = s.Fetch(now, now+20)
+	if len(dps) != 3 || !math.IsNaN(dps[1].Value) || !math.IsNaN(dps[2].Value) {
+		t.Error(fmt.Errorf("%v", dps))
+	}
+
+	last := s.Last()
+	if last == nil || last.Value != 9 {
+		t.Error(fmt.Errorf("%v", last))
+	}
+}
@@ -0,0 +1,304 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package memory provides a pure Go in-memory time series store for Graphite servers.
+package memory
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cybergarage/go-graphite/net/graphite"
+	"github.com/cybergarage/go-graphite/net/graphite/storage"
+	"github.com/cybergarage/go-graphite/net/graphite/whisper"
+)
+
+const (
+	// DefaultResolution is the default resolution of the series.
+	DefaultResolution = time.Second * 10
+	// DefaultRetention is the default retention period of the series.
+	DefaultRetention = time.Hour * 24
+	// DefaultQueryRange is the default range of the queries which have no from or until times.
+	DefaultQueryRange = time.Hour * 24
+)
+
+const (
+	storeRuleName = "store"
+)
+
+// Store is an in-memory time series store which implements graphite.CarbonListener and graphite.RenderRequestListener.
+// The ingested datapoints are rolled up into the lower precision series, and the queries read the highest precision series which covers the range.
+type Store struct {
+	sync.RWMutex
+	resolution        time.Duration
+	retention         time.Duration
+	rollups           []*storage.Retention
+	xFilesFactor      float64
+	aggregationMethod whisper.AggregationMethod
+	resolver          *storage.Resolver
+	series            map[string]*Rollup
+	index             *graphite.MetricIndex
+}
+
+// NewStore returns a new empty store with the default resolution and retention.
+func NewStore() *Store {
+	store := &Store{
+		RWMutex:           sync.RWMutex{},
+		resolution:        DefaultResolution,
+		retention:         DefaultRetention,
+		rollups:           []*storage.Retention{},
+		xFilesFactor:      storage.DefaultXFilesFactor,
+		aggregationMethod: whisper.DefaultAggregationMethod,
+		resolver:          storage.NewResolver(),
+		series:            map[string]*Rollup{},
+		index:             graphite.NewMetricIndex(),
+	}
+	return store
+}
+
+// SetResolution sets the resolution of the new series, which is rounded to seconds.
+func (store *Store) SetResolution(d time.Duration) {
+	store.Lock()
+	defer store.Unlock()
+	store.resolution = max(d.Truncate(time.Second), time.Second)
+}
+
+// GetResolution returns the resolution of the new series.
+func (store *Store) GetResolution() time.Duration {
+	store.RLock()
+	defer store.RUnlock()
+	return store.resolution
+}
+
+// SetRetention sets the retention period of the new series.
+func (store *Store) SetRetention(d time.Duration) {
+	store.Lock()
+	defer store.Unlock()
+	store.retention = d
+}
+
+// GetRetention returns the retention period of the new series.
+func (store *Store) GetRetention() time.Duration {
+	store.RLock()
+	defer store.RUnlock()
+	return store.retention
+}
+
+// SetRollups sets the lower precision retentions of the new series such as '1m:7d,10m:30d,1h:1y'.
+// The retentions must follow the rules of the Whisper archives together with the resolution and retention of the store.
+func (store *Store) SetRollups(rollups []*storage.Retention) error {
+	store.Lock()
+	defer store.Unlock()
+
+	retentions := append([]*storage.Retention{store.baseRetention()}, rollups...)
+	err := storage.ValidateRetentions(retentions)
+	if err != nil {
+		return err
+	}
+	store.rollups = retentions[1:]
+
+	return nil
+}
+
+// GetRollups returns the lower precision retentions of the new series.
+func (store *Store) GetRollups() []*storage.Retention {
+	store.RLock()
+	defer store.RUnlock()
+	return store.rollups
+}
+
+// SetXFilesFactor sets the ratio of the known values to roll up them into the lower precisions.
+func (store *Store) SetXFilesFactor(xff float64) error {
+	if xff < 0 || 1 < xff {
+		return fmt.Errorf(errorInvalidXFilesFactor, xff)
+	}
+	store.Lock()
+	defer store.Unlock()
+	store.xFilesFactor = xff
+	return nil
+}
+
+// SetAggregationMethod sets the method to roll up the values into the lower precisions such as 'average', 'sum', 'min', 'max' or 'last'.
+func (store *Store) SetAggregationMethod(name string) error {
+	method, err := whisper.ParseAggregationMethod(name)
+	if err != nil {
+		return err
+	}
+	store.Lock()
+	defer store.Unlock()
+	store.aggregationMethod = method
+	return nil
+}
+
+// SetResolver sets the storage-schemas.conf and storage-aggregation.conf rules to decide the retentions, xFilesFactor and aggregation method of the new series.
+// The rules take precedence over the settings of the store, which are the rules of the unmatched series.
+// SetResolver returns an error when a schema has no retentions or invalid retentions.
+func (store *Store) SetResolver(resolver *storage.Resolver) error {
+	if resolver == nil {
+		resolver = storage.NewResolver()
+	}
+	for _, schema := range resolver.Schemas {
+		err := storage.ValidateRetentions(append([]*storage.Retention{}, schema.Retentions...))
+		if err != nil {
+			return fmt.Errorf(errorInvalidSchema, schema.Name, err)
+		}
+	}
+	store.Lock()
+	defer store.Unlock()
+	store.resolver = resolver
+	return nil
+}
+
+// GetMetricIndex returns the index of the stored series names.
+func (store *Store) GetMetricIndex() *graphite.MetricIndex {
+	store.RLock()
+	defer store.RUnlock()
+	return store.index
+}
+
+// GetSeries returns the highest precision series of the specified name.
+func (store *Store) GetSeries(name string) (*Series, bool) {
+	r, ok := store.GetRollup(name)
+	if !ok {
+		return nil, false
+	}
+	return r.GetArchives()[0], true
+}
+
+// GetRollup returns the rollup of the specified name.
+func (store *Store) GetRollup(name string) (*Rollup, bool) {
+	store.RLock()
+	defer store.RUnlock()
+	r, ok := store.series[name]
+	return r, ok
+}
+
+// GetSeriesCount returns the number of the stored series.
+func (store *Store) GetSeriesCount() int {
+	store.RLock()
+	defer store.RUnlock()
+	return len(store.series)
+}
+
+// Clear removes all series. The index is cleared in place because it may be shared with the servers.
+func (store *Store) Clear() {
+	store.Lock()
+	defer store.Unlock()
+	store.series = map[string]*Rollup{}
+	for _, name := range store.index.Snapshot() {
+		store.index.Delete(name)
+	}
+}
+
+// AddMetrics stores all datapoints of the specified metrics.
+func (store *Store) AddMetrics(m *graphite.Metrics) {
+	r := store.getOrCreateRollup(m.Name)
+	r.AddDataPoints(m.DataPoints)
+}
+
+func (store *Store) baseRetention() *storage.Retention {
+	secondsPerPoint := int(store.resolution / time.Second)
+	return storage.NewRetention(secondsPerPoint, max(int(store.retention/store.resolution), 1))
+}
+
+func (store *Store) getOrCreateRollup(name string) *Rollup {
+	r, ok := store.GetRollup(name)
+	if ok {
+		return r
+	}
+
+	store.Lock()
+	defer store.Unlock()
+
+	r, ok = store.series[name]
+	if ok {
+		return r
+	}
+
+	retentions, xff, method := newPolicyParameters(store.policyResolver().Resolve(name), store.baseRetention(), store.aggregationMethod)
+	r = NewRollup(name, retentions, xff, method)
+	store.series[name] = r
+	store.index.Insert(name)
+
+	return r
+}
+
+// policyResolver returns the resolver set by SetResolver, which rolls up the unmatched series
+// with the base and rollup retentions, xFilesFactor and aggregation method of the store.
+func (store *Store) policyResolver() *storage.Resolver {
+	retentions := append([]*storage.Retention{store.baseRetention()}, store.rollups...)
+	return store.resolver.WithDefaults(
+		storage.NewSchema(storeRuleName, retentions),
+		storage.NewAggregation(storeRuleName, store.xFilesFactor, store.aggregationMethod.String()))
+}
+
+// newPolicyParameters returns the rollup parameters of the specified policy.
+// The policy which has invalid retentions, such as the rules changed after SetResolver, uses the specified base retention,
+// and the unknown aggregation method falls back to the specified one.
+func newPolicyParameters(policy *storage.Policy, base *storage.Retention, method whisper.AggregationMethod) ([]*storage.Retention, float64, whisper.AggregationMethod) {
+	retentions := append([]*storage.Retention{}, policy.Retentions()...)
+	err := storage.ValidateRetentions(retentions)
+	if err != nil {
+		retentions = []*storage.Retention{base}
+	}
+	policyMethod, err := whisper.ParseAggregationMethod(policy.AggregationMethod())
+	if err == nil {
+		method = policyMethod
+	}
+	return retentions, policy.XFilesFactor(), method
+}
+
+// InsertMetricsRequestReceived stores the ingested metrics.
+func (store *Store) InsertMetricsRequestReceived(ms []*graphite.Metrics, err error) {
+	for _, m := range ms {
+		if m == nil || len(m.Name) == 0 {
+			continue
+		}
+		store.AddMetrics(m)
+	}
+}
+
+// FindMetricsRequestReceived returns the series names which match the query target.
+func (store *Store) FindMetricsRequestReceived(query *graphite.Query, err error) ([]*graphite.Metrics, error) {
+	if err != nil {
+		return nil, err
+	}
+	return store.GetMetricIndex().FindMetrics(query.Target)
+}
+
+// QueryMetricsRequestReceived returns the datapoints of the series which match the query target.
+func (store *Store) QueryMetricsRequestReceived(query *graphite.Query, err error) ([]*graphite.Metrics, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	until := time.Now()
+	if query.Until != nil {
+		until = *query.Until
+	}
+	from := until.Add(-DefaultQueryRange)
+	if query.From != nil {
+		from = *query.From
+	}
+
+	names, err := store.GetMetricIndex().FindMetrics(query.Target)
+	if err != nil {
+		return nil, err
+	}
+
+	ms := []*graphite.Metrics{}
+	for _, name := range names {
+		r, ok := store.GetRollup(name.Name)
+		if !ok {
+			continue
+		}
+		m := graphite.NewMetrics()
+		m.SetName(r.GetName())
+		m.DataPoints = r.Fetch(from.Unix(), until.Unix())
+		ms = append(ms, m)
+	}
+
+	return ms, nil
+}
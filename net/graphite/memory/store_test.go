@@ -0,0 +1,106 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package memory
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cybergarage/go-graphite/net/graphite"
+)
+
+func TestStore(t *testing.T) {
+	store := NewStore()
+	store.SetResolution(time.Second)
+
+	server := graphite.NewServer()
+	server.SetStore(store)
+
+	now := time.Now().Unix()
+
+	var wg sync.WaitGroup
+	for n := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			line := fmt.Sprintf("servers.host%d.cpu %d %d\nservers.host%d.mem %d %d\n", n, n, now, n, n, now)
+			_, err := server.FeedPlainTextString(line)
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if store.GetSeriesCount() != 20 {
+		t.Error(fmt.Errorf("%d != %d", store.GetSeriesCount(), 20))
+	}
+
+	q := graphite.NewQuery()
+	q.Target = "servers.*.cpu"
+	ms, err := store.FindMetricsRequestReceived(q, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(ms) != 10 {
+		t.Error(fmt.Errorf("%d != %d", len(ms), 10))
+	}
+
+	from := time.Unix(now-4, 0)
+	until := time.Unix(now, 0)
+	q.Target = "servers.host{1,2}.cpu"
+	q.From = &from
+	q.Until = &until
+	ms, err = store.QueryMetricsRequestReceived(q, nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(ms) != 2 {
+		t.Error(fmt.Errorf("%d != %d", len(ms), 2))
+		return
+	}
+	for n, m := range ms {
+		if m.Name != fmt.Sprintf("servers.host%d.cpu", n+1) {
+			t.Error(fmt.Errorf("%s", m.Name))
+		}
+		if m.GetDataPointCount() != 5 {
+			t.Error(fmt.Errorf("%d != %d", m.GetDataPointCount(), 5))
+			continue
+		}
+		dp := m.DataPoints[4]
+		if dp.Value != float64(n+1) {
+			t.Error(fmt.Errorf("%f != %f", dp.Value, float64(n+1)))
+		}
+	}
+}
+
+func TestStoreMetricIndex(t *testing.T) {
+	store := NewStore()
+	server := graphite.NewServer()
+	server.SetStore(store)
+	server.SetMetricIndex(store.GetMetricIndex())
+
+	_, err := server.FeedPlainTextString(fmt.Sprintf("servers.host1.cpu 1 %d\n", time.Now().Unix()))
+	if err != nil {
+		t.Error(err)
+	}
+
+	// The branches are found by the shared index.
+	matches, err := server.GetMetricIndex().Find("*")
+	if err != nil {
+		t.Error(err)
+	}
+	if len(matches) != 1 || matches[0].Path != "servers" || !matches[0].IsBranch {
+		t.Error(fmt.Errorf("%v", matches))
+	}
+
+	store.Clear()
+	if server.GetMetricIndex() != store.GetMetricIndex() || server.GetMetricIndex().Len() != 0 {
+		t.Error(fmt.Errorf("%d", server.GetMetricIndex().Len()))
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package graphite
+
+import (
+	"sync"
+)
+
+// MetricIndex is a concurrency-safe index of metric names to answer find and index requests without storage accesses.
+type MetricIndex struct {
+	sync.RWMutex
+	tree *PathTree
+}
+
+// NewMetricIndex returns a new empty index.
+func NewMetricIndex() *MetricIndex {
+	idx := &MetricIndex{
+		RWMutex: sync.RWMutex{},
+		tree:    NewPathTree(),
+	}
+	return idx
+}
+
+// Insert adds the specified name, and returns true when the name is new, otherwise false.
+func (idx *MetricIndex) Insert(name string) bool {
+	idx.Lock()
+	defer idx.Unlock()
+	return idx.tree.Add(name)
+}
+
+// InsertMetrics adds the names of the specified metrics, and returns the number of the new names.
+func (idx *MetricIndex) InsertMetrics(ms []*Metrics) int {
+	idx.Lock()
+	defer idx.Unlock()
+	count := 0
+	for _, m := range ms {
+		if idx.tree.Add(m.Name) {
+			count++
+		}
+	}
+	return count
+}
+
+// Delete removes the specified name, and returns true when the name was found, otherwise false.
+func (idx *MetricIndex) Delete(name string) bool {
+	idx.Lock()
+	defer idx.Unlock()
+	return idx.tree.Remove(name)
+}
+
+// Has returns true whether the specified name is indexed, otherwise false.
+func (idx *MetricIndex) Has(name string) bool {
+	idx.RLock()
+	defer idx.RUnlock()
+	return idx.tree.Has(name)
+}
+
+// Find returns the nodes which match the specified Graphite path pattern.
+func (idx *MetricIndex) Find(pattern string) ([]*PathMatch, error) {
+	p, err := NewPathPattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return idx.FindPattern(p), nil
+}
+
+// FindPattern returns the nodes which match the specified compiled path pattern.
+func (idx *MetricIndex) FindPattern(p *PathPattern) []*PathMatch {
+	idx.RLock()
+	defer idx.RUnlock()
+	return p.Expand(idx.tree)
+}
+
+// FindMetrics returns the leaf names which match the specified Graphite path pattern or tag query as metrics.
+func (idx *MetricIndex) FindMetrics(pattern string) ([]*Metrics, error) {
+	if IsTagQuery(pattern) {
+		return idx.FindTaggedMetrics(pattern)
+	}
+	matches, err := idx.Find(pattern)
+	if err != nil {
+		return nil, err
+	}
+	ms := []*Metrics{}
+	for _, match := range matches {
+		if !match.IsLeaf {
+			continue
+		}
+		m := NewMetrics()
+		m.SetName(match.Path)
+		ms = append(ms, m)
+	}
+	return ms, nil
+}
+
+// FindTaggedMetrics returns the series which match the specified tag query such as "seriesByTag('name=cpu','host=~web.*')" as metrics.
+func (idx *MetricIndex) FindTaggedMetrics(target string) ([]*Metrics, error) {
+	q, err := ParseTagQuery(target)
+	if err != nil {
+		return nil, err
+	}
+	ms := []*Metrics{}
+	for _, name := range idx.Snapshot() {
+		if !q.Match(name) {
+			continue
+		}
+		m := NewMetrics()
+		m.SetName(name)
+		ms = append(ms, m)
+	}
+	return ms, nil
+}
+
+// ListWithPrefix returns the sorted names under the specified dotted prefix.
+func (idx *MetricIndex) ListWithPrefix(prefix string) []string {
+	idx.RLock()
+	defer idx.RUnlock()
+	return idx.tree.NamesWithPrefix(prefix)
+}
+
+// Children returns the sorted child node names of the specified dotted path.
+func (idx *MetricIndex) Children(path string) []string {
+	idx.RLock()
+	defer idx.RUnlock()
+	return idx.tree.Children(path)
+}
+
+// Len returns the total number of the indexed names.
+func (idx *MetricIndex) Len() int {
+	idx.RLock()
+	defer idx.RUnlock()
+	return idx.tree.Len()
+}
+
+// NodeCount returns the total number of the nodes including the branches.
+func (idx *MetricIndex) NodeCount() int {
+	idx.RLock()
+	defer idx.RUnlock()
+	return idx.tree.NodeCount()
+}
+
+// Snapshot returns all indexed names in the sorted order.
+func (idx *MetricIndex) Snapshot() []string {
+	idx.RLock()
+	defer idx.RUnlock()
+	return idx.tree.Names()
+}
@@ -0,0 +1,117 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package graphite
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestMetricIndex(t *testing.T) {
+	idx := NewMetricIndex()
+
+	var wg sync.WaitGroup
+	for n := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			idx.Insert(fmt.Sprintf("servers.host%d.cpu", n))
+			idx.Insert(fmt.Sprintf("servers.host%d.mem", n))
+		}()
+	}
+	wg.Wait()
+
+	if idx.Len() != 20 {
+		t.Error(fmt.Errorf("%d != %d", idx.Len(), 20))
+	}
+
+	matches, err := idx.Find("servers.host[0-4].cpu")
+	if err != nil {
+		t.Error(err)
+	}
+	if len(matches) != 5 {
+		t.Error(fmt.Errorf("%d != %d", len(matches), 5))
+	}
+
+	ms, err := idx.FindMetrics("servers.*")
+	if err != nil {
+		t.Error(err)
+	}
+	if len(ms) != 0 {
+		t.Error(fmt.Errorf("%d != %d", len(ms), 0))
+	}
+
+	if !idx.Delete("servers.host0.cpu") {
+		t.Error(fmt.Errorf("servers.host0.cpu is not deleted"))
+	}
+
+	names := idx.ListWithPrefix("servers.host0")
+	if len(names) != 1 || names[0] != "servers.host0.mem" {
+		t.Error(fmt.Errorf("%v", names))
+	}
+
+	snapshot := idx.Snapshot()
+	if len(snapshot) != 19 || snapshot[0] != "servers.host0.mem" {
+		t.Error(fmt.Errorf("%v", snapshot))
+	}
+}
+
+func TestMetricIndexRender(t *testing.T) {
+	idx := NewMetricIndex()
+
+	carbon := NewCarbon()
+	carbon.SetMetricIndex(idx)
+	_, err := carbon.FeedPlainTextString("a.b.c 1 1000000000\na.b.d 2 1000000000\na.e 3 1000000000\n")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	render := NewRender()
+	render.SetMetricIndex(idx)
+
+	req := httptest.NewRequest(http.MethodGet, renderDefaultFindRequestPath+"?query=a.*", nil)
+	res := httptest.NewRecorder()
+	render.ServeHTTP(res, req)
+	if res.Code != http.StatusOK {
+		t.Error(fmt.Errorf("%d", res.Code))
+		return
+	}
+
+	var findRes renderFindMetricJSONResponse
+	err = json.Unmarshal(res.Body.Bytes(), &findRes)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(findRes.Metrics) != 2 {
+		t.Error(fmt.Errorf("%v", findRes.Metrics))
+		return
+	}
+	if findRes.Metrics[0].Path != "a.b" || findRes.Metrics[0].IsLeaf != 0 {
+		t.Error(fmt.Errorf("%v", findRes.Metrics[0]))
+	}
+	if findRes.Metrics[1].Path != "a.e" || findRes.Metrics[1].IsLeaf != 1 {
+		t.Error(fmt.Errorf("%v", findRes.Metrics[1]))
+	}
+
+	req = httptest.NewRequest(http.MethodGet, renderDefaultIndexRequestPath, nil)
+	res = httptest.NewRecorder()
+	render.ServeHTTP(res, req)
+
+	var indexRes renderMetricIndexJSONResponse
+	err = json.Unmarshal(res.Body.Bytes(), &indexRes)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(indexRes) != 3 || indexRes[0] != "a.b.c" {
+		t.Error(fmt.Errorf("%v", indexRes))
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opentsdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	putCommand  = "put"
+	tagValueSep = "="
+	// The timestamps which don't fit in 32 bits are milliseconds like OpenTSDB.
+	secondsMask int64 = ^int64(0xFFFFFFFF)
+)
+
+// DataPoint represents a datapoint of the put requests of OpenTSDB.
+type DataPoint struct {
+	Metric    string            `json:"metric"`
+	Timestamp int64             `json:"timestamp"`
+	Value     json.RawMessage   `json:"value"`
+	Tags      map[string]string `json:"tags"`
+}
+
+// ParsePutLine returns a datapoint of the telnet style line such as 'put sys.cpu.user 1356998400 42.5 host=web01 cpu=0'.
+func ParsePutLine(line string) (*DataPoint, error) {
+	words := strings.Fields(line)
+	if len(words) == 0 || words[0] != putCommand {
+		return nil, fmt.Errorf(errorUnknownCommand, line)
+	}
+	if len(words) < 4 {
+		return nil, fmt.Errorf(errorNotEnoughArguments, len(words))
+	}
+
+	ts, err := strconv.ParseInt(words[2], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf(errorInvalidTimestamp, words[2])
+	}
+
+	dp := &DataPoint{
+		Metric:    words[1],
+		Timestamp: ts,
+		Value:     newValue(words[3]),
+		Tags:      map[string]string{},
+	}
+	for _, tag := range words[4:] {
+		key, value, ok := strings.Cut(tag, tagValueSep)
+		if !ok || len(key) == 0 || len(value) == 0 {
+			return nil, fmt.Errorf(errorInvalidTag, tag)
+		}
+		dp.Tags[key] = value
+	}
+
+	err = dp.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return dp, nil
+}
+
+// newValue returns the JSON value of the specified word, which is quoted unless it's a number.
+func newValue(word string) json.RawMessage {
+	_, err := strconv.ParseFloat(word, 64)
+	if err != nil {
+		return json.RawMessage(strconv.Quote(word))
+	}
+	return json.RawMessage(word)
+}
+
+// ParsePutRequest returns the datapoints of the JSON body of '/api/put' which is a datapoint or an array of datapoints.
+func ParsePutRequest(body []byte) ([]*DataPoint, error) {
+	body = bytes.TrimSpace(body)
+	if 0 < len(body) && body[0] == '[' {
+		dps := []*DataPoint{}
+		err := json.Unmarshal(body, &dps)
+		if err != nil {
+			return nil, fmt.Errorf(errorInvalidRequest, err.Error())
+		}
+		return dps, nil
+	}
+	dp := &DataPoint{}
+	err := json.Unmarshal(body, dp)
+	if err != nil {
+		return nil, fmt.Errorf(errorInvalidRequest, err.Error())
+	}
+	return []*DataPoint{dp}, nil
+}
+
+// isValidName returns true when the name has only the characters which OpenTSDB allows.
+func isValidName(name string) bool {
+	for _, c := range name {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case strings.ContainsRune("-_./", c):
+		default:
+			return false
+		}
+	}
+	return 0 < len(name)
+}
+
+// Validate returns an error when the datapoint isn't acceptable for OpenTSDB, including the nil datapoints such as the null entries of the put requests.
+// Unlike OpenTSDB, the datapoints which have no tags are accepted as plain Graphite metrics.
+func (dp *DataPoint) Validate() error {
+	if dp == nil {
+		return fmt.Errorf(errorMissingDataPoint)
+	}
+	if len(dp.Metric) == 0 {
+		return fmt.Errorf(errorMissingMetric)
+	}
+	if !isValidName(dp.Metric) {
+		return fmt.Errorf(errorInvalidMetric, dp.Metric)
+	}
+	if dp.Timestamp <= 0 {
+		return fmt.Errorf(errorInvalidTimestamp, strconv.FormatInt(dp.Timestamp, 10))
+	}
+	_, err := dp.Float()
+	if err != nil {
+		return err
+	}
+	for key, value := range dp.Tags {
+		if !isValidName(key) || !isValidName(value) {
+			return fmt.Errorf(errorInvalidTag, key+tagValueSep+value)
+		}
+	}
+	return nil
+}
+
+// Float returns the value of the datapoint which is a number or a string of a number.
+func (dp *DataPoint) Float() (float64, error) {
+	value := string(dp.Value)
+	unquoted, err := strconv.Unquote(value)
+	if err == nil {
+		value = unquoted
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsInf(v, 0) {
+		return 0, fmt.Errorf(errorInvalidValue, string(dp.Value))
+	}
+	return v, nil
+}
+
+// Time returns the timestamp of the datapoint which is seconds or milliseconds.
+func (dp *DataPoint) Time() time.Time {
+	if dp.Timestamp&secondsMask != 0 {
+		return time.UnixMilli(dp.Timestamp)
+	}
+	return time.Unix(dp.Timestamp, 0)
+}
@@ -0,0 +1,99 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opentsdb
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParsePutLine(t *testing.T) {
+	dp, err := ParsePutLine("put sys.cpu.user 1356998400 42.5 host=web01 cpu=0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dp.Metric != "sys.cpu.user" || dp.Timestamp != 1356998400 || len(dp.Tags) != 2 {
+		t.Error(fmt.Errorf("%v", dp))
+	}
+	if v, _ := dp.Float(); v != 42.5 {
+		t.Error(fmt.Errorf("%f != %f", v, 42.5))
+	}
+	if dp.Time().Unix() != 1356998400 {
+		t.Error(fmt.Errorf("%d != %d", dp.Time().Unix(), 1356998400))
+	}
+
+	dp, err = ParsePutLine("put sys.cpu.user 1356998400500 1 host=web01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dp.Time().UnixMilli() != 1356998400500 {
+		t.Error(fmt.Errorf("%d != %d", dp.Time().UnixMilli(), 1356998400500))
+	}
+
+	lines := []string{
+		"put sys.cpu.user 1356998400",
+		"put sys.cpu.user abc 1 host=web01",
+		"put sys.cpu.user 1356998400 abc host=web01",
+		"put sys.cpu.user 1356998400 1 host",
+		"put sys.cpu.user 1356998400 1 host=web 01",
+		"put sys.cpu;user 1356998400 1 host=web01",
+		"get sys.cpu.user 1356998400 1 host=web01",
+	}
+	for _, line := range lines {
+		_, err := ParsePutLine(line)
+		if err == nil {
+			t.Error(fmt.Errorf("%s is parsed", line))
+		}
+	}
+}
+
+func TestParsePutRequest(t *testing.T) {
+	dps, err := ParsePutRequest([]byte(`{"metric":"sys.cpu.nice","timestamp":1346846400,"value":18,"tags":{"host":"web01"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dps) != 1 {
+		t.Fatal(fmt.Errorf("%d != %d", len(dps), 1))
+	}
+
+	dps, err = ParsePutRequest([]byte(`[
+		{"metric":"sys.cpu.nice","timestamp":1346846400,"value":18,"tags":{"host":"web01"}},
+		{"metric":"sys.cpu.nice","timestamp":1346846400,"value":"9","tags":{"host":"web02"}}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dps) != 2 {
+		t.Fatal(fmt.Errorf("%d != %d", len(dps), 2))
+	}
+	if v, _ := dps[1].Float(); v != 9 {
+		t.Error(fmt.Errorf("%f != %f", v, 9.0))
+	}
+
+	_, err = ParsePutRequest([]byte(`{"metric":`))
+	if err == nil {
+		t.Error(fmt.Errorf("invalid request is parsed"))
+	}
+}
+
+func TestMapper(t *testing.T) {
+	dp, err := ParsePutLine("put sys.cpu.user 1356998400 42.5 host=web01.example.com cpu=0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mapper := NewMapper()
+	expected := "sys.cpu.user;cpu=0;host=web01.example.com"
+	if name := mapper.Name(dp); name != expected {
+		t.Error(fmt.Errorf("%s != %s", name, expected))
+	}
+
+	mapper.SetTagSupport(false)
+	mapper.SetTagKeys([]string{"host"})
+	expected = "sys.cpu.user.web01_example_com.0"
+	if name := mapper.Name(dp); name != expected {
+		t.Error(fmt.Errorf("%s != %s", name, expected))
+	}
+}
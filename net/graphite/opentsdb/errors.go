@@ -0,0 +1,18 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opentsdb
+
+const (
+	errorNotEnoughArguments = "not enough arguments (need at least 4, got %d)"
+	errorUnknownCommand     = "unknown command : %s"
+	errorMissingDataPoint   = "missing datapoint"
+	errorMissingMetric      = "missing metric name"
+	errorInvalidMetric      = "invalid metric name : %s"
+	errorInvalidTimestamp   = "invalid timestamp : %s"
+	errorInvalidValue       = "unable to parse value to a number : %s"
+	errorInvalidTag         = "invalid tag : %s"
+	errorInvalidRequest     = "unable to parse the request : %s"
+	errorBodyTooLarge       = "request body is larger than %d bytes"
+)
@@ -0,0 +1,104 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opentsdb
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/cybergarage/go-graphite/net/graphite"
+)
+
+const (
+	graphiteNodeSep  = "."
+	graphiteTagSep   = ";"
+	graphiteTagValue = "="
+)
+
+// nodeReplacer replaces the characters of the tag values which split or escape the nodes of the dotted paths.
+var nodeReplacer = strings.NewReplacer(".", "_", "/", "_")
+
+// Mapper converts the OpenTSDB datapoints to the Graphite tagged series such as 'sys.cpu.user;cpu=0;host=web01' by default,
+// or to the dotted paths such as 'sys.cpu.user.web01.0' which have the tag values in the order of the tag keys.
+type Mapper struct {
+	tagSupport bool
+	tagKeys    []string
+}
+
+// NewMapper returns a new mapper to the tagged series.
+func NewMapper() *Mapper {
+	return &Mapper{
+		tagSupport: true,
+		tagKeys:    []string{},
+	}
+}
+
+// SetTagSupport sets whether the datapoints are converted to the tagged series or to the dotted paths.
+func (mapper *Mapper) SetTagSupport(flag bool) {
+	mapper.tagSupport = flag
+}
+
+// SetTagKeys sets the order of the tag values in the dotted paths such as 'host' and 'cpu'.
+// The tags which aren't listed follow them in the order of the keys.
+func (mapper *Mapper) SetTagKeys(keys []string) {
+	mapper.tagKeys = keys
+}
+
+func sortedTagKeys(tags map[string]string) []string {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Name returns the Graphite name of the specified datapoint.
+func (mapper *Mapper) Name(dp *DataPoint) string {
+	if mapper.tagSupport {
+		name := dp.Metric
+		for _, key := range sortedTagKeys(dp.Tags) {
+			name += graphiteTagSep + key + graphiteTagValue + dp.Tags[key]
+		}
+		return name
+	}
+
+	nodes := []string{dp.Metric}
+	used := map[string]bool{}
+	for _, key := range mapper.tagKeys {
+		value, ok := dp.Tags[key]
+		if !ok {
+			continue
+		}
+		nodes = append(nodes, nodeReplacer.Replace(value))
+		used[key] = true
+	}
+	for _, key := range sortedTagKeys(dp.Tags) {
+		if used[key] {
+			continue
+		}
+		nodes = append(nodes, nodeReplacer.Replace(dp.Tags[key]))
+	}
+	return strings.Join(nodes, graphiteNodeSep)
+}
+
+// Metrics returns the Graphite metrics of the specified valid datapoints.
+func (mapper *Mapper) Metrics(dps []*DataPoint) []*graphite.Metrics {
+	ms := []*graphite.Metrics{}
+	for _, dp := range dps {
+		value, err := dp.Float()
+		if err != nil {
+			continue
+		}
+		m := graphite.NewMetrics()
+		m.SetName(mapper.Name(dp))
+		gdp := graphite.NewDataPoint()
+		gdp.SetTimestamp(dp.Time())
+		gdp.SetValue(value)
+		m.AddDataPoint(gdp)
+		ms = append(ms, m)
+	}
+	return ms
+}
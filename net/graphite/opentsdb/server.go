@@ -0,0 +1,347 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package opentsdb provides the OpenTSDB telnet and HTTP put listeners which convert the datapoints to Graphite metrics
+// and deliver them to a graphite.CarbonListener.
+package opentsdb
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cybergarage/go-graphite/net/graphite"
+)
+
+const (
+	// DefaultPort is the default port number of the telnet listener.
+	DefaultPort int = 4242
+	// DefaultPutRequestPath is the default path of the HTTP put endpoint.
+	DefaultPutRequestPath = "/api/put"
+	// DefaultConnectionWaitTimeout is the default read timeout of the telnet connections.
+	DefaultConnectionWaitTimeout = time.Second * 60
+	// DefaultMaxBodySize is the default maximum byte size of the HTTP put requests.
+	DefaultMaxBodySize = graphite.DefaultMaxIngestSize
+)
+
+const (
+	putSummaryParam = "summary"
+	putDetailsParam = "details"
+	exitCommand     = "exit"
+)
+
+// PutError represents an error of a datapoint in the details of the put responses.
+type PutError struct {
+	DataPoint *DataPoint `json:"datapoint"`
+	Error     string     `json:"error"`
+}
+
+// PutResponse represents the summary and the details of the put responses.
+type PutResponse struct {
+	Errors  []*PutError `json:"errors,omitempty"`
+	Failed  int         `json:"failed"`
+	Success int         `json:"success"`
+}
+
+// Server receives the OpenTSDB put requests with telnet, and with HTTP as a graphite.RenderHTTPRequestListener of '/api/put'.
+type Server struct {
+	sync.Mutex
+	addr                  string
+	port                  int
+	connectionWaitTimeout time.Duration
+	maxBodySize           int64
+	mapper                *Mapper
+	carbonListener        graphite.CarbonListener
+	tcpListener           net.Listener
+	wg                    sync.WaitGroup
+}
+
+// NewServer returns a new server which converts the datapoints to the tagged series.
+func NewServer() *Server {
+	return &Server{
+		Mutex:                 sync.Mutex{},
+		addr:                  "",
+		port:                  DefaultPort,
+		connectionWaitTimeout: DefaultConnectionWaitTimeout,
+		maxBodySize:           DefaultMaxBodySize,
+		mapper:                NewMapper(),
+		carbonListener:        nil,
+		tcpListener:           nil,
+		wg:                    sync.WaitGroup{},
+	}
+}
+
+// SetAddress sets a bind address to the server.
+func (server *Server) SetAddress(addr string) {
+	server.Lock()
+	defer server.Unlock()
+	server.addr = addr
+}
+
+// GetAddress returns a bound address.
+func (server *Server) GetAddress() string {
+	server.Lock()
+	defer server.Unlock()
+	return server.addr
+}
+
+// SetPort sets a bind port of the telnet listener.
+func (server *Server) SetPort(port int) {
+	server.Lock()
+	defer server.Unlock()
+	server.port = port
+}
+
+// GetPort returns a bound port.
+func (server *Server) GetPort() int {
+	server.Lock()
+	defer server.Unlock()
+	return server.port
+}
+
+// SetConnectionWaitTimeout sets the read timeout of the telnet connections.
+func (server *Server) SetConnectionWaitTimeout(d time.Duration) {
+	server.Lock()
+	defer server.Unlock()
+	server.connectionWaitTimeout = d
+}
+
+// SetMaxBodySize sets the maximum byte size of the HTTP put requests. The zero size disables the limit.
+func (server *Server) SetMaxBodySize(n int64) {
+	server.Lock()
+	defer server.Unlock()
+	server.maxBodySize = n
+}
+
+// GetMaxBodySize returns the maximum byte size of the HTTP put requests.
+func (server *Server) GetMaxBodySize() int64 {
+	server.Lock()
+	defer server.Unlock()
+	return server.maxBodySize
+}
+
+// SetMapper sets the mapper from the datapoints to the Graphite metrics.
+func (server *Server) SetMapper(mapper *Mapper) {
+	server.Lock()
+	defer server.Unlock()
+	server.mapper = mapper
+}
+
+// GetMapper returns the mapper from the datapoints to the Graphite metrics.
+func (server *Server) GetMapper() *Mapper {
+	server.Lock()
+	defer server.Unlock()
+	return server.mapper
+}
+
+// SetCarbonListener sets the listener of the metrics converted from the telnet and HTTP datapoints.
+// Set the listener of Carbon.GetFeedListener() to put the datapoints through the ingest path of the Carbon server.
+func (server *Server) SetCarbonListener(listener graphite.CarbonListener) {
+	server.Lock()
+	defer server.Unlock()
+	server.carbonListener = listener
+}
+
+// FeedDataPoints passes the valid datapoints to the listener, and returns the errors of the invalid datapoints.
+func (server *Server) FeedDataPoints(dps []*DataPoint) []*PutError {
+	ms, errs, listener := server.convertDataPoints(dps)
+	if listener != nil && 0 < len(ms) {
+		listener.InsertMetricsRequestReceived(ms, nil)
+	}
+	return errs
+}
+
+// convertDataPoints converts the valid datapoints, and returns them with the errors of the invalid datapoints and the listener.
+func (server *Server) convertDataPoints(dps []*DataPoint) ([]*graphite.Metrics, []*PutError, graphite.CarbonListener) {
+	errs := []*PutError{}
+	valid := make([]*DataPoint, 0, len(dps))
+	for _, dp := range dps {
+		err := dp.Validate()
+		if err != nil {
+			errs = append(errs, &PutError{DataPoint: dp, Error: err.Error()})
+			continue
+		}
+		valid = append(valid, dp)
+	}
+
+	server.Lock()
+	mapper := server.mapper
+	listener := server.carbonListener
+	server.Unlock()
+
+	return mapper.Metrics(valid), errs, listener
+}
+
+// HTTPRequestReceived handles the put requests such as 'POST /api/put?details'.
+// The valid datapoints are written even if the request has invalid datapoints, and the response is 400 when any datapoint is invalid.
+// The failed and succeeded counts are returned with '?summary', and the errors of the invalid datapoints are added with '?details'.
+func (server *Server) HTTPRequestReceived(r *http.Request, w http.ResponseWriter) {
+	server.AuthorizedHTTPRequestReceived(r, w, nil)
+}
+
+// AuthorizedHTTPRequestReceived handles the put requests with the path rules and the tenancy of Render.
+// The metrics are prefixed with the tenant of the request, and no datapoints are written with 403 when the principal can't write one of them.
+func (server *Server) AuthorizedHTTPRequestReceived(r *http.Request, w http.ResponseWriter, auth graphite.RenderAuthorizer) {
+	if r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	var reader io.Reader = r.Body
+	limit := server.GetMaxBodySize()
+	if 0 < limit {
+		reader = http.MaxBytesReader(w, r.Body, limit)
+	}
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			http.Error(w, fmt.Sprintf(errorBodyTooLarge, maxErr.Limit), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	dps, err := ParsePutRequest(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ms, errs, listener := server.convertDataPoints(dps)
+	if listener != nil && 0 < len(ms) {
+		err := graphite.InsertRequestMetrics(r, auth, listener, ms)
+		if err != nil {
+			http.Error(w, err.Error(), graphite.AsRenderError(err).StatusCode())
+			return
+		}
+	}
+
+	status := http.StatusNoContent
+	if 0 < len(errs) {
+		status = http.StatusBadRequest
+	}
+
+	query := r.URL.Query()
+	_, details := query[putDetailsParam]
+	_, summary := query[putSummaryParam]
+	if !details && !summary {
+		if 0 < len(errs) {
+			http.Error(w, errs[0].Error, status)
+			return
+		}
+		w.WriteHeader(status)
+		return
+	}
+
+	res := &PutResponse{
+		Errors:  nil,
+		Failed:  len(errs),
+		Success: len(dps) - len(errs),
+	}
+	if details {
+		res.Errors = errs
+	}
+	if status == http.StatusNoContent {
+		status = http.StatusOK
+	}
+	w.Header().Set("Content-Type", graphite.QueryContentTypeJSON)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(res)
+}
+
+// Start starts the telnet listener.
+func (server *Server) Start() error {
+	err := server.Stop()
+	if err != nil {
+		return err
+	}
+
+	server.Lock()
+	defer server.Unlock()
+
+	addr := net.JoinHostPort(server.addr, strconv.Itoa(server.port))
+	server.tcpListener, err = net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	server.wg.Add(1)
+	go server.serve(server.tcpListener)
+
+	return nil
+}
+
+// Stop stops the telnet listener.
+func (server *Server) Stop() error {
+	server.Lock()
+	tcpListener := server.tcpListener
+	server.tcpListener = nil
+	server.Unlock()
+
+	if tcpListener == nil {
+		return nil
+	}
+	err := tcpListener.Close()
+	server.wg.Wait()
+
+	return err
+}
+
+func (server *Server) serve(l net.Listener) {
+	defer server.wg.Done()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go server.receive(conn)
+	}
+}
+
+// receive handles the telnet commands of the connection, and writes back the errors of the invalid lines like OpenTSDB.
+func (server *Server) receive(conn net.Conn) error {
+	defer conn.Close()
+
+	server.Lock()
+	timeout := server.connectionWaitTimeout
+	server.Unlock()
+
+	scanner := bufio.NewScanner(conn)
+	for {
+		conn.SetReadDeadline(time.Now().Add(timeout))
+		if !scanner.Scan() {
+			return scanner.Err()
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 {
+			continue
+		}
+		if line == exitCommand {
+			return nil
+		}
+		if strings.Fields(line)[0] != putCommand {
+			fmt.Fprintf(conn, "%s\n", fmt.Sprintf(errorUnknownCommand, line))
+			continue
+		}
+		dp, err := ParsePutLine(line)
+		if err == nil {
+			errs := server.FeedDataPoints([]*DataPoint{dp})
+			if 0 < len(errs) {
+				err = fmt.Errorf("%s", errs[0].Error)
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(conn, "%s: %s\n", putCommand, err.Error())
+		}
+	}
+}
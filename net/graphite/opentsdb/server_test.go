@@ -0,0 +1,181 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opentsdb
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cybergarage/go-graphite/net/graphite"
+)
+
+type testListener struct {
+	sync.Mutex
+	names map[string]float64
+}
+
+func newTestListener() *testListener {
+	return &testListener{names: map[string]float64{}}
+}
+
+func (l *testListener) InsertMetricsRequestReceived(ms []*graphite.Metrics, err error) {
+	l.Lock()
+	defer l.Unlock()
+	for _, m := range ms {
+		l.names[m.Name] = m.DataPoints[0].Value
+	}
+}
+
+func (l *testListener) get(name string) (float64, bool) {
+	l.Lock()
+	defer l.Unlock()
+	v, ok := l.names[name]
+	return v, ok
+}
+
+const testPutRequest = `[
+	{"metric":"sys.cpu.nice","timestamp":1346846400,"value":18,"tags":{"host":"web01"}},
+	{"metric":"sys.cpu.nice","timestamp":1346846400,"value":"abc","tags":{"host":"web02"}}
+]`
+
+func TestServerHTTPPut(t *testing.T) {
+	listener := newTestListener()
+	server := NewServer()
+	server.SetCarbonListener(listener)
+	server.SetMaxBodySize(1024)
+
+	testCases := []struct {
+		query  string
+		body   string
+		status int
+	}{
+		{"", `{"metric":"sys.cpu.user","timestamp":1346846400,"value":1,"tags":{"host":"web01"}}`, http.StatusNoContent},
+		{"?summary", `{"metric":"sys.cpu.user","timestamp":1346846400,"value":1,"tags":{"host":"web01"}}`, http.StatusOK},
+		{"", testPutRequest, http.StatusBadRequest},
+		{"?summary", testPutRequest, http.StatusBadRequest},
+		{"?details", testPutRequest, http.StatusBadRequest},
+		{"", `{"metric":`, http.StatusBadRequest},
+		{"?details", `[null]`, http.StatusBadRequest},
+		{"", `[` + strings.Repeat(`{"metric":"sys.cpu.user","timestamp":1346846400,"value":1},`, 100) + `null]`, http.StatusRequestEntityTooLarge},
+	}
+	for _, tc := range testCases {
+		r := httptest.NewRequest(http.MethodPost, DefaultPutRequestPath+tc.query, strings.NewReader(tc.body))
+		w := httptest.NewRecorder()
+		server.HTTPRequestReceived(r, w)
+		if w.Code != tc.status {
+			t.Error(fmt.Errorf("%s %s : %d != %d", tc.query, tc.body, w.Code, tc.status))
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodPost, DefaultPutRequestPath+"?details", strings.NewReader(testPutRequest))
+	w := httptest.NewRecorder()
+	server.HTTPRequestReceived(r, w)
+	res := PutResponse{}
+	err := json.Unmarshal(w.Body.Bytes(), &res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Failed != 1 || res.Success != 1 || len(res.Errors) != 1 {
+		t.Error(fmt.Errorf("%v", res))
+	}
+	if res.Errors[0].DataPoint.Tags["host"] != "web02" {
+		t.Error(fmt.Errorf("%v", res.Errors[0].DataPoint))
+	}
+
+	if v, ok := listener.get("sys.cpu.nice;host=web01"); !ok || v != 18 {
+		t.Error(fmt.Errorf("%f != %f", v, 18.0))
+	}
+}
+
+func TestServerTelnet(t *testing.T) {
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	listener := newTestListener()
+	server := NewServer()
+	server.SetAddress("localhost")
+	server.SetPort(port)
+	server.SetCarbonListener(listener)
+	err = server.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Stop()
+
+	conn, err := net.Dial("tcp", net.JoinHostPort("localhost", strconv.Itoa(port)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	fmt.Fprintf(conn, "put sys.cpu.user 1356998400 42.5 host=web01\n")
+	fmt.Fprintf(conn, "put sys.cpu.user 1356998400 abc host=web01\n")
+
+	conn.SetReadDeadline(time.Now().Add(time.Second * 5))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(line, "put: ") {
+		t.Error(fmt.Errorf("%s", line))
+	}
+
+	if v, ok := listener.get("sys.cpu.user;host=web01"); !ok || v != 42.5 {
+		t.Error(fmt.Errorf("%f != %f", v, 42.5))
+	}
+}
+
+func TestServerHTTPPutAuthorization(t *testing.T) {
+	listener := newTestListener()
+	server := NewServer()
+	server.SetCarbonListener(listener)
+
+	rules := graphite.NewPathRules()
+	rules.AddRule("", "sys.cpu")
+	tenancy := graphite.NewTenancy(graphite.TenantPrefix)
+	tenancy.SetHeader(graphite.DefaultTenantHeader)
+	render := graphite.NewRender()
+	render.SetPathRules(rules)
+	render.SetTenancy(tenancy)
+	render.SetHTTPRequestListener(DefaultPutRequestPath, server)
+
+	put := func(body string) int {
+		r := httptest.NewRequest(http.MethodPost, DefaultPutRequestPath, strings.NewReader(body))
+		r.Header.Set(graphite.DefaultTenantHeader, "team-a")
+		w := httptest.NewRecorder()
+		render.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	// The allowed datapoints are written with the prefix of the tenant.
+	if code := put(`{"metric":"sys.cpu.user","timestamp":1346846400,"value":1}`); code != http.StatusNoContent {
+		t.Error(fmt.Errorf("%d != %d", code, http.StatusNoContent))
+	}
+	if _, ok := listener.get("team-a.sys.cpu.user"); !ok {
+		t.Error(fmt.Errorf("team-a.sys.cpu.user is not found"))
+	}
+
+	// No datapoints are written when one of them isn't allowed.
+	code := put(`[{"metric":"sys.cpu.nice","timestamp":1346846400,"value":1},{"metric":"sys.mem.free","timestamp":1346846400,"value":1}]`)
+	if code != http.StatusForbidden {
+		t.Error(fmt.Errorf("%d != %d", code, http.StatusForbidden))
+	}
+	if _, ok := listener.get("team-a.sys.cpu.nice"); ok {
+		t.Error(fmt.Errorf("team-a.sys.cpu.nice is written"))
+	}
+}
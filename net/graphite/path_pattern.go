@@ -0,0 +1,440 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package graphite
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	pathPatternSep          = "."
+	pathPatternMetaChars    = "*?[{"
+	pathPatternAsterisk     = "*"
+	pathPatternBraceOpen    = '{'
+	pathPatternBraceClose   = '}'
+	pathPatternBraceSep     = ','
+	pathPatternBracketOpen  = '['
+	pathPatternBracketClose = ']'
+)
+
+// PathPattern is a compiled Graphite path pattern such as 'a.*.c', 'a.{b,c}.d' or 'host[0-9]'.
+// Each wildcard is evaluated for a dot-separated node, so '*' never matches across a dot.
+// See : The Metrics API (https://graphite-api.readthedocs.io/en/latest/api.html#the-metrics-api)
+type PathPattern struct {
+	pattern  string
+	variants [][]*pathSegment
+	prefix   string
+}
+
+// PathMatch represents a node which matched a path pattern.
+type PathMatch struct {
+	Path     string
+	IsLeaf   bool
+	IsBranch bool
+}
+
+// pathSegment is a compiled matcher for a node of a path pattern.
+type pathSegment struct {
+	pattern string
+	literal bool
+	prefix  string
+	any     bool
+	regex   *regexp.Regexp
+}
+
+// NewPathPattern compiles the specified Graphite path pattern.
+func NewPathPattern(pattern string) (*PathPattern, error) {
+	p := &PathPattern{
+		pattern:  pattern,
+		variants: [][]*pathSegment{},
+		prefix:   "",
+	}
+
+	for _, variant := range expandDottedBraces(pattern) {
+		segs := []*pathSegment{}
+		for _, seg := range splitPathPattern(variant) {
+			s, err := newPathSegment(seg)
+			if err != nil {
+				return nil, err
+			}
+			segs = append(segs, s)
+		}
+		p.variants = append(p.variants, segs)
+	}
+
+	p.prefix = p.literalPrefix()
+
+	return p, nil
+}
+
+// String returns the source pattern.
+func (p *PathPattern) String() string {
+	return p.pattern
+}
+
+// IsLiteral returns true whether the pattern has no wildcards, otherwise false.
+func (p *PathPattern) IsLiteral() bool {
+	if len(p.variants) != 1 {
+		return false
+	}
+	for _, seg := range p.variants[0] {
+		if !seg.literal {
+			return false
+		}
+	}
+	return true
+}
+
+// LiteralPrefix returns the leading literal nodes which all matched paths start with.
+func (p *PathPattern) LiteralPrefix() string {
+	return p.prefix
+}
+
+// Match returns true whether the specified dotted name matches the pattern, otherwise false.
+func (p *PathPattern) Match(name string) bool {
+	if !strings.HasPrefix(name, p.prefix) {
+		return false
+	}
+
+	nodes := strings.Split(name, pathPatternSep)
+	for _, segs := range p.variants {
+		if matchPathSegments(segs, nodes) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MatchPrefix returns true whether the leading nodes of the specified dotted name match the pattern, so the name is a matched path or one of its descendants.
+func (p *PathPattern) MatchPrefix(name string) bool {
+	nodes := strings.Split(name, pathPatternSep)
+	for _, segs := range p.variants {
+		if len(segs) <= len(nodes) && matchPathSegments(segs, nodes[:len(segs)]) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchAncestor returns true whether the specified dotted name matches the leading nodes of the pattern, so the name is a branch to the matched paths.
+func (p *PathPattern) MatchAncestor(name string) bool {
+	nodes := strings.Split(name, pathPatternSep)
+	for _, segs := range p.variants {
+		if len(nodes) < len(segs) && matchPathSegments(segs[:len(nodes)], nodes) {
+			return true
+		}
+	}
+	return false
+}
+
+// Expand returns all nodes in the specified tree which match the pattern, ordered by path.
+func (p *PathPattern) Expand(tree *PathTree) []*PathMatch {
+	found := map[string]*PathMatch{}
+	for _, segs := range p.variants {
+		expandPathSegments(tree.root, segs, "", found)
+	}
+
+	matches := make([]*PathMatch, 0, len(found))
+	for _, m := range found {
+		matches = append(matches, m)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].Path < matches[j].Path
+	})
+
+	return matches
+}
+
+func (p *PathPattern) literalPrefix() string {
+	var common []string
+	for n, segs := range p.variants {
+		nodes := []string{}
+		for _, seg := range segs {
+			if !seg.literal {
+				break
+			}
+			nodes = append(nodes, seg.pattern)
+		}
+		if n == 0 {
+			common = nodes
+			continue
+		}
+		i := 0
+		for i < len(common) && i < len(nodes) && common[i] == nodes[i] {
+			i++
+		}
+		common = common[:i]
+	}
+	return strings.Join(common, pathPatternSep)
+}
+
+func matchPathSegments(segs []*pathSegment, nodes []string) bool {
+	if len(segs) != len(nodes) {
+		return false
+	}
+	for n, seg := range segs {
+		if !seg.match(nodes[n]) {
+			return false
+		}
+	}
+	return true
+}
+
+func expandPathSegments(node *pathTreeNode, segs []*pathSegment, path string, found map[string]*PathMatch) {
+	if len(segs) == 0 {
+		return
+	}
+
+	seg := segs[0]
+	visit := func(name string, child *pathTreeNode) {
+		childPath := name
+		if 0 < len(path) {
+			childPath = path + pathPatternSep + name
+		}
+		if 1 < len(segs) {
+			expandPathSegments(child, segs[1:], childPath, found)
+			return
+		}
+		found[childPath] = &PathMatch{
+			Path:     childPath,
+			IsLeaf:   child.leaf,
+			IsBranch: 0 < len(child.children),
+		}
+	}
+
+	if seg.literal {
+		child, ok := node.children[seg.pattern]
+		if ok {
+			visit(seg.pattern, child)
+		}
+		return
+	}
+
+	for name, child := range node.children {
+		if seg.match(name) {
+			visit(name, child)
+		}
+	}
+}
+
+func newPathSegment(seg string) (*pathSegment, error) {
+	s := &pathSegment{
+		pattern: seg,
+		literal: false,
+		prefix:  "",
+		any:     false,
+		regex:   nil,
+	}
+
+	metaIdx := strings.IndexAny(seg, pathPatternMetaChars)
+	switch {
+	case metaIdx < 0:
+		s.literal = true
+		return s, nil
+	case seg == pathPatternAsterisk:
+		s.any = true
+		return s, nil
+	case metaIdx == (len(seg)-1) && strings.HasSuffix(seg, pathPatternAsterisk):
+		s.prefix = seg[:metaIdx]
+		return s, nil
+	}
+
+	regex, err := regexp.Compile("^" + translatePathSegment(seg) + "$")
+	if err != nil {
+		return nil, err
+	}
+	s.regex = regex
+
+	return s, nil
+}
+
+func (s *pathSegment) match(node string) bool {
+	switch {
+	case s.literal:
+		return node == s.pattern
+	case s.any:
+		return true
+	case s.regex == nil:
+		return strings.HasPrefix(node, s.prefix)
+	}
+	return s.regex.MatchString(node)
+}
+
+// translatePathSegment converts a node pattern into a regular expression using the fnmatch semantics of Graphite.
+func translatePathSegment(seg string) string {
+	var buf strings.Builder
+
+	n := 0
+	for n < len(seg) {
+		c := seg[n]
+		switch c {
+		case '*':
+			buf.WriteString(".*")
+			for n+1 < len(seg) && seg[n+1] == '*' {
+				n++
+			}
+		case '?':
+			buf.WriteString(".")
+		case pathPatternBracketOpen:
+			end := findBracketClose(seg, n)
+			if end < 0 {
+				buf.WriteString(regexp.QuoteMeta(string(c)))
+				break
+			}
+			buf.WriteString(translateBracket(seg[n+1 : end]))
+			n = end
+		case pathPatternBraceOpen:
+			end := findBraceClose(seg, n)
+			if end < 0 {
+				buf.WriteString(regexp.QuoteMeta(string(c)))
+				break
+			}
+			alts := splitBraceAlternatives(seg[n+1 : end])
+			for i, alt := range alts {
+				alts[i] = translatePathSegment(alt)
+			}
+			buf.WriteString("(?:" + strings.Join(alts, "|") + ")")
+			n = end
+		default:
+			buf.WriteString(regexp.QuoteMeta(string(c)))
+		}
+		n++
+	}
+
+	return buf.String()
+}
+
+func translateBracket(class string) string {
+	var buf strings.Builder
+	buf.WriteString("[")
+	if 0 < len(class) && (class[0] == '!' || class[0] == '^') {
+		buf.WriteString("^")
+		class = class[1:]
+	}
+	for _, r := range class {
+		switch {
+		case r == '-':
+			buf.WriteRune(r)
+		case ('0' <= r && r <= '9') || ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z'):
+			buf.WriteRune(r)
+		case r < 0x80:
+			buf.WriteRune('\\')
+			buf.WriteRune(r)
+		default:
+			buf.WriteRune(r)
+		}
+	}
+	buf.WriteString("]")
+	return buf.String()
+}
+
+// findBracketClose returns the index of the bracket which closes the class at the specified index, or -1.
+func findBracketClose(s string, start int) int {
+	n := start + 1
+	if n < len(s) && (s[n] == '!' || s[n] == '^') {
+		n++
+	}
+	if n < len(s) && s[n] == pathPatternBracketClose {
+		n++
+	}
+	for n < len(s) {
+		if s[n] == pathPatternBracketClose {
+			return n
+		}
+		n++
+	}
+	return -1
+}
+
+// findBraceClose returns the index of the brace which closes the group at the specified index, or -1.
+func findBraceClose(s string, start int) int {
+	depth := 0
+	for n := start; n < len(s); n++ {
+		switch s[n] {
+		case pathPatternBraceOpen:
+			depth++
+		case pathPatternBraceClose:
+			depth--
+			if depth == 0 {
+				return n
+			}
+		}
+	}
+	return -1
+}
+
+// splitBraceAlternatives splits the contents of a brace group by the top level commas.
+func splitBraceAlternatives(s string) []string {
+	alts := []string{}
+	depth := 0
+	last := 0
+	for n := range len(s) {
+		switch s[n] {
+		case pathPatternBraceOpen:
+			depth++
+		case pathPatternBraceClose:
+			depth--
+		case pathPatternBraceSep:
+			if depth == 0 {
+				alts = append(alts, s[last:n])
+				last = n + 1
+			}
+		}
+	}
+	return append(alts, s[last:])
+}
+
+// splitPathPattern splits the specified pattern into nodes by the dots outside brace groups.
+func splitPathPattern(pattern string) []string {
+	nodes := []string{}
+	depth := 0
+	last := 0
+	for n := range len(pattern) {
+		switch pattern[n] {
+		case pathPatternBraceOpen:
+			if 0 <= findBraceClose(pattern, n) {
+				depth++
+			}
+		case pathPatternBraceClose:
+			if 0 < depth {
+				depth--
+			}
+		case pathPatternSep[0]:
+			if depth == 0 {
+				nodes = append(nodes, pattern[last:n])
+				last = n + 1
+			}
+		}
+	}
+	return append(nodes, pattern[last:])
+}
+
+// expandDottedBraces expands the brace groups which contain dots, such as 'a.{b.c,d}.e',
+// because the alternatives have a different number of nodes and can't be matched node by node.
+func expandDottedBraces(pattern string) []string {
+	for n := range len(pattern) {
+		if pattern[n] != pathPatternBraceOpen {
+			continue
+		}
+		end := findBraceClose(pattern, n)
+		if end < 0 {
+			continue
+		}
+		body := pattern[n+1 : end]
+		if !strings.Contains(body, pathPatternSep) {
+			continue
+		}
+		variants := []string{}
+		for _, alt := range splitBraceAlternatives(body) {
+			variant := pattern[:n] + alt + pattern[end+1:]
+			variants = append(variants, expandDottedBraces(variant)...)
+		}
+		return variants
+	}
+	return []string{pattern}
+}
@@ -0,0 +1,136 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package graphite
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestPathPatternMatch(t *testing.T) {
+	testCases := []struct {
+		pattern string
+		name    string
+		match   bool
+	}{
+		{"a.b.c", "a.b.c", true},
+		{"a.b.c", "a.b.d", false},
+		{"a.*.c", "a.b.c", true},
+		{"a.*.c", "a.b.x.c", false},
+		{"a.*", "a.b.c", false},
+		{"*", "a", true},
+		{"*", "a.b", false},
+		{"a.b*", "a.bcd", true},
+		{"a.b*", "a.xb", false},
+		{"*foo*bar", "xfooybar", true},
+		{"*foo*bar", "foobar", true},
+		{"*foo*bar", "foo.bar", false},
+		{"a.{b,c}.d", "a.b.d", true},
+		{"a.{b,c}.d", "a.c.d", true},
+		{"a.{b,c}.d", "a.e.d", false},
+		{"a.{b*,c}.d", "a.bx.d", true},
+		{"a.{b.x,c}.d", "a.b.x.d", true},
+		{"a.{b.x,c}.d", "a.c.d", true},
+		{"a.{b.x,c}.d", "a.b.d", false},
+		{"host[0-9]", "host5", true},
+		{"host[0-9]", "hostx", false},
+		{"host[!0-9]", "hostx", true},
+		{"host[!0-9]", "host5", false},
+		{"host[ab].cpu", "hostb.cpu", true},
+		{"host?", "host1", true},
+		{"host?", "host12", false},
+		{"host[", "host[", true},
+		{"a.{b", "a.{b", true},
+		{"a+b.(c)", "a+b.(c)", true},
+	}
+
+	for _, tc := range testCases {
+		p, err := NewPathPattern(tc.pattern)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if p.Match(tc.name) != tc.match {
+			t.Error(fmt.Errorf("%s : %s (%t)", tc.pattern, tc.name, tc.match))
+		}
+	}
+}
+
+func TestPathPatternLiteralPrefix(t *testing.T) {
+	testCases := []struct {
+		pattern string
+		prefix  string
+		literal bool
+	}{
+		{"a.b.c", "a.b.c", true},
+		{"a.b.*.d", "a.b", false},
+		{"*.b", "", false},
+		{"a.{b.c,b.d}.e", "a.b", false},
+	}
+
+	for _, tc := range testCases {
+		p, err := NewPathPattern(tc.pattern)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if p.LiteralPrefix() != tc.prefix {
+			t.Error(fmt.Errorf("%s : %s != %s", tc.pattern, p.LiteralPrefix(), tc.prefix))
+		}
+		if p.IsLiteral() != tc.literal {
+			t.Error(fmt.Errorf("%s : %t != %t", tc.pattern, p.IsLiteral(), tc.literal))
+		}
+	}
+}
+
+func TestPathPatternExpand(t *testing.T) {
+	tree := NewPathTree()
+	names := []string{
+		"servers.host1.cpu.user",
+		"servers.host1.cpu.system",
+		"servers.host2.cpu.user",
+		"servers.host2.mem.free",
+		"servers.web.cpu",
+		"servers.web.cpu.user",
+	}
+	for _, name := range names {
+		tree.Add(name)
+	}
+
+	testCases := []struct {
+		pattern string
+		paths   []string
+	}{
+		{"servers.*", []string{"servers.host1", "servers.host2", "servers.web"}},
+		{"servers.host[0-9].cpu.user", []string{"servers.host1.cpu.user", "servers.host2.cpu.user"}},
+		{"servers.{host1,web}.cpu", []string{"servers.host1.cpu", "servers.web.cpu"}},
+		{"servers.*.mem.*", []string{"servers.host2.mem.free"}},
+		{"servers.host3.*", []string{}},
+	}
+
+	for _, tc := range testCases {
+		p, err := NewPathPattern(tc.pattern)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		matches := p.Expand(tree)
+		if len(matches) != len(tc.paths) {
+			t.Error(fmt.Errorf("%s : %d != %d", tc.pattern, len(matches), len(tc.paths)))
+			continue
+		}
+		for n, m := range matches {
+			if m.Path != tc.paths[n] {
+				t.Error(fmt.Errorf("%s : %s != %s", tc.pattern, m.Path, tc.paths[n]))
+			}
+		}
+	}
+
+	p, _ := NewPathPattern("servers.web.cpu")
+	matches := p.Expand(tree)
+	if len(matches) != 1 || !matches[0].IsLeaf || !matches[0].IsBranch {
+		t.Error(fmt.Errorf("%v", matches))
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package graphite
+
+import (
+	"sort"
+	"strings"
+)
+
+// PathTree is a trie of dotted metric names to expand path patterns.
+// PathTree is not safe for concurrent use.
+type PathTree struct {
+	root      *pathTreeNode
+	leafCount int
+}
+
+type pathTreeNode struct {
+	children map[string]*pathTreeNode
+	leaf     bool
+}
+
+func newPathTreeNode() *pathTreeNode {
+	return &pathTreeNode{
+		children: map[string]*pathTreeNode{},
+		leaf:     false,
+	}
+}
+
+// NewPathTree returns a new empty tree.
+func NewPathTree() *PathTree {
+	tree := &PathTree{
+		root:      newPathTreeNode(),
+		leafCount: 0,
+	}
+	return tree
+}
+
+// Add adds the specified name, and returns true when the name is new, otherwise false.
+func (tree *PathTree) Add(name string) bool {
+	if len(name) == 0 {
+		return false
+	}
+
+	node := tree.root
+	for _, nodeName := range strings.Split(name, pathPatternSep) {
+		child, ok := node.children[nodeName]
+		if !ok {
+			child = newPathTreeNode()
+			node.children[nodeName] = child
+		}
+		node = child
+	}
+
+	if node.leaf {
+		return false
+	}
+	node.leaf = true
+	tree.leafCount++
+
+	return true
+}
+
+// Remove removes the specified name and the branches which become empty, and returns true when the name was found, otherwise false.
+func (tree *PathTree) Remove(name string) bool {
+	if len(name) == 0 {
+		return false
+	}
+
+	nodeNames := strings.Split(name, pathPatternSep)
+	nodes := make([]*pathTreeNode, 0, len(nodeNames)+1)
+	nodes = append(nodes, tree.root)
+	for _, nodeName := range nodeNames {
+		child, ok := nodes[len(nodes)-1].children[nodeName]
+		if !ok {
+			return false
+		}
+		nodes = append(nodes, child)
+	}
+
+	leaf := nodes[len(nodes)-1]
+	if !leaf.leaf {
+		return false
+	}
+	leaf.leaf = false
+	tree.leafCount--
+
+	for n := len(nodeNames) - 1; 0 <= n; n-- {
+		child := nodes[n+1]
+		if child.leaf || 0 < len(child.children) {
+			break
+		}
+		delete(nodes[n].children, nodeNames[n])
+	}
+
+	return true
+}
+
+// Has returns true whether the specified name is a leaf of the tree, otherwise false.
+func (tree *PathTree) Has(name string) bool {
+	node := tree.lookup(name)
+	return node != nil && node.leaf
+}
+
+// Len returns the number of the leaf names.
+func (tree *PathTree) Len() int {
+	return tree.leafCount
+}
+
+// Names returns all leaf names in the sorted order.
+func (tree *PathTree) Names() []string {
+	return tree.NamesWithPrefix("")
+}
+
+// NamesWithPrefix returns the sorted leaf names under the specified dotted prefix.
+// The prefix itself is included when it is a leaf.
+func (tree *PathTree) NamesWithPrefix(prefix string) []string {
+	names := []string{}
+
+	node := tree.root
+	if 0 < len(prefix) {
+		node = tree.lookup(prefix)
+		if node == nil {
+			return names
+		}
+	}
+
+	names = appendPathTreeNames(names, node, prefix)
+	sort.Strings(names)
+
+	return names
+}
+
+// Children returns the sorted child node names of the specified dotted path, or the top level nodes for an empty path.
+func (tree *PathTree) Children(path string) []string {
+	names := []string{}
+
+	node := tree.root
+	if 0 < len(path) {
+		node = tree.lookup(path)
+		if node == nil {
+			return names
+		}
+	}
+
+	for name := range node.children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// NodeCount returns the number of the nodes including the branches.
+func (tree *PathTree) NodeCount() int {
+	return countPathTreeNodes(tree.root) - 1
+}
+
+func (tree *PathTree) lookup(name string) *pathTreeNode {
+	if len(name) == 0 {
+		return nil
+	}
+	node := tree.root
+	for _, nodeName := range strings.Split(name, pathPatternSep) {
+		child, ok := node.children[nodeName]
+		if !ok {
+			return nil
+		}
+		node = child
+	}
+	return node
+}
+
+func appendPathTreeNames(names []string, node *pathTreeNode, path string) []string {
+	if node.leaf {
+		names = append(names, path)
+	}
+	for name, child := range node.children {
+		childPath := name
+		if 0 < len(path) {
+			childPath = path + pathPatternSep + name
+		}
+		names = appendPathTreeNames(names, child, childPath)
+	}
+	return names
+}
+
+func countPathTreeNodes(node *pathTreeNode) int {
+	count := 1
+	for _, child := range node.children {
+		count += countPathTreeNodes(child)
+	}
+	return count
+}
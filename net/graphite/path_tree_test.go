@@ -0,0 +1,55 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package graphite
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestPathTree(t *testing.T) {
+	tree := NewPathTree()
+
+	names := []string{"a.b.c", "a.b.d", "a.e", "f"}
+	for _, name := range names {
+		if !tree.Add(name) {
+			t.Error(fmt.Errorf("%s", name))
+		}
+	}
+
+	if tree.Add("a.b.c") {
+		t.Error(fmt.Errorf("a.b.c is added twice"))
+	}
+
+	if tree.Len() != len(names) {
+		t.Error(fmt.Errorf("%d != %d", tree.Len(), len(names)))
+	}
+
+	if !tree.Has("a.e") || tree.Has("a.b") {
+		t.Error(fmt.Errorf("%v", tree.Names()))
+	}
+
+	prefixNames := tree.NamesWithPrefix("a.b")
+	if len(prefixNames) != 2 || prefixNames[0] != "a.b.c" {
+		t.Error(fmt.Errorf("%v", prefixNames))
+	}
+
+	if !tree.Remove("a.b.c") || !tree.Remove("a.b.d") {
+		t.Error(fmt.Errorf("%v", tree.Names()))
+	}
+
+	if tree.Remove("a.b.c") {
+		t.Error(fmt.Errorf("a.b.c is removed twice"))
+	}
+
+	children := tree.Children("a")
+	if len(children) != 1 || children[0] != "e" {
+		t.Error(fmt.Errorf("%v", children))
+	}
+
+	if tree.NodeCount() != 3 {
+		t.Error(fmt.Errorf("%d != %d", tree.NodeCount(), 3))
+	}
+}
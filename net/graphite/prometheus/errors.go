@@ -0,0 +1,20 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package prometheus
+
+const (
+	errorCorruptedSnappy     = "corrupted snappy input"
+	errorTooLargeSnappy      = "too large snappy input : %d"
+	errorCorruptedProtobuf   = "corrupted protobuf input"
+	errorUnsupportedWireType = "unsupported protobuf wire type : %d"
+	errorMissingMetricName   = "missing metric name : %s"
+	errorUnsupportedEncoding = "unsupported content encoding : %s"
+	errorTooLargeRequest     = "too large request : %d"
+	errorInvalidMatcher      = "invalid label matcher : %s"
+	errorUnsupportedResponse = "unsupported response types : %v"
+	errorMissingListener     = "missing render listener"
+	errorTenantListener      = "tenant-aware render listener is required : %s"
+	errorInvalidMappingRule  = "invalid mapping rule : %s"
+)
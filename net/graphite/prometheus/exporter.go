@@ -0,0 +1,242 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package prometheus
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cybergarage/go-graphite/net/graphite"
+)
+
+const (
+	// DefaultExpositionRequestPath is the default path of the exposition endpoint.
+	DefaultExpositionRequestPath = "/metrics"
+	// DefaultStaleness is the default period to keep the values which aren't updated like graphite_exporter.
+	DefaultStaleness = time.Minute * 5
+	// ExpositionContentType is the content type of the text exposition format.
+	ExpositionContentType = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+type cachedValue struct {
+	name      string
+	labels    []Label
+	value     float64
+	timestamp time.Time
+	updated   time.Time
+}
+
+// Exporter is a graphite.CarbonProcessor which caches the latest value of each ingested series,
+// and exposes them in the Prometheus text exposition format as a graphite.RenderHTTPRequestListener.
+type Exporter struct {
+	sync.RWMutex
+	values     map[string]*cachedValue
+	rules      []*MappingRule
+	staleness  time.Duration
+	timestamps bool
+}
+
+// NewExporter returns a new exporter with the default staleness.
+func NewExporter() *Exporter {
+	return &Exporter{
+		RWMutex:    sync.RWMutex{},
+		values:     map[string]*cachedValue{},
+		rules:      []*MappingRule{},
+		staleness:  DefaultStaleness,
+		timestamps: false,
+	}
+}
+
+// SetMappingRules sets the rules to map the untagged paths to the metric names and labels. Set them before the values are cached.
+func (exporter *Exporter) SetMappingRules(rules []*MappingRule) {
+	exporter.Lock()
+	defer exporter.Unlock()
+	exporter.rules = rules
+}
+
+// SetStaleness sets the period to keep the values which aren't updated. Zero keeps them forever.
+func (exporter *Exporter) SetStaleness(d time.Duration) {
+	exporter.Lock()
+	defer exporter.Unlock()
+	exporter.staleness = d
+}
+
+// SetTimestamps sets whether the timestamps of the datapoints are exposed.
+func (exporter *Exporter) SetTimestamps(flag bool) {
+	exporter.Lock()
+	defer exporter.Unlock()
+	exporter.timestamps = flag
+}
+
+// Len returns the number of the cached series.
+func (exporter *Exporter) Len() int {
+	exporter.RLock()
+	defer exporter.RUnlock()
+	return len(exporter.values)
+}
+
+// ProcessMetrics caches the latest datapoints of the specified metrics, and returns the metrics as they are.
+func (exporter *Exporter) ProcessMetrics(ms []*graphite.Metrics) []*graphite.Metrics {
+	exporter.update(ms, time.Now())
+	return ms
+}
+
+// InsertMetricsRequestReceived caches the latest datapoints of the specified metrics.
+func (exporter *Exporter) InsertMetricsRequestReceived(ms []*graphite.Metrics, err error) {
+	if err != nil {
+		return
+	}
+	exporter.update(ms, time.Now())
+}
+
+func (exporter *Exporter) update(ms []*graphite.Metrics, now time.Time) {
+	exporter.Lock()
+	defer exporter.Unlock()
+	for _, m := range ms {
+		if m == nil || len(m.DataPoints) == 0 {
+			continue
+		}
+		var last *graphite.DataPoint
+		for _, dp := range m.DataPoints {
+			if dp != nil && (last == nil || !dp.Timestamp.Before(last.Timestamp)) {
+				last = dp
+			}
+		}
+		if last == nil {
+			continue
+		}
+		v, ok := exporter.values[m.Name]
+		if !ok {
+			name, labels := MapName(m.Name, exporter.rules)
+			if len(name) == 0 {
+				continue
+			}
+			v = &cachedValue{name: name, labels: labels}
+			exporter.values[m.Name] = v
+		}
+		if last.Timestamp.Before(v.timestamp) {
+			continue
+		}
+		v.value = last.Value
+		v.timestamp = last.Timestamp
+		v.updated = now
+	}
+}
+
+// Evict removes the values which aren't updated within the staleness, and returns the number of the removed values.
+func (exporter *Exporter) Evict(now time.Time) int {
+	exporter.Lock()
+	defer exporter.Unlock()
+	if exporter.staleness <= 0 {
+		return 0
+	}
+	count := 0
+	for key, v := range exporter.values {
+		if now.Sub(v.updated) <= exporter.staleness {
+			continue
+		}
+		delete(exporter.values, key)
+		count++
+	}
+	return count
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func seriesString(name string, labels []Label) string {
+	if len(labels) == 0 {
+		return name
+	}
+	pairs := make([]string, 0, len(labels))
+	for _, label := range labels {
+		pairs = append(pairs, label.Name+`="`+labelValueReplacer.Replace(label.Value)+`"`)
+	}
+	return name + "{" + strings.Join(pairs, ",") + "}"
+}
+
+// WriteTo writes the cached values in the text exposition format after evicting the stale values.
+// The series which are mapped to the same name and labels are exposed with the latest value.
+func (exporter *Exporter) WriteTo(w io.Writer) (int64, error) {
+	exporter.Evict(time.Now())
+
+	exporter.RLock()
+	timestamps := exporter.timestamps
+	series := map[string]*cachedValue{}
+	families := map[string][]string{}
+	for _, v := range exporter.values {
+		key := seriesString(v.name, v.labels)
+		prev, ok := series[key]
+		if ok {
+			if v.timestamp.Before(prev.timestamp) {
+				continue
+			}
+		} else {
+			families[v.name] = append(families[v.name], key)
+		}
+		series[key] = v
+	}
+	exporter.RUnlock()
+
+	names := make([]string, 0, len(families))
+	for name := range families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	bw := bufio.NewWriter(w)
+	var n int64
+	write := func(s string) {
+		written, _ := bw.WriteString(s)
+		n += int64(written)
+	}
+	for _, name := range names {
+		write("# TYPE " + name + " untyped\n")
+		keys := families[name]
+		sort.Strings(keys)
+		for _, key := range keys {
+			v := series[key]
+			write(key + " " + formatFloat(v.value))
+			if timestamps {
+				write(" " + strconv.FormatInt(v.timestamp.UnixMilli(), 10))
+			}
+			write("\n")
+		}
+	}
+
+	return n, bw.Flush()
+}
+
+// HTTPRequestReceived exposes the cached values in the text exposition format.
+func (exporter *Exporter) HTTPRequestReceived(r *http.Request, w http.ResponseWriter) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set(httpHeaderContentType, ExpositionContentType)
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodHead {
+		return
+	}
+	exporter.WriteTo(w)
+}
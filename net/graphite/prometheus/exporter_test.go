@@ -0,0 +1,103 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package prometheus
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cybergarage/go-graphite/net/graphite"
+)
+
+func newTestMetrics(name string, value float64, ts time.Time) *graphite.Metrics {
+	m := graphite.NewMetrics()
+	m.SetName(name)
+	dp := graphite.NewDataPoint()
+	dp.SetTimestamp(ts)
+	dp.SetValue(value)
+	m.AddDataPoint(dp)
+	return m
+}
+
+func TestMapName(t *testing.T) {
+	rule, err := NewMappingRule("servers.*.cpu.*", "server_cpu_$2", map[string]string{"host": "$1", "dc": "us-east"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules := []*MappingRule{rule}
+
+	testCases := []struct {
+		name     string
+		expected string
+	}{
+		{"servers.web01.cpu.user", `server_cpu_user{dc="us-east",host="web01"}`},
+		{"servers.web01.load.shortterm", "servers_web01_load_shortterm"},
+		{"1min.load-avg", "_1min_load_avg"},
+		{"http_requests_total;job=api;code=200;status.class=2xx", `http_requests_total{code="200",job="api",status_class="2xx"}`},
+	}
+	for _, tc := range testCases {
+		name, labels := MapName(tc.name, rules)
+		if s := seriesString(name, labels); s != tc.expected {
+			t.Error(fmt.Errorf("%s : %s != %s", tc.name, s, tc.expected))
+		}
+	}
+
+	_, err = NewMappingRule("servers..cpu", "cpu", nil)
+	if err == nil {
+		t.Error(fmt.Errorf("invalid rule is created"))
+	}
+}
+
+func TestExporter(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	exporter := NewExporter()
+	exporter.SetTimestamps(true)
+
+	ms := []*graphite.Metrics{
+		newTestMetrics("servers.web01.cpu", 1.5, now.Add(-time.Second*20)),
+		newTestMetrics("servers.web01.cpu", 2.5, now.Add(-time.Second*10)),
+		newTestMetrics("http_requests_total;job=api;path=/a\"b", 10, now),
+	}
+	passed := exporter.ProcessMetrics(ms)
+	if len(passed) != len(ms) {
+		t.Error(fmt.Errorf("%d != %d", len(passed), len(ms)))
+	}
+
+	// The older datapoint doesn't overwrite the cached value.
+	exporter.ProcessMetrics([]*graphite.Metrics{newTestMetrics("servers.web01.cpu", 0.5, now.Add(-time.Minute))})
+
+	r := httptest.NewRequest(http.MethodGet, DefaultExpositionRequestPath, nil)
+	w := httptest.NewRecorder()
+	exporter.HTTPRequestReceived(r, w)
+	if w.Code != http.StatusOK {
+		t.Fatal(fmt.Errorf("%d != %d", w.Code, http.StatusOK))
+	}
+
+	expected := "# TYPE http_requests_total untyped\n" +
+		fmt.Sprintf("http_requests_total{job=\"api\",path=\"/a\\\"b\"} 10 %d\n", now.UnixMilli()) +
+		"# TYPE servers_web01_cpu untyped\n" +
+		fmt.Sprintf("servers_web01_cpu 2.5 %d\n", now.Add(-time.Second*10).UnixMilli())
+	if body := w.Body.String(); body != expected {
+		t.Error(fmt.Errorf("\n%s!=\n%s", body, expected))
+	}
+
+	exporter.SetStaleness(time.Minute)
+	if n := exporter.Evict(time.Now().Add(time.Minute * 2)); n != 2 {
+		t.Error(fmt.Errorf("%d != %d", n, 2))
+	}
+	if exporter.Len() != 0 {
+		t.Error(fmt.Errorf("%d != %d", exporter.Len(), 0))
+	}
+
+	var b strings.Builder
+	exporter.WriteTo(&b)
+	if b.Len() != 0 {
+		t.Error(fmt.Errorf("%s", b.String()))
+	}
+}
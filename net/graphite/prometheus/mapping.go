@@ -0,0 +1,147 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package prometheus
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cybergarage/go-graphite/net/graphite"
+)
+
+var (
+	mappingVariableRegex = regexp.MustCompile(`\$\{?(\d+)\}?`)
+	invalidNameRegex     = regexp.MustCompile(`[^a-zA-Z0-9_:]`)
+	invalidLabelRegex    = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+)
+
+// MappingRule maps the Graphite paths which match a pattern such as 'servers.*.cpu.*' to a metric name and labels
+// like the mappings of graphite_exporter. The nodes which have wildcards are referred as '$1', '$2' and so on in order.
+type MappingRule struct {
+	Match  string
+	Name   string
+	Labels map[string]string
+	nodes  []string
+}
+
+// NewMappingRule returns a new mapping rule.
+func NewMappingRule(match string, name string, labels map[string]string) (*MappingRule, error) {
+	rule := &MappingRule{
+		Match:  match,
+		Name:   name,
+		Labels: labels,
+		nodes:  strings.Split(match, graphiteNodeSep),
+	}
+	for _, node := range rule.nodes {
+		_, err := path.Match(node, "")
+		if len(node) == 0 || err != nil {
+			return nil, fmt.Errorf(errorInvalidMappingRule, match)
+		}
+	}
+	if len(name) == 0 {
+		return nil, fmt.Errorf(errorInvalidMappingRule, match)
+	}
+	return rule, nil
+}
+
+// captures returns the nodes which match the wildcards of the rule, and false when the path doesn't match.
+func (rule *MappingRule) captures(name string) ([]string, bool) {
+	nodes := strings.Split(name, graphiteNodeSep)
+	if len(nodes) != len(rule.nodes) {
+		return nil, false
+	}
+	captures := []string{}
+	for n, pattern := range rule.nodes {
+		ok, _ := path.Match(pattern, nodes[n])
+		if !ok {
+			return nil, false
+		}
+		if strings.ContainsAny(pattern, "*?[") {
+			captures = append(captures, nodes[n])
+		}
+	}
+	return captures, true
+}
+
+func expandMappingVariables(s string, captures []string) string {
+	return mappingVariableRegex.ReplaceAllStringFunc(s, func(v string) string {
+		n, _ := strconv.Atoi(mappingVariableRegex.FindStringSubmatch(v)[1])
+		if n < 1 || len(captures) < n {
+			return ""
+		}
+		return captures[n-1]
+	})
+}
+
+// Apply returns the metric name and the labels of the specified path, and false when the path doesn't match.
+func (rule *MappingRule) Apply(name string) (string, []Label, bool) {
+	captures, ok := rule.captures(name)
+	if !ok {
+		return "", nil, false
+	}
+	labels := []Label{}
+	for key, value := range rule.Labels {
+		value = expandMappingVariables(value, captures)
+		if len(value) == 0 {
+			continue
+		}
+		labels = append(labels, Label{Name: SanitizeLabelName(key), Value: value})
+	}
+	return SanitizeMetricName(expandMappingVariables(rule.Name, captures)), labels, true
+}
+
+// SanitizeMetricName replaces the characters which can't be used in the Prometheus metric names with '_'.
+func SanitizeMetricName(name string) string {
+	name = invalidNameRegex.ReplaceAllString(name, "_")
+	if 0 < len(name) && '0' <= name[0] && name[0] <= '9' {
+		name = "_" + name
+	}
+	return name
+}
+
+// SanitizeLabelName replaces the characters which can't be used in the Prometheus label names with '_'.
+func SanitizeLabelName(name string) string {
+	name = invalidLabelRegex.ReplaceAllString(name, "_")
+	if 0 < len(name) && '0' <= name[0] && name[0] <= '9' {
+		name = "_" + name
+	}
+	return name
+}
+
+// MapName returns the Prometheus metric name and the sorted labels of the specified Graphite name.
+// The tagged series are mapped to the labels of the tags, and the untagged series are mapped by the first matched rule,
+// otherwise the paths are sanitized such as 'servers_web01_cpu'.
+func MapName(name string, rules []*MappingRule) (string, []Label) {
+	var metricName string
+	labels := []Label{}
+	if strings.Contains(name, graphiteTagSep) {
+		seriesName, tags := graphite.ParseTaggedName(name)
+		metricName = SanitizeMetricName(seriesName)
+		for key, value := range tags {
+			if key == graphite.TagNameKey {
+				continue
+			}
+			labels = append(labels, Label{Name: SanitizeLabelName(key), Value: value})
+		}
+	} else {
+		metricName = SanitizeMetricName(name)
+		for _, rule := range rules {
+			ruleName, ruleLabels, ok := rule.Apply(name)
+			if ok {
+				metricName = ruleName
+				labels = ruleLabels
+				break
+			}
+		}
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		return labels[i].Name < labels[j].Name
+	})
+	return metricName, labels
+}
@@ -0,0 +1,138 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package prometheus
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// The minimal protobuf wire format to decode and encode the messages of the remote write and read protocols.
+// See : Protocol Buffers Encoding (https://protobuf.dev/programming-guides/encoding/)
+
+const (
+	protoWireVarint  = 0
+	protoWireFixed64 = 1
+	protoWireBytes   = 2
+	protoWireFixed32 = 5
+)
+
+// protoReader reads the fields of a protobuf message.
+type protoReader struct {
+	buf []byte
+}
+
+func newProtoReader(buf []byte) *protoReader {
+	return &protoReader{buf: buf}
+}
+
+// next returns the field number and the wire type of the next field, and false at the end of the message.
+func (r *protoReader) next() (int, int, bool, error) {
+	if len(r.buf) == 0 {
+		return 0, 0, false, nil
+	}
+	key, err := r.varint()
+	if err != nil {
+		return 0, 0, false, err
+	}
+	return int(key >> 3), int(key & 0x07), true, nil
+}
+
+func (r *protoReader) varint() (uint64, error) {
+	v, n := binary.Uvarint(r.buf)
+	if n <= 0 {
+		return 0, errors.New(errorCorruptedProtobuf)
+	}
+	r.buf = r.buf[n:]
+	return v, nil
+}
+
+func (r *protoReader) fixed64() (uint64, error) {
+	if len(r.buf) < 8 {
+		return 0, errors.New(errorCorruptedProtobuf)
+	}
+	v := binary.LittleEndian.Uint64(r.buf)
+	r.buf = r.buf[8:]
+	return v, nil
+}
+
+func (r *protoReader) double() (float64, error) {
+	v, err := r.fixed64()
+	return math.Float64frombits(v), err
+}
+
+func (r *protoReader) bytes() ([]byte, error) {
+	length, err := r.varint()
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(r.buf)) < length {
+		return nil, errors.New(errorCorruptedProtobuf)
+	}
+	b := r.buf[:length]
+	r.buf = r.buf[length:]
+	return b, nil
+}
+
+// skip skips the value of the specified wire type for the unknown fields.
+func (r *protoReader) skip(wireType int) error {
+	var err error
+	switch wireType {
+	case protoWireVarint:
+		_, err = r.varint()
+	case protoWireFixed64:
+		_, err = r.fixed64()
+	case protoWireBytes:
+		_, err = r.bytes()
+	case protoWireFixed32:
+		if len(r.buf) < 4 {
+			return errors.New(errorCorruptedProtobuf)
+		}
+		r.buf = r.buf[4:]
+	default:
+		return fmt.Errorf(errorUnsupportedWireType, wireType)
+	}
+	return err
+}
+
+// protoWriter writes the fields of a protobuf message.
+type protoWriter struct {
+	buf []byte
+}
+
+func newProtoWriter() *protoWriter {
+	return &protoWriter{buf: []byte{}}
+}
+
+func (w *protoWriter) key(field int, wireType int) {
+	w.buf = binary.AppendUvarint(w.buf, uint64(field)<<3|uint64(wireType))
+}
+
+func (w *protoWriter) varint(field int, v uint64) {
+	w.key(field, protoWireVarint)
+	w.buf = binary.AppendUvarint(w.buf, v)
+}
+
+func (w *protoWriter) double(field int, v float64) {
+	w.key(field, protoWireFixed64)
+	w.buf = binary.LittleEndian.AppendUint64(w.buf, math.Float64bits(v))
+}
+
+func (w *protoWriter) bytes(field int, b []byte) {
+	w.key(field, protoWireBytes)
+	w.buf = binary.AppendUvarint(w.buf, uint64(len(b)))
+	w.buf = append(w.buf, b...)
+}
+
+func (w *protoWriter) string(field int, s string) {
+	w.bytes(field, []byte(s))
+}
+
+// Bytes returns the written message.
+func (w *protoWriter) Bytes() []byte {
+	return w.buf
+}
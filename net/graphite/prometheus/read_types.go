@@ -0,0 +1,341 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package prometheus
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// MatchType represents a type of the label matchers.
+type MatchType int
+
+const (
+	// MatchEqual is the type of the '=' matchers.
+	MatchEqual MatchType = 0
+	// MatchNotEqual is the type of the '!=' matchers.
+	MatchNotEqual MatchType = 1
+	// MatchRegexp is the type of the '=~' matchers.
+	MatchRegexp MatchType = 2
+	// MatchNotRegexp is the type of the '!~' matchers.
+	MatchNotRegexp MatchType = 3
+)
+
+const (
+	// ResponseTypeSamples is the response type of the samples, which is the only supported type.
+	ResponseTypeSamples = 0
+)
+
+// LabelMatcher represents a label matcher of the read queries.
+type LabelMatcher struct {
+	Type  MatchType
+	Name  string
+	Value string
+	regex *regexp.Regexp
+}
+
+// NewLabelMatcher returns a new label matcher. The regular expressions are fully anchored like Prometheus.
+func NewLabelMatcher(t MatchType, name string, value string) (*LabelMatcher, error) {
+	m := &LabelMatcher{
+		Type:  t,
+		Name:  name,
+		Value: value,
+		regex: nil,
+	}
+	switch t {
+	case MatchEqual, MatchNotEqual:
+	case MatchRegexp, MatchNotRegexp:
+		regex, err := regexp.Compile("^(?:" + value + ")$")
+		if err != nil {
+			return nil, fmt.Errorf(errorInvalidMatcher, m.String())
+		}
+		m.regex = regex
+	default:
+		return nil, fmt.Errorf(errorInvalidMatcher, m.String())
+	}
+	return m, nil
+}
+
+// String returns the string of the matcher such as 'job=~"api.*"'.
+func (m *LabelMatcher) String() string {
+	ops := map[MatchType]string{
+		MatchEqual:     "=",
+		MatchNotEqual:  "!=",
+		MatchRegexp:    "=~",
+		MatchNotRegexp: "!~",
+	}
+	return fmt.Sprintf("%s%s%q", m.Name, ops[m.Type], m.Value)
+}
+
+// Match returns true when the specified label value matches. The missing labels are matched as the empty values.
+func (m *LabelMatcher) Match(value string) bool {
+	switch m.Type {
+	case MatchEqual:
+		return value == m.Value
+	case MatchNotEqual:
+		return value != m.Value
+	case MatchRegexp:
+		return m.regex.MatchString(value)
+	case MatchNotRegexp:
+		return !m.regex.MatchString(value)
+	}
+	return false
+}
+
+// Query represents a query of the read requests. The timestamps are milliseconds.
+type Query struct {
+	StartTimestampMs int64
+	EndTimestampMs   int64
+	Matchers         []*LabelMatcher
+}
+
+// ReadRequest represents a request of the remote read protocol.
+type ReadRequest struct {
+	Queries               []*Query
+	AcceptedResponseTypes []int
+}
+
+// QueryResult represents the series of a query.
+type QueryResult struct {
+	Timeseries []*TimeSeries
+}
+
+// ReadResponse represents a response of the remote read protocol.
+type ReadResponse struct {
+	Results []*QueryResult
+}
+
+func unmarshalLabelMatcher(b []byte) (*LabelMatcher, error) {
+	t := MatchEqual
+	name := ""
+	value := ""
+	r := newProtoReader(b)
+	for {
+		field, wireType, ok, err := r.next()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		switch {
+		case field == 1 && wireType == protoWireVarint:
+			v, err := r.varint()
+			if err != nil {
+				return nil, err
+			}
+			t = MatchType(v)
+		case field == 2 && wireType == protoWireBytes:
+			v, err := r.bytes()
+			if err != nil {
+				return nil, err
+			}
+			name = string(v)
+		case field == 3 && wireType == protoWireBytes:
+			v, err := r.bytes()
+			if err != nil {
+				return nil, err
+			}
+			value = string(v)
+		default:
+			err := r.skip(wireType)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return NewLabelMatcher(t, name, value)
+}
+
+func unmarshalQuery(b []byte) (*Query, error) {
+	q := &Query{
+		StartTimestampMs: 0,
+		EndTimestampMs:   0,
+		Matchers:         []*LabelMatcher{},
+	}
+	r := newProtoReader(b)
+	for {
+		field, wireType, ok, err := r.next()
+		if err != nil || !ok {
+			return q, err
+		}
+		switch {
+		case field == 1 && wireType == protoWireVarint:
+			v, err := r.varint()
+			if err != nil {
+				return nil, err
+			}
+			q.StartTimestampMs = int64(v)
+		case field == 2 && wireType == protoWireVarint:
+			v, err := r.varint()
+			if err != nil {
+				return nil, err
+			}
+			q.EndTimestampMs = int64(v)
+		case field == 3 && wireType == protoWireBytes:
+			v, err := r.bytes()
+			if err != nil {
+				return nil, err
+			}
+			m, err := unmarshalLabelMatcher(v)
+			if err != nil {
+				return nil, err
+			}
+			q.Matchers = append(q.Matchers, m)
+		default:
+			// The read hints aren't supported.
+			err := r.skip(wireType)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// UnmarshalReadRequest returns the read request of the specified protobuf message.
+func UnmarshalReadRequest(b []byte) (*ReadRequest, error) {
+	req := &ReadRequest{
+		Queries:               []*Query{},
+		AcceptedResponseTypes: []int{},
+	}
+	r := newProtoReader(b)
+	for {
+		field, wireType, ok, err := r.next()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return req, nil
+		}
+		switch {
+		case field == 1 && wireType == protoWireBytes:
+			v, err := r.bytes()
+			if err != nil {
+				return nil, err
+			}
+			q, err := unmarshalQuery(v)
+			if err != nil {
+				return nil, err
+			}
+			req.Queries = append(req.Queries, q)
+		case field == 2 && wireType == protoWireVarint:
+			v, err := r.varint()
+			if err != nil {
+				return nil, err
+			}
+			req.AcceptedResponseTypes = append(req.AcceptedResponseTypes, int(v))
+		case field == 2 && wireType == protoWireBytes:
+			// packed repeated enum
+			v, err := r.bytes()
+			if err != nil {
+				return nil, err
+			}
+			pr := newProtoReader(v)
+			for 0 < len(pr.buf) {
+				t, err := pr.varint()
+				if err != nil {
+					return nil, err
+				}
+				req.AcceptedResponseTypes = append(req.AcceptedResponseTypes, int(t))
+			}
+		default:
+			err := r.skip(wireType)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// Marshal returns the protobuf message of the read request.
+func (req *ReadRequest) Marshal() []byte {
+	w := newProtoWriter()
+	for _, q := range req.Queries {
+		qw := newProtoWriter()
+		qw.varint(1, uint64(q.StartTimestampMs))
+		qw.varint(2, uint64(q.EndTimestampMs))
+		for _, m := range q.Matchers {
+			mw := newProtoWriter()
+			mw.varint(1, uint64(m.Type))
+			mw.string(2, m.Name)
+			mw.string(3, m.Value)
+			qw.bytes(3, mw.Bytes())
+		}
+		w.bytes(1, qw.Bytes())
+	}
+	for _, t := range req.AcceptedResponseTypes {
+		w.varint(2, uint64(t))
+	}
+	return w.Bytes()
+}
+
+// UnmarshalReadResponse returns the read response of the specified protobuf message.
+func UnmarshalReadResponse(b []byte) (*ReadResponse, error) {
+	res := &ReadResponse{
+		Results: []*QueryResult{},
+	}
+	r := newProtoReader(b)
+	for {
+		field, wireType, ok, err := r.next()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return res, nil
+		}
+		if field != 1 || wireType != protoWireBytes {
+			err := r.skip(wireType)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		v, err := r.bytes()
+		if err != nil {
+			return nil, err
+		}
+		result := &QueryResult{Timeseries: []*TimeSeries{}}
+		qr := newProtoReader(v)
+		for {
+			field, wireType, ok, err := qr.next()
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				break
+			}
+			if field != 1 || wireType != protoWireBytes {
+				err := qr.skip(wireType)
+				if err != nil {
+					return nil, err
+				}
+				continue
+			}
+			tv, err := qr.bytes()
+			if err != nil {
+				return nil, err
+			}
+			ts, err := unmarshalTimeSeries(tv)
+			if err != nil {
+				return nil, err
+			}
+			result.Timeseries = append(result.Timeseries, ts)
+		}
+		res.Results = append(res.Results, result)
+	}
+}
+
+// Marshal returns the protobuf message of the read response.
+func (res *ReadResponse) Marshal() []byte {
+	w := newProtoWriter()
+	for _, result := range res.Results {
+		rw := newProtoWriter()
+		for _, ts := range result.Timeseries {
+			rw.bytes(1, ts.marshal())
+		}
+		w.bytes(1, rw.Bytes())
+	}
+	return w.Bytes()
+}
@@ -0,0 +1,345 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cybergarage/go-graphite/net/graphite"
+)
+
+const (
+	// DefaultRemoteReadRequestPath is the default path of the remote read endpoint.
+	DefaultRemoteReadRequestPath = "/api/v1/read"
+)
+
+const (
+	httpHeaderContentType    = "Content-Type"
+	httpContentTypeProtobuf  = "application/x-protobuf"
+	graphiteNodeSep          = "."
+	graphitePathPatternChars = "*?[]{},"
+)
+
+// RemoteReader is a graphite.RenderAuthorizedHTTPRequestListener which answers the read requests of the remote read protocol
+// with the series of the render listener. The label matchers are translated into a tag query such as
+// "seriesByTag('name=http_requests_total','job=~(?:api.*)$')", and into a path query with the path labels.
+// The path rules and the tenancy of Render are applied to the series like the render requests.
+type RemoteReader struct {
+	sync.Mutex
+	renderListener graphite.RenderRequestListener
+	pathLabels     []string
+}
+
+// NewRemoteReader returns a new remote read endpoint.
+func NewRemoteReader() *RemoteReader {
+	return &RemoteReader{
+		Mutex:          sync.Mutex{},
+		renderListener: nil,
+		pathLabels:     []string{},
+	}
+}
+
+// SetRenderListener sets the listener which is queried.
+func (reader *RemoteReader) SetRenderListener(listener graphite.RenderRequestListener) {
+	reader.Lock()
+	defer reader.Unlock()
+	reader.renderListener = listener
+}
+
+// SetPathLabels sets the label names of the nodes of the untagged series such as '', 'host' and '__name__' for 'servers.web01.cpu'.
+// The nodes of the empty label names are ignored, and the untagged series which have another number of nodes are named by the paths.
+func (reader *RemoteReader) SetPathLabels(labels []string) {
+	reader.Lock()
+	defer reader.Unlock()
+	reader.pathLabels = labels
+}
+
+// GetPathLabels returns the label names of the nodes of the untagged series.
+func (reader *RemoteReader) GetPathLabels() []string {
+	reader.Lock()
+	defer reader.Unlock()
+	return reader.pathLabels
+}
+
+// Labels returns the sorted labels of the specified series name.
+func (reader *RemoteReader) Labels(name string) []Label {
+	labels := []Label{}
+	pathLabels := reader.GetPathLabels()
+	nodes := strings.Split(name, graphiteNodeSep)
+	switch {
+	case strings.Contains(name, graphiteTagSep):
+		seriesName, tags := graphite.ParseTaggedName(name)
+		labels = append(labels, Label{Name: MetricNameLabel, Value: seriesName})
+		for key, value := range tags {
+			if key == graphite.TagNameKey {
+				continue
+			}
+			labels = append(labels, Label{Name: key, Value: value})
+		}
+	case 0 < len(pathLabels) && len(nodes) == len(pathLabels):
+		for n, label := range pathLabels {
+			if len(label) == 0 {
+				continue
+			}
+			labels = append(labels, Label{Name: label, Value: nodes[n]})
+		}
+	default:
+		labels = append(labels, Label{Name: MetricNameLabel, Value: name})
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		return labels[i].Name < labels[j].Name
+	})
+	return labels
+}
+
+// tagQuery returns the Graphite tag query of the specified matchers.
+func tagQuery(matchers []*LabelMatcher) (string, error) {
+	exprs := []*graphite.TagExpression{}
+	for _, m := range matchers {
+		key := m.Name
+		if key == MetricNameLabel {
+			key = graphite.TagNameKey
+		}
+		var expr *graphite.TagExpression
+		var err error
+		switch m.Type {
+		case MatchEqual:
+			expr, err = graphite.NewTagExpression(key, graphite.TagEqual, m.Value)
+		case MatchNotEqual:
+			expr, err = graphite.NewTagExpression(key, graphite.TagNotEqual, m.Value)
+		case MatchRegexp:
+			// Graphite anchors the regular expressions only at the beginning.
+			expr, err = graphite.NewTagExpression(key, graphite.TagMatch, "(?:"+m.Value+")$")
+		case MatchNotRegexp:
+			expr, err = graphite.NewTagExpression(key, graphite.TagNotMatch, "(?:"+m.Value+")$")
+		}
+		if err != nil {
+			return "", err
+		}
+		exprs = append(exprs, expr)
+	}
+	return graphite.NewTagQuery(exprs...).String(), nil
+}
+
+// pathQuery returns the Graphite path pattern of the path labels whose nodes are the values of the equal matchers or wildcards.
+func pathQuery(pathLabels []string, matchers []*LabelMatcher) string {
+	nodes := make([]string, len(pathLabels))
+	for n, label := range pathLabels {
+		nodes[n] = "*"
+		for _, m := range matchers {
+			if m.Name != label || m.Type != MatchEqual || len(m.Value) == 0 {
+				continue
+			}
+			if strings.ContainsAny(m.Value, graphitePathPatternChars+graphiteNodeSep) {
+				continue
+			}
+			nodes[n] = m.Value
+		}
+	}
+	return strings.Join(nodes, graphiteNodeSep)
+}
+
+// Targets returns the Graphite targets of the specified matchers.
+func (reader *RemoteReader) Targets(matchers []*LabelMatcher) ([]string, error) {
+	target, err := tagQuery(matchers)
+	if err != nil {
+		return nil, err
+	}
+	targets := []string{target}
+	pathLabels := reader.GetPathLabels()
+	if 0 < len(pathLabels) {
+		targets = append(targets, pathQuery(pathLabels, matchers))
+	}
+	return targets, nil
+}
+
+// matchLabels returns true when the labels match all matchers.
+func matchLabels(labels []Label, matchers []*LabelMatcher) bool {
+	for _, m := range matchers {
+		value := ""
+		for _, label := range labels {
+			if label.Name == m.Name {
+				value = label.Value
+				break
+			}
+		}
+		if !m.Match(value) {
+			return false
+		}
+	}
+	return true
+}
+
+// queryListener calls the most specific method of the specified listener for the query of the tenant.
+func queryListener(ctx context.Context, listener graphite.RenderRequestListener, tenant string, q *graphite.Query) ([]*graphite.Metrics, error) {
+	switch l := listener.(type) {
+	case graphite.ContextRenderRequestListener:
+		return l.QueryMetricsRequestReceivedContext(ctx, q, nil)
+	case graphite.TenantRenderRequestListener:
+		if 0 < len(tenant) {
+			return l.QueryTenantMetricsRequestReceived(tenant, q, nil)
+		}
+	}
+	return listener.QueryMetricsRequestReceived(q, nil)
+}
+
+// Read returns the series of the specified query.
+func (reader *RemoteReader) Read(q *Query) (*QueryResult, error) {
+	return reader.read(context.Background(), q, "", "", nil)
+}
+
+// ReadRequest returns the series of the specified query which the principal and the tenant of the request can see with the path rules and the tenancy.
+func (reader *RemoteReader) ReadRequest(r *http.Request, q *Query, auth graphite.RenderAuthorizer) (*QueryResult, error) {
+	principal, _ := graphite.PrincipalFromRequest(r)
+	tenant, _ := graphite.TenantFromRequest(r)
+	return reader.read(r.Context(), q, principal, tenant, auth)
+}
+
+// read returns the series of the specified query of the principal and the tenant, and the nil authorizer doesn't filter the series.
+func (reader *RemoteReader) read(ctx context.Context, q *Query, principal string, tenant string, auth graphite.RenderAuthorizer) (*QueryResult, error) {
+	reader.Lock()
+	listener := reader.renderListener
+	reader.Unlock()
+	if listener == nil {
+		return nil, errors.New(errorMissingListener)
+	}
+
+	var rules *graphite.PathRules
+	var tenancy *graphite.Tenancy
+	if auth != nil {
+		rules = auth.GetPathRules()
+		tenancy = auth.GetTenancy()
+	}
+	if tenancy == nil {
+		tenant = ""
+	}
+	if 0 < len(tenant) && tenancy.GetMode() == graphite.TenantIsolate {
+		switch listener.(type) {
+		case graphite.ContextRenderRequestListener, graphite.TenantRenderRequestListener:
+		default:
+			return nil, fmt.Errorf(errorTenantListener, tenant)
+		}
+	}
+
+	targets, err := reader.Targets(q.Matchers)
+	if err != nil {
+		return nil, err
+	}
+
+	from := time.UnixMilli(q.StartTimestampMs)
+	until := time.UnixMilli(q.EndTimestampMs)
+	result := &QueryResult{
+		Timeseries: []*TimeSeries{},
+	}
+	found := map[string]bool{}
+	for _, target := range targets {
+		if 0 < len(tenant) {
+			target, err = tenancy.TenantTarget(tenant, target)
+			if err != nil {
+				return nil, err
+			}
+		}
+		gq := graphite.NewQuery()
+		gq.Target = target
+		gq.From = &from
+		gq.Until = &until
+		ms, err := queryListener(ctx, listener, tenant, gq)
+		if err != nil {
+			return nil, err
+		}
+		if 0 < len(tenant) {
+			ms = tenancy.StripTenantMetrics(tenant, ms)
+		}
+		for _, m := range ms {
+			if m == nil || found[m.Name] {
+				continue
+			}
+			if rules != nil && !rules.IsAllowed(principal, m.Name) {
+				continue
+			}
+			labels := reader.Labels(m.Name)
+			if !matchLabels(labels, q.Matchers) {
+				continue
+			}
+			found[m.Name] = true
+			ts := &TimeSeries{
+				Labels:  labels,
+				Samples: []Sample{},
+			}
+			for _, dp := range m.DataPoints {
+				if dp == nil || math.IsNaN(dp.Value) {
+					continue
+				}
+				ms := dp.Timestamp.UnixMilli()
+				if ms < q.StartTimestampMs || q.EndTimestampMs < ms {
+					continue
+				}
+				ts.Samples = append(ts.Samples, Sample{Value: dp.Value, Timestamp: ms})
+			}
+			result.Timeseries = append(result.Timeseries, ts)
+		}
+	}
+
+	sort.Slice(result.Timeseries, func(i, j int) bool {
+		return fmt.Sprint(result.Timeseries[i].Labels) < fmt.Sprint(result.Timeseries[j].Labels)
+	})
+
+	return result, nil
+}
+
+// HTTPRequestReceived handles the snappy compressed protobuf requests of the remote read protocol, and returns the samples.
+func (reader *RemoteReader) HTTPRequestReceived(r *http.Request, w http.ResponseWriter) {
+	reader.AuthorizedHTTPRequestReceived(r, w, nil)
+}
+
+// AuthorizedHTTPRequestReceived handles the requests of the remote read protocol, and returns the samples which
+// the principal and the tenant of the request can see with the path rules and the tenancy of Render.
+func (reader *RemoteReader) AuthorizedHTTPRequestReceived(r *http.Request, w http.ResponseWriter, auth graphite.RenderAuthorizer) {
+	if r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := readProtobufRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req, err := UnmarshalReadRequest(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if 0 < len(req.AcceptedResponseTypes) && !slices.Contains(req.AcceptedResponseTypes, ResponseTypeSamples) {
+		http.Error(w, fmt.Sprintf(errorUnsupportedResponse, req.AcceptedResponseTypes), http.StatusBadRequest)
+		return
+	}
+
+	res := &ReadResponse{
+		Results: []*QueryResult{},
+	}
+	for _, q := range req.Queries {
+		result, err := reader.ReadRequest(r, q, auth)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		res.Results = append(res.Results, result)
+	}
+
+	w.Header().Set(httpHeaderContentType, httpContentTypeProtobuf)
+	w.Header().Set(httpHeaderContentEncoding, httpContentEncodingSnappy)
+	w.WriteHeader(http.StatusOK)
+	w.Write(SnappyEncode(res.Marshal()))
+}
@@ -0,0 +1,284 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package prometheus
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cybergarage/go-graphite/net/graphite"
+	"github.com/cybergarage/go-graphite/net/graphite/memory"
+)
+
+func newTestStore(t *testing.T, now time.Time) *memory.Store {
+	t.Helper()
+	store := memory.NewStore()
+	names := []string{
+		"http_requests_total;code=200;job=api",
+		"http_requests_total;code=500;job=api",
+		"http_requests_total;code=200;job=web",
+		"servers.web01.cpu",
+		"servers.db01.cpu",
+		"servers.web01.load.shortterm",
+	}
+	ms := []*graphite.Metrics{}
+	for n, name := range names {
+		m := graphite.NewMetrics()
+		m.SetName(name)
+		dp := graphite.NewDataPoint()
+		dp.SetTimestamp(now.Add(-time.Minute))
+		dp.SetValue(float64(n))
+		m.AddDataPoint(dp)
+		ms = append(ms, m)
+	}
+	store.InsertMetricsRequestReceived(ms, nil)
+	return store
+}
+
+func newTestMatcher(t *testing.T, mt MatchType, name string, value string) *LabelMatcher {
+	t.Helper()
+	m, err := NewLabelMatcher(mt, name, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestRemoteReaderRead(t *testing.T) {
+	now := time.Now().Truncate(time.Minute)
+	reader := NewRemoteReader()
+	reader.SetRenderListener(newTestStore(t, now))
+	reader.SetPathLabels([]string{"", "host", MetricNameLabel})
+
+	testCases := []struct {
+		matchers []*LabelMatcher
+		expected []string
+	}{
+		{
+			[]*LabelMatcher{newTestMatcher(t, MatchEqual, MetricNameLabel, "http_requests_total")},
+			[]string{
+				"[{__name__ http_requests_total} {code 200} {job api}]",
+				"[{__name__ http_requests_total} {code 200} {job web}]",
+				"[{__name__ http_requests_total} {code 500} {job api}]",
+			},
+		},
+		{
+			[]*LabelMatcher{
+				newTestMatcher(t, MatchEqual, MetricNameLabel, "http_requests_total"),
+				newTestMatcher(t, MatchNotEqual, "code", "500"),
+				newTestMatcher(t, MatchRegexp, "job", "a.*"),
+			},
+			[]string{"[{__name__ http_requests_total} {code 200} {job api}]"},
+		},
+		{
+			[]*LabelMatcher{
+				newTestMatcher(t, MatchEqual, MetricNameLabel, "http_requests_total"),
+				newTestMatcher(t, MatchNotRegexp, "job", "a"),
+			},
+			[]string{
+				"[{__name__ http_requests_total} {code 200} {job api}]",
+				"[{__name__ http_requests_total} {code 200} {job web}]",
+				"[{__name__ http_requests_total} {code 500} {job api}]",
+			},
+		},
+		{
+			[]*LabelMatcher{
+				newTestMatcher(t, MatchEqual, MetricNameLabel, "cpu"),
+				newTestMatcher(t, MatchRegexp, "host", "web.*"),
+			},
+			[]string{"[{__name__ cpu} {host web01}]"},
+		},
+		{
+			[]*LabelMatcher{newTestMatcher(t, MatchEqual, MetricNameLabel, "servers.web01.load.shortterm")},
+			[]string{"[{__name__ servers.web01.load.shortterm}]"},
+		},
+	}
+
+	for _, tc := range testCases {
+		q := &Query{
+			StartTimestampMs: now.Add(-time.Hour).UnixMilli(),
+			EndTimestampMs:   now.UnixMilli(),
+			Matchers:         tc.matchers,
+		}
+		result, err := reader.Read(q)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if len(result.Timeseries) != len(tc.expected) {
+			t.Error(fmt.Errorf("%v : %d != %d", tc.matchers, len(result.Timeseries), len(tc.expected)))
+			continue
+		}
+		for n, ts := range result.Timeseries {
+			labels := fmt.Sprintf("%v", ts.Labels)
+			if labels != tc.expected[n] {
+				t.Error(fmt.Errorf("%s != %s", labels, tc.expected[n]))
+			}
+			if len(ts.Samples) != 1 || ts.Samples[0].Timestamp != now.Add(-time.Minute).UnixMilli() {
+				t.Error(fmt.Errorf("%v", ts.Samples))
+			}
+		}
+	}
+}
+
+func TestRemoteReaderHTTP(t *testing.T) {
+	now := time.Now().Truncate(time.Minute)
+	reader := NewRemoteReader()
+	reader.SetRenderListener(newTestStore(t, now))
+
+	req := &ReadRequest{
+		Queries: []*Query{
+			{
+				StartTimestampMs: now.Add(-time.Hour).UnixMilli(),
+				EndTimestampMs:   now.UnixMilli(),
+				Matchers: []*LabelMatcher{
+					newTestMatcher(t, MatchEqual, MetricNameLabel, "http_requests_total"),
+					newTestMatcher(t, MatchEqual, "job", "web"),
+				},
+			},
+		},
+		AcceptedResponseTypes: []int{ResponseTypeSamples},
+	}
+	r := httptest.NewRequest(http.MethodPost, DefaultRemoteReadRequestPath, bytes.NewReader(SnappyEncode(req.Marshal())))
+	r.Header.Set("Content-Encoding", "snappy")
+	w := httptest.NewRecorder()
+	reader.HTTPRequestReceived(r, w)
+	if w.Code != http.StatusOK {
+		t.Fatal(fmt.Errorf("%d != %d : %s", w.Code, http.StatusOK, w.Body.String()))
+	}
+
+	body, _ := io.ReadAll(w.Body)
+	decoded, err := SnappyDecode(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := UnmarshalReadResponse(decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Results) != 1 || len(res.Results[0].Timeseries) != 1 {
+		t.Fatal(fmt.Errorf("%v", res.Results))
+	}
+	ts := res.Results[0].Timeseries[0]
+	if ts.MetricName() != "http_requests_total" || len(ts.Samples) != 1 || ts.Samples[0].Value != 2 {
+		t.Error(fmt.Errorf("%v", ts))
+	}
+
+	req.AcceptedResponseTypes = []int{1}
+	r = httptest.NewRequest(http.MethodPost, DefaultRemoteReadRequestPath, bytes.NewReader(SnappyEncode(req.Marshal())))
+	w = httptest.NewRecorder()
+	reader.HTTPRequestReceived(r, w)
+	if w.Code != http.StatusBadRequest {
+		t.Error(fmt.Errorf("%d != %d", w.Code, http.StatusBadRequest))
+	}
+}
+
+type testHeaderAuthenticator struct{}
+
+func (auth *testHeaderAuthenticator) AuthenticateRequest(r *http.Request) (string, bool) {
+	principal := r.Header.Get("X-Principal")
+	return principal, 0 < len(principal)
+}
+
+func serveTestReadRequest(t *testing.T, render *graphite.Render, header map[string]string, matchers ...*LabelMatcher) (int, []string) {
+	t.Helper()
+	now := time.Now()
+	req := &ReadRequest{
+		Queries: []*Query{
+			{
+				StartTimestampMs: now.Add(-time.Hour).UnixMilli(),
+				EndTimestampMs:   now.UnixMilli(),
+				Matchers:         matchers,
+			},
+		},
+	}
+	r := httptest.NewRequest(http.MethodPost, DefaultRemoteReadRequestPath, bytes.NewReader(SnappyEncode(req.Marshal())))
+	for name, value := range header {
+		r.Header.Set(name, value)
+	}
+	w := httptest.NewRecorder()
+	render.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		return w.Code, nil
+	}
+
+	decoded, err := SnappyDecode(w.Body.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := UnmarshalReadResponse(decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, ts := range res.Results[0].Timeseries {
+		names = append(names, ts.MetricName())
+	}
+	return w.Code, names
+}
+
+func TestRemoteReaderAuthorization(t *testing.T) {
+	now := time.Now().Truncate(time.Minute)
+	reader := NewRemoteReader()
+	reader.SetRenderListener(newTestStore(t, now))
+
+	rules := graphite.NewPathRules()
+	err := rules.AddRule("alice", "servers.web01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	render := graphite.NewRender()
+	render.AddAuthenticator(&testHeaderAuthenticator{})
+	render.SetPathRules(rules)
+	render.SetHTTPRequestListener(DefaultRemoteReadRequestPath, reader)
+	render.SetHTTPRequestListener(DefaultExpositionRequestPath, NewExporter())
+
+	// The hidden series aren't returned for any names.
+	_, names := serveTestReadRequest(t, render, map[string]string{"X-Principal": "alice"}, newTestMatcher(t, MatchRegexp, MetricNameLabel, ".*"))
+	if len(names) != 2 || names[0] != "servers.web01.cpu" || names[1] != "servers.web01.load.shortterm" {
+		t.Error(fmt.Errorf("%v", names))
+	}
+
+	// The extra listeners which don't apply the rules are forbidden.
+	r := httptest.NewRequest(http.MethodGet, DefaultExpositionRequestPath, nil)
+	r.Header.Set("X-Principal", "alice")
+	w := httptest.NewRecorder()
+	render.ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Error(fmt.Errorf("%d != %d", w.Code, http.StatusForbidden))
+	}
+}
+
+func TestRemoteReaderTenancy(t *testing.T) {
+	store := memory.NewStore()
+	for _, name := range []string{"team-a.servers.web01.cpu", "team-b.servers.db01.cpu"} {
+		m := graphite.NewMetrics()
+		m.SetName(name)
+		dp := graphite.NewDataPoint()
+		dp.SetTimestamp(time.Now().Add(-time.Minute))
+		dp.SetValue(1)
+		m.AddDataPoint(dp)
+		store.AddMetrics(m)
+	}
+	reader := NewRemoteReader()
+	reader.SetRenderListener(store)
+
+	tenancy := graphite.NewTenancy(graphite.TenantPrefix)
+	tenancy.SetHeader(graphite.DefaultTenantHeader)
+	render := graphite.NewRender()
+	render.SetTenancy(tenancy)
+	render.SetHTTPRequestListener(DefaultRemoteReadRequestPath, reader)
+
+	// The series of the other tenants aren't returned, and the names are stripped.
+	_, names := serveTestReadRequest(t, render, map[string]string{graphite.DefaultTenantHeader: "team-a"}, newTestMatcher(t, MatchRegexp, MetricNameLabel, ".*"))
+	if len(names) != 1 || names[0] != "servers.web01.cpu" {
+		t.Error(fmt.Errorf("%v", names))
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package prometheus provides the remote write and read protocols and the text exposition format of Prometheus over Graphite metrics
+// without the dependencies of the protobuf and snappy packages.
+package prometheus
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cybergarage/go-graphite/net/graphite"
+)
+
+const (
+	// DefaultRemoteWriteRequestPath is the default path of the remote write endpoint.
+	DefaultRemoteWriteRequestPath = "/api/v1/write"
+	// MaxRequestSize is the maximum size of the compressed requests.
+	MaxRequestSize = 1 << 25
+)
+
+const (
+	httpHeaderContentEncoding = "Content-Encoding"
+	httpContentEncodingSnappy = "snappy"
+	graphiteTagSep            = ";"
+	graphiteTagValue          = "="
+)
+
+// tagReplacer replaces the characters which can't be used in the Graphite tags.
+var tagReplacer = strings.NewReplacer(";", "_", "~", "_", "=", "_", " ", "_")
+
+// TaggedName returns the Graphite tagged series name of the specified labels such as 'http_requests_total;code=200;job=api'.
+// The '__name__' label is the series name, and the other labels are the tags in the order of the names.
+func TaggedName(labels []Label) string {
+	name := ""
+	tags := []string{}
+	for _, label := range labels {
+		if label.Name == MetricNameLabel {
+			name = tagReplacer.Replace(label.Value)
+			continue
+		}
+		if len(label.Value) == 0 {
+			continue
+		}
+		tags = append(tags, tagReplacer.Replace(label.Name)+graphiteTagValue+tagReplacer.Replace(label.Value))
+	}
+	if len(name) == 0 {
+		return ""
+	}
+	sort.Strings(tags)
+	for _, tag := range tags {
+		name += graphiteTagSep + tag
+	}
+	return name
+}
+
+// RemoteWriter is a graphite.RenderHTTPRequestListener which receives the samples of the remote write protocol,
+// and passes them to the listener as the Graphite tagged series.
+type RemoteWriter struct {
+	sync.Mutex
+	carbonListener graphite.CarbonListener
+}
+
+// NewRemoteWriter returns a new remote write receiver.
+func NewRemoteWriter() *RemoteWriter {
+	return &RemoteWriter{
+		Mutex:          sync.Mutex{},
+		carbonListener: nil,
+	}
+}
+
+// SetCarbonListener sets the listener of the tagged series converted from the remote write samples.
+// Set the listener of Carbon.GetFeedListener() to store the samples through the ingest path of the Carbon server.
+func (writer *RemoteWriter) SetCarbonListener(listener graphite.CarbonListener) {
+	writer.Lock()
+	defer writer.Unlock()
+	writer.carbonListener = listener
+}
+
+// Metrics returns the Graphite metrics of the specified write request, and the first error of the series which have no name.
+// The staleness markers are skipped.
+func (writer *RemoteWriter) Metrics(req *WriteRequest) ([]*graphite.Metrics, error) {
+	var firstErr error
+	ms := []*graphite.Metrics{}
+	for _, ts := range req.Timeseries {
+		name := TaggedName(ts.Labels)
+		if len(name) == 0 {
+			if firstErr == nil {
+				firstErr = fmt.Errorf(errorMissingMetricName, fmt.Sprintf("%v", ts.Labels))
+			}
+			continue
+		}
+		m := graphite.NewMetrics()
+		m.SetName(name)
+		for _, sample := range ts.Samples {
+			if sample.IsStaleMarker() {
+				continue
+			}
+			dp := graphite.NewDataPoint()
+			dp.SetTimestamp(time.UnixMilli(sample.Timestamp))
+			dp.SetValue(sample.Value)
+			m.AddDataPoint(dp)
+		}
+		if len(m.DataPoints) == 0 {
+			continue
+		}
+		ms = append(ms, m)
+	}
+	return ms, firstErr
+}
+
+// HTTPRequestReceived handles the snappy compressed protobuf requests of the remote write protocol.
+// The valid series are written even if the request has invalid series, and the response is 400 so that Prometheus doesn't retry them.
+func (writer *RemoteWriter) HTTPRequestReceived(r *http.Request, w http.ResponseWriter) {
+	writer.AuthorizedHTTPRequestReceived(r, w, nil)
+}
+
+// AuthorizedHTTPRequestReceived handles the requests of the remote write protocol with the path rules and the tenancy of Render.
+// The series are prefixed with the tenant of the request, and no series are written with 403 when the principal can't write one of them.
+func (writer *RemoteWriter) AuthorizedHTTPRequestReceived(r *http.Request, w http.ResponseWriter, auth graphite.RenderAuthorizer) {
+	if r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	req, err := readProtobufRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeReq, err := UnmarshalWriteRequest(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ms, err := writer.Metrics(writeReq)
+
+	writer.Lock()
+	listener := writer.carbonListener
+	writer.Unlock()
+	if listener != nil && 0 < len(ms) {
+		err := graphite.InsertRequestMetrics(r, auth, listener, ms)
+		if err != nil {
+			http.Error(w, err.Error(), graphite.AsRenderError(err).StatusCode())
+			return
+		}
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// readProtobufRequest returns the decoded body of the snappy compressed protobuf request.
+func readProtobufRequest(r *http.Request) ([]byte, error) {
+	encoding := r.Header.Get(httpHeaderContentEncoding)
+	if 0 < len(encoding) && encoding != httpContentEncodingSnappy {
+		return nil, fmt.Errorf(errorUnsupportedEncoding, encoding)
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, MaxRequestSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if MaxRequestSize < len(body) {
+		return nil, fmt.Errorf(errorTooLargeRequest, len(body))
+	}
+	return SnappyDecode(body)
+}
@@ -0,0 +1,172 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package prometheus
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/cybergarage/go-graphite/net/graphite"
+)
+
+type testListener struct {
+	sync.Mutex
+	metrics []*graphite.Metrics
+}
+
+func (l *testListener) InsertMetricsRequestReceived(ms []*graphite.Metrics, err error) {
+	l.Lock()
+	defer l.Unlock()
+	l.metrics = append(l.metrics, ms...)
+}
+
+func newTestWriteRequest() *WriteRequest {
+	return &WriteRequest{
+		Timeseries: []*TimeSeries{
+			{
+				Labels: []Label{
+					{MetricNameLabel, "http_requests_total"},
+					{"job", "api"},
+					{"code", "200"},
+				},
+				Samples: []Sample{
+					{Value: 10, Timestamp: 1700000000000},
+					{Value: 12, Timestamp: 1700000015000},
+					{Value: math.Float64frombits(staleNaN), Timestamp: 1700000030000},
+				},
+			},
+			{
+				Labels:  []Label{{"job", "api"}},
+				Samples: []Sample{{Value: 1, Timestamp: 1700000000000}},
+			},
+		},
+	}
+}
+
+func TestWriteRequestMarshal(t *testing.T) {
+	req := newTestWriteRequest()
+	decoded, err := UnmarshalWriteRequest(req.Marshal())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Timeseries) != len(req.Timeseries) {
+		t.Fatal(fmt.Errorf("%d != %d", len(decoded.Timeseries), len(req.Timeseries)))
+	}
+	for n, ts := range req.Timeseries {
+		if fmt.Sprintf("%v", decoded.Timeseries[n].Labels) != fmt.Sprintf("%v", ts.Labels) {
+			t.Error(fmt.Errorf("%v != %v", decoded.Timeseries[n].Labels, ts.Labels))
+		}
+		for i, sample := range ts.Samples {
+			got := decoded.Timeseries[n].Samples[i]
+			if math.Float64bits(got.Value) != math.Float64bits(sample.Value) || got.Timestamp != sample.Timestamp {
+				t.Error(fmt.Errorf("%v != %v", got, sample))
+			}
+		}
+	}
+
+	_, err = UnmarshalWriteRequest([]byte{0x0a, 0x10, 0x01})
+	if err == nil {
+		t.Error(fmt.Errorf("corrupted request is decoded"))
+	}
+}
+
+func TestRemoteWriter(t *testing.T) {
+	listener := &testListener{}
+	writer := NewRemoteWriter()
+	writer.SetCarbonListener(listener)
+
+	body := SnappyEncode(newTestWriteRequest().Marshal())
+	r := httptest.NewRequest(http.MethodPost, DefaultRemoteWriteRequestPath, bytes.NewReader(body))
+	r.Header.Set("Content-Encoding", "snappy")
+	r.Header.Set("Content-Type", "application/x-protobuf")
+	w := httptest.NewRecorder()
+	writer.HTTPRequestReceived(r, w)
+
+	// The series which has no name is rejected, and the others are written.
+	if w.Code != http.StatusBadRequest {
+		t.Error(fmt.Errorf("%d != %d", w.Code, http.StatusBadRequest))
+	}
+	if len(listener.metrics) != 1 {
+		t.Fatal(fmt.Errorf("%d != %d", len(listener.metrics), 1))
+	}
+	m := listener.metrics[0]
+	expected := "http_requests_total;code=200;job=api"
+	if m.Name != expected {
+		t.Error(fmt.Errorf("%s != %s", m.Name, expected))
+	}
+	if len(m.DataPoints) != 2 {
+		t.Fatal(fmt.Errorf("%d != %d", len(m.DataPoints), 2))
+	}
+	if m.DataPoints[1].UnixTimestamp() != 1700000015 || m.DataPoints[1].Value != 12 {
+		t.Error(fmt.Errorf("%d %f", m.DataPoints[1].UnixTimestamp(), m.DataPoints[1].Value))
+	}
+
+	req := newTestWriteRequest()
+	req.Timeseries = req.Timeseries[:1]
+	r = httptest.NewRequest(http.MethodPost, DefaultRemoteWriteRequestPath, bytes.NewReader(SnappyEncode(req.Marshal())))
+	w = httptest.NewRecorder()
+	writer.HTTPRequestReceived(r, w)
+	if w.Code != http.StatusNoContent {
+		t.Error(fmt.Errorf("%d != %d", w.Code, http.StatusNoContent))
+	}
+
+	r = httptest.NewRequest(http.MethodPost, DefaultRemoteWriteRequestPath, bytes.NewReader(body))
+	r.Header.Set("Content-Encoding", "gzip")
+	w = httptest.NewRecorder()
+	writer.HTTPRequestReceived(r, w)
+	if w.Code != http.StatusBadRequest {
+		t.Error(fmt.Errorf("%d != %d", w.Code, http.StatusBadRequest))
+	}
+}
+
+func TestRemoteWriterAuthorization(t *testing.T) {
+	listener := &testListener{}
+	writer := NewRemoteWriter()
+	writer.SetCarbonListener(listener)
+
+	rules := graphite.NewPathRules()
+	rules.AddRule("", "http_requests_total")
+	tenancy := graphite.NewTenancy(graphite.TenantPrefix)
+	tenancy.SetHeader(graphite.DefaultTenantHeader)
+	render := graphite.NewRender()
+	render.SetPathRules(rules)
+	render.SetTenancy(tenancy)
+	render.SetHTTPRequestListener(DefaultRemoteWriteRequestPath, writer)
+
+	write := func(req *WriteRequest) int {
+		r := httptest.NewRequest(http.MethodPost, DefaultRemoteWriteRequestPath, bytes.NewReader(SnappyEncode(req.Marshal())))
+		r.Header.Set(graphite.DefaultTenantHeader, "team-a")
+		w := httptest.NewRecorder()
+		render.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	// The allowed series are written with the prefix of the tenant.
+	req := newTestWriteRequest()
+	req.Timeseries = req.Timeseries[:1]
+	if code := write(req); code != http.StatusNoContent {
+		t.Error(fmt.Errorf("%d != %d", code, http.StatusNoContent))
+	}
+	if len(listener.metrics) != 1 || listener.metrics[0].Name != "team-a.http_requests_total;code=200;job=api" {
+		t.Fatal(fmt.Errorf("%v", listener.metrics))
+	}
+
+	// No series are written when one of them isn't allowed.
+	req.Timeseries = append(req.Timeseries, &TimeSeries{
+		Labels:  []Label{{MetricNameLabel, "up"}},
+		Samples: []Sample{{Value: 1, Timestamp: 1700000000000}},
+	})
+	if code := write(req); code != http.StatusForbidden {
+		t.Error(fmt.Errorf("%d != %d", code, http.StatusForbidden))
+	}
+	if len(listener.metrics) != 1 {
+		t.Error(fmt.Errorf("%d != %d", len(listener.metrics), 1))
+	}
+}
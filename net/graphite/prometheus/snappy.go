@@ -0,0 +1,189 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package prometheus
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// The snappy block format which is used by the remote write and read protocols of Prometheus.
+// See : Snappy compressed format description (https://github.com/google/snappy/blob/main/format_description.txt)
+
+const (
+	snappyTagLiteral = 0x00
+	snappyTagCopy1   = 0x01
+	snappyTagCopy2   = 0x02
+	snappyTagCopy4   = 0x03
+	snappyBlockSize  = 1 << 16
+	snappyHashBits   = 14
+	// MaxSnappyDecodedSize is the maximum size of the decoded snappy blocks.
+	MaxSnappyDecodedSize = 1 << 28
+)
+
+// SnappyDecode returns the decoded bytes of the specified snappy block.
+func SnappyDecode(src []byte) ([]byte, error) {
+	size, n := binary.Uvarint(src)
+	if n <= 0 {
+		return nil, errors.New(errorCorruptedSnappy)
+	}
+	if MaxSnappyDecodedSize < size {
+		return nil, fmt.Errorf(errorTooLargeSnappy, size)
+	}
+	src = src[n:]
+
+	dst := make([]byte, 0, size)
+	for 0 < len(src) {
+		tag := src[0]
+		switch tag & 0x03 {
+		case snappyTagLiteral:
+			length := int(tag >> 2)
+			src = src[1:]
+			if 60 <= length {
+				extra := length - 59
+				if len(src) < extra {
+					return nil, errors.New(errorCorruptedSnappy)
+				}
+				length = 0
+				for i := extra - 1; 0 <= i; i-- {
+					length = length<<8 | int(src[i])
+				}
+				src = src[extra:]
+			}
+			length++
+			if len(src) < length || int(size) < len(dst)+length {
+				return nil, errors.New(errorCorruptedSnappy)
+			}
+			dst = append(dst, src[:length]...)
+			src = src[length:]
+			continue
+		case snappyTagCopy1:
+			if len(src) < 2 {
+				return nil, errors.New(errorCorruptedSnappy)
+			}
+			length := 4 + int(tag>>2&0x07)
+			offset := int(tag&0xe0)<<3 | int(src[1])
+			src = src[2:]
+			if !snappyCopy(&dst, offset, length, int(size)) {
+				return nil, errors.New(errorCorruptedSnappy)
+			}
+		case snappyTagCopy2:
+			if len(src) < 3 {
+				return nil, errors.New(errorCorruptedSnappy)
+			}
+			length := 1 + int(tag>>2)
+			offset := int(binary.LittleEndian.Uint16(src[1:3]))
+			src = src[3:]
+			if !snappyCopy(&dst, offset, length, int(size)) {
+				return nil, errors.New(errorCorruptedSnappy)
+			}
+		case snappyTagCopy4:
+			if len(src) < 5 {
+				return nil, errors.New(errorCorruptedSnappy)
+			}
+			length := 1 + int(tag>>2)
+			offset := int(binary.LittleEndian.Uint32(src[1:5]))
+			src = src[5:]
+			if !snappyCopy(&dst, offset, length, int(size)) {
+				return nil, errors.New(errorCorruptedSnappy)
+			}
+		}
+	}
+
+	if len(dst) != int(size) {
+		return nil, errors.New(errorCorruptedSnappy)
+	}
+
+	return dst, nil
+}
+
+// snappyCopy appends the bytes at the offset from the end, which can overlap the appended bytes.
+func snappyCopy(dst *[]byte, offset int, length int, size int) bool {
+	d := *dst
+	if offset <= 0 || len(d) < offset || size < len(d)+length {
+		return false
+	}
+	start := len(d) - offset
+	for i := range length {
+		d = append(d, d[start+i])
+	}
+	*dst = d
+	return true
+}
+
+// SnappyEncode returns the snappy block of the specified bytes.
+func SnappyEncode(src []byte) []byte {
+	dst := binary.AppendUvarint(make([]byte, 0, len(src)/2+16), uint64(len(src)))
+	for 0 < len(src) {
+		block := src
+		if snappyBlockSize < len(block) {
+			block = block[:snappyBlockSize]
+		}
+		dst = snappyEncodeBlock(dst, block)
+		src = src[len(block):]
+	}
+	return dst
+}
+
+func snappyHash(u uint32) uint32 {
+	return (u * 0x1e35a7bd) >> (32 - snappyHashBits)
+}
+
+// snappyEncodeBlock appends the literals and the copies of the greedy matches of 4 bytes.
+func snappyEncodeBlock(dst []byte, src []byte) []byte {
+	table := make([]int, 1<<snappyHashBits)
+	lit := 0
+	for i := 0; i+4 <= len(src); {
+		u := binary.LittleEndian.Uint32(src[i:])
+		h := snappyHash(u)
+		candidate := table[h] - 1
+		table[h] = i + 1
+		if candidate < 0 || binary.LittleEndian.Uint32(src[candidate:]) != u {
+			i++
+			continue
+		}
+		length := 4
+		for i+length < len(src) && src[candidate+length] == src[i+length] {
+			length++
+		}
+		dst = snappyEmitLiteral(dst, src[lit:i])
+		dst = snappyEmitCopy(dst, i-candidate, length)
+		i += length
+		lit = i
+	}
+	return snappyEmitLiteral(dst, src[lit:])
+}
+
+func snappyEmitLiteral(dst []byte, lit []byte) []byte {
+	if len(lit) == 0 {
+		return dst
+	}
+	n := len(lit) - 1
+	switch {
+	case n < 60:
+		dst = append(dst, byte(n)<<2|snappyTagLiteral)
+	case n < 1<<8:
+		dst = append(dst, 60<<2|snappyTagLiteral, byte(n))
+	default:
+		dst = append(dst, 61<<2|snappyTagLiteral, byte(n), byte(n>>8))
+	}
+	return append(dst, lit...)
+}
+
+func snappyEmitCopy(dst []byte, offset int, length int) []byte {
+	for 68 <= length {
+		dst = append(dst, 63<<2|snappyTagCopy2, byte(offset), byte(offset>>8))
+		length -= 64
+	}
+	if 64 < length {
+		dst = append(dst, 59<<2|snappyTagCopy2, byte(offset), byte(offset>>8))
+		length -= 60
+	}
+	if 12 <= length || 2048 <= offset {
+		return append(dst, byte(length-1)<<2|snappyTagCopy2, byte(offset), byte(offset>>8))
+	}
+	return append(dst, byte(offset>>8)<<5|byte(length-4)<<2|snappyTagCopy1, byte(offset))
+}
@@ -0,0 +1,67 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package prometheus
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestSnappyDecode(t *testing.T) {
+	// 'abc' as a literal and a copy of 9 bytes at the offset 3.
+	src := []byte{12, 0x08, 'a', 'b', 'c', 0x15, 0x03}
+	dst, err := SnappyDecode(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(dst) != "abcabcabcabc" {
+		t.Error(fmt.Errorf("%s != %s", string(dst), "abcabcabcabc"))
+	}
+
+	corrupted := [][]byte{
+		{},
+		{12, 0x08, 'a', 'b', 'c'},
+		{12, 0x08, 'a', 'b', 'c', 0x15, 0x04},
+		{3, 0x08, 'a', 'b'},
+	}
+	for _, src := range corrupted {
+		_, err := SnappyDecode(src)
+		if err == nil {
+			t.Error(fmt.Errorf("%v is decoded", src))
+		}
+	}
+}
+
+func TestSnappyEncode(t *testing.T) {
+	random := make([]byte, 100000)
+	rand.New(rand.NewSource(1)).Read(random)
+
+	inputs := [][]byte{
+		{},
+		[]byte("a"),
+		[]byte(strings.Repeat("abcdefgh", 10000)),
+		[]byte(strings.Repeat("http_requests_total{code=\"200\",job=\"api\"} ", 3000)),
+		random,
+	}
+	for _, src := range inputs {
+		encoded := SnappyEncode(src)
+		decoded, err := SnappyDecode(encoded)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if !bytes.Equal(src, decoded) {
+			t.Error(fmt.Errorf("%d bytes aren't decoded", len(src)))
+		}
+	}
+
+	repeated := []byte(strings.Repeat("abcdefgh", 10000))
+	if encoded := SnappyEncode(repeated); len(repeated)/10 < len(encoded) {
+		t.Error(fmt.Errorf("%d bytes are compressed to %d bytes", len(repeated), len(encoded)))
+	}
+}
@@ -0,0 +1,219 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package prometheus
+
+import (
+	"math"
+)
+
+// The messages of the remote write and read protocols of Prometheus.
+// See : prompb (https://github.com/prometheus/prometheus/tree/main/prompb)
+
+const (
+	// MetricNameLabel is the label name of the metric names.
+	MetricNameLabel = "__name__"
+	// staleNaN is the bits of the staleness markers of Prometheus.
+	staleNaN uint64 = 0x7ff0000000000002
+)
+
+// Label represents a label of a series.
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sample represents a sample of a series. The timestamp is milliseconds.
+type Sample struct {
+	Value     float64
+	Timestamp int64
+}
+
+// IsStaleMarker returns true when the sample is a staleness marker of Prometheus.
+func (sample *Sample) IsStaleMarker() bool {
+	return math.Float64bits(sample.Value) == staleNaN
+}
+
+// TimeSeries represents the labels and the samples of a series.
+type TimeSeries struct {
+	Labels  []Label
+	Samples []Sample
+}
+
+// WriteRequest represents a request of the remote write protocol.
+type WriteRequest struct {
+	Timeseries []*TimeSeries
+}
+
+func unmarshalLabel(b []byte) (Label, error) {
+	label := Label{}
+	r := newProtoReader(b)
+	for {
+		field, wireType, ok, err := r.next()
+		if err != nil || !ok {
+			return label, err
+		}
+		switch {
+		case field == 1 && wireType == protoWireBytes:
+			v, err := r.bytes()
+			if err != nil {
+				return label, err
+			}
+			label.Name = string(v)
+		case field == 2 && wireType == protoWireBytes:
+			v, err := r.bytes()
+			if err != nil {
+				return label, err
+			}
+			label.Value = string(v)
+		default:
+			err := r.skip(wireType)
+			if err != nil {
+				return label, err
+			}
+		}
+	}
+}
+
+func unmarshalSample(b []byte) (Sample, error) {
+	sample := Sample{}
+	r := newProtoReader(b)
+	for {
+		field, wireType, ok, err := r.next()
+		if err != nil || !ok {
+			return sample, err
+		}
+		switch {
+		case field == 1 && wireType == protoWireFixed64:
+			v, err := r.double()
+			if err != nil {
+				return sample, err
+			}
+			sample.Value = v
+		case field == 2 && wireType == protoWireVarint:
+			v, err := r.varint()
+			if err != nil {
+				return sample, err
+			}
+			sample.Timestamp = int64(v)
+		default:
+			err := r.skip(wireType)
+			if err != nil {
+				return sample, err
+			}
+		}
+	}
+}
+
+func unmarshalTimeSeries(b []byte) (*TimeSeries, error) {
+	ts := &TimeSeries{
+		Labels:  []Label{},
+		Samples: []Sample{},
+	}
+	r := newProtoReader(b)
+	for {
+		field, wireType, ok, err := r.next()
+		if err != nil || !ok {
+			return ts, err
+		}
+		switch {
+		case field == 1 && wireType == protoWireBytes:
+			v, err := r.bytes()
+			if err != nil {
+				return nil, err
+			}
+			label, err := unmarshalLabel(v)
+			if err != nil {
+				return nil, err
+			}
+			ts.Labels = append(ts.Labels, label)
+		case field == 2 && wireType == protoWireBytes:
+			v, err := r.bytes()
+			if err != nil {
+				return nil, err
+			}
+			sample, err := unmarshalSample(v)
+			if err != nil {
+				return nil, err
+			}
+			ts.Samples = append(ts.Samples, sample)
+		default:
+			// The exemplars and the native histograms aren't supported.
+			err := r.skip(wireType)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+func (ts *TimeSeries) marshal() []byte {
+	w := newProtoWriter()
+	for _, label := range ts.Labels {
+		lw := newProtoWriter()
+		lw.string(1, label.Name)
+		lw.string(2, label.Value)
+		w.bytes(1, lw.Bytes())
+	}
+	for _, sample := range ts.Samples {
+		sw := newProtoWriter()
+		sw.double(1, sample.Value)
+		sw.varint(2, uint64(sample.Timestamp))
+		w.bytes(2, sw.Bytes())
+	}
+	return w.Bytes()
+}
+
+// MetricName returns the value of the '__name__' label.
+func (ts *TimeSeries) MetricName() string {
+	for _, label := range ts.Labels {
+		if label.Name == MetricNameLabel {
+			return label.Value
+		}
+	}
+	return ""
+}
+
+// UnmarshalWriteRequest returns the write request of the specified protobuf message.
+func UnmarshalWriteRequest(b []byte) (*WriteRequest, error) {
+	req := &WriteRequest{
+		Timeseries: []*TimeSeries{},
+	}
+	r := newProtoReader(b)
+	for {
+		field, wireType, ok, err := r.next()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return req, nil
+		}
+		if field != 1 || wireType != protoWireBytes {
+			// The metadata aren't supported.
+			err := r.skip(wireType)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		v, err := r.bytes()
+		if err != nil {
+			return nil, err
+		}
+		ts, err := unmarshalTimeSeries(v)
+		if err != nil {
+			return nil, err
+		}
+		req.Timeseries = append(req.Timeseries, ts)
+	}
+}
+
+// Marshal returns the protobuf message of the write request.
+func (req *WriteRequest) Marshal() []byte {
+	w := newProtoWriter()
+	for _, ts := range req.Timeseries {
+		w.bytes(1, ts.marshal())
+	}
+	return w.Bytes()
+}
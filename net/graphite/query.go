@@ -82,7 +82,7 @@ func NewQueryWithQuery(oq *Query) *Query {
 func (q *Query) ParseHTTPRequest(httpReq *http.Request) error {
 	err := httpReq.ParseForm()
 	if err != nil {
-		return err
+		return NewParseError("", err)
 	}
 
 	return q.ParseURLValues(httpReq.Form)
@@ -110,14 +110,14 @@ func (q *Query) ParseURLValues(urlValues url.Values) error {
 			if 0 < len(values) {
 				q.From, err = q.parseTimeString(values[0])
 				if err != nil {
-					return err
+					return NewParseError(QueryFrom, err)
 				}
 			}
 		case QueryUntil:
 			if 0 < len(values) {
 				q.Until, err = q.parseTimeString(values[0])
 				if err != nil {
-					return err
+					return NewParseError(QueryUntil, err)
 				}
 			}
 		case QueryFormat:
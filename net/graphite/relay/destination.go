@@ -0,0 +1,114 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package relay
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/cybergarage/go-graphite/net/graphite"
+)
+
+const (
+	destinationSep     = ":"
+	destinationListSep = ","
+)
+
+// Destination represents a downstream Carbon server such as '127.0.0.1:2004:a' of the DESTINATIONS setting of carbon-relay.
+type Destination struct {
+	Host     string
+	Port     int
+	Instance string
+}
+
+// NewDestination returns a new destination.
+func NewDestination(host string, port int, instance string) *Destination {
+	return &Destination{
+		Host:     host,
+		Port:     port,
+		Instance: instance,
+	}
+}
+
+// ParseDestination parses the specified destination such as 'host', 'host:port', 'host:port:instance' or '[::1]:port:instance'.
+// The port is the default Carbon port when it's omitted.
+func ParseDestination(def string) (*Destination, error) {
+	def = strings.TrimSpace(def)
+	if len(def) == 0 {
+		return nil, fmt.Errorf(errorInvalidDestination, def)
+	}
+
+	host := def
+	rest := ""
+	if strings.HasPrefix(def, "[") {
+		end := strings.Index(def, "]")
+		if end < 0 {
+			return nil, fmt.Errorf(errorInvalidDestination, def)
+		}
+		host = def[1:end]
+		rest = strings.TrimPrefix(def[end+1:], destinationSep)
+	} else {
+		host, rest, _ = strings.Cut(def, destinationSep)
+	}
+	if len(host) == 0 {
+		return nil, fmt.Errorf(errorInvalidDestination, def)
+	}
+
+	port := graphite.DefaultCarbonPort
+	portStr, instance, _ := strings.Cut(rest, destinationSep)
+	if 0 < len(portStr) {
+		var err error
+		port, err = strconv.Atoi(portStr)
+		if err != nil || port <= 0 || 65535 < port {
+			return nil, fmt.Errorf(errorInvalidDestination, def)
+		}
+	}
+
+	return NewDestination(host, port, instance), nil
+}
+
+// ParseDestinations parses the specified comma separated destinations.
+func ParseDestinations(defs string) ([]*Destination, error) {
+	dests := []*Destination{}
+	for def := range strings.SplitSeq(defs, destinationListSep) {
+		if len(strings.TrimSpace(def)) == 0 {
+			continue
+		}
+		dest, err := ParseDestination(def)
+		if err != nil {
+			return nil, err
+		}
+		dests = append(dests, dest)
+	}
+	if len(dests) == 0 {
+		return nil, fmt.Errorf(errorNoDestinations)
+	}
+	return dests, nil
+}
+
+// Address returns the network address of the destination.
+func (dest *Destination) Address() string {
+	return net.JoinHostPort(dest.Host, strconv.Itoa(dest.Port))
+}
+
+// Key returns the key of the destination in the hash rings, which is the pair of the host and the instance like carbon.
+func (dest *Destination) Key() string {
+	return dest.Host + destinationSep + dest.Instance
+}
+
+// String returns the destination definition such as '127.0.0.1:2004:a'.
+func (dest *Destination) String() string {
+	host := dest.Host
+	if strings.Contains(host, destinationSep) {
+		host = "[" + host + "]"
+	}
+	s := host + destinationSep + strconv.Itoa(dest.Port)
+	if 0 < len(dest.Instance) {
+		s += destinationSep + dest.Instance
+	}
+	return s
+}
@@ -0,0 +1,72 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package relay
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/cybergarage/go-graphite/net/graphite"
+)
+
+const (
+	// DefaultDryRunRequestPath is the default path of the dry-run API, which can be added to Render using Render::SetHTTPRequestListener().
+	DefaultDryRunRequestPath = "/relay/destinations"
+	dryRunTargetParam        = "target"
+)
+
+// DryRunResult represents the routing result of a metric name.
+type DryRunResult struct {
+	Metric       string   `json:"metric"`
+	Rules        []string `json:"rules,omitempty"`
+	Destinations []string `json:"destinations"`
+}
+
+// DryRun returns the routing result of the specified metric name without forwarding any datapoints.
+func (relay *Relay) DryRun(name string) *DryRunResult {
+	router := relay.GetRouter()
+
+	result := &DryRunResult{
+		Metric:       name,
+		Rules:        nil,
+		Destinations: []string{},
+	}
+	for _, dest := range router.Route(name) {
+		result.Destinations = append(result.Destinations, dest.String())
+	}
+	rulesRouter, ok := router.(*RulesRouter)
+	if ok {
+		result.Rules = []string{}
+		for _, rule := range rulesRouter.MatchRules(name) {
+			result.Rules = append(result.Rules, rule.Name)
+		}
+	}
+
+	return result
+}
+
+// HTTPRequestReceived reports the routing results of the target parameters as JSON, such as '/relay/destinations?target=a.b.c'.
+func (relay *Relay) HTTPRequestReceived(r *http.Request, w http.ResponseWriter) {
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	targets := r.Form[dryRunTargetParam]
+	if len(targets) == 0 {
+		http.Error(w, errorMissingDryRunTarget, http.StatusBadRequest)
+		return
+	}
+
+	results := []*DryRunResult{}
+	for _, target := range targets {
+		results = append(results, relay.DryRun(target))
+	}
+
+	w.Header().Set("Content-Type", graphite.QueryContentTypeJSON)
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(results)
+}
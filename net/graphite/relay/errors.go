@@ -0,0 +1,25 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package relay
+
+const (
+	errorInvalidDestination    = "invalid destination : %s"
+	errorDuplicatedDestination = "duplicated destination : %s"
+	errorInvalidHashType       = "invalid hash type : %s"
+	errorInvalidReplication    = "invalid replication factor : %d"
+	errorNoDestinations        = "no destinations"
+	errorNotEnoughDestinations = "replication factor %d is larger than the number of destinations %d"
+	errorSenderQueueFull       = "send queue of %s is full"
+	errorSenderAlreadyStopped  = "sender of %s is already stopped"
+)
+
+const (
+	errorRuleMissingOption   = "relay rule [%s] is missing '%s'"
+	errorRuleInvalidPattern  = "relay rule [%s] has an invalid pattern : %s"
+	errorRuleInvalidBool     = "relay rule [%s] has an invalid %s : %s"
+	errorRuleDuplicatedDef   = "only one default relay rule can be specified : [%s]"
+	errorRuleNoDefault       = "no default relay rule defined"
+	errorMissingDryRunTarget = "missing target parameter"
+)
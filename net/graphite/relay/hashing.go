@@ -0,0 +1,203 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package relay
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// HashType represents a hash function of the consistent hash rings.
+type HashType string
+
+const (
+	// CarbonCH is the original carbon hash which uses the first 16 bits of the MD5 digest.
+	CarbonCH HashType = "carbon_ch"
+	// FNV1aCH is the hash which is compatible with carbon-c-relay, and uses the folded 32 bit FNV-1a hash.
+	FNV1aCH HashType = "fnv1a_ch"
+)
+
+const (
+	// DefaultReplicaCount is the number of the ring positions of each destination.
+	DefaultReplicaCount = 100
+)
+
+// ParseHashType returns the hash type of the specified name, 'carbon_ch' or 'fnv1a_ch'.
+func ParseHashType(name string) (HashType, error) {
+	switch HashType(name) {
+	case CarbonCH, FNV1aCH:
+		return HashType(name), nil
+	}
+	return "", fmt.Errorf(errorInvalidHashType, name)
+}
+
+// Position returns the 16 bit ring position of the specified key in the same way as carbon.hashing.carbonHash.
+func (hashType HashType) Position(key string) int {
+	switch hashType {
+	case FNV1aCH:
+		bigHash := fnv32a(key)
+		return int((bigHash >> 16) ^ (bigHash & 0xffff))
+	default:
+		sum := md5.Sum([]byte(key))
+		smallHash, _ := strconv.ParseUint(hex.EncodeToString(sum[:2]), 16, 32)
+		return int(smallHash)
+	}
+}
+
+// fnv32a returns the 32 bit FNV-1a hash of the specified key in the same way as carbon.hashing.fnv32a,
+// which hashes the code points of the key instead of the UTF-8 bytes.
+func fnv32a(key string) uint32 {
+	const (
+		offsetBasis = 0x811c9dc5
+		prime       = 0x01000193
+	)
+	hash := uint32(offsetBasis)
+	for _, r := range key {
+		hash ^= uint32(r)
+		hash *= prime
+	}
+	return hash
+}
+
+type ringEntry struct {
+	position int
+	dest     *Destination
+}
+
+// ConsistentHashRing is a hash ring which places the destinations at the same positions as carbon.hashing.ConsistentHashRing,
+// so that the metrics are routed to the same destinations as the existing carbon clusters.
+type ConsistentHashRing struct {
+	hashType     HashType
+	replicaCount int
+	entries      []*ringEntry
+	dests        []*Destination
+}
+
+// NewConsistentHashRing returns a new empty ring with the specified hash type.
+func NewConsistentHashRing(hashType HashType) *ConsistentHashRing {
+	return &ConsistentHashRing{
+		hashType:     hashType,
+		replicaCount: DefaultReplicaCount,
+		entries:      []*ringEntry{},
+		dests:        []*Destination{},
+	}
+}
+
+// GetHashType returns the hash type of the ring.
+func (ring *ConsistentHashRing) GetHashType() HashType {
+	return ring.hashType
+}
+
+// GetDestinations returns the destinations in the ring.
+func (ring *ConsistentHashRing) GetDestinations() []*Destination {
+	return ring.dests
+}
+
+// pythonRepr returns the Python representation of the specified string or None for the empty string.
+func pythonRepr(s string) string {
+	if len(s) == 0 {
+		return "None"
+	}
+	quote := "'"
+	if strings.Contains(s, "'") && !strings.Contains(s, "\"") {
+		quote = "\""
+	}
+	s = strings.ReplaceAll(s, "\\", "\\\\")
+	s = strings.ReplaceAll(s, quote, "\\"+quote)
+	return quote + s + quote
+}
+
+// replicaKey returns the key of the specified replica of the destination.
+// carbon formats the (server, instance) tuple with Python for carbon_ch, and uses only the instance for fnv1a_ch like carbon-c-relay.
+// The missing instance is formatted as None in both cases.
+func (ring *ConsistentHashRing) replicaKey(dest *Destination, n int) string {
+	if ring.hashType == FNV1aCH {
+		instance := dest.Instance
+		if len(instance) == 0 {
+			instance = "None"
+		}
+		return fmt.Sprintf("%d-%s", n, instance)
+	}
+	return fmt.Sprintf("(%s, %s):%d", pythonRepr(dest.Host), pythonRepr(dest.Instance), n)
+}
+
+// AddDestination adds the specified destination into the ring.
+func (ring *ConsistentHashRing) AddDestination(dest *Destination) error {
+	for _, d := range ring.dests {
+		if d.Key() == dest.Key() {
+			return fmt.Errorf(errorDuplicatedDestination, dest.String())
+		}
+	}
+
+	positions := map[int]bool{}
+	for _, e := range ring.entries {
+		positions[e.position] = true
+	}
+
+	for n := range ring.replicaCount {
+		position := ring.hashType.Position(ring.replicaKey(dest, n))
+		for positions[position] {
+			position++
+		}
+		positions[position] = true
+		ring.entries = append(ring.entries, &ringEntry{position: position, dest: dest})
+	}
+	sort.Slice(ring.entries, func(i, j int) bool {
+		return ring.entries[i].position < ring.entries[j].position
+	})
+
+	ring.dests = append(ring.dests, dest)
+
+	return nil
+}
+
+// search returns the index of the first entry at or after the position of the specified key.
+func (ring *ConsistentHashRing) search(key string) int {
+	position := ring.hashType.Position(key)
+	idx := sort.Search(len(ring.entries), func(n int) bool {
+		return position <= ring.entries[n].position
+	})
+	return idx % len(ring.entries)
+}
+
+// GetDestination returns the destination of the specified key, or nil when the ring is empty.
+func (ring *ConsistentHashRing) GetDestination(key string) *Destination {
+	if len(ring.entries) == 0 {
+		return nil
+	}
+	return ring.entries[ring.search(key)].dest
+}
+
+// GetReplicaDestinations returns the specified number of the distinct destinations which follow the position of the specified key.
+func (ring *ConsistentHashRing) GetReplicaDestinations(key string, count int) []*Destination {
+	if len(ring.entries) == 0 || count <= 0 {
+		return []*Destination{}
+	}
+	if len(ring.dests) == 1 {
+		return ring.dests
+	}
+
+	count = min(count, len(ring.dests))
+	dests := make([]*Destination, 0, count)
+	found := map[*Destination]bool{}
+	idx := ring.search(key)
+	for range ring.entries {
+		if len(dests) == count {
+			break
+		}
+		dest := ring.entries[idx].dest
+		if !found[dest] {
+			found[dest] = true
+			dests = append(dests, dest)
+		}
+		idx = (idx + 1) % len(ring.entries)
+	}
+
+	return dests
+}
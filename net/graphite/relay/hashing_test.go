@@ -0,0 +1,128 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package relay
+
+import (
+	"fmt"
+	"testing"
+)
+
+func newTestRing(t *testing.T, hashType HashType) *ConsistentHashRing {
+	t.Helper()
+	dests := []*Destination{
+		NewDestination("127.0.0.1", 2003, "cache0"),
+		NewDestination("127.0.0.1", 2003, "cache1"),
+		NewDestination("127.0.0.1", 2003, "cache2"),
+	}
+	if hashType == FNV1aCH {
+		dests = []*Destination{
+			NewDestination("127.0.0.1", 2003, "ba603c36342304ed77953f84ac4d357b"),
+			NewDestination("127.0.0.2", 2003, "5dd63865534f84899c6e5594dba6749a"),
+			NewDestination("127.0.0.3", 2003, "866a18b81f2dc4649517a1df13e26f28"),
+		}
+	}
+	ring := NewConsistentHashRing(hashType)
+	for _, dest := range dests {
+		err := ring.AddDestination(dest)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return ring
+}
+
+// The expected positions and destinations are the same as the placements of carbon.hashing.
+func TestConsistentHashRing(t *testing.T) {
+	testCases := []struct {
+		hashType HashType
+		key      string
+		position int
+		instance string
+	}{
+		{CarbonCH, "hosts.worker1.cpu", 64833, "cache2"},
+		{CarbonCH, "hosts.worker2.cpu", 38509, "cache2"},
+		{FNV1aCH, "hosts.worker1.cpu", 59573, "ba603c36342304ed77953f84ac4d357b"},
+		{FNV1aCH, "hosts.worker2.cpu", 35749, "866a18b81f2dc4649517a1df13e26f28"},
+		{FNV1aCH, "hosts.wörker1.cpu", 21115, "ba603c36342304ed77953f84ac4d357b"},
+	}
+
+	for _, tc := range testCases {
+		ring := newTestRing(t, tc.hashType)
+		position := tc.hashType.Position(tc.key)
+		if position != tc.position {
+			t.Error(fmt.Errorf("%s : %s : %d != %d", tc.hashType, tc.key, position, tc.position))
+		}
+		dest := ring.GetDestination(tc.key)
+		if dest.Instance != tc.instance {
+			t.Error(fmt.Errorf("%s : %s : %s != %s", tc.hashType, tc.key, dest.Instance, tc.instance))
+		}
+		dests := ring.GetReplicaDestinations(tc.key, 2)
+		if len(dests) != 2 || dests[0] != dest || dests[0] == dests[1] {
+			t.Error(fmt.Errorf("%s : %s : %v", tc.hashType, tc.key, dests))
+		}
+	}
+}
+
+// The destinations without instances are placed with None like carbon.hashing.
+func TestConsistentHashRingWithoutInstance(t *testing.T) {
+	ring := NewConsistentHashRing(FNV1aCH)
+	for _, dest := range []*Destination{
+		NewDestination("127.0.0.1", 2003, ""),
+		NewDestination("127.0.0.2", 2003, "b"),
+		NewDestination("127.0.0.3", 2003, "c"),
+	} {
+		err := ring.AddDestination(dest)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := map[string]string{
+		"hosts.worker1.cpu":  "127.0.0.1",
+		"hosts.работник.cpu": "127.0.0.2",
+		"a.b":                "127.0.0.3",
+		"x.y.z":              "127.0.0.2",
+	}
+	for key, host := range expected {
+		dest := ring.GetDestination(key)
+		if dest.Host != host {
+			t.Error(fmt.Errorf("%s : %s != %s", key, dest.Host, host))
+		}
+	}
+}
+
+func TestConsistentHashRingErrors(t *testing.T) {
+	ring := newTestRing(t, CarbonCH)
+	if ring.AddDestination(NewDestination("127.0.0.1", 2004, "cache0")) == nil {
+		t.Error(fmt.Errorf("a duplicated destination is added"))
+	}
+
+	if pythonRepr("a'b") != "\"a'b\"" || pythonRepr("") != "None" || pythonRepr("a") != "'a'" {
+		t.Error(fmt.Errorf("%s %s %s", pythonRepr("a'b"), pythonRepr(""), pythonRepr("a")))
+	}
+}
+
+func TestParseDestinations(t *testing.T) {
+	dests, err := ParseDestinations("127.0.0.1:2004:a, host-b:2104 ,[::1]:2204:c,host-d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"127.0.0.1:2004:a", "host-b:2104", "[::1]:2204:c", "host-d:2003"}
+	if len(dests) != len(expected) {
+		t.Fatal(fmt.Errorf("%v", dests))
+	}
+	for n, dest := range dests {
+		if dest.String() != expected[n] {
+			t.Error(fmt.Errorf("%s != %s", dest.String(), expected[n]))
+		}
+	}
+
+	for _, def := range []string{"", ":2003", "host:port", "[::1:2003"} {
+		_, err := ParseDestinations(def)
+		if err == nil {
+			t.Error(fmt.Errorf("%s is parsed", def))
+		}
+	}
+}
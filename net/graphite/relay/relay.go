@@ -0,0 +1,189 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package relay provides a Carbon compatible relay which forwards the ingested metrics to the downstream Carbon servers.
+package relay
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cybergarage/go-graphite/net/graphite"
+)
+
+const (
+	// DefaultStopTimeout is the default time to wait for sending the queued datapoints in Stop.
+	DefaultStopTimeout = time.Second * 5
+)
+
+// Relay is a graphite.CarbonListener which forwards the ingested metrics to the destinations of the router.
+type Relay struct {
+	sync.Mutex
+	router      Router
+	senders     map[string]*Sender
+	queueSize   int
+	batchSize   int
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+	stopTimeout time.Duration
+	running     bool
+}
+
+// NewRelay returns a new relay with the specified router.
+func NewRelay(router Router) *Relay {
+	return &Relay{
+		Mutex:       sync.Mutex{},
+		router:      router,
+		senders:     map[string]*Sender{},
+		queueSize:   DefaultMaxQueueSize,
+		batchSize:   DefaultMaxBatchSize,
+		minBackoff:  DefaultMinReconnectBackoff,
+		maxBackoff:  DefaultMaxReconnectBackoff,
+		stopTimeout: DefaultStopTimeout,
+		running:     false,
+	}
+}
+
+// GetRouter returns the router of the relay.
+func (relay *Relay) GetRouter() Router {
+	relay.Lock()
+	defer relay.Unlock()
+	return relay.router
+}
+
+// SetMaxQueueSize sets the queue size of each destination. Set it before Start.
+func (relay *Relay) SetMaxQueueSize(size int) {
+	relay.Lock()
+	defer relay.Unlock()
+	relay.queueSize = size
+}
+
+// SetMaxBatchSize sets the number of the datapoints which are written at once. Set it before Start.
+func (relay *Relay) SetMaxBatchSize(size int) {
+	relay.Lock()
+	defer relay.Unlock()
+	relay.batchSize = size
+}
+
+// SetReconnectBackoff sets the first and maximum wait times to reconnect to the destinations. Set them before Start.
+func (relay *Relay) SetReconnectBackoff(minBackoff time.Duration, maxBackoff time.Duration) {
+	relay.Lock()
+	defer relay.Unlock()
+	relay.minBackoff = minBackoff
+	relay.maxBackoff = maxBackoff
+}
+
+// SetStopTimeout sets the time to wait for sending the queued datapoints in Stop.
+func (relay *Relay) SetStopTimeout(d time.Duration) {
+	relay.Lock()
+	defer relay.Unlock()
+	relay.stopTimeout = d
+}
+
+// Start starts the senders of all destinations of the router.
+func (relay *Relay) Start() error {
+	err := relay.Stop()
+	if err != nil {
+		return err
+	}
+
+	relay.Lock()
+	defer relay.Unlock()
+
+	relay.running = true
+	for _, dest := range relay.router.Destinations() {
+		relay.getSender(dest)
+	}
+
+	return nil
+}
+
+// Stop stops all senders after sending the queued datapoints.
+func (relay *Relay) Stop() error {
+	relay.Lock()
+	senders := relay.senders
+	timeout := relay.stopTimeout
+	relay.senders = map[string]*Sender{}
+	relay.running = false
+	relay.Unlock()
+
+	var wg sync.WaitGroup
+	for _, sender := range senders {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sender.Stop(timeout)
+		}()
+	}
+	wg.Wait()
+
+	return nil
+}
+
+// getSender returns the running sender of the specified destination, and starts a new sender when it isn't found.
+func (relay *Relay) getSender(dest *Destination) *Sender {
+	key := dest.String()
+	sender, ok := relay.senders[key]
+	if ok {
+		return sender
+	}
+	sender = NewSender(dest, relay.queueSize, relay.batchSize)
+	sender.SetReconnectBackoff(relay.minBackoff, relay.maxBackoff)
+	sender.Start()
+	relay.senders[key] = sender
+	return sender
+}
+
+// Stats returns the statistics of all senders.
+func (relay *Relay) Stats() []*SenderStats {
+	relay.Lock()
+	defer relay.Unlock()
+	stats := []*SenderStats{}
+	for _, dest := range relay.router.Destinations() {
+		sender, ok := relay.senders[dest.String()]
+		if !ok {
+			continue
+		}
+		stats = append(stats, sender.Stats())
+	}
+	return stats
+}
+
+// InsertMetricsRequestReceived forwards the ingested metrics to the destinations of the router.
+// The metrics are dropped while the relay isn't running.
+func (relay *Relay) InsertMetricsRequestReceived(ms []*graphite.Metrics, err error) {
+	if err != nil {
+		return
+	}
+
+	relay.Lock()
+	defer relay.Unlock()
+
+	if !relay.running {
+		return
+	}
+
+	for _, m := range ms {
+		if m == nil || len(m.Name) == 0 {
+			continue
+		}
+		dests := relay.router.Route(m.Name)
+		if len(dests) == 0 {
+			continue
+		}
+		for n, dp := range m.DataPoints {
+			if dp == nil {
+				continue
+			}
+			line, err := m.DataPointPlainTextString(n)
+			if err != nil {
+				continue
+			}
+			line += "\n"
+			for _, dest := range dests {
+				relay.getSender(dest).Enqueue(line)
+			}
+		}
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package relay
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/cybergarage/go-graphite/net/graphite"
+	"github.com/cybergarage/go-graphite/net/graphite/aggregator"
+	"github.com/cybergarage/go-graphite/net/graphite/memory"
+)
+
+func freePort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+type testNode struct {
+	*graphite.Carbon
+	store *memory.Store
+}
+
+func newTestNode(t *testing.T, port int) *testNode {
+	t.Helper()
+	node := &testNode{
+		Carbon: graphite.NewCarbon(),
+		store:  memory.NewStore(),
+	}
+	node.SetAddress("localhost")
+	node.SetPort(port)
+	node.SetCarbonListener(node.store)
+	return node
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	for range 100 {
+		if cond() {
+			return
+		}
+		time.Sleep(time.Millisecond * 50)
+	}
+	t.Error(fmt.Errorf("timeout"))
+}
+
+func TestRelay(t *testing.T) {
+	nodes := []*testNode{}
+	dests := []*Destination{}
+	for n := range 3 {
+		node := newTestNode(t, freePort(t))
+		err := node.Start()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer node.Stop()
+		nodes = append(nodes, node)
+		dests = append(dests, NewDestination("localhost", node.GetPort(), fmt.Sprintf("%c", 'a'+n)))
+	}
+
+	router, err := NewConsistentHashingRouter(dests, CarbonCH, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	relay := NewRelay(router)
+	err = relay.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := graphite.NewServer()
+	server.SetCarbonListener(relay)
+
+	now := time.Now().Unix()
+	names := []string{}
+	for n := range 20 {
+		name := fmt.Sprintf("servers.host%d.cpu", n)
+		names = append(names, name)
+		_, err := server.FeedPlainTextString(fmt.Sprintf("%s %d %d\n", name, n, now))
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	// Each metric is stored in the replicas of the ring
+
+	for _, name := range names {
+		for _, dest := range router.Route(name) {
+			for _, node := range nodes {
+				if node.GetPort() != dest.Port {
+					continue
+				}
+				waitFor(t, func() bool {
+					_, ok := node.store.GetSeries(name)
+					return ok
+				})
+			}
+		}
+	}
+
+	total := 0
+	for _, node := range nodes {
+		total += node.store.GetSeriesCount()
+	}
+	if total != len(names)*2 {
+		t.Error(fmt.Errorf("%d != %d", total, len(names)*2))
+	}
+
+	err = relay.Stop()
+	if err != nil {
+		t.Error(err)
+	}
+	for _, stats := range relay.Stats() {
+		if stats.Dropped != 0 {
+			t.Error(fmt.Errorf("%v", stats))
+		}
+	}
+}
+
+func TestRelayReconnect(t *testing.T) {
+	port := freePort(t)
+	dest := NewDestination("localhost", port, "")
+
+	router, err := NewConsistentHashingRouter([]*Destination{dest}, CarbonCH, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	relay := NewRelay(router)
+	relay.SetReconnectBackoff(time.Millisecond*10, time.Millisecond*100)
+	err = relay.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer relay.Stop()
+
+	// The datapoints are queued while the destination is down
+
+	now := time.Now().Unix()
+	relay.InsertMetricsRequestReceived(newTestMetrics("a.b", now), nil)
+	time.Sleep(time.Millisecond * 50)
+
+	stats := relay.Stats()
+	if len(stats) != 1 || stats[0].Connected || stats[0].Sent != 0 {
+		t.Error(fmt.Errorf("%v", stats[0]))
+	}
+
+	node := newTestNode(t, port)
+	err = node.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer node.Stop()
+
+	waitFor(t, func() bool {
+		_, ok := node.store.GetSeries("a.b")
+		return ok
+	})
+
+	stats = relay.Stats()
+	if !stats[0].Connected || stats[0].Sent != 1 {
+		t.Error(fmt.Errorf("%v", stats[0]))
+	}
+}
+
+func newTestMetrics(name string, ts int64) []*graphite.Metrics {
+	m := graphite.NewMetrics()
+	m.SetName(name)
+	dp := graphite.NewDataPoint()
+	dp.SetTimestamp(time.Unix(ts, 0))
+	dp.SetValue(1)
+	m.AddDataPoint(dp)
+	return []*graphite.Metrics{m}
+}
+
+func TestRelayAggregatedMetrics(t *testing.T) {
+	node := newTestNode(t, freePort(t))
+	err := node.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer node.Stop()
+
+	router, err := NewConsistentHashingRouter([]*Destination{NewDestination("localhost", node.GetPort(), "")}, CarbonCH, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	relay := NewRelay(router)
+	err = relay.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer relay.Stop()
+
+	rule, err := aggregator.ParseRule("servers.all.cpu (60) = sum servers.*.cpu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	agg := aggregator.NewAggregator([]*aggregator.Rule{rule}, relay)
+	agg.SetForwardAll(false)
+
+	now := time.Now().Unix()
+	agg.InsertMetricsRequestReceived(newTestMetrics("servers.host1.cpu", now), nil)
+	agg.InsertMetricsRequestReceived(newTestMetrics("servers.host2.cpu", now), nil)
+	agg.Flush()
+
+	waitFor(t, func() bool {
+		_, ok := node.store.GetSeries("servers.all.cpu")
+		return ok
+	})
+	if node.store.GetSeriesCount() != 1 {
+		t.Error(fmt.Errorf("%d != %d", node.store.GetSeriesCount(), 1))
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package relay
+
+import (
+	"fmt"
+
+	"github.com/cybergarage/go-graphite/net/graphite/aggregator"
+)
+
+// Router represents a routing method of the relay, which is the RELAY_METHOD setting of carbon-relay.
+type Router interface {
+	// Destinations returns all destinations of the router.
+	Destinations() []*Destination
+	// Route returns the destinations of the specified metric name.
+	Route(name string) []*Destination
+}
+
+// ConsistentHashingRouter is a router of the consistent-hashing method of carbon-relay.
+type ConsistentHashingRouter struct {
+	ring              *ConsistentHashRing
+	replicationFactor int
+}
+
+// NewConsistentHashingRouter returns a new router which routes the metrics to the specified number of the destinations in the hash ring.
+func NewConsistentHashingRouter(dests []*Destination, hashType HashType, replicationFactor int) (*ConsistentHashingRouter, error) {
+	if len(dests) == 0 {
+		return nil, fmt.Errorf(errorNoDestinations)
+	}
+	if replicationFactor <= 0 {
+		return nil, fmt.Errorf(errorInvalidReplication, replicationFactor)
+	}
+	if len(dests) < replicationFactor {
+		return nil, fmt.Errorf(errorNotEnoughDestinations, replicationFactor, len(dests))
+	}
+
+	ring := NewConsistentHashRing(hashType)
+	for _, dest := range dests {
+		err := ring.AddDestination(dest)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	router := &ConsistentHashingRouter{
+		ring:              ring,
+		replicationFactor: replicationFactor,
+	}
+	return router, nil
+}
+
+// GetRing returns the hash ring of the router.
+func (router *ConsistentHashingRouter) GetRing() *ConsistentHashRing {
+	return router.ring
+}
+
+// GetReplicationFactor returns the number of the destinations of each metric.
+func (router *ConsistentHashingRouter) GetReplicationFactor() int {
+	return router.replicationFactor
+}
+
+// Destinations returns all destinations in the hash ring.
+func (router *ConsistentHashingRouter) Destinations() []*Destination {
+	return router.ring.GetDestinations()
+}
+
+// Route returns the destinations of the specified metric name in the hash ring.
+func (router *ConsistentHashingRouter) Route(name string) []*Destination {
+	return router.ring.GetReplicaDestinations(name, router.replicationFactor)
+}
+
+// RulesRouter is a router of the rules method of carbon-relay.
+type RulesRouter struct {
+	rules []*Rule
+	dests []*Destination
+}
+
+// NewRulesRouter returns a new router with the specified rules, which are ordered with the default rule at the end such as the results of ParseRules.
+func NewRulesRouter(rules []*Rule) (*RulesRouter, error) {
+	if len(rules) == 0 || !rules[len(rules)-1].Default {
+		return nil, fmt.Errorf(errorRuleNoDefault)
+	}
+
+	dests := []*Destination{}
+	found := map[string]bool{}
+	for _, rule := range rules {
+		for _, dest := range rule.Destinations {
+			if found[dest.String()] {
+				continue
+			}
+			found[dest.String()] = true
+			dests = append(dests, dest)
+		}
+	}
+
+	router := &RulesRouter{
+		rules: rules,
+		dests: dests,
+	}
+	return router, nil
+}
+
+// GetRules returns the rules of the router.
+func (router *RulesRouter) GetRules() []*Rule {
+	return router.rules
+}
+
+// Destinations returns all destinations of the rules.
+func (router *RulesRouter) Destinations() []*Destination {
+	return router.dests
+}
+
+// MatchRules returns the rules which route the specified metric name.
+// The rules are evaluated in order, and the evaluation stops at the first matched rule which doesn't continue.
+func (router *RulesRouter) MatchRules(name string) []*Rule {
+	rules := []*Rule{}
+	for _, rule := range router.rules {
+		if !rule.Match(name) {
+			continue
+		}
+		rules = append(rules, rule)
+		if !rule.Continue {
+			break
+		}
+	}
+	return rules
+}
+
+// Route returns the destinations of the rules which match the specified metric name.
+func (router *RulesRouter) Route(name string) []*Destination {
+	dests := []*Destination{}
+	found := map[string]bool{}
+	for _, rule := range router.MatchRules(name) {
+		for _, dest := range rule.Destinations {
+			if found[dest.String()] {
+				continue
+			}
+			found[dest.String()] = true
+			dests = append(dests, dest)
+		}
+	}
+	return dests
+}
+
+// AggregatedConsistentHashingRouter is a router of the aggregated-consistent-hashing method of carbon-relay.
+type AggregatedConsistentHashingRouter struct {
+	*ConsistentHashingRouter
+	rules []*aggregator.Rule
+}
+
+// NewAggregatedConsistentHashingRouter returns a new router which routes the metrics with the hash ring of their aggregate metric names of the specified aggregation rules.
+func NewAggregatedConsistentHashingRouter(dests []*Destination, hashType HashType, replicationFactor int, rules []*aggregator.Rule) (*AggregatedConsistentHashingRouter, error) {
+	hashRouter, err := NewConsistentHashingRouter(dests, hashType, replicationFactor)
+	if err != nil {
+		return nil, err
+	}
+	router := &AggregatedConsistentHashingRouter{
+		ConsistentHashingRouter: hashRouter,
+		rules:                   rules,
+	}
+	return router, nil
+}
+
+// GetAggregationRules returns the aggregation rules of the router.
+func (router *AggregatedConsistentHashingRouter) GetAggregationRules() []*aggregator.Rule {
+	return router.rules
+}
+
+// Route returns the destinations of the aggregate metric names of the specified metric name.
+// The metric which isn't aggregated by any rules is routed with its own name.
+func (router *AggregatedConsistentHashingRouter) Route(name string) []*Destination {
+	keys := aggregator.AggregateMetrics(router.rules, name)
+	if len(keys) == 0 {
+		keys = append(keys, name)
+	}
+
+	dests := []*Destination{}
+	found := map[*Destination]bool{}
+	for _, key := range keys {
+		for _, dest := range router.ConsistentHashingRouter.Route(key) {
+			if found[dest] {
+				continue
+			}
+			found[dest] = true
+			dests = append(dests, dest)
+		}
+	}
+	return dests
+}
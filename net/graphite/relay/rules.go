@@ -0,0 +1,131 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package relay
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/cybergarage/go-graphite/net/graphite/storage"
+)
+
+const (
+	ruleOptionPattern      = "pattern"
+	ruleOptionDestinations = "destinations"
+	ruleOptionContinue     = "continue"
+	ruleOptionDefault      = "default"
+)
+
+// Rule represents a rule of relay-rules.conf.
+type Rule struct {
+	Name         string
+	Pattern      *regexp.Regexp
+	Destinations []*Destination
+	Continue     bool
+	Default      bool
+}
+
+// Match returns true when the rule matches the specified metric name. The default rule matches all names.
+func (rule *Rule) Match(name string) bool {
+	if rule.Default {
+		return true
+	}
+	return rule.Pattern.MatchString(name)
+}
+
+// parseBool parses the boolean option values in the same way as Python's ConfigParser.
+func parseBool(value string) (bool, bool) {
+	switch strings.ToLower(value) {
+	case "1", "yes", "true", "on":
+		return true, true
+	case "0", "no", "false", "off":
+		return false, true
+	}
+	return false, false
+}
+
+// LoadRules parses the specified relay-rules.conf.
+func LoadRules(path string) ([]*Rule, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ParseRules(file)
+}
+
+// ParseRules parses the rules of relay-rules.conf in the order of appearance like carbon-relay,
+// and moves the default rule, which is required, to the end.
+func ParseRules(reader io.Reader) ([]*Rule, error) {
+	sections, err := storage.ParseConfig(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := []*Rule{}
+	var defaultRule *Rule
+	for _, section := range sections {
+		rule := &Rule{
+			Name:         section.Name,
+			Pattern:      nil,
+			Destinations: nil,
+			Continue:     false,
+			Default:      false,
+		}
+
+		for _, opt := range []string{ruleOptionContinue, ruleOptionDefault} {
+			value, ok := section.Option(opt)
+			if !ok {
+				continue
+			}
+			b, ok := parseBool(value)
+			if !ok {
+				return nil, fmt.Errorf(errorRuleInvalidBool, section.Name, opt, value)
+			}
+			if opt == ruleOptionContinue {
+				rule.Continue = b
+			} else {
+				rule.Default = b
+			}
+		}
+
+		defs, ok := section.Option(ruleOptionDestinations)
+		if !ok {
+			return nil, fmt.Errorf(errorRuleMissingOption, section.Name, ruleOptionDestinations)
+		}
+		rule.Destinations, err = ParseDestinations(defs)
+		if err != nil {
+			return nil, err
+		}
+
+		if rule.Default {
+			if defaultRule != nil {
+				return nil, fmt.Errorf(errorRuleDuplicatedDef, section.Name)
+			}
+			defaultRule = rule
+			continue
+		}
+
+		pattern, ok := section.Option(ruleOptionPattern)
+		if !ok {
+			return nil, fmt.Errorf(errorRuleMissingOption, section.Name, ruleOptionPattern)
+		}
+		rule.Pattern, err = regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf(errorRuleInvalidPattern, section.Name, pattern)
+		}
+
+		rules = append(rules, rule)
+	}
+
+	if defaultRule == nil {
+		return nil, fmt.Errorf(errorRuleNoDefault)
+	}
+
+	return append(rules, defaultRule), nil
+}
@@ -0,0 +1,157 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package relay
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cybergarage/go-graphite/net/graphite/aggregator"
+)
+
+const testRelayRules = `
+# You must have exactly one section with 'default = true'
+[default]
+default = true
+destinations = 127.0.0.1:2003:a, 127.0.0.1:2103:b
+
+[mydata]
+pattern = ^mydata\.foo\..+
+destinations = 10.1.2.3:2003, 10.1.2.4:2003
+continue = true
+
+[mydata_bar]
+pattern = ^mydata\..+
+destinations = 10.1.2.5:2003
+`
+
+func TestRulesRouter(t *testing.T) {
+	rules, err := ParseRules(strings.NewReader(testRelayRules))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 3 || !rules[2].Default || !rules[0].Continue {
+		t.Fatal(fmt.Errorf("%v", rules))
+	}
+
+	router, err := NewRulesRouter(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(router.Destinations()) != 5 {
+		t.Error(fmt.Errorf("%v", router.Destinations()))
+	}
+
+	testCases := []struct {
+		name  string
+		dests []string
+	}{
+		{"mydata.foo.bar", []string{"10.1.2.3:2003", "10.1.2.4:2003", "10.1.2.5:2003"}},
+		{"mydata.bar", []string{"10.1.2.5:2003"}},
+		{"servers.host1.cpu", []string{"127.0.0.1:2003:a", "127.0.0.1:2103:b"}},
+	}
+	for _, tc := range testCases {
+		dests := router.Route(tc.name)
+		if len(dests) != len(tc.dests) {
+			t.Error(fmt.Errorf("%s : %v", tc.name, dests))
+			continue
+		}
+		for n, dest := range dests {
+			if dest.String() != tc.dests[n] {
+				t.Error(fmt.Errorf("%s : %s != %s", tc.name, dest.String(), tc.dests[n]))
+			}
+		}
+	}
+
+	badRules := []string{
+		"[a]\npattern = ^a\ndestinations = 127.0.0.1\n",
+		"[a]\ndefault = true\ndestinations = 127.0.0.1\n[b]\ndefault = true\ndestinations = 127.0.0.2\n",
+		"[a]\ndefault = true\n",
+		"[a]\ndefault = maybe\ndestinations = 127.0.0.1\n",
+		"[a]\npattern = ^a(\ndestinations = 127.0.0.1\n[b]\ndefault = true\ndestinations = 127.0.0.2\n",
+		"[a]\ndestinations = 127.0.0.1\n[b]\ndefault = true\ndestinations = 127.0.0.2\n",
+	}
+	for _, conf := range badRules {
+		_, err := ParseRules(strings.NewReader(conf))
+		if err == nil {
+			t.Error(fmt.Errorf("%s is parsed", conf))
+		}
+	}
+}
+
+func TestAggregatedConsistentHashingRouter(t *testing.T) {
+	aggRules, err := aggregator.ParseRules(strings.NewReader("<env>.applications.<app>.all.requests (60) = sum <env>.applications.<app>.*.requests\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dests, err := ParseDestinations("127.0.0.1:2003:a,127.0.0.1:2103:b,127.0.0.1:2203:c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	router, err := NewAggregatedConsistentHashingRouter(dests, CarbonCH, 1, aggRules)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// All inputs of an aggregate metric are routed to the destination of the aggregate metric
+	expected := router.ConsistentHashingRouter.Route("prod.applications.apache.all.requests")
+	for n := range 10 {
+		dests := router.Route(fmt.Sprintf("prod.applications.apache.host%d.requests", n))
+		if len(dests) != 1 || dests[0] != expected[0] {
+			t.Error(fmt.Errorf("%d : %v != %v", n, dests, expected))
+		}
+	}
+
+	name := "servers.host1.cpu"
+	if router.Route(name)[0] != router.ConsistentHashingRouter.Route(name)[0] {
+		t.Error(fmt.Errorf("%s is routed to %v", name, router.Route(name)))
+	}
+}
+
+func TestRelayDryRun(t *testing.T) {
+	rules, err := ParseRules(strings.NewReader(testRelayRules))
+	if err != nil {
+		t.Fatal(err)
+	}
+	router, err := NewRulesRouter(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	relay := NewRelay(router)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, DefaultDryRunRequestPath+"?target=mydata.foo.bar&target=servers.host1.cpu", nil)
+	relay.HTTPRequestReceived(r, w)
+	if w.Code != http.StatusOK {
+		t.Fatal(fmt.Errorf("%d", w.Code))
+	}
+
+	results := []*DryRunResult{}
+	err = json.NewDecoder(w.Body).Decode(&results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatal(fmt.Errorf("%v", results))
+	}
+	if strings.Join(results[0].Rules, ",") != "mydata,mydata_bar" || len(results[0].Destinations) != 3 {
+		t.Error(fmt.Errorf("%v", results[0]))
+	}
+	if strings.Join(results[1].Rules, ",") != "default" || len(results[1].Destinations) != 2 {
+		t.Error(fmt.Errorf("%v", results[1]))
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodGet, DefaultDryRunRequestPath, nil)
+	relay.HTTPRequestReceived(r, w)
+	if w.Code != http.StatusBadRequest {
+		t.Error(fmt.Errorf("%d", w.Code))
+	}
+}
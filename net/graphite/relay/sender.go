@@ -0,0 +1,238 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package relay
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cybergarage/go-graphite/net/graphite"
+)
+
+const (
+	// DefaultMaxQueueSize is the default number of the datapoints which can wait for sending to a destination.
+	DefaultMaxQueueSize = 10000
+	// DefaultMaxBatchSize is the default number of the datapoints which are written at once.
+	DefaultMaxBatchSize = 500
+	// DefaultMinReconnectBackoff is the default first wait time to reconnect to a destination.
+	DefaultMinReconnectBackoff = time.Millisecond * 100
+	// DefaultMaxReconnectBackoff is the default maximum wait time to reconnect to a destination.
+	DefaultMaxReconnectBackoff = time.Second * 30
+)
+
+// SenderStats represents the statistics of a sender.
+type SenderStats struct {
+	Destination string
+	Queued      int
+	Sent        int64
+	Dropped     int64
+	Connected   bool
+}
+
+// Sender is a queue of the plaintext datapoints for a destination, which sends them and reconnects with the exponential backoff.
+type Sender struct {
+	dest       *Destination
+	queue      chan string
+	batchSize  int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	timeout    time.Duration
+	sent       atomic.Int64
+	dropped    atomic.Int64
+	connected  atomic.Bool
+	stopOnce   sync.Once
+	done       chan struct{}
+	stopped    chan struct{}
+}
+
+// NewSender returns a new sender of the specified destination.
+func NewSender(dest *Destination, queueSize int, batchSize int) *Sender {
+	return &Sender{
+		dest:       dest,
+		queue:      make(chan string, max(queueSize, 1)),
+		batchSize:  max(batchSize, 1),
+		minBackoff: DefaultMinReconnectBackoff,
+		maxBackoff: DefaultMaxReconnectBackoff,
+		timeout:    time.Second * graphite.DefaultTimeoutSecond,
+		sent:       atomic.Int64{},
+		dropped:    atomic.Int64{},
+		connected:  atomic.Bool{},
+		stopOnce:   sync.Once{},
+		done:       make(chan struct{}),
+		stopped:    make(chan struct{}),
+	}
+}
+
+// SetReconnectBackoff sets the first and maximum wait times to reconnect. Set them before Start.
+func (sender *Sender) SetReconnectBackoff(minBackoff time.Duration, maxBackoff time.Duration) {
+	sender.minBackoff = minBackoff
+	sender.maxBackoff = max(minBackoff, maxBackoff)
+}
+
+// SetTimeout sets the timeout to connect and write. Set it before Start.
+func (sender *Sender) SetTimeout(d time.Duration) {
+	sender.timeout = d
+}
+
+// GetDestination returns the destination of the sender.
+func (sender *Sender) GetDestination() *Destination {
+	return sender.dest
+}
+
+// Enqueue adds the specified plaintext line into the queue, and drops it when the queue is full.
+func (sender *Sender) Enqueue(line string) error {
+	select {
+	case <-sender.done:
+		sender.dropped.Add(1)
+		return fmt.Errorf(errorSenderAlreadyStopped, sender.dest.String())
+	default:
+	}
+
+	select {
+	case sender.queue <- line:
+		return nil
+	default:
+		sender.dropped.Add(1)
+		return fmt.Errorf(errorSenderQueueFull, sender.dest.String())
+	}
+}
+
+// Stats returns the current statistics of the sender.
+func (sender *Sender) Stats() *SenderStats {
+	return &SenderStats{
+		Destination: sender.dest.String(),
+		Queued:      len(sender.queue),
+		Sent:        sender.sent.Load(),
+		Dropped:     sender.dropped.Load(),
+		Connected:   sender.connected.Load(),
+	}
+}
+
+// Start starts sending the queued datapoints.
+func (sender *Sender) Start() {
+	go sender.run()
+}
+
+// Stop stops the sender, and waits until the queued datapoints are sent or the specified timeout passes.
+// The datapoints which aren't sent until the timeout are dropped.
+func (sender *Sender) Stop(timeout time.Duration) {
+	sender.stopOnce.Do(func() {
+		close(sender.done)
+	})
+	select {
+	case <-sender.stopped:
+	case <-time.After(timeout):
+	}
+}
+
+func (sender *Sender) connect() (net.Conn, error) {
+	client := graphite.NewClient()
+	client.SetHost(sender.dest.Host)
+	client.SetCarbonPort(sender.dest.Port)
+	client.SetTimeout(sender.timeout)
+	return client.Open()
+}
+
+// nextBatch waits for the queued datapoints, and returns up to the batch size datapoints.
+// nextBatch returns false when the sender is stopped and the queue is empty.
+func (sender *Sender) nextBatch() ([]string, bool) {
+	batch := make([]string, 0, sender.batchSize)
+	select {
+	case line := <-sender.queue:
+		batch = append(batch, line)
+	case <-sender.done:
+		select {
+		case line := <-sender.queue:
+			batch = append(batch, line)
+		default:
+			return nil, false
+		}
+	}
+	for len(batch) < sender.batchSize {
+		select {
+		case line := <-sender.queue:
+			batch = append(batch, line)
+		default:
+			return batch, true
+		}
+	}
+	return batch, true
+}
+
+// wait sleeps the specified duration, and returns false when the sender is stopped.
+func (sender *Sender) wait(d time.Duration) bool {
+	select {
+	case <-sender.done:
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+func (sender *Sender) run() {
+	defer close(sender.stopped)
+
+	var conn net.Conn
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+		sender.connected.Store(false)
+	}()
+
+	client := graphite.NewClient()
+	backoff := sender.minBackoff
+
+	for {
+		batch, ok := sender.nextBatch()
+		if !ok {
+			return
+		}
+		data := strings.Join(batch, "")
+
+		for {
+			var err error
+			if conn == nil {
+				conn, err = sender.connect()
+			}
+			if err == nil {
+				conn.SetWriteDeadline(time.Now().Add(sender.timeout))
+				err = client.FeedStringWithConnection(conn, data)
+				if err == nil {
+					sender.sent.Add(int64(len(batch)))
+					sender.connected.Store(true)
+					backoff = sender.minBackoff
+					break
+				}
+				conn.Close()
+				conn = nil
+			}
+			sender.connected.Store(false)
+			if !sender.wait(backoff) {
+				// Give up the batch after the stopping while the destination is down.
+				sender.dropped.Add(int64(len(batch)))
+				sender.drop()
+				return
+			}
+			backoff = min(backoff*2, sender.maxBackoff)
+		}
+	}
+}
+
+// drop discards all queued datapoints.
+func (sender *Sender) drop() {
+	for {
+		select {
+		case <-sender.queue:
+			sender.dropped.Add(1)
+		default:
+			return
+		}
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"net"
 	"net/http"
 	"strconv"
+	"sync/atomic"
 	"time"
 )
 
@@ -17,6 +18,10 @@ const (
 	DefaultRenderPort int = 8080
 	// DefaultRenderConnectionTimeout is a default timeout for Render.
 	DefaultRenderConnectionTimeout time.Duration = DefaultConnectionTimeout
+	// DefaultRenderRequestTimeout is a default timeout of the listener calls for Render. The zero timeout disables it.
+	DefaultRenderRequestTimeout time.Duration = DefaultRequestTimeout
+	// DefaultRenderMaxIngestSize is a default maximum byte size of the posted metrics for Render. The zero size disables it.
+	DefaultRenderMaxIngestSize int64 = DefaultMaxIngestSize
 )
 
 // Render is an instance for Graphite render protocols.
@@ -24,7 +29,17 @@ type Render struct {
 	addr               string
 	port               int
 	connectionTimeout  time.Duration
+	requestTimeout     time.Duration
+	maxSeries          int
+	maxIngestSize      int64
 	renderListener     RenderRequestListener
+	ingestListener     CarbonListener
+	metricIndex        *MetricIndex
+	tlsConfig          *TLSConfig
+	tlsServerConfig    atomic.Pointer[tlsServerConfig]
+	authenticators     []RenderAuthenticator
+	pathRules          *PathRules
+	tenancy            *Tenancy
 	server             *http.Server
 	extraHTTPListeners map[string]RenderHTTPRequestListener
 }
@@ -35,7 +50,17 @@ func NewRender() *Render {
 		addr:               "",
 		port:               DefaultRenderPort,
 		connectionTimeout:  DefaultRenderConnectionTimeout,
+		requestTimeout:     DefaultRenderRequestTimeout,
+		maxSeries:          0,
+		maxIngestSize:      DefaultRenderMaxIngestSize,
 		renderListener:     nil,
+		ingestListener:     nil,
+		metricIndex:        nil,
+		tlsConfig:          nil,
+		tlsServerConfig:    atomic.Pointer[tlsServerConfig]{},
+		authenticators:     []RenderAuthenticator{},
+		pathRules:          nil,
+		tenancy:            nil,
 		server:             nil,
 		extraHTTPListeners: make(map[string]RenderHTTPRequestListener),
 	}
@@ -73,11 +98,110 @@ func (render *Render) GetConnectionTimeout() time.Duration {
 	return render.connectionTimeout
 }
 
+// SetRequestTimeout sets the timeout of each request, and the contexts of the listener calls are canceled when it expires.
+func (render *Render) SetRequestTimeout(d time.Duration) {
+	render.requestTimeout = d
+}
+
+// GetRequestTimeout returns the timeout of each request.
+func (render *Render) GetRequestTimeout() time.Duration {
+	return render.requestTimeout
+}
+
+// SetMaxSeries sets the maximum number of the series of each render request, and the zero number disables the limit.
+func (render *Render) SetMaxSeries(n int) {
+	render.maxSeries = n
+}
+
+// GetMaxSeries returns the maximum number of the series of each render request.
+func (render *Render) GetMaxSeries() int {
+	return render.maxSeries
+}
+
+// SetMaxIngestSize sets the maximum byte size of the posted metrics, which is checked for both of the compressed and the decompressed bodies.
+// The zero size disables the limit.
+func (render *Render) SetMaxIngestSize(n int64) {
+	render.maxIngestSize = n
+}
+
+// GetMaxIngestSize returns the maximum byte size of the posted metrics.
+func (render *Render) GetMaxIngestSize() int64 {
+	return render.maxIngestSize
+}
+
 // SetRenderListener sets a default listener.
 func (render *Render) SetRenderListener(listener RenderRequestListener) {
 	render.renderListener = listener
 }
 
+// SetIngestListener sets a listener of the metrics which are posted to '/metrics'.
+func (render *Render) SetIngestListener(listener CarbonListener) {
+	render.ingestListener = listener
+}
+
+// GetIngestListener returns the listener of the posted metrics.
+func (render *Render) GetIngestListener() CarbonListener {
+	return render.ingestListener
+}
+
+// SetMetricIndex sets an index to serve find and index requests without the render listener.
+func (render *Render) SetMetricIndex(idx *MetricIndex) {
+	render.metricIndex = idx
+}
+
+// GetMetricIndex returns the index to serve find and index requests.
+func (render *Render) GetMetricIndex() *MetricIndex {
+	return render.metricIndex
+}
+
+// SetTLSConfig sets the TLS configuration of the HTTP server. The nil configuration disables TLS.
+func (render *Render) SetTLSConfig(conf *TLSConfig) {
+	render.tlsConfig = conf
+}
+
+// GetTLSConfig returns the TLS configuration of the HTTP server.
+func (render *Render) GetTLSConfig() *TLSConfig {
+	return render.tlsConfig
+}
+
+// AddAuthenticator adds an authenticator of the HTTP requests.
+// The requests are accepted when one of the authenticators accepts them, and all requests are accepted without the authenticators.
+func (render *Render) AddAuthenticator(authenticator RenderAuthenticator) {
+	render.authenticators = append(render.authenticators, authenticator)
+}
+
+// SetAuthenticators sets the authenticators of the HTTP requests.
+func (render *Render) SetAuthenticators(authenticators []RenderAuthenticator) {
+	render.authenticators = authenticators
+}
+
+// GetAuthenticators returns the authenticators of the HTTP requests.
+func (render *Render) GetAuthenticators() []RenderAuthenticator {
+	return render.authenticators
+}
+
+// SetPathRules sets the rules which authorize the principals to see the metrics in the find, index, expand and render requests,
+// and the extra listeners which aren't RenderAuthorizedHTTPRequestListener are forbidden while Render has the rules. The nil rules authorize all metrics.
+func (render *Render) SetPathRules(rules *PathRules) {
+	render.pathRules = rules
+}
+
+// GetPathRules returns the authorization rules of the metrics.
+func (render *Render) GetPathRules() *PathRules {
+	return render.pathRules
+}
+
+// SetTenancy sets the tenancy of the HTTP requests, and the extra listeners which aren't RenderAuthorizedHTTPRequestListener are forbidden
+// while Render has the tenancy. The nil tenancy disables it.
+func (render *Render) SetTenancy(tenancy *Tenancy) {
+	render.tenancy = tenancy
+}
+
+// GetTenancy returns the tenancy of the HTTP requests.
+func (render *Render) GetTenancy() *Tenancy {
+	return render.tenancy
+}
+
 // SetHTTPRequestListener sets a extra HTTP request listener.
 func (render *Render) SetHTTPRequestListener(path string, listener RenderHTTPRequestListener) error {
 	if len(path) == 0 || listener == nil {
@@ -116,8 +240,23 @@ func (render *Render) Start() error {
 		Handler:     render,
 	}
 
+	render.tlsServerConfig.Store(nil)
+	if render.tlsConfig.IsEnabled() {
+		tlsConf, err := render.tlsConfig.ServerConfig()
+		if err != nil {
+			return err
+		}
+		tlsServerConf := newTLSServerConfig(tlsConf)
+		render.tlsServerConfig.Store(tlsServerConf)
+		render.server.TLSConfig = tlsServerConf.ListenerConfig()
+	}
+
 	c := make(chan error)
 	go func() {
+		if render.server.TLSConfig != nil {
+			c <- render.server.ListenAndServeTLS("", "")
+			return
+		}
 		c <- render.server.ListenAndServe()
 	}()
 
@@ -130,6 +269,21 @@ func (render *Render) Start() error {
 	return err
 }
 
+// ReloadTLS reloads the certificate files of the TLS configuration for the new connections without closing the HTTP server.
+// The current certificates are kept when the new files are invalid, and the server which is started without TLS isn't changed until restarted.
+func (render *Render) ReloadTLS() error {
+	serverConf := render.tlsServerConfig.Load()
+	if serverConf == nil || !render.tlsConfig.IsEnabled() {
+		return nil
+	}
+	tlsConf, err := render.tlsConfig.ServerConfig()
+	if err != nil {
+		return err
+	}
+	serverConf.Store(tlsConf)
+	return nil
+}
+
 // Stop stops the HTTP server.
 func (render *Render) Stop() error {
 	if render.server == nil {
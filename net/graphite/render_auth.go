@@ -0,0 +1,179 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package graphite
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const (
+	httpHeaderWWWAuthenticate = "WWW-Authenticate"
+	renderAuthRealm           = "Basic realm=\"graphite\""
+	pathRuleDescendants       = ".*"
+)
+
+// RenderAuthenticator represents an authenticator of the HTTP requests of Render such as HTTP basic and bearer tokens.
+// AuthenticateRequest returns the principal name of the request, and false when the request has no valid credentials for the authenticator.
+type RenderAuthenticator interface {
+	AuthenticateRequest(r *http.Request) (string, bool)
+}
+
+type renderPrincipalKey struct{}
+
+// WithPrincipal returns a copy of the specified request which carries the authenticated principal name.
+func WithPrincipal(r *http.Request, principal string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), renderPrincipalKey{}, principal))
+}
+
+// PrincipalFromRequest returns the principal name which is authenticated by Render, so the extra HTTP request listeners can also use it.
+func PrincipalFromRequest(r *http.Request) (string, bool) {
+	principal, ok := r.Context().Value(renderPrincipalKey{}).(string)
+	return principal, ok
+}
+
+// PathRules is a set of the path prefix rules which authorize the principals to see the metrics such as 'team.x.*'.
+// The principals which have no rules can't see any metrics, and the tagged series are authorized with the names without the tags.
+type PathRules struct {
+	sync.RWMutex
+	rules map[string][]*PathPattern
+}
+
+// NewPathRules returns a new empty rules.
+func NewPathRules() *PathRules {
+	rules := &PathRules{
+		RWMutex: sync.RWMutex{},
+		rules:   map[string][]*PathPattern{},
+	}
+	return rules
+}
+
+// AddRule authorizes the specified principal to see the metrics under the specified prefix such as 'team.x' and 'team.x.*', which can have the wildcards such as 'team.{x,y}'.
+func (rules *PathRules) AddRule(principal string, prefix string) error {
+	if prefix != pathPatternAsterisk {
+		prefix = strings.TrimSuffix(prefix, pathRuleDescendants)
+	}
+	p, err := NewPathPattern(prefix)
+	if err != nil {
+		return err
+	}
+
+	rules.Lock()
+	defer rules.Unlock()
+	rules.rules[principal] = append(rules.rules[principal], p)
+
+	return nil
+}
+
+// SetRules replaces all rules of the specified principal.
+func (rules *PathRules) SetRules(principal string, prefixes []string) error {
+	rules.Lock()
+	delete(rules.rules, principal)
+	rules.Unlock()
+	for _, prefix := range prefixes {
+		err := rules.AddRule(principal, prefix)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IsAllowed returns true when the specified principal can see the specified path or series.
+func (rules *PathRules) IsAllowed(principal string, path string) bool {
+	name, _, _ := strings.Cut(path, tagSep)
+
+	rules.RLock()
+	defer rules.RUnlock()
+	for _, p := range rules.rules[principal] {
+		if p.MatchPrefix(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsBranchAllowed returns true when the specified principal can see the specified branch, which is allowed or leads to the allowed paths.
+func (rules *PathRules) IsBranchAllowed(principal string, path string) bool {
+	rules.RLock()
+	defer rules.RUnlock()
+	for _, p := range rules.rules[principal] {
+		if p.MatchPrefix(path) || p.MatchAncestor(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// isPathAllowed returns true when the principal of the specified request can see the path.
+func (render *Render) isPathAllowed(httpReq *http.Request, path string) bool {
+	if render.pathRules == nil {
+		return true
+	}
+	principal, _ := PrincipalFromRequest(httpReq)
+	return render.pathRules.IsAllowed(principal, path)
+}
+
+// isPathMatchAllowed returns true when the principal of the specified request can see the matched node.
+func (render *Render) isPathMatchAllowed(httpReq *http.Request, match *PathMatch) bool {
+	if render.pathRules == nil {
+		return true
+	}
+	principal, _ := PrincipalFromRequest(httpReq)
+	if match.IsLeaf && render.pathRules.IsAllowed(principal, match.Path) {
+		return true
+	}
+	return match.IsBranch && render.pathRules.IsBranchAllowed(principal, match.Path)
+}
+
+// filterMetrics returns the metrics which the principal of the specified request can see.
+func (render *Render) filterMetrics(httpReq *http.Request, metrics []*Metrics) []*Metrics {
+	if render.pathRules == nil {
+		return metrics
+	}
+	allowed := make([]*Metrics, 0, len(metrics))
+	for _, m := range metrics {
+		if m != nil && render.isPathAllowed(httpReq, m.Name) {
+			allowed = append(allowed, m)
+		}
+	}
+	return allowed
+}
+
+// filterPathMatches returns the matched nodes which the principal of the specified request can see.
+func (render *Render) filterPathMatches(httpReq *http.Request, matches []*PathMatch) []*PathMatch {
+	if render.pathRules == nil {
+		return matches
+	}
+	allowed := make([]*PathMatch, 0, len(matches))
+	for _, match := range matches {
+		if render.isPathMatchAllowed(httpReq, match) {
+			allowed = append(allowed, match)
+		}
+	}
+	return allowed
+}
+
+// authenticateRequest returns the request which carries the principal, and false when no authenticator accepts the request.
+// All requests are accepted when Render has no authenticators.
+func (render *Render) authenticateRequest(httpReq *http.Request) (*http.Request, bool) {
+	if len(render.authenticators) == 0 {
+		return httpReq, true
+	}
+	for _, authenticator := range render.authenticators {
+		principal, ok := authenticator.AuthenticateRequest(httpReq)
+		if ok {
+			return WithPrincipal(httpReq, principal), true
+		}
+	}
+	return httpReq, false
+}
+
+func (render *Render) responseUnauthorized(httpWriter http.ResponseWriter, httpReq *http.Request) {
+	httpWriter.Header().Set(httpHeaderWWWAuthenticate, renderAuthRealm)
+	render.responseError(httpWriter, httpReq, NewUnauthorizedError(httpReq.URL.Path), "")
+}
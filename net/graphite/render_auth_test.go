@@ -0,0 +1,189 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package graphite
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+type testHeaderAuthenticator struct{}
+
+func (a *testHeaderAuthenticator) AuthenticateRequest(r *http.Request) (string, bool) {
+	principal := r.Header.Get("X-Test-Principal")
+	return principal, 0 < len(principal)
+}
+
+func TestPathRules(t *testing.T) {
+	rules := NewPathRules()
+	rules.AddRule("x", "team.x.*")
+	rules.AddRule("y", "team.{y,z}")
+	rules.AddRule("admin", "*")
+
+	testCases := []struct {
+		principal string
+		path      string
+		allowed   bool
+		branch    bool
+	}{
+		{"x", "team.x", true, true},
+		{"x", "team.x.cpu", true, true},
+		{"x", "team.x.cpu;host=web01", true, true},
+		{"x", "team.xy.cpu", false, false},
+		{"x", "team", false, true},
+		{"x", "team.y.cpu", false, false},
+		{"y", "team.z.cpu", true, true},
+		{"admin", "other.cpu", true, true},
+		{"unknown", "team.x.cpu", false, false},
+	}
+	for _, tc := range testCases {
+		allowed := rules.IsAllowed(tc.principal, tc.path)
+		branch := rules.IsBranchAllowed(tc.principal, tc.path)
+		if allowed != tc.allowed || branch != tc.branch {
+			t.Error(fmt.Errorf("%s %s : %t %t != %t %t", tc.principal, tc.path, allowed, branch, tc.allowed, tc.branch))
+		}
+	}
+}
+
+func TestRenderAuthorization(t *testing.T) {
+	idx := NewMetricIndex()
+	for _, name := range []string{"team.x.cpu", "team.x.mem", "team.y.cpu", "other.cpu"} {
+		idx.Insert(name)
+	}
+
+	rules := NewPathRules()
+	rules.AddRule("x", "team.x")
+
+	render := NewRender()
+	render.SetMetricIndex(idx)
+	render.AddAuthenticator(&testHeaderAuthenticator{})
+	render.SetPathRules(rules)
+
+	serve := func(path string, principal string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if 0 < len(principal) {
+			req.Header.Set("X-Test-Principal", principal)
+		}
+		res := httptest.NewRecorder()
+		render.ServeHTTP(res, req)
+		return res
+	}
+
+	res := serve(renderDefaultIndexRequestPath, "")
+	if res.Code != http.StatusUnauthorized {
+		t.Error(fmt.Errorf("%d != %d", res.Code, http.StatusUnauthorized))
+	}
+	if len(res.Header().Get(httpHeaderWWWAuthenticate)) == 0 {
+		t.Error(fmt.Errorf("%s isn't set", httpHeaderWWWAuthenticate))
+	}
+
+	res = serve(renderDefaultIndexRequestPath, "x")
+	names := []string{}
+	json.Unmarshal(res.Body.Bytes(), &names)
+	if !reflect.DeepEqual(names, []string{"team.x.cpu", "team.x.mem"}) {
+		t.Error(fmt.Errorf("%v", names))
+	}
+
+	// The wildcards never show the hidden metrics except the branches to the allowed metrics.
+	res = serve(renderDefaultFindRequestPath+"?query=*", "x")
+	find := renderFindMetricJSONResponse{}
+	json.Unmarshal(res.Body.Bytes(), &find)
+	if len(find.Metrics) != 1 || find.Metrics[0].Path != "team" {
+		t.Error(fmt.Errorf("%v", find.Metrics))
+	}
+	res = serve(renderDefaultFindRequestPath+"?query=team.*.cpu", "x")
+	find = renderFindMetricJSONResponse{}
+	json.Unmarshal(res.Body.Bytes(), &find)
+	if len(find.Metrics) != 1 || find.Metrics[0].Path != "team.x.cpu" {
+		t.Error(fmt.Errorf("%v", find.Metrics))
+	}
+
+	res = serve(renderDefaultExpandRequestPath+"?query=*.*.cpu&leavesOnly=1", "x")
+	expand := map[string][]string{}
+	json.Unmarshal(res.Body.Bytes(), &expand)
+	if !reflect.DeepEqual(expand["results"], []string{"team.x.cpu"}) {
+		t.Error(fmt.Errorf("%v", expand))
+	}
+}
+
+func TestRenderAuthorizationIngest(t *testing.T) {
+	rules := NewPathRules()
+	rules.AddRule("x", "team.x")
+
+	listener := newTestTenantListener()
+	render := NewRender()
+	render.SetIngestListener(listener)
+	render.AddAuthenticator(&testHeaderAuthenticator{})
+	render.SetPathRules(rules)
+
+	serve := func(principal string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, renderDefaultIngestRequestPath, bytes.NewBufferString(body))
+		req.Header.Set("X-Test-Principal", principal)
+		res := httptest.NewRecorder()
+		render.ServeHTTP(res, req)
+		return res
+	}
+
+	res := serve("x", "team.x.cpu 1 1500000000\n")
+	if res.Code != http.StatusOK {
+		t.Error(fmt.Errorf("%d != %d", res.Code, http.StatusOK))
+	}
+
+	// No metrics are written when one of them isn't allowed.
+	res = serve("x", "team.x.mem 1 1500000000\nteam.y.cpu 1 1500000000\n")
+	if res.Code != http.StatusForbidden {
+		t.Error(fmt.Errorf("%d != %d", res.Code, http.StatusForbidden))
+	}
+
+	if names := listener.names(""); !reflect.DeepEqual(names, []string{"team.x.cpu"}) {
+		t.Error(fmt.Errorf("%v", names))
+	}
+}
+
+func TestRenderAuthorizationQuery(t *testing.T) {
+	listener := &testAuthRenderListener{}
+	rules := NewPathRules()
+	rules.AddRule("x", "team.x")
+
+	render := NewRender()
+	render.SetRenderListener(listener)
+	render.AddAuthenticator(&testHeaderAuthenticator{})
+	render.SetPathRules(rules)
+
+	req := httptest.NewRequest(http.MethodGet, renderDefaultQueryRequestPath+"?target=*.*.cpu&format=json", nil)
+	req.Header.Set("X-Test-Principal", "x")
+	res := httptest.NewRecorder()
+	render.ServeHTTP(res, req)
+
+	series := []map[string]any{}
+	err := json.Unmarshal(res.Body.Bytes(), &series)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(series) != 1 || series[0]["target"] != "team.x.cpu" {
+		t.Error(fmt.Errorf("%v", series))
+	}
+}
+
+type testAuthRenderListener struct{}
+
+func (l *testAuthRenderListener) FindMetricsRequestReceived(query *Query, err error) ([]*Metrics, error) {
+	return nil, nil
+}
+
+func (l *testAuthRenderListener) QueryMetricsRequestReceived(query *Query, err error) ([]*Metrics, error) {
+	ms := []*Metrics{}
+	for _, name := range []string{"team.x.cpu", "team.y.cpu", "other.host.cpu"} {
+		m := NewMetrics()
+		m.SetName(name)
+		ms = append(ms, m)
+	}
+	return ms, nil
+}
@@ -0,0 +1,95 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package graphite
+
+import (
+	"context"
+	"net/http"
+)
+
+const (
+	// StatusClientClosedRequest is the non-standard HTTP status of the requests which are canceled by the clients.
+	StatusClientClosedRequest     = 499
+	statusTextClientClosedRequest = "Client Closed Request"
+)
+
+// isTenantRenderListener returns true when the specified listener receives the tenants of the queries.
+func isTenantRenderListener(listener RenderRequestListener) bool {
+	switch listener.(type) {
+	case ContextRenderRequestListener, TenantRenderRequestListener:
+		return true
+	}
+	return false
+}
+
+// waitListenerMetrics calls the specified listener function which doesn't know the context, and returns the error of the context
+// as soon as the context is done. The listener keeps running in the background until it returns, and the result is dropped.
+func waitListenerMetrics(ctx context.Context, listenerFunc func() ([]*Metrics, error)) ([]*Metrics, error) {
+	if ctx.Done() == nil {
+		return listenerFunc()
+	}
+
+	type listenerResult struct {
+		metrics []*Metrics
+		err     error
+	}
+
+	c := make(chan listenerResult, 1)
+	go func() {
+		metrics, err := listenerFunc()
+		c <- listenerResult{metrics: metrics, err: err}
+	}()
+
+	select {
+	case res := <-c:
+		return res.metrics, res.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// callRenderListener calls the most specific method of the render listener for the specified query of the request.
+// The error of the request context precedes the error of the listener, so the canceled requests are always reported as such.
+func (render *Render) callRenderListener(httpReq *http.Request, query *Query, find bool) ([]*Metrics, error) {
+	ctx := httpReq.Context()
+	tenant := render.requestTenant(httpReq)
+
+	var metrics []*Metrics
+	var err error
+	switch listener := render.renderListener.(type) {
+	case ContextRenderRequestListener:
+		if find {
+			metrics, err = listener.FindMetricsRequestReceivedContext(ctx, query, nil)
+		} else {
+			metrics, err = listener.QueryMetricsRequestReceivedContext(ctx, query, nil)
+		}
+	case TenantRenderRequestListener:
+		metrics, err = waitListenerMetrics(ctx, func() ([]*Metrics, error) {
+			if len(tenant) == 0 {
+				if find {
+					return listener.FindMetricsRequestReceived(query, nil)
+				}
+				return listener.QueryMetricsRequestReceived(query, nil)
+			}
+			if find {
+				return listener.FindTenantMetricsRequestReceived(tenant, query, nil)
+			}
+			return listener.QueryTenantMetricsRequestReceived(tenant, query, nil)
+		})
+	default:
+		metrics, err = waitListenerMetrics(ctx, func() ([]*Metrics, error) {
+			if find {
+				return listener.FindMetricsRequestReceived(query, nil)
+			}
+			return listener.QueryMetricsRequestReceived(query, nil)
+		})
+	}
+
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	return metrics, err
+}
@@ -0,0 +1,157 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package graphite
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type testContextListener struct {
+	tenant  string
+	err     error
+	metrics []*Metrics
+}
+
+func (l *testContextListener) InsertMetricsRequestReceived(ms []*Metrics, err error) {
+	l.InsertMetricsRequestReceivedContext(context.Background(), ms, err)
+}
+
+func (l *testContextListener) InsertMetricsRequestReceivedContext(ctx context.Context, ms []*Metrics, err error) {
+	l.tenant, _ = TenantFromContext(ctx)
+	l.metrics = append(l.metrics, ms...)
+}
+
+func (l *testContextListener) FindMetricsRequestReceived(query *Query, err error) ([]*Metrics, error) {
+	return l.FindMetricsRequestReceivedContext(context.Background(), query, err)
+}
+
+func (l *testContextListener) QueryMetricsRequestReceived(query *Query, err error) ([]*Metrics, error) {
+	return l.QueryMetricsRequestReceivedContext(context.Background(), query, err)
+}
+
+func (l *testContextListener) FindMetricsRequestReceivedContext(ctx context.Context, query *Query, err error) ([]*Metrics, error) {
+	return l.QueryMetricsRequestReceivedContext(ctx, query, err)
+}
+
+// QueryMetricsRequestReceivedContext blocks until the context is done like a slow backend.
+func (l *testContextListener) QueryMetricsRequestReceivedContext(ctx context.Context, query *Query, err error) ([]*Metrics, error) {
+	l.tenant, _ = TenantFromContext(ctx)
+	<-ctx.Done()
+	l.err = ctx.Err()
+	return nil, l.err
+}
+
+type testSlowRenderListener struct {
+	delay time.Duration
+}
+
+func (l *testSlowRenderListener) FindMetricsRequestReceived(query *Query, err error) ([]*Metrics, error) {
+	return l.QueryMetricsRequestReceived(query, err)
+}
+
+func (l *testSlowRenderListener) QueryMetricsRequestReceived(query *Query, err error) ([]*Metrics, error) {
+	time.Sleep(l.delay)
+	return []*Metrics{}, nil
+}
+
+func TestRenderRequestTimeout(t *testing.T) {
+	listener := &testContextListener{}
+	tenancy := NewTenancy(TenantPrefix)
+	tenancy.SetHeader(DefaultTenantHeader)
+
+	render := NewRender()
+	render.SetRenderListener(listener)
+	render.SetTenancy(tenancy)
+	render.SetRequestTimeout(time.Millisecond * 50)
+
+	req := httptest.NewRequest(http.MethodGet, renderDefaultQueryRequestPath+"?target=cpu&format=json", nil)
+	req.Header.Set(DefaultTenantHeader, "team-a")
+	res := httptest.NewRecorder()
+	render.ServeHTTP(res, req)
+
+	if res.Code != http.StatusGatewayTimeout {
+		t.Error(fmt.Errorf("%d != %d", res.Code, http.StatusGatewayTimeout))
+	}
+	if !errors.Is(listener.err, context.DeadlineExceeded) {
+		t.Error(fmt.Errorf("%v != %v", listener.err, context.DeadlineExceeded))
+	}
+	if listener.tenant != "team-a" {
+		t.Error(fmt.Errorf("%s != %s", listener.tenant, "team-a"))
+	}
+}
+
+func TestRenderRequestCanceled(t *testing.T) {
+	listener := &testContextListener{}
+	render := NewRender()
+	render.SetRenderListener(listener)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, renderDefaultFindRequestPath+"?query=*", nil).WithContext(ctx)
+	res := httptest.NewRecorder()
+	time.AfterFunc(time.Millisecond*10, cancel)
+	render.ServeHTTP(res, req)
+
+	if res.Code != StatusClientClosedRequest {
+		t.Error(fmt.Errorf("%d != %d", res.Code, StatusClientClosedRequest))
+	}
+	if !errors.Is(listener.err, context.Canceled) {
+		t.Error(fmt.Errorf("%v != %v", listener.err, context.Canceled))
+	}
+}
+
+func TestRenderRequestTimeoutWithoutContext(t *testing.T) {
+	render := NewRender()
+	render.SetRenderListener(&testSlowRenderListener{delay: time.Second})
+	render.SetRequestTimeout(time.Millisecond * 50)
+
+	start := time.Now()
+	req := httptest.NewRequest(http.MethodGet, renderDefaultQueryRequestPath+"?target=cpu&format=json", nil)
+	res := httptest.NewRecorder()
+	render.ServeHTTP(res, req)
+
+	if res.Code != http.StatusGatewayTimeout {
+		t.Error(fmt.Errorf("%d != %d", res.Code, http.StatusGatewayTimeout))
+	}
+	if elapsed := time.Since(start); time.Millisecond*500 < elapsed {
+		t.Error(fmt.Errorf("%s", elapsed))
+	}
+
+	// The listeners which return in time are served as before.
+	render.SetRenderListener(&testSlowRenderListener{delay: 0})
+	res = httptest.NewRecorder()
+	render.ServeHTTP(res, req)
+	if res.Code != http.StatusOK {
+		t.Error(fmt.Errorf("%d != %d", res.Code, http.StatusOK))
+	}
+}
+
+func TestRenderIngestContext(t *testing.T) {
+	listener := &testContextListener{}
+	tenancy := NewTenancy(TenantIsolate)
+	tenancy.SetHeader(DefaultTenantHeader)
+
+	server := NewServer()
+	server.SetCarbonListener(listener)
+	server.SetTenancy(tenancy)
+
+	req := httptest.NewRequest(http.MethodPost, renderDefaultIngestRequestPath, bytes.NewBufferString("cpu 1 1500000000\n"))
+	req.Header.Set(DefaultTenantHeader, "team-a")
+	res := httptest.NewRecorder()
+	server.ServeHTTP(res, req)
+
+	if res.Code != http.StatusOK {
+		t.Error(fmt.Errorf("%d != %d", res.Code, http.StatusOK))
+	}
+	if len(listener.metrics) != 1 || listener.tenant != "team-a" {
+		t.Error(fmt.Errorf("%s %d", listener.tenant, len(listener.metrics)))
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package graphite
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// RenderErrorType represents a type of the errors of Render requests.
+type RenderErrorType int
+
+const (
+	// RenderErrorParse is a type of the errors of the invalid or missing parameters.
+	RenderErrorParse RenderErrorType = iota
+	// RenderErrorUnknownFunction is a type of the errors of the targets which have unknown functions.
+	RenderErrorUnknownFunction
+	// RenderErrorNotFound is a type of the errors of the targets which have no metrics.
+	RenderErrorNotFound
+	// RenderErrorTooManySeries is a type of the errors of the targets which have more series than the limit.
+	RenderErrorTooManySeries
+	// RenderErrorTimeout is a type of the errors of the requests whose timeout expired.
+	RenderErrorTimeout
+	// RenderErrorCanceled is a type of the errors of the requests which are canceled by the clients.
+	RenderErrorCanceled
+	// RenderErrorBackend is a type of the errors of the listeners such as the storage failures.
+	RenderErrorBackend
+	// RenderErrorTooLarge is a type of the errors of the posted bodies which are larger than the limit.
+	RenderErrorTooLarge
+	// RenderErrorUnauthorized is a type of the errors of the requests which have no valid credentials.
+	RenderErrorUnauthorized
+	// RenderErrorForbidden is a type of the errors of the requests which have no tenants or aren't allowed to the paths.
+	RenderErrorForbidden
+)
+
+var renderErrorTypeNames = map[RenderErrorType]string{
+	RenderErrorParse:           "parse_error",
+	RenderErrorUnknownFunction: "unknown_function",
+	RenderErrorNotFound:        "not_found",
+	RenderErrorTooManySeries:   "too_many_series",
+	RenderErrorTimeout:         "timeout",
+	RenderErrorCanceled:        "canceled",
+	RenderErrorBackend:         "backend_error",
+	RenderErrorTooLarge:        "too_large",
+	RenderErrorUnauthorized:    "unauthorized",
+	RenderErrorForbidden:       "forbidden",
+}
+
+var renderErrorTypeStatuses = map[RenderErrorType]int{
+	RenderErrorParse:           http.StatusBadRequest,
+	RenderErrorUnknownFunction: http.StatusBadRequest,
+	RenderErrorNotFound:        http.StatusNotFound,
+	RenderErrorTooManySeries:   http.StatusRequestEntityTooLarge,
+	RenderErrorTimeout:         http.StatusGatewayTimeout,
+	RenderErrorCanceled:        StatusClientClosedRequest,
+	RenderErrorBackend:         http.StatusInternalServerError,
+	RenderErrorTooLarge:        http.StatusRequestEntityTooLarge,
+	RenderErrorUnauthorized:    http.StatusUnauthorized,
+	RenderErrorForbidden:       http.StatusForbidden,
+}
+
+// String returns the name of the error type such as 'parse_error'.
+func (t RenderErrorType) String() string {
+	name, ok := renderErrorTypeNames[t]
+	if !ok {
+		return renderErrorTypeNames[RenderErrorBackend]
+	}
+	return name
+}
+
+// StatusCode returns the HTTP status of the error type.
+func (t RenderErrorType) StatusCode() int {
+	status, ok := renderErrorTypeStatuses[t]
+	if !ok {
+		return http.StatusInternalServerError
+	}
+	return status
+}
+
+// RenderError is a typed error of Render requests, which has the offending parameter such as 'target'.
+// The listeners can return the errors to respond with the matching HTTP statuses, and the other errors of the listeners are backend errors.
+type RenderError struct {
+	Type      RenderErrorType
+	Parameter string
+	Err       error
+}
+
+// RenderErrorResponse is the JSON body of the error responses of Render.
+type RenderErrorResponse struct {
+	Status    int    `json:"status"`
+	Error     string `json:"error"`
+	Message   string `json:"message"`
+	Parameter string `json:"parameter,omitempty"`
+}
+
+// NewRenderError returns a new error of the specified type and parameter.
+func NewRenderError(t RenderErrorType, param string, err error) *RenderError {
+	return &RenderError{
+		Type:      t,
+		Parameter: param,
+		Err:       err,
+	}
+}
+
+// NewParseError returns a new error of the specified invalid parameter.
+func NewParseError(param string, err error) *RenderError {
+	return NewRenderError(RenderErrorParse, param, err)
+}
+
+// NewUnknownFunctionError returns a new error of the specified unknown function in the target.
+func NewUnknownFunctionError(name string) *RenderError {
+	return NewRenderError(RenderErrorUnknownFunction, "", fmt.Errorf(errorUnknownFunction, name))
+}
+
+// NewNotFoundError returns a new error of the specified target which has no metrics.
+func NewNotFoundError(target string) *RenderError {
+	return NewRenderError(RenderErrorNotFound, "", fmt.Errorf(errorMetricsNotFound, target))
+}
+
+// NewTooManySeriesError returns a new error of the series count which is more than the limit.
+func NewTooManySeriesError(count int, limit int) *RenderError {
+	return NewRenderError(RenderErrorTooManySeries, "", fmt.Errorf(errorTooManySeries, count, limit))
+}
+
+// NewTimeoutError returns a new error of the request whose timeout expired.
+func NewTimeoutError(err error) *RenderError {
+	return NewRenderError(RenderErrorTimeout, "", err)
+}
+
+// NewTooLargeError returns a new error of the posted body which is larger than the specified limit.
+func NewTooLargeError(limit int64) *RenderError {
+	return NewRenderError(RenderErrorTooLarge, "", fmt.Errorf(errorIngestTooLarge, limit))
+}
+
+// NewUnauthorizedError returns a new error of the request path which has no valid credentials.
+func NewUnauthorizedError(path string) *RenderError {
+	return NewRenderError(RenderErrorUnauthorized, "", fmt.Errorf(errorUnauthorizedRequest, path))
+}
+
+// NewForbiddenError returns a new error of the request which isn't allowed.
+func NewForbiddenError(err error) *RenderError {
+	return NewRenderError(RenderErrorForbidden, "", err)
+}
+
+// NewBackendError returns a new error of the specified listener failure.
+func NewBackendError(err error) *RenderError {
+	return NewRenderError(RenderErrorBackend, "", err)
+}
+
+// Error returns the message of the error.
+func (e *RenderError) Error() string {
+	if e.Err == nil {
+		return e.Type.String()
+	}
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *RenderError) Unwrap() error {
+	return e.Err
+}
+
+// StatusCode returns the HTTP status of the error.
+func (e *RenderError) StatusCode() int {
+	return e.Type.StatusCode()
+}
+
+// Response returns the JSON body of the error.
+func (e *RenderError) Response() *RenderErrorResponse {
+	return &RenderErrorResponse{
+		Status:    e.StatusCode(),
+		Error:     e.Type.String(),
+		Message:   e.Error(),
+		Parameter: e.Parameter,
+	}
+}
+
+// AsRenderError returns the typed error of the specified error. The expired and canceled contexts are timeout and canceled errors,
+// and the other untyped errors are backend errors.
+func AsRenderError(err error) *RenderError {
+	var renderErr *RenderError
+	switch {
+	case errors.As(err, &renderErr):
+		return renderErr
+	case errors.Is(err, context.DeadlineExceeded):
+		return NewTimeoutError(err)
+	case errors.Is(err, context.Canceled):
+		return NewRenderError(RenderErrorCanceled, "", err)
+	}
+	return NewBackendError(err)
+}
+
+// responseError responds the specified error with the JSON body.
+// The specified parameter of the target is used for the errors of the target which have no parameters.
+func (render *Render) responseError(httpWriter http.ResponseWriter, httpReq *http.Request, err error, param string) {
+	renderErr := AsRenderError(err)
+	res := renderErr.Response()
+	if len(res.Parameter) == 0 {
+		switch renderErr.Type {
+		case RenderErrorParse, RenderErrorUnknownFunction, RenderErrorNotFound, RenderErrorTooManySeries:
+			res.Parameter = param
+		}
+	}
+
+	httpWriter.Header().Set(httpHeaderContentType, QueryContentTypeJSON)
+	httpWriter.Header().Set(httpHeaderAccessControlAllowOrigin, httpHeaderAccessControlAllowOriginAll)
+	httpWriter.WriteHeader(res.Status)
+	json.NewEncoder(httpWriter).Encode(res)
+}
@@ -0,0 +1,164 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package graphite
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type testErrorRenderListener struct {
+	err   error
+	count int
+}
+
+func (l *testErrorRenderListener) FindMetricsRequestReceived(query *Query, err error) ([]*Metrics, error) {
+	return l.QueryMetricsRequestReceived(query, err)
+}
+
+func (l *testErrorRenderListener) QueryMetricsRequestReceived(query *Query, err error) ([]*Metrics, error) {
+	if l.err != nil {
+		return nil, l.err
+	}
+	ms := []*Metrics{}
+	for n := range l.count {
+		m := NewMetrics()
+		m.SetName(fmt.Sprintf("cpu%d", n))
+		ms = append(ms, m)
+	}
+	return ms, nil
+}
+
+func TestRenderErrorResponses(t *testing.T) {
+	listener := &testErrorRenderListener{}
+	render := NewRender()
+	render.SetRenderListener(listener)
+	render.SetMaxSeries(2)
+
+	testCases := []struct {
+		path      string
+		err       error
+		count     int
+		status    int
+		errorType RenderErrorType
+		param     string
+	}{
+		{renderDefaultQueryRequestPath + "?target=cpu&format=json&from=xyz", nil, 0, http.StatusBadRequest, RenderErrorParse, QueryFrom},
+		{renderDefaultQueryRequestPath + "?format=json", nil, 0, http.StatusBadRequest, RenderErrorParse, QueryTarget},
+		{renderDefaultQueryRequestPath + "?target=cpu&format=xml", nil, 0, http.StatusBadRequest, RenderErrorParse, QueryFormat},
+		{renderDefaultQueryRequestPath + "?target=foo(cpu)&format=json", NewUnknownFunctionError("foo"), 0, http.StatusBadRequest, RenderErrorUnknownFunction, QueryTarget},
+		{renderDefaultQueryRequestPath + "?target=cpu&format=json", NewNotFoundError("cpu"), 0, http.StatusNotFound, RenderErrorNotFound, QueryTarget},
+		{renderDefaultQueryRequestPath + "?target=cpu*&format=json", nil, 3, http.StatusRequestEntityTooLarge, RenderErrorTooManySeries, QueryTarget},
+		{renderDefaultQueryRequestPath + "?target=cpu&format=json", errors.New("disk failure"), 0, http.StatusInternalServerError, RenderErrorBackend, ""},
+		{renderDefaultFindRequestPath + "?query=cpu", errors.New("disk failure"), 0, http.StatusInternalServerError, RenderErrorBackend, ""},
+		{renderDefaultFindRequestPath + "?query=cpu", NewNotFoundError("cpu"), 0, http.StatusNotFound, RenderErrorNotFound, QueryTargetRegexp},
+		{renderDefaultFindRequestPath, nil, 0, http.StatusBadRequest, RenderErrorParse, QueryTargetRegexp},
+	}
+
+	for _, tc := range testCases {
+		listener.err = tc.err
+		listener.count = tc.count
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		res := httptest.NewRecorder()
+		render.ServeHTTP(res, req)
+
+		if res.Code != tc.status {
+			t.Error(fmt.Errorf("%s : %d != %d", tc.path, res.Code, tc.status))
+			continue
+		}
+		body := RenderErrorResponse{}
+		err := json.Unmarshal(res.Body.Bytes(), &body)
+		if err != nil {
+			t.Error(fmt.Errorf("%s : %w", tc.path, err))
+			continue
+		}
+		if body.Status != tc.status || body.Error != tc.errorType.String() || body.Parameter != tc.param || len(body.Message) == 0 {
+			t.Error(fmt.Errorf("%s : %v", tc.path, body))
+		}
+	}
+
+	// The series within the limit are served as before.
+	listener.err = nil
+	listener.count = 2
+	req := httptest.NewRequest(http.MethodGet, renderDefaultQueryRequestPath+"?target=cpu*&format=json", nil)
+	res := httptest.NewRecorder()
+	render.ServeHTTP(res, req)
+	if res.Code != http.StatusOK {
+		t.Error(fmt.Errorf("%d != %d", res.Code, http.StatusOK))
+	}
+}
+
+func TestAsRenderError(t *testing.T) {
+	err := fmt.Errorf("wrapped : %w", NewNotFoundError("cpu"))
+	if renderErr := AsRenderError(err); renderErr.StatusCode() != http.StatusNotFound {
+		t.Error(fmt.Errorf("%d != %d", renderErr.StatusCode(), http.StatusNotFound))
+	}
+
+	err = NewParseError(QueryFrom, errors.New("invalid time format : xyz"))
+	if err.Error() != "invalid time format : xyz" {
+		t.Error(fmt.Errorf("%s", err.Error()))
+	}
+}
+
+func TestRenderAccessErrorResponses(t *testing.T) {
+	tenancy := NewTenancy(TenantPrefix)
+	tenancy.SetHeader(DefaultTenantHeader)
+
+	authRender := NewRender()
+	authRender.AddAuthenticator(&testHeaderAuthenticator{})
+	tenantRender := NewRender()
+	tenantRender.SetTenancy(tenancy)
+	ingestRender := NewRender()
+	ingestRender.SetIngestListener(&testIngestListener{})
+
+	testCases := []struct {
+		render    *Render
+		method    string
+		path      string
+		header    string
+		status    int
+		errorType RenderErrorType
+	}{
+		{authRender, http.MethodGet, renderDefaultFindRequestPath + "?query=*", "", http.StatusUnauthorized, RenderErrorUnauthorized},
+		{tenantRender, http.MethodGet, renderDefaultFindRequestPath + "?query=*", "", http.StatusForbidden, RenderErrorForbidden},
+		{ingestRender, http.MethodPost, renderDefaultIngestRequestPath, httpContentEncodingGzip, http.StatusBadRequest, RenderErrorParse},
+	}
+
+	for _, tc := range testCases {
+		req := httptest.NewRequest(tc.method, tc.path, strings.NewReader("not gzip"))
+		if 0 < len(tc.header) {
+			req.Header.Set(httpHeaderContentEncoding, tc.header)
+		}
+		res := httptest.NewRecorder()
+		tc.render.ServeHTTP(res, req)
+
+		if res.Code != tc.status {
+			t.Error(fmt.Errorf("%s : %d != %d", tc.path, res.Code, tc.status))
+			continue
+		}
+		body := RenderErrorResponse{}
+		err := json.Unmarshal(res.Body.Bytes(), &body)
+		if err != nil {
+			t.Error(fmt.Errorf("%s : %w", tc.path, err))
+			continue
+		}
+		if body.Status != tc.status || body.Error != tc.errorType.String() || len(body.Message) == 0 {
+			t.Error(fmt.Errorf("%s : %v", tc.path, body))
+		}
+	}
+
+	// The unauthorized responses keep the challenge.
+	req := httptest.NewRequest(http.MethodGet, renderDefaultQueryRequestPath, nil)
+	res := httptest.NewRecorder()
+	authRender.ServeHTTP(res, req)
+	if res.Header().Get(httpHeaderWWWAuthenticate) != renderAuthRealm {
+		t.Error(fmt.Errorf("%s != %s", res.Header().Get(httpHeaderWWWAuthenticate), renderAuthRealm))
+	}
+}
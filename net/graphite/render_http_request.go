@@ -5,11 +5,15 @@
 package graphite
 
 import (
+	"context"
+	"fmt"
 	"net/http"
 )
 
 const (
 	httpHeaderContentType                 = "Content-Type"
+	httpHeaderContentEncoding             = "Content-Encoding"
+	httpContentEncodingGzip               = "gzip"
 	httpHeaderAccessControlAllowOrigin    = "Access-Control-Allow-Origin"
 	httpHeaderAccessControlAllowOriginAll = "*"
 )
@@ -19,20 +23,52 @@ const (
 	renderDefaultExpandRequestPath string = "/metrics/expand"
 	renderDefaultIndexRequestPath  string = "/metrics/index.json"
 	renderDefaultQueryRequestPath  string = "/render"
+	renderDefaultIngestRequestPath string = "/metrics"
 	renderMetricsDelim             string = "."
 	renderMetricsAsterisk          string = "*"
+	renderExpandLeavesOnly         string = "leavesOnly"
+	renderExpandGroupByExpr        string = "groupByExpr"
 )
 
 // ServeHTTP handles HTTP requests.
 func (render *Render) ServeHTTP(httpWriter http.ResponseWriter, httpReq *http.Request) {
+	httpReq, ok := render.authenticateRequest(httpReq)
+	if !ok {
+		render.responseUnauthorized(httpWriter, httpReq)
+		return
+	}
+
+	if render.tenancy != nil {
+		tenant, ok := render.tenancy.RequestTenant(httpReq)
+		if !ok {
+			render.responseForbidden(httpWriter, httpReq, fmt.Errorf(errorUnknownTenant, httpReq.RemoteAddr))
+			return
+		}
+		httpReq = WithTenant(httpReq, tenant)
+	}
+
+	// The contexts of the listener calls are canceled when the clients go away or the timeout expires.
+	if 0 < render.requestTimeout {
+		ctx, cancel := context.WithTimeout(httpReq.Context(), render.requestTimeout)
+		defer cancel()
+		httpReq = httpReq.WithContext(ctx)
+	}
+
 	path := httpReq.URL.Path
 
+	// The other methods of '/metrics' are passed to the extra listeners such as the exposition of Prometheus.
+	if path == renderDefaultIngestRequestPath && httpReq.Method == http.MethodPost && render.ingestListener != nil {
+		render.handleIngestRequest(httpWriter, httpReq)
+		return
+	}
+
 	switch path {
 	case renderDefaultFindRequestPath:
 		render.handleFindRequest(httpWriter, httpReq)
 		return
 	case renderDefaultExpandRequestPath:
-		// TODO : Not implemented yet
+		render.handleExpandRequest(httpWriter, httpReq)
+		return
 	case renderDefaultIndexRequestPath:
 		render.handleIndexRequest(httpWriter, httpReq)
 		return
@@ -43,19 +79,24 @@ func (render *Render) ServeHTTP(httpWriter http.ResponseWriter, httpReq *http.Re
 
 	httpListener, ok := render.extraHTTPListeners[path]
 	if ok {
-		httpListener.HTTPRequestReceived(httpReq, httpWriter)
+		render.serveHTTPRequestListener(httpWriter, httpReq, httpListener)
 		return
 	}
 
 	http.NotFound(httpWriter, httpReq)
 }
 
-func (render *Render) responseBadRequest(httpWriter http.ResponseWriter, httpReq *http.Request) {
-	httpWriter.Header().Set(httpHeaderAccessControlAllowOrigin, httpHeaderAccessControlAllowOriginAll)
-	http.Error(httpWriter, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
-}
-
-func (render *Render) responseInternalServerError(httpWriter http.ResponseWriter, httpReq *http.Request) {
-	httpWriter.Header().Set(httpHeaderAccessControlAllowOrigin, httpHeaderAccessControlAllowOriginAll)
-	http.Error(httpWriter, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+// serveHTTPRequestListener passes the specified request to the extra listener.
+// The listeners which don't apply the path rules and the tenancy by themselves are forbidden while Render has them not to leak the other metrics.
+func (render *Render) serveHTTPRequestListener(httpWriter http.ResponseWriter, httpReq *http.Request, listener RenderHTTPRequestListener) {
+	authListener, ok := listener.(RenderAuthorizedHTTPRequestListener)
+	if ok {
+		authListener.AuthorizedHTTPRequestReceived(httpReq, httpWriter, render)
+		return
+	}
+	if render.pathRules != nil || render.tenancy != nil {
+		render.responseForbidden(httpWriter, httpReq, fmt.Errorf(errorForbiddenListener, httpReq.URL.Path))
+		return
+	}
+	listener.HTTPRequestReceived(httpReq, httpWriter)
 }
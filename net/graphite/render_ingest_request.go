@@ -0,0 +1,208 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package graphite
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// IngestMetric is a posted metric of the JSON format such as '{"name":"cpu","value":1.5,"timestamp":1500000000,"tags":{"host":"web01"}}'.
+// The metric which has no timestamp is stamped with the received time.
+type IngestMetric struct {
+	Name      string            `json:"name"`
+	Value     *float64          `json:"value"`
+	Timestamp *float64          `json:"timestamp,omitempty"`
+	Tags      map[string]string `json:"tags,omitempty"`
+}
+
+// IngestError is an error of a posted line or a posted JSON object which is numbered from 1.
+type IngestError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// IngestResponse is the response of the posted metrics.
+type IngestResponse struct {
+	Accepted int            `json:"accepted"`
+	Failed   int            `json:"failed"`
+	Errors   []*IngestError `json:"errors,omitempty"`
+}
+
+// Metrics returns a new metrics of the posted metric.
+func (im *IngestMetric) Metrics(now time.Time) (*Metrics, error) {
+	if len(im.Name) == 0 {
+		return nil, fmt.Errorf(errorIngestMissingName, im.String())
+	}
+	if im.Value == nil {
+		return nil, fmt.Errorf(errorIngestMissingValue, im.String())
+	}
+
+	ts := now
+	if im.Timestamp != nil {
+		ts = time.Unix(int64(*im.Timestamp), 0)
+	}
+
+	m := NewMetrics()
+	m.SetName(TaggedName(im.Name, im.Tags))
+	dp := NewDataPoint()
+	dp.SetTimestamp(ts)
+	dp.SetValue(*im.Value)
+	m.AddDataPoint(dp)
+
+	return m, nil
+}
+
+// String returns the JSON string of the posted metric.
+func (im *IngestMetric) String() string {
+	b, _ := json.Marshal(im)
+	return string(b)
+}
+
+// ParseIngestJSON parses the specified JSON array or object of the posted metrics.
+// The invalid objects are reported with the positions in the array, and the other objects are returned.
+func ParseIngestJSON(b []byte, now time.Time) ([]*Metrics, []*IngestError, error) {
+	b = bytes.TrimSpace(b)
+	objs := []json.RawMessage{}
+	if bytes.HasPrefix(b, []byte("{")) {
+		objs = append(objs, json.RawMessage(b))
+	} else {
+		err := json.Unmarshal(b, &objs)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	ms := []*Metrics{}
+	errs := []*IngestError{}
+	for n, obj := range objs {
+		im := &IngestMetric{}
+		err := json.Unmarshal(obj, im)
+		if err == nil {
+			var m *Metrics
+			m, err = im.Metrics(now)
+			if err == nil {
+				ms = append(ms, m)
+				continue
+			}
+		}
+		errs = append(errs, &IngestError{Line: n + 1, Error: err.Error()})
+	}
+
+	return ms, errs, nil
+}
+
+// ParseIngestPlainText parses the specified lines of the plain text protocol of Carbon.
+// The invalid lines are reported with the line numbers, and the other lines are returned.
+func ParseIngestPlainText(text string) ([]*Metrics, []*IngestError) {
+	ms := []*Metrics{}
+	errs := []*IngestError{}
+	for n, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if len(strings.TrimSpace(line)) == 0 {
+			continue
+		}
+		m, err := NewMetricsWithPlainLine(line)
+		if err != nil {
+			errs = append(errs, &IngestError{Line: n + 1, Error: err.Error()})
+			continue
+		}
+		ms = append(ms, m)
+	}
+	return ms, errs
+}
+
+// isIngestJSON returns true when the specified body is posted as JSON.
+func isIngestJSON(httpReq *http.Request, b []byte) bool {
+	if strings.HasPrefix(httpReq.Header.Get(httpHeaderContentType), QueryContentTypeJSON) {
+		return true
+	}
+	b = bytes.TrimSpace(b)
+	return bytes.HasPrefix(b, []byte("[")) || bytes.HasPrefix(b, []byte("{"))
+}
+
+// handleIngestRequest handles 'POST /metrics' of a JSON array of the metrics or the plain text lines of Carbon.
+// The valid metrics are delivered to the ingest listener even if the request has invalid ones, and the errors are reported with 400.
+// The bodies which are larger than the limit before or after the decompression are rejected with 413.
+func (render *Render) handleIngestRequest(httpWriter http.ResponseWriter, httpReq *http.Request) {
+	limit := render.maxIngestSize
+	var body io.Reader = httpReq.Body
+	if 0 < limit {
+		body = http.MaxBytesReader(httpWriter, httpReq.Body, limit)
+	}
+	if httpReq.Header.Get(httpHeaderContentEncoding) == httpContentEncodingGzip {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			render.responseIngestReadError(httpWriter, httpReq, err)
+			return
+		}
+		defer gz.Close()
+		body = gz
+	}
+	if 0 < limit {
+		body = io.LimitReader(body, limit+1)
+	}
+
+	b, err := io.ReadAll(body)
+	if err == nil && 0 < limit && limit < int64(len(b)) {
+		err = &http.MaxBytesError{Limit: limit}
+	}
+	if err != nil {
+		render.responseIngestReadError(httpWriter, httpReq, err)
+		return
+	}
+
+	var ms []*Metrics
+	var errs []*IngestError
+	if isIngestJSON(httpReq, b) {
+		ms, errs, err = ParseIngestJSON(b, time.Now())
+		if err != nil {
+			errs = []*IngestError{{Line: 0, Error: err.Error()}}
+		}
+	} else {
+		ms, errs = ParseIngestPlainText(string(b))
+	}
+
+	if 0 < len(ms) {
+		err = render.insertMetrics(httpReq, ms)
+		if err != nil {
+			render.responseError(httpWriter, httpReq, err, "")
+			return
+		}
+	}
+
+	res := &IngestResponse{
+		Accepted: len(ms),
+		Failed:   len(errs),
+		Errors:   errs,
+	}
+
+	status := http.StatusOK
+	if 0 < len(errs) {
+		status = http.StatusBadRequest
+	}
+
+	httpWriter.Header().Set(httpHeaderContentType, QueryContentTypeJSON)
+	httpWriter.Header().Set(httpHeaderAccessControlAllowOrigin, httpHeaderAccessControlAllowOriginAll)
+	httpWriter.WriteHeader(status)
+	json.NewEncoder(httpWriter).Encode(res)
+}
+
+// responseIngestReadError responds the specified error of reading the posted body with 400, and the bodies over the limit are responded with 413.
+func (render *Render) responseIngestReadError(httpWriter http.ResponseWriter, httpReq *http.Request, err error) {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		render.responseError(httpWriter, httpReq, NewTooLargeError(maxErr.Limit), "")
+		return
+	}
+	render.responseError(httpWriter, httpReq, NewParseError("", err), "")
+}
@@ -0,0 +1,208 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package graphite
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testIngestListener struct {
+	ms []*Metrics
+}
+
+func (l *testIngestListener) InsertMetricsRequestReceived(ms []*Metrics, err error) {
+	l.ms = append(l.ms, ms...)
+}
+
+func TestParseIngestJSON(t *testing.T) {
+	now := time.Unix(1500000100, 0)
+	body := `[
+		{"name":"cpu","value":1.5,"timestamp":1500000000,"tags":{"host":"web01","dc":"us"}},
+		{"name":"mem","value":2},
+		{"name":"disk"},
+		{"value":3},
+		"cpu 1 1500000000"
+	]`
+	ms, errs, err := ParseIngestJSON([]byte(body), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{"cpu;dc=us;host=web01", "mem"}
+	if len(ms) != len(names) {
+		t.Fatal(fmt.Errorf("%d != %d", len(ms), len(names)))
+	}
+	for n, name := range names {
+		if ms[n].Name != name {
+			t.Error(fmt.Errorf("%s != %s", ms[n].Name, name))
+		}
+	}
+	if ts := ms[0].DataPoints[0].UnixTimestamp(); ts != 1500000000 {
+		t.Error(fmt.Errorf("%d != %d", ts, 1500000000))
+	}
+	if ts := ms[1].DataPoints[0].UnixTimestamp(); ts != now.Unix() {
+		t.Error(fmt.Errorf("%d != %d", ts, now.Unix()))
+	}
+
+	lines := []int{3, 4, 5}
+	if len(errs) != len(lines) {
+		t.Fatal(fmt.Errorf("%d != %d", len(errs), len(lines)))
+	}
+	for n, line := range lines {
+		if errs[n].Line != line {
+			t.Error(fmt.Errorf("%d != %d", errs[n].Line, line))
+		}
+	}
+
+	_, _, err = ParseIngestJSON([]byte("[{"), now)
+	if err == nil {
+		t.Error(fmt.Errorf("invalid JSON is parsed"))
+	}
+}
+
+func TestParseIngestPlainText(t *testing.T) {
+	text := "a.b 1 1500000000\r\n\nbad line\na.c 2 1500000000\na.d x 1500000000\n"
+	ms, errs := ParseIngestPlainText(text)
+	if len(ms) != 2 {
+		t.Error(fmt.Errorf("%d != %d", len(ms), 2))
+	}
+	lines := []int{3, 5}
+	if len(errs) != len(lines) {
+		t.Fatal(fmt.Errorf("%d != %d", len(errs), len(lines)))
+	}
+	for n, line := range lines {
+		if errs[n].Line != line {
+			t.Error(fmt.Errorf("%d != %d", errs[n].Line, line))
+		}
+	}
+}
+
+func TestRenderIngestRequest(t *testing.T) {
+	listener := &testIngestListener{}
+	server := NewServer()
+	server.SetCarbonListener(listener)
+	server.SetMetricIndex(NewMetricIndex())
+
+	gzipBody := func(s string) *bytes.Buffer {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		gz.Write([]byte(s))
+		gz.Close()
+		return &buf
+	}
+
+	testCases := []struct {
+		body     *bytes.Buffer
+		gzip     bool
+		status   int
+		accepted int
+		failed   int
+	}{
+		{bytes.NewBufferString(`[{"name":"json.a","value":1,"tags":{"host":"web01"}}]`), false, http.StatusOK, 1, 0},
+		{bytes.NewBufferString("plain.a 1 1500000000\nplain.b 2 1500000000\n"), false, http.StatusOK, 2, 0},
+		{gzipBody("gzip.a 1 1500000000\nbad\n"), true, http.StatusBadRequest, 1, 1},
+		{bytes.NewBufferString(`[{"name":"json.b"}]`), false, http.StatusBadRequest, 0, 1},
+	}
+
+	total := 0
+	for _, tc := range testCases {
+		req := httptest.NewRequest(http.MethodPost, renderDefaultIngestRequestPath, tc.body)
+		if tc.gzip {
+			req.Header.Set(httpHeaderContentEncoding, httpContentEncodingGzip)
+		}
+		res := httptest.NewRecorder()
+		server.ServeHTTP(res, req)
+		if res.Code != tc.status {
+			t.Error(fmt.Errorf("%d != %d", res.Code, tc.status))
+		}
+		ingestRes := &IngestResponse{}
+		err := json.Unmarshal(res.Body.Bytes(), ingestRes)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if ingestRes.Accepted != tc.accepted || ingestRes.Failed != tc.failed {
+			t.Error(fmt.Errorf("%d/%d != %d/%d", ingestRes.Accepted, ingestRes.Failed, tc.accepted, tc.failed))
+		}
+		total += tc.accepted
+	}
+
+	if len(listener.ms) != total {
+		t.Error(fmt.Errorf("%d != %d", len(listener.ms), total))
+	}
+	if !server.GetMetricIndex().Has("json.a;host=web01") {
+		t.Error(fmt.Errorf("json.a;host=web01 isn't indexed"))
+	}
+
+	// The other methods are passed to the extra listeners.
+	req := httptest.NewRequest(http.MethodGet, renderDefaultIngestRequestPath, strings.NewReader(""))
+	res := httptest.NewRecorder()
+	server.ServeHTTP(res, req)
+	if res.Code != http.StatusNotFound {
+		t.Error(fmt.Errorf("%d != %d", res.Code, http.StatusNotFound))
+	}
+}
+
+func TestRenderIngestRequestLimit(t *testing.T) {
+	listener := &testIngestListener{}
+	render := NewRender()
+	render.SetIngestListener(listener)
+	render.SetMaxIngestSize(1024)
+
+	line := "limit.a 1 1500000000\n"
+	small := strings.Repeat(line, 1024/len(line))
+	large := strings.Repeat(line, 1024)
+
+	var bomb bytes.Buffer
+	gz := gzip.NewWriter(&bomb)
+	gz.Write([]byte(large))
+	gz.Close()
+	if 1024 <= bomb.Len() {
+		t.Fatal(fmt.Errorf("%d", bomb.Len()))
+	}
+
+	testCases := []struct {
+		body   string
+		gzip   bool
+		status int
+	}{
+		{small, false, http.StatusOK},
+		{large, false, http.StatusRequestEntityTooLarge},
+		{bomb.String(), true, http.StatusRequestEntityTooLarge},
+	}
+	for _, tc := range testCases {
+		req := httptest.NewRequest(http.MethodPost, renderDefaultIngestRequestPath, strings.NewReader(tc.body))
+		if tc.gzip {
+			req.Header.Set(httpHeaderContentEncoding, httpContentEncodingGzip)
+		}
+		res := httptest.NewRecorder()
+		render.ServeHTTP(res, req)
+		if res.Code != tc.status {
+			t.Error(fmt.Errorf("%d != %d", res.Code, tc.status))
+			continue
+		}
+		if tc.status == http.StatusOK {
+			continue
+		}
+		errRes := RenderErrorResponse{}
+		err := json.Unmarshal(res.Body.Bytes(), &errRes)
+		if err != nil || errRes.Error != RenderErrorTooLarge.String() {
+			t.Error(fmt.Errorf("%s", res.Body.String()))
+		}
+	}
+
+	// The rejected bodies aren't delivered to the listener.
+	if len(listener.ms) != 1024/len(line) {
+		t.Error(fmt.Errorf("%d != %d", len(listener.ms), 1024/len(line)))
+	}
+}
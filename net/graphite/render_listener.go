@@ -4,7 +4,10 @@
 
 package graphite
 
-import "net/http"
+import (
+	"context"
+	"net/http"
+)
 
 // RenderRequestListener represents a listener for Render protocol.
 type RenderRequestListener interface {
@@ -12,11 +15,35 @@ type RenderRequestListener interface {
 	QueryMetricsRequestReceived(*Query, error) ([]*Metrics, error)
 }
 
+// ContextRenderRequestListener represents a listener for Render protocol which also receives the contexts of the requests.
+// The contexts are canceled when the clients go away or the request timeout of Render expires, so the listeners should stop scanning then.
+// The context-aware methods are called instead of the other methods, and the tenant of the query is available using TenantFromContext().
+type ContextRenderRequestListener interface {
+	RenderRequestListener
+	FindMetricsRequestReceivedContext(ctx context.Context, query *Query, err error) ([]*Metrics, error)
+	QueryMetricsRequestReceivedContext(ctx context.Context, query *Query, err error) ([]*Metrics, error)
+}
+
 // RenderHTTPRequestListener represents a listener for HTTP requests.
 type RenderHTTPRequestListener interface {
 	HTTPRequestReceived(r *http.Request, w http.ResponseWriter)
 }
 
+// RenderAuthorizer represents the path rules and the tenancy of Render for the extra HTTP request listeners.
+type RenderAuthorizer interface {
+	GetPathRules() *PathRules
+	GetTenancy() *Tenancy
+}
+
+// RenderAuthorizedHTTPRequestListener represents an extra HTTP request listener which applies the path rules and the tenancy of Render by itself.
+// The authorized method is called instead of HTTPRequestReceived(), and the principal and the tenant of the request are available
+// using PrincipalFromRequest() and TenantFromRequest(). The listeners which write the metrics can apply them using InsertRequestMetrics().
+// Render forbids the other extra listeners while it has the path rules or the tenancy.
+type RenderAuthorizedHTTPRequestListener interface {
+	RenderHTTPRequestListener
+	AuthorizedHTTPRequestReceived(r *http.Request, w http.ResponseWriter, auth RenderAuthorizer)
+}
+
 // RenderListener represents a listener for all requests of Render.
 type RenderListener interface {
 	RenderRequestListener
@@ -5,8 +5,10 @@
 package graphite
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"sort"
 	"strings"
 )
 
@@ -17,89 +19,90 @@ func (render *Render) handleFindRequest(httpWriter http.ResponseWriter, httpReq
 	query := NewQuery()
 	err := query.ParseHTTPRequest(httpReq)
 	if err != nil {
-		render.responseBadRequest(httpWriter, httpReq)
+		render.responseError(httpWriter, httpReq, err, QueryTargetRegexp)
+		return
+	}
+	if len(query.Target) == 0 {
+		render.responseError(httpWriter, httpReq, NewParseError(QueryTargetRegexp, fmt.Errorf(errorMissingParameter, QueryTargetRegexp)), QueryTargetRegexp)
+		return
+	}
+	if query.Format == QueryFormatTypeCompleter { // TODO : Not implemented yet
+		render.responseError(httpWriter, httpReq, NewParseError(QueryFormat, fmt.Errorf(errorUnsupportedFormat, query.Format)), QueryFormat)
+		return
+	}
+
+	idx := render.requestMetricIndex(httpReq)
+	if idx != nil {
+		matches, err := render.findPathMatches(httpReq, idx, query.Target)
+		if err != nil {
+			render.responseError(httpWriter, httpReq, err, QueryTargetRegexp)
+			return
+		}
+		render.responseFindPathMatches(httpWriter, httpReq, matches)
 		return
 	}
 
 	if render.renderListener == nil {
-		render.responseInternalServerError(httpWriter, httpReq)
+		render.responseError(httpWriter, httpReq, NewBackendError(fmt.Errorf(errorRenderListenerNotFound, httpReq.URL.Path)), QueryTargetRegexp)
 		return
 	}
 
-	metrics, err := render.renderListener.FindMetricsRequestReceived(query, nil)
+	metrics, err := render.findMetrics(httpReq, query)
 	if err != nil {
-		render.responseBadRequest(httpWriter, httpReq)
+		render.responseError(httpWriter, httpReq, err, QueryTargetRegexp)
 		return
 	}
 
-	render.responseFindMetrics(httpWriter, httpReq, query, metrics)
+	// The metrics of the listener are leaves which have the full paths like the nodes of the index.
+	matches := make([]*PathMatch, 0, len(metrics))
+	for _, m := range metrics {
+		matches = append(matches, &PathMatch{Path: m.Name, IsLeaf: true, IsBranch: false})
+	}
+	render.responseFindPathMatches(httpWriter, httpReq, matches)
 }
 
-func (render *Render) responseFindMetrics(httpWriter http.ResponseWriter, httpReq *http.Request, query *Query, metrics []*Metrics) {
-	switch query.Format {
-	case QueryFormatTypeCompleter: // TODO : Not implemented yet
-		render.responseBadRequest(httpWriter, httpReq)
-		return
-	case QueryFormatTypeTreeJSON:
-		render.responseFindJSONMetrics(httpWriter, httpReq, query, metrics)
-		return
-	default:
-		render.responseFindJSONMetrics(httpWriter, httpReq, query, metrics)
-		return
+func (render *Render) responseFindPathMatches(httpWriter http.ResponseWriter, httpReq *http.Request, matches []*PathMatch) {
+	res := renderFindMetricJSONResponse{
+		Metrics: make([]renderFindMetricsJSONMetrics, 0, len(matches)),
+	}
+	for _, match := range matches {
+		isLeaf := 0
+		if match.IsLeaf {
+			isLeaf = 1
+		}
+		name := match.Path
+		if idx := strings.LastIndex(name, renderMetricsDelim); 0 <= idx {
+			name = name[idx+1:]
+		}
+		res.Metrics = append(res.Metrics, renderFindMetricsJSONMetrics{
+			IsLeaf: isLeaf,
+			Name:   name,
+			Path:   match.Path,
+		})
 	}
-}
 
-func (render *Render) responseFindJSONMetrics(httpWriter http.ResponseWriter, httpReq *http.Request, query *Query, metrics []*Metrics) {
 	httpWriter.Header().Set(httpHeaderContentType, QueryContentTypeJSON)
 	httpWriter.Header().Set(httpHeaderAccessControlAllowOrigin, httpHeaderAccessControlAllowOriginAll)
 	httpWriter.WriteHeader(http.StatusOK)
 
-	httpWriter.Write([]byte("{\"metrics\": [\n"))
-
-	mCount := len(metrics)
-
-	for i, m := range metrics {
-		paths := strings.Split(m.Name, renderMetricsDelim)
-		pathCount := len(paths)
-		if pathCount <= 0 {
-			continue
-		}
-
-		// Start bracket
-		httpWriter.Write([]byte("{\n"))
-
-		// Leaf information (static)
-		httpWriter.Write([]byte("\"is_leaf\": 1,\n"))
-
-		// Name and path
-
-		name := paths[pathCount-1]
-		prefix := ""
-		if 1 < pathCount {
-			prefixes := paths[:pathCount-1]
-			prefix = strings.Join(prefixes, renderMetricsDelim)
-		}
-
-		fmt.Fprintf(httpWriter, "\"name\": \"%s\",\n", name)
-		fmt.Fprintf(httpWriter, "\"path\": \"%s\"\n", prefix)
-
-		// End bracket
-		if i < (mCount - 1) {
-			httpWriter.Write([]byte("},\n"))
-		} else {
-			httpWriter.Write([]byte("}\n"))
-		}
-	}
-
-	httpWriter.Write([]byte("]}\n"))
+	json.NewEncoder(httpWriter).Encode(res)
 }
 
 // handleIndexRequest handles requests for Metrics API.
 // The Render URL API
 // http://readthedocs.io/en/latest/render_api.html
 func (render *Render) handleIndexRequest(httpWriter http.ResponseWriter, httpReq *http.Request) {
+	idx := render.requestMetricIndex(httpReq)
+	if idx != nil {
+		httpWriter.Header().Set(httpHeaderContentType, QueryContentTypeJSON)
+		httpWriter.Header().Set(httpHeaderAccessControlAllowOrigin, httpHeaderAccessControlAllowOriginAll)
+		httpWriter.WriteHeader(http.StatusOK)
+		json.NewEncoder(httpWriter).Encode(renderMetricIndexJSONResponse(render.indexNames(httpReq, idx)))
+		return
+	}
+
 	if render.renderListener == nil {
-		render.responseInternalServerError(httpWriter, httpReq)
+		render.responseError(httpWriter, httpReq, NewBackendError(fmt.Errorf(errorRenderListenerNotFound, httpReq.URL.Path)), "")
 		return
 	}
 
@@ -107,28 +110,105 @@ func (render *Render) handleIndexRequest(httpWriter http.ResponseWriter, httpReq
 
 	query := NewQuery()
 	query.Target = renderMetricsAsterisk
-	metrics, err := render.renderListener.FindMetricsRequestReceived(query, nil)
+	metrics, err := render.findMetrics(httpReq, query)
 	if err != nil {
-		render.responseBadRequest(httpWriter, httpReq)
+		render.responseError(httpWriter, httpReq, err, "")
 		return
 	}
 
 	// Response by JSON array
 
+	names := make([]string, 0, len(metrics))
+	for _, m := range metrics {
+		names = append(names, m.Name)
+	}
+
+	httpWriter.Header().Set(httpHeaderContentType, QueryContentTypeJSON)
+	httpWriter.Header().Set(httpHeaderAccessControlAllowOrigin, httpHeaderAccessControlAllowOriginAll)
+	httpWriter.WriteHeader(http.StatusOK)
+	json.NewEncoder(httpWriter).Encode(renderMetricIndexJSONResponse(names))
+}
+
+// handleExpandRequest handles expand requests of Metrics API such as '/metrics/expand?query=a.*&leavesOnly=1'.
+// The Metrics API
+// https://graphite-api.readthedocs.io/en/latest/api.html#metrics-expand
+func (render *Render) handleExpandRequest(httpWriter http.ResponseWriter, httpReq *http.Request) {
+	params := httpReq.URL.Query()
+	targets := params[QueryTargetRegexp]
+	if len(targets) == 0 {
+		render.responseError(httpWriter, httpReq, NewParseError(QueryTargetRegexp, fmt.Errorf(errorMissingParameter, QueryTargetRegexp)), QueryTargetRegexp)
+		return
+	}
+	leavesOnly := params.Get(renderExpandLeavesOnly) == "1"
+
+	if render.requestMetricIndex(httpReq) == nil && render.renderListener == nil {
+		render.responseError(httpWriter, httpReq, NewBackendError(fmt.Errorf(errorRenderListenerNotFound, httpReq.URL.Path)), QueryTargetRegexp)
+		return
+	}
+
+	groups := map[string][]string{}
+	for _, target := range targets {
+		paths, err := render.expandPaths(httpReq, target, leavesOnly)
+		if err != nil {
+			render.responseError(httpWriter, httpReq, err, QueryTargetRegexp)
+			return
+		}
+		groups[target] = paths
+	}
+
 	httpWriter.Header().Set(httpHeaderContentType, QueryContentTypeJSON)
 	httpWriter.Header().Set(httpHeaderAccessControlAllowOrigin, httpHeaderAccessControlAllowOriginAll)
 	httpWriter.WriteHeader(http.StatusOK)
 
-	httpWriter.Write([]byte("[\n"))
+	if params.Get(renderExpandGroupByExpr) == "1" {
+		json.NewEncoder(httpWriter).Encode(map[string]any{"results": groups})
+		return
+	}
 
-	mCount := len(metrics)
-	for i, m := range metrics {
-		if i < (mCount - 1) {
-			fmt.Fprintf(httpWriter, "\"%s\",\n", m.Name)
-		} else {
-			fmt.Fprintf(httpWriter, "\"%s\"\n", m.Name)
+	found := map[string]bool{}
+	results := []string{}
+	for _, paths := range groups {
+		for _, path := range paths {
+			if found[path] {
+				continue
+			}
+			found[path] = true
+			results = append(results, path)
 		}
 	}
+	sort.Strings(results)
+	json.NewEncoder(httpWriter).Encode(map[string]any{"results": results})
+}
+
+// expandPaths returns the paths which match the specified target and the principal of the request can see.
+func (render *Render) expandPaths(httpReq *http.Request, target string, leavesOnly bool) ([]string, error) {
+	paths := []string{}
+
+	idx := render.requestMetricIndex(httpReq)
+	if idx != nil {
+		matches, err := render.findPathMatches(httpReq, idx, target)
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			if leavesOnly && !match.IsLeaf {
+				continue
+			}
+			paths = append(paths, match.Path)
+		}
+		return paths, nil
+	}
+
+	query := NewQuery()
+	query.Target = target
+	metrics, err := render.findMetrics(httpReq, query)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range metrics {
+		paths = append(paths, m.Name)
+	}
+	sort.Strings(paths)
 
-	httpWriter.Write([]byte("]\n"))
+	return paths, nil
 }
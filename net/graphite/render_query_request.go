@@ -18,18 +18,22 @@ func (render *Render) handleRenderRequest(httpWriter http.ResponseWriter, httpRe
 	query := NewQuery()
 	err := query.ParseHTTPRequest(httpReq)
 	if err != nil {
-		render.responseBadRequest(httpWriter, httpReq)
+		render.responseError(httpWriter, httpReq, err, QueryTarget)
+		return
+	}
+	if len(query.Target) == 0 {
+		render.responseError(httpWriter, httpReq, NewParseError(QueryTarget, fmt.Errorf(errorMissingParameter, QueryTarget)), QueryTarget)
 		return
 	}
 
 	if render.renderListener == nil {
-		render.responseInternalServerError(httpWriter, httpReq)
+		render.responseError(httpWriter, httpReq, NewBackendError(fmt.Errorf(errorRenderListenerNotFound, httpReq.URL.Path)), QueryTarget)
 		return
 	}
 
-	metrics, err := render.renderListener.QueryMetricsRequestReceived(query, nil)
+	metrics, err := render.queryMetrics(httpReq, query)
 	if err != nil {
-		render.responseBadRequest(httpWriter, httpReq)
+		render.responseError(httpWriter, httpReq, err, QueryTarget)
 		return
 	}
 
@@ -49,7 +53,7 @@ func (render *Render) responseQueryMetrics(httpWriter http.ResponseWriter, httpR
 		return
 	}
 
-	render.responseBadRequest(httpWriter, httpReq)
+	render.responseError(httpWriter, httpReq, NewParseError(QueryFormat, fmt.Errorf(errorUnsupportedFormat, query.Format)), QueryFormat)
 }
 
 func (render *Render) responseQueryRawMetrics(httpWriter http.ResponseWriter, httpReq *http.Request, query *Query, metrics []*Metrics) {
@@ -0,0 +1,193 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package graphite
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// requestTenant returns the tenant of the specified request, and the empty tenant without the tenancy.
+func (render *Render) requestTenant(httpReq *http.Request) string {
+	if render.tenancy == nil {
+		return ""
+	}
+	tenant, _ := TenantFromRequest(httpReq)
+	return tenant
+}
+
+// isTenantIsolated returns true when the tenant of the specified request is isolated by the listeners.
+func (render *Render) isTenantIsolated(httpReq *http.Request) bool {
+	return 0 < len(render.requestTenant(httpReq)) && render.tenancy.GetMode() == TenantIsolate
+}
+
+// requestMetricIndex returns the index to serve the specified request, and nil for the isolated tenants which don't share the index.
+func (render *Render) requestMetricIndex(httpReq *http.Request) *MetricIndex {
+	if render.isTenantIsolated(httpReq) {
+		return nil
+	}
+	return render.metricIndex
+}
+
+// tenantTarget returns the target of the tenant of the specified request for the index and the listener.
+func (render *Render) tenantTarget(httpReq *http.Request, target string) (string, error) {
+	tenant := render.requestTenant(httpReq)
+	if len(tenant) == 0 {
+		return target, nil
+	}
+	target, err := render.tenancy.TenantTarget(tenant, target)
+	if err != nil {
+		return "", NewParseError("", err)
+	}
+	return target, nil
+}
+
+// tenantQuery returns a copy of the specified query whose target is of the tenant of the request.
+func (render *Render) tenantQuery(httpReq *http.Request, query *Query) (*Query, error) {
+	target, err := render.tenantTarget(httpReq, query.Target)
+	if err != nil {
+		return nil, err
+	}
+	tenantQuery := *query
+	tenantQuery.Target = target
+	return &tenantQuery, nil
+}
+
+// stripTenantMetrics returns the metrics of the tenant of the specified request whose names are for the client.
+func (render *Render) stripTenantMetrics(httpReq *http.Request, metrics []*Metrics) []*Metrics {
+	tenant := render.requestTenant(httpReq)
+	if len(tenant) == 0 {
+		return metrics
+	}
+	return render.tenancy.StripTenantMetrics(tenant, metrics)
+}
+
+// findPathMatches returns the nodes of the specified index which match the target, and the client of the request can see.
+func (render *Render) findPathMatches(httpReq *http.Request, idx *MetricIndex, target string) ([]*PathMatch, error) {
+	target, err := render.tenantTarget(httpReq, target)
+	if err != nil {
+		return nil, err
+	}
+	matches, err := idx.Find(target)
+	if err != nil {
+		return nil, NewParseError("", err)
+	}
+
+	tenant := render.requestTenant(httpReq)
+	if 0 < len(tenant) {
+		stripped := make([]*PathMatch, 0, len(matches))
+		for _, match := range matches {
+			path, ok := render.tenancy.StripTenantName(tenant, match.Path)
+			if !ok || len(path) == 0 {
+				continue
+			}
+			stripped = append(stripped, &PathMatch{Path: path, IsLeaf: match.IsLeaf, IsBranch: match.IsBranch})
+		}
+		matches = stripped
+	}
+
+	return render.filterPathMatches(httpReq, matches), nil
+}
+
+// indexNames returns all names of the specified index which the client of the request can see.
+func (render *Render) indexNames(httpReq *http.Request, idx *MetricIndex) []string {
+	tenant := render.requestTenant(httpReq)
+	names := []string{}
+	for _, name := range idx.Snapshot() {
+		if 0 < len(tenant) {
+			var ok bool
+			name, ok = render.tenancy.StripTenantName(tenant, name)
+			if !ok {
+				continue
+			}
+		}
+		if render.isPathAllowed(httpReq, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// findMetrics calls the listener to find the metrics of the specified query, and returns the metrics which the client of the request can see.
+func (render *Render) findMetrics(httpReq *http.Request, query *Query) ([]*Metrics, error) {
+	return render.listenMetrics(httpReq, query, true)
+}
+
+// queryMetrics calls the listener to query the metrics of the specified query, and returns the metrics which the client of the request can see.
+// The metrics which have more series than the limit are rejected.
+func (render *Render) queryMetrics(httpReq *http.Request, query *Query) ([]*Metrics, error) {
+	metrics, err := render.listenMetrics(httpReq, query, false)
+	if err != nil {
+		return nil, err
+	}
+	if 0 < render.maxSeries && render.maxSeries < len(metrics) {
+		return nil, NewTooManySeriesError(len(metrics), render.maxSeries)
+	}
+	return metrics, nil
+}
+
+// listenMetrics calls the listener with the query of the tenant of the request, and returns the metrics which the client of the request can see.
+func (render *Render) listenMetrics(httpReq *http.Request, query *Query, find bool) ([]*Metrics, error) {
+	if render.isTenantIsolated(httpReq) && !isTenantRenderListener(render.renderListener) {
+		return nil, fmt.Errorf(errorTenantListenerRequired, render.requestTenant(httpReq))
+	}
+
+	tenantQuery, err := render.tenantQuery(httpReq, query)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics, err := render.callRenderListener(httpReq, tenantQuery, find)
+	if err != nil {
+		return nil, err
+	}
+
+	return render.filterMetrics(httpReq, render.stripTenantMetrics(httpReq, metrics)), nil
+}
+
+// insertMetrics delivers the specified posted metrics to the ingest listener with the tenant and the context of the request,
+// and the principal of the request can write only the metrics which the path rules allow.
+func (render *Render) insertMetrics(httpReq *http.Request, ms []*Metrics) error {
+	return InsertRequestMetrics(httpReq, render, render.ingestListener, ms)
+}
+
+// InsertRequestMetrics delivers the metrics written by the specified request to the listener with the tenant and the context of the request,
+// so the extra HTTP request listeners which write the metrics apply the path rules and the tenancy of Render like POST /metrics.
+// No metrics are delivered when the principal of the request can't write one of them, and the nil authorizer delivers them as they are.
+func InsertRequestMetrics(httpReq *http.Request, auth RenderAuthorizer, listener CarbonListener, ms []*Metrics) error {
+	if auth == nil {
+		insertListenerMetrics(httpReq.Context(), listener, "", ms)
+		return nil
+	}
+
+	rules := auth.GetPathRules()
+	if rules != nil {
+		principal, _ := PrincipalFromRequest(httpReq)
+		for _, m := range ms {
+			if m != nil && !rules.IsAllowed(principal, m.Name) {
+				return NewForbiddenError(fmt.Errorf(errorForbiddenPath, m.Name))
+			}
+		}
+	}
+
+	tenancy := auth.GetTenancy()
+	tenant := ""
+	if tenancy != nil {
+		tenant, _ = TenantFromRequest(httpReq)
+	}
+	if len(tenant) == 0 {
+		insertListenerMetrics(httpReq.Context(), listener, "", ms)
+		return nil
+	}
+	if tenancy.GetMode() == TenantIsolate && !isTenantCarbonListener(listener) {
+		return fmt.Errorf(errorTenantListenerRequired, tenant)
+	}
+	insertListenerMetrics(httpReq.Context(), listener, tenant, tenancy.TenantMetrics(tenant, ms))
+	return nil
+}
+
+func (render *Render) responseForbidden(httpWriter http.ResponseWriter, httpReq *http.Request, err error) {
+	render.responseError(httpWriter, httpReq, NewForbiddenError(err), "")
+}
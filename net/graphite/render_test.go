@@ -5,7 +5,10 @@
 package graphite
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 )
 
@@ -97,3 +100,67 @@ func TestRenderHTTPListener(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+type testFindRender struct {
+	names []string
+}
+
+func (l *testFindRender) FindMetricsRequestReceived(query *Query, err error) ([]*Metrics, error) {
+	ms := []*Metrics{}
+	for _, name := range l.names {
+		m := NewMetrics()
+		m.SetName(name)
+		ms = append(ms, m)
+	}
+	return ms, nil
+}
+
+func (l *testFindRender) QueryMetricsRequestReceived(query *Query, err error) ([]*Metrics, error) {
+	return nil, nil
+}
+
+func TestRenderFindResponse(t *testing.T) {
+	listener := &testFindRender{names: []string{"a.b.c"}}
+	idx := NewMetricIndex()
+	for _, name := range []string{"a.b.c", "a.\"quoted\""} {
+		idx.Insert(name)
+	}
+
+	// The listener and the index respond the same shape which has the full paths.
+	listenerRender := NewRender()
+	listenerRender.SetRenderListener(listener)
+	indexRender := NewRender()
+	indexRender.SetMetricIndex(idx)
+
+	for _, render := range []*Render{listenerRender, indexRender} {
+		req := httptest.NewRequest(http.MethodGet, renderDefaultFindRequestPath+"?query=a.*.c", nil)
+		res := httptest.NewRecorder()
+		render.ServeHTTP(res, req)
+		find := renderFindMetricJSONResponse{}
+		err := json.Unmarshal(res.Body.Bytes(), &find)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if len(find.Metrics) != 1 || find.Metrics[0].Path != "a.b.c" || find.Metrics[0].Name != "c" || find.Metrics[0].IsLeaf != 1 {
+			t.Error(fmt.Errorf("%v", find.Metrics))
+		}
+
+		req = httptest.NewRequest(http.MethodGet, renderDefaultFindRequestPath+"?query=a.*&format="+QueryFormatTypeCompleter, nil)
+		res = httptest.NewRecorder()
+		render.ServeHTTP(res, req)
+		if res.Code != http.StatusBadRequest {
+			t.Error(fmt.Errorf("%d != %d", res.Code, http.StatusBadRequest))
+		}
+	}
+
+	listener.names = []string{"a.\"quoted\""}
+	req := httptest.NewRequest(http.MethodGet, renderDefaultFindRequestPath+"?query=a.*", nil)
+	res := httptest.NewRecorder()
+	listenerRender.ServeHTTP(res, req)
+	find := renderFindMetricJSONResponse{}
+	err := json.Unmarshal(res.Body.Bytes(), &find)
+	if err != nil || len(find.Metrics) != 1 || find.Metrics[0].Path != listener.names[0] {
+		t.Error(fmt.Errorf("%s", res.Body.String()))
+	}
+}
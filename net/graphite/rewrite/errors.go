@@ -0,0 +1,11 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rewrite
+
+const (
+	errorInvalidRuleLine    = "invalid rewrite rule (%d) : %s"
+	errorInvalidRulePattern = "invalid rewrite rule pattern (%d) : %s"
+	errorInvalidSection     = "invalid rewrite rule section (%d) : %s"
+)
@@ -0,0 +1,104 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package rewrite provides the metric name rewriting of rewrite-rules.conf of carbon.
+package rewrite
+
+import (
+	"sync"
+
+	"github.com/cybergarage/go-graphite/net/graphite"
+)
+
+// RuleStats represents the statistics of a rule.
+type RuleStats struct {
+	Rule string
+	Hits int64
+}
+
+// Rewriter rewrites the metric names with the rules in order.
+// Rewriter is a graphite.CarbonProcessor for the [pre] rules in the Carbon ingest path,
+// and is also a graphite.CarbonListener which passes the rewritten metrics to the next listener for the [post] rules such as the output of the aggregator.
+type Rewriter struct {
+	sync.RWMutex
+	rules    []*Rule
+	listener graphite.CarbonListener
+}
+
+// NewRewriter returns a new rewriter with the specified rules.
+func NewRewriter(rules []*Rule) *Rewriter {
+	return &Rewriter{
+		RWMutex:  sync.RWMutex{},
+		rules:    rules,
+		listener: nil,
+	}
+}
+
+// SetRules replaces the rules.
+func (rewriter *Rewriter) SetRules(rules []*Rule) {
+	rewriter.Lock()
+	defer rewriter.Unlock()
+	rewriter.rules = rules
+}
+
+// GetRules returns the rules.
+func (rewriter *Rewriter) GetRules() []*Rule {
+	rewriter.RLock()
+	defer rewriter.RUnlock()
+	return rewriter.rules
+}
+
+// SetCarbonListener sets the next listener of the rewritten metrics.
+func (rewriter *Rewriter) SetCarbonListener(listener graphite.CarbonListener) {
+	rewriter.Lock()
+	defer rewriter.Unlock()
+	rewriter.listener = listener
+}
+
+// Rewrite returns the name which is rewritten by all rules in order.
+func (rewriter *Rewriter) Rewrite(name string) string {
+	for _, rule := range rewriter.GetRules() {
+		name, _ = rule.Apply(name)
+	}
+	return name
+}
+
+// Stats returns the hit counters of the rules.
+func (rewriter *Rewriter) Stats() []*RuleStats {
+	stats := []*RuleStats{}
+	for _, rule := range rewriter.GetRules() {
+		stats = append(stats, &RuleStats{
+			Rule: rule.String(),
+			Hits: rule.Hits(),
+		})
+	}
+	return stats
+}
+
+// ProcessMetrics rewrites the names of the specified metrics, and drops the metrics whose names are rewritten into empty.
+func (rewriter *Rewriter) ProcessMetrics(ms []*graphite.Metrics) []*graphite.Metrics {
+	rewritten := make([]*graphite.Metrics, 0, len(ms))
+	for _, m := range ms {
+		if m == nil {
+			continue
+		}
+		m.SetName(rewriter.Rewrite(m.Name))
+		if len(m.Name) == 0 {
+			continue
+		}
+		rewritten = append(rewritten, m)
+	}
+	return rewritten
+}
+
+// InsertMetricsRequestReceived rewrites the names of the specified metrics, and passes them to the next listener.
+func (rewriter *Rewriter) InsertMetricsRequestReceived(ms []*graphite.Metrics, err error) {
+	rewriter.RLock()
+	listener := rewriter.listener
+	rewriter.RUnlock()
+	if listener == nil {
+		return
+	}
+	listener.InsertMetricsRequestReceived(rewriter.ProcessMetrics(ms), err)
+}
@@ -0,0 +1,101 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rewrite
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cybergarage/go-graphite/net/graphite"
+	"github.com/cybergarage/go-graphite/net/graphite/memory"
+)
+
+const testRewriteRules = `
+[pre]
+^legacy\.prefix\.(.*)$ = servers.\1
+^servers\.(\w+)_example_com\. = servers.\g<1>.
+^tmp\..*$ =
+
+[post]
+_sum$ =
+`
+
+func TestParseConfig(t *testing.T) {
+	conf, err := ParseConfig(strings.NewReader(testRewriteRules))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conf.Pre) != 3 || len(conf.Post) != 1 {
+		t.Fatal(fmt.Errorf("%d %d", len(conf.Pre), len(conf.Post)))
+	}
+
+	rewriter := NewRewriter(conf.Pre)
+	testCases := []struct {
+		name     string
+		expected string
+	}{
+		{"legacy.prefix.host1.cpu", "servers.host1.cpu"},
+		{"legacy.prefix.www01_example_com.cpu", "servers.www01.cpu"},
+		{"servers.db01.mem", "servers.db01.mem"},
+		{"tmp.debug.value", ""},
+	}
+	for _, tc := range testCases {
+		name := rewriter.Rewrite(tc.name)
+		if name != tc.expected {
+			t.Error(fmt.Errorf("%s : %s != %s", tc.name, name, tc.expected))
+		}
+	}
+
+	expectedHits := []int64{2, 1, 1}
+	for n, stats := range rewriter.Stats() {
+		if stats.Hits != expectedHits[n] {
+			t.Error(fmt.Errorf("%s : %d != %d", stats.Rule, stats.Hits, expectedHits[n]))
+		}
+	}
+
+	if NewRewriter(conf.Post).Rewrite("servers.all.requests_sum") != "servers.all.requests" {
+		t.Error(fmt.Errorf("post rule is not applied"))
+	}
+
+	badConfs := []string{
+		"^a = b\n",
+		"[other]\n^a = b\n",
+		"[pre]\n^a(\n",
+		"[pre]\n^a( = b\n",
+	}
+	for _, conf := range badConfs {
+		_, err := ParseConfig(strings.NewReader(conf))
+		if err == nil {
+			t.Error(fmt.Errorf("%s is parsed", conf))
+		}
+	}
+}
+
+func TestRewriterInCarbon(t *testing.T) {
+	conf, err := ParseConfig(strings.NewReader(testRewriteRules))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := memory.NewStore()
+	server := graphite.NewServer()
+	server.SetStore(store)
+	server.AddCarbonProcessor(NewRewriter(conf.Pre))
+
+	now := time.Now().Unix()
+	ms, err := server.FeedPlainTextString(fmt.Sprintf("legacy.prefix.www01_example_com.cpu 1 %d\ntmp.debug 1 %d\n", now, now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ms) != 1 || ms[0].Name != "servers.www01.cpu" {
+		t.Error(fmt.Errorf("%v", ms))
+	}
+	names := store.GetMetricIndex().Snapshot()
+	if len(names) != 1 || names[0] != "servers.www01.cpu" {
+		t.Error(fmt.Errorf("%v", names))
+	}
+}
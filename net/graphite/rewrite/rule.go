@@ -0,0 +1,149 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rewrite
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	// PreSection is the section of the rules which run before routing and aggregation.
+	PreSection = "pre"
+	// PostSection is the section of the rules which run after aggregation.
+	PostSection = "post"
+	ruleSep     = "="
+)
+
+// pythonGroupRegex is the group references of the Python replacement strings such as '\1', '\g<1>' and '\g<name>'.
+var pythonGroupRegex = regexp.MustCompile(`\\(\d+)|\\g<(\w+)>`)
+
+// Rule represents a rewrite rule such as '^xyz\.(.*)$ = abc.\1'.
+type Rule struct {
+	Pattern     *regexp.Regexp
+	Replacement string
+	expand      string
+	hits        atomic.Int64
+}
+
+// NewRule returns a new rule of the specified pattern and Python style replacement.
+func NewRule(pattern string, replacement string) (*Rule, error) {
+	regex, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	rule := &Rule{
+		Pattern:     regex,
+		Replacement: replacement,
+		expand:      toExpandTemplate(replacement),
+		hits:        atomic.Int64{},
+	}
+	return rule, nil
+}
+
+// toExpandTemplate converts the Python style replacement into the template of regexp.Regexp.Expand.
+func toExpandTemplate(replacement string) string {
+	escaped := strings.ReplaceAll(replacement, "$", "$$")
+	return pythonGroupRegex.ReplaceAllStringFunc(escaped, func(ref string) string {
+		match := pythonGroupRegex.FindStringSubmatch(ref)
+		if 0 < len(match[1]) {
+			return "${" + match[1] + "}"
+		}
+		return "${" + match[2] + "}"
+	})
+}
+
+// Apply returns the rewritten name, and returns false when the rule doesn't match the specified name.
+func (rule *Rule) Apply(name string) (string, bool) {
+	if !rule.Pattern.MatchString(name) {
+		return name, false
+	}
+	rule.hits.Add(1)
+	return rule.Pattern.ReplaceAllString(name, rule.expand), true
+}
+
+// Hits returns the number of the metrics which the rule matched.
+func (rule *Rule) Hits() int64 {
+	return rule.hits.Load()
+}
+
+// String returns the rule definition.
+func (rule *Rule) String() string {
+	return rule.Pattern.String() + " " + ruleSep + " " + rule.Replacement
+}
+
+// Config represents the rules of rewrite-rules.conf.
+type Config struct {
+	Pre  []*Rule
+	Post []*Rule
+}
+
+// LoadConfig parses the specified rewrite-rules.conf.
+func LoadConfig(path string) (*Config, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ParseConfig(file)
+}
+
+// ParseConfig parses the [pre] and [post] rules of rewrite-rules.conf in the order of appearance.
+func ParseConfig(reader io.Reader) (*Config, error) {
+	conf := &Config{
+		Pre:  []*Rule{},
+		Post: []*Rule{},
+	}
+
+	var rules *[]*Rule
+	scanner := bufio.NewScanner(reader)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			switch strings.TrimSpace(line[1 : len(line)-1]) {
+			case PreSection:
+				rules = &conf.Pre
+			case PostSection:
+				rules = &conf.Post
+			default:
+				return nil, fmt.Errorf(errorInvalidSection, lineNo, line)
+			}
+			continue
+		}
+
+		if rules == nil {
+			return nil, fmt.Errorf(errorInvalidRuleLine, lineNo, line)
+		}
+
+		// The patterns can't have the separator like carbon, and the replacements can.
+		pattern, replacement, ok := strings.Cut(line, ruleSep)
+		if !ok {
+			return nil, fmt.Errorf(errorInvalidRuleLine, lineNo, line)
+		}
+		rule, err := NewRule(strings.TrimSpace(pattern), strings.TrimSpace(replacement))
+		if err != nil {
+			return nil, fmt.Errorf(errorInvalidRulePattern, lineNo, line)
+		}
+		*rules = append(*rules, rule)
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return conf, nil
+}
@@ -5,13 +5,18 @@
 package graphite
 
 import (
+	"log"
 	"net"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 )
 
 // Server is an instance for Graphite protocols.
 type Server struct {
 	boundInterface *net.Interface
+	reloadSignals  chan os.Signal
 	*Carbon
 	*Render
 }
@@ -20,9 +25,12 @@ type Server struct {
 func NewServer() *Server {
 	server := &Server{
 		boundInterface: nil,
+		reloadSignals:  nil,
 		Carbon:         NewCarbon(),
 		Render:         NewRender(),
 	}
+	// The posted metrics of Render go through the same ingest path as Carbon.
+	server.Render.SetIngestListener(server.Carbon.GetFeedListener())
 	return server
 }
 
@@ -32,6 +40,43 @@ func (server *Server) SetConfig(conf *Config) {
 	server.SetRenderPort(conf.GetRenderPort())
 	server.SetConnectionTimeout(conf.GetConnectionTimeout())
 	server.SetConnectionWaitTimeout(conf.GetConnectionWaitTimeout())
+	server.SetRequestTimeout(conf.GetRequestTimeout())
+	server.SetMaxSeries(conf.GetMaxSeries())
+	server.SetMaxIngestSize(conf.GetMaxIngestSize())
+	server.SetTLSConfig(conf.GetTLSConfig())
+}
+
+// SetTLSConfig sets a TLS configuration to Carbon and Render.
+func (server *Server) SetTLSConfig(conf *TLSConfig) {
+	server.Carbon.SetTLSConfig(conf)
+	server.Render.SetTLSConfig(conf)
+}
+
+// SetTenancy sets a tenancy to Carbon and Render.
+func (server *Server) SetTenancy(tenancy *Tenancy) {
+	server.Carbon.SetTenancy(tenancy)
+	server.Render.SetTenancy(tenancy)
+}
+
+// GetTenancy returns the tenancy of the server.
+func (server *Server) GetTenancy() *Tenancy {
+	return server.Render.GetTenancy()
+}
+
+// GetTLSConfig returns the TLS configuration of the server.
+func (server *Server) GetTLSConfig() *TLSConfig {
+	return server.Render.GetTLSConfig()
+}
+
+// SetMetricIndex sets an index which is fed by Carbon and serves find and index requests of Render.
+func (server *Server) SetMetricIndex(idx *MetricIndex) {
+	server.Carbon.SetMetricIndex(idx)
+	server.Render.SetMetricIndex(idx)
+}
+
+// GetMetricIndex returns the index of the server.
+func (server *Server) GetMetricIndex() *MetricIndex {
+	return server.Render.GetMetricIndex()
 }
 
 // SetBoundInterface sets a bound interface to the server.
@@ -90,6 +135,36 @@ func (server *Server) GetConnectionTimeout() time.Duration {
 	return server.Render.GetConnectionTimeout()
 }
 
+// SetRequestTimeout sets the request timeout for Render .
+func (server *Server) SetRequestTimeout(d time.Duration) {
+	server.Render.SetRequestTimeout(d)
+}
+
+// GetRequestTimeout return the request timeout.
+func (server *Server) GetRequestTimeout() time.Duration {
+	return server.Render.GetRequestTimeout()
+}
+
+// SetMaxSeries sets the maximum number of the series for Render .
+func (server *Server) SetMaxSeries(n int) {
+	server.Render.SetMaxSeries(n)
+}
+
+// GetMaxSeries return the maximum number of the series.
+func (server *Server) GetMaxSeries() int {
+	return server.Render.GetMaxSeries()
+}
+
+// SetMaxIngestSize sets the maximum byte size of the posted metrics for Render.
+func (server *Server) SetMaxIngestSize(n int64) {
+	server.Render.SetMaxIngestSize(n)
+}
+
+// GetMaxIngestSize return the maximum byte size of the posted metrics.
+func (server *Server) GetMaxIngestSize() int64 {
+	return server.Render.GetMaxIngestSize()
+}
+
 // Start starts the server.
 func (server *Server) Start() error {
 	err := server.Carbon.Start()
@@ -131,3 +206,55 @@ func (server *Server) Restart() error {
 
 	return server.Start()
 }
+
+// ReloadTLS reloads the certificate files of the TLS configuration for the new connections of Carbon and Render without restarting them.
+// The server keeps the current certificates when the new files are invalid.
+func (server *Server) ReloadTLS() error {
+	conf := server.GetTLSConfig()
+	if conf.IsEnabled() {
+		_, err := conf.ServerConfig()
+		if err != nil {
+			return err
+		}
+	}
+	err := server.Carbon.ReloadTLS()
+	if err != nil {
+		return err
+	}
+	return server.Render.ReloadTLS()
+}
+
+// StartReloadSignal starts to call ReloadTLS() whenever the process receives SIGHUP.
+// The errors of the invalid certificate files are passed to the specified function, or output with log.Printf when the function is nil.
+func (server *Server) StartReloadSignal(errFunc func(error)) {
+	server.StopReloadSignal()
+
+	if errFunc == nil {
+		errFunc = func(err error) {
+			log.Printf(errorReloadTLS, err)
+		}
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGHUP)
+	server.reloadSignals = sigs
+
+	go func() {
+		for range sigs {
+			err := server.ReloadTLS()
+			if err != nil {
+				errFunc(err)
+			}
+		}
+	}()
+}
+
+// StopReloadSignal stops to reload the server on SIGHUP.
+func (server *Server) StopReloadSignal() {
+	if server.reloadSignals == nil {
+		return
+	}
+	signal.Stop(server.reloadSignals)
+	close(server.reloadSignals)
+	server.reloadSignals = nil
+}
@@ -0,0 +1,230 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cybergarage/go-graphite/net/graphite"
+)
+
+const (
+	statsPrefix       = "stats"
+	statsCountsPrefix = "stats_counts"
+	timersPrefix      = "stats.timers"
+	gaugesPrefix      = "stats.gauges"
+	setsPrefix        = "stats.sets"
+)
+
+// buckets holds the samples of a flush interval.
+type buckets struct {
+	counters      map[string]float64
+	timers        map[string][]float64
+	timerCounters map[string]float64
+	gauges        map[string]float64
+	sets          map[string]map[string]bool
+}
+
+func newBuckets() *buckets {
+	return &buckets{
+		counters:      map[string]float64{},
+		timers:        map[string][]float64{},
+		timerCounters: map[string]float64{},
+		gauges:        map[string]float64{},
+		sets:          map[string]map[string]bool{},
+	}
+}
+
+// add aggregates the specified sample like StatsD.
+func (b *buckets) add(sample *Sample) {
+	key := sample.Key()
+	switch sample.Type {
+	case Counter:
+		b.counters[key] += sample.Value / sample.SampleRate
+	case Timer:
+		b.timers[key] = append(b.timers[key], sample.Value)
+		b.timerCounters[key] += 1 / sample.SampleRate
+	case Gauge:
+		if sample.Delta {
+			b.gauges[key] += sample.Value
+		} else {
+			b.gauges[key] = sample.Value
+		}
+	case Set:
+		set, ok := b.sets[key]
+		if !ok {
+			set = map[string]bool{}
+			b.sets[key] = set
+		}
+		set[sample.SetValue] = true
+	}
+}
+
+// reset clears the values for the next interval. The gauges keep the last values, and the other keys are kept to report zero unless deleteIdle is true.
+func (b *buckets) reset(deleteIdle bool) {
+	if deleteIdle {
+		b.counters = map[string]float64{}
+		b.timers = map[string][]float64{}
+		b.timerCounters = map[string]float64{}
+		b.gauges = map[string]float64{}
+		b.sets = map[string]map[string]bool{}
+		return
+	}
+	for key := range b.counters {
+		b.counters[key] = 0
+	}
+	for key := range b.timers {
+		b.timers[key] = []float64{}
+		b.timerCounters[key] = 0
+	}
+	for key := range b.sets {
+		b.sets[key] = map[string]bool{}
+	}
+}
+
+// metricName returns the Graphite name of the specified bucket key which can have the tags.
+func metricName(prefix string, key string, suffix string) string {
+	name, tags, ok := strings.Cut(key, graphiteTagSep)
+	name = prefix + "." + name
+	if 0 < len(suffix) {
+		name += "." + suffix
+	}
+	if ok {
+		name += graphiteTagSep + tags
+	}
+	return name
+}
+
+// percentileName returns the metric name suffix of the percentile such as '90' and '99_9' like StatsD.
+func percentileName(pct float64) string {
+	name := strconv.FormatFloat(pct, 'f', -1, 64)
+	name = strings.ReplaceAll(name, ".", "_")
+	return strings.ReplaceAll(name, "-", "top")
+}
+
+// timerStats returns the statistics of the timer values like StatsD.
+// The negative percentiles are calculated from the highest values.
+func timerStats(values []float64, count float64, interval time.Duration, percentiles []float64) map[string]float64 {
+	stats := map[string]float64{}
+	seconds := interval.Seconds()
+
+	if len(values) == 0 {
+		stats["count"] = 0
+		stats["count_ps"] = 0
+		return stats
+	}
+
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+
+	// cumulative sums with a leading zero to sum up any range.
+	sums := make([]float64, n+1)
+	squares := make([]float64, n+1)
+	for i, v := range sorted {
+		sums[i+1] = sums[i] + v
+		squares[i+1] = squares[i] + v*v
+	}
+
+	for _, pct := range percentiles {
+		inThreshold := n
+		if 1 < n {
+			inThreshold = int(math.Round(math.Abs(pct) / 100 * float64(n)))
+		}
+		if inThreshold == 0 {
+			continue
+		}
+		name := percentileName(pct)
+		var boundary, sum, sumSquares float64
+		if 0 < pct {
+			boundary = sorted[inThreshold-1]
+			sum = sums[inThreshold]
+			sumSquares = squares[inThreshold]
+			stats["upper_"+name] = boundary
+		} else {
+			boundary = sorted[n-inThreshold]
+			sum = sums[n] - sums[n-inThreshold]
+			sumSquares = squares[n] - squares[n-inThreshold]
+			stats["lower_"+name] = boundary
+		}
+		stats["count_"+name] = float64(inThreshold)
+		stats["mean_"+name] = sum / float64(inThreshold)
+		stats["sum_"+name] = sum
+		stats["sum_squares_"+name] = sumSquares
+	}
+
+	sum := sums[n]
+	mean := sum / float64(n)
+	variance := 0.0
+	for _, v := range sorted {
+		variance += (v - mean) * (v - mean)
+	}
+	median := sorted[n/2]
+	if n%2 == 0 {
+		median = (sorted[n/2-1] + sorted[n/2]) / 2
+	}
+
+	stats["std"] = math.Sqrt(variance / float64(n))
+	stats["upper"] = sorted[n-1]
+	stats["lower"] = sorted[0]
+	stats["count"] = count
+	stats["count_ps"] = count / seconds
+	stats["sum"] = sum
+	stats["sum_squares"] = squares[n]
+	stats["mean"] = mean
+	stats["median"] = median
+
+	return stats
+}
+
+// metrics returns the Graphite metrics of the buckets with the legacy namespace of StatsD.
+func (b *buckets) metrics(now time.Time, interval time.Duration, percentiles []float64) []*graphite.Metrics {
+	ms := []*graphite.Metrics{}
+	add := func(name string, value float64) {
+		m := graphite.NewMetrics()
+		m.SetName(name)
+		dp := graphite.NewDataPoint()
+		dp.SetTimestamp(now)
+		dp.SetValue(value)
+		m.AddDataPoint(dp)
+		ms = append(ms, m)
+	}
+
+	for _, key := range sortedKeys(b.counters) {
+		value := b.counters[key]
+		add(metricName(statsPrefix, key, ""), value/interval.Seconds())
+		add(metricName(statsCountsPrefix, key, ""), value)
+	}
+
+	for _, key := range sortedKeys(b.timers) {
+		stats := timerStats(b.timers[key], b.timerCounters[key], interval, percentiles)
+		for _, name := range sortedKeys(stats) {
+			add(metricName(timersPrefix, key, name), stats[name])
+		}
+	}
+
+	for _, key := range sortedKeys(b.gauges) {
+		add(metricName(gaugesPrefix, key, ""), b.gauges[key])
+	}
+
+	for _, key := range sortedKeys(b.sets) {
+		add(metricName(setsPrefix, key, "count"), float64(len(b.sets[key])))
+	}
+
+	return ms
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
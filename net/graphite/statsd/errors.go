@@ -0,0 +1,12 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+const (
+	errorInvalidLine       = "invalid line : %s"
+	errorInvalidValue      = "invalid value (%s) : %s"
+	errorInvalidType       = "invalid type (%s) : %s"
+	errorInvalidSampleRate = "invalid sample rate (%s) : %s"
+)
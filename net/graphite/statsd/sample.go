@@ -0,0 +1,178 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Type represents a metric type of StatsD.
+type Type string
+
+const (
+	// Counter is the type of the counters such as 'name:1|c'.
+	Counter Type = "c"
+	// Timer is the type of the timers such as 'name:320|ms'.
+	Timer Type = "ms"
+	// Gauge is the type of the gauges such as 'name:333|g' and 'name:-10|g'.
+	Gauge Type = "g"
+	// Set is the type of the sets such as 'name:765|s'.
+	Set Type = "s"
+)
+
+const (
+	sampleNameSep      = ":"
+	sampleFieldSep     = "|"
+	sampleRatePrefix   = "@"
+	sampleTagsPrefix   = "#"
+	sampleTagSep       = ","
+	sampleTagValueSep  = ":"
+	sampleLineSep      = "\n"
+	sampleLineTrim     = " \r\t"
+	sampleBareTagValue = "true"
+	graphiteTagSep     = ";"
+	graphiteTagValue   = "="
+)
+
+var (
+	sampleWhitespaceRegex = regexp.MustCompile(`\s+`)
+	sampleInvalidRegex    = regexp.MustCompile(`[^a-zA-Z_\-0-9\.]`)
+)
+
+// Sample represents a line of the StatsD protocol.
+type Sample struct {
+	Name       string
+	Type       Type
+	Value      float64
+	SetValue   string
+	SampleRate float64
+	Delta      bool
+	Tags       []string
+}
+
+// sanitizeName replaces or removes the characters which can't be used in the Graphite paths like StatsD.
+func sanitizeName(name string) string {
+	name = sampleWhitespaceRegex.ReplaceAllString(name, "_")
+	name = strings.ReplaceAll(name, "/", "-")
+	return sampleInvalidRegex.ReplaceAllString(name, "")
+}
+
+// parseTags returns the Graphite tags of the specified DogStatsD tags such as 'env:prod,canary'.
+// The tags which have no value are set to 'true'.
+func parseTags(field string) []string {
+	tags := []string{}
+	for _, tag := range strings.Split(field, sampleTagSep) {
+		key, value, ok := strings.Cut(tag, sampleTagValueSep)
+		if !ok {
+			value = sampleBareTagValue
+		}
+		key = sanitizeName(key)
+		value = sanitizeName(value)
+		if len(key) == 0 || len(value) == 0 {
+			continue
+		}
+		tags = append(tags, key+graphiteTagValue+value)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// ParseSample returns a sample of the specified line such as 'name:value|type|@rate|#tags'.
+func ParseSample(line string) (*Sample, error) {
+	line = strings.Trim(line, sampleLineTrim)
+
+	name, body, ok := strings.Cut(line, sampleNameSep)
+	if !ok {
+		return nil, fmt.Errorf(errorInvalidLine, line)
+	}
+	name = sanitizeName(name)
+	fields := strings.Split(body, sampleFieldSep)
+	if len(name) == 0 || len(fields) < 2 {
+		return nil, fmt.Errorf(errorInvalidLine, line)
+	}
+
+	sample := &Sample{
+		Name:       name,
+		Type:       Type(strings.TrimSpace(fields[1])),
+		Value:      0,
+		SetValue:   "",
+		SampleRate: 1,
+		Delta:      false,
+		Tags:       []string{},
+	}
+
+	// DogStatsD histograms and distributions are aggregated as timers.
+	switch sample.Type {
+	case "h", "d":
+		sample.Type = Timer
+	case Counter, Timer, Gauge, Set:
+	default:
+		return nil, fmt.Errorf(errorInvalidType, fields[1], line)
+	}
+
+	value := strings.TrimSpace(fields[0])
+	if sample.Type == Set {
+		if len(value) == 0 {
+			return nil, fmt.Errorf(errorInvalidValue, value, line)
+		}
+		sample.SetValue = value
+	} else {
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf(errorInvalidValue, value, line)
+		}
+		sample.Value = v
+		if sample.Type == Gauge && (strings.HasPrefix(value, "+") || strings.HasPrefix(value, "-")) {
+			sample.Delta = true
+		}
+	}
+
+	for _, field := range fields[2:] {
+		switch {
+		case strings.HasPrefix(field, sampleRatePrefix):
+			rate, err := strconv.ParseFloat(field[len(sampleRatePrefix):], 64)
+			if err != nil || rate <= 0 || 1 < rate {
+				return nil, fmt.Errorf(errorInvalidSampleRate, field, line)
+			}
+			sample.SampleRate = rate
+		case strings.HasPrefix(field, sampleTagsPrefix):
+			sample.Tags = parseTags(field[len(sampleTagsPrefix):])
+		}
+	}
+
+	return sample, nil
+}
+
+// ParseSamples returns the samples of the specified lines, and the first error of the invalid lines which are skipped.
+func ParseSamples(text string) ([]*Sample, error) {
+	var firstErr error
+	samples := []*Sample{}
+	for _, line := range strings.Split(text, sampleLineSep) {
+		if len(strings.Trim(line, sampleLineTrim)) == 0 {
+			continue
+		}
+		sample, err := ParseSample(line)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		samples = append(samples, sample)
+	}
+	return samples, firstErr
+}
+
+// Key returns the bucket key of the sample, which is the name followed by the Graphite tags such as 'name;env=prod'.
+func (sample *Sample) Key() string {
+	if len(sample.Tags) == 0 {
+		return sample.Name
+	}
+	return sample.Name + graphiteTagSep + strings.Join(sample.Tags, graphiteTagSep)
+}
@@ -0,0 +1,74 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParseSample(t *testing.T) {
+	testCases := []struct {
+		line       string
+		key        string
+		typ        Type
+		value      float64
+		setValue   string
+		sampleRate float64
+		delta      bool
+	}{
+		{"gorets:1|c", "gorets", Counter, 1, "", 1, false},
+		{"gorets:1|c|@0.1", "gorets", Counter, 1, "", 0.1, false},
+		{"glork:320|ms", "glork", Timer, 320, "", 1, false},
+		{"glork:320|h|@0.5", "glork", Timer, 320, "", 0.5, false},
+		{"gaugor:333|g", "gaugor", Gauge, 333, "", 1, false},
+		{"gaugor:-10|g", "gaugor", Gauge, -10, "", 1, true},
+		{"gaugor:+4|g", "gaugor", Gauge, 4, "", 1, true},
+		{"uniques:765|s", "uniques", Set, 0, "765", 1, false},
+		{"api requests/sec:1|c", "api_requests-sec", Counter, 1, "", 1, false},
+		{"page.views:1|c|#env:prod,canary,region:us-east", "page.views;canary=true;env=prod;region=us-east", Counter, 1, "", 1, false},
+	}
+
+	for _, tc := range testCases {
+		sample, err := ParseSample(tc.line)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if sample.Key() != tc.key {
+			t.Error(fmt.Errorf("%s : %s != %s", tc.line, sample.Key(), tc.key))
+		}
+		if sample.Type != tc.typ {
+			t.Error(fmt.Errorf("%s : %s != %s", tc.line, sample.Type, tc.typ))
+		}
+		if sample.Value != tc.value || sample.SetValue != tc.setValue {
+			t.Error(fmt.Errorf("%s : %f %s != %f %s", tc.line, sample.Value, sample.SetValue, tc.value, tc.setValue))
+		}
+		if sample.SampleRate != tc.sampleRate {
+			t.Error(fmt.Errorf("%s : %f != %f", tc.line, sample.SampleRate, tc.sampleRate))
+		}
+		if sample.Delta != tc.delta {
+			t.Error(fmt.Errorf("%s : %t != %t", tc.line, sample.Delta, tc.delta))
+		}
+	}
+}
+
+func TestParseInvalidSample(t *testing.T) {
+	lines := []string{
+		"gorets",
+		"gorets:1",
+		"gorets:1|x",
+		"gorets:a|c",
+		"gorets:1|c|@0",
+		"gorets:1|c|@1.5",
+		":1|c",
+	}
+	for _, line := range lines {
+		_, err := ParseSample(line)
+		if err == nil {
+			t.Error(fmt.Errorf("%s is parsed", line))
+		}
+	}
+}
@@ -0,0 +1,359 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package statsd provides a StatsD compatible server which aggregates the samples over a flush interval
+// and flushes them as Graphite metrics into a graphite.CarbonListener.
+package statsd
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cybergarage/go-graphite/net/graphite"
+)
+
+const (
+	// DefaultPort is the default port number of StatsD for both UDP and TCP.
+	DefaultPort int = 8125
+	// DefaultFlushInterval is the default flush interval of StatsD.
+	DefaultFlushInterval = time.Second * 10
+	// DefaultConnectionWaitTimeout is the default read timeout of the TCP connections.
+	DefaultConnectionWaitTimeout = time.Second * 60
+	// MaxPacketSize is the maximum size of the UDP packets.
+	MaxPacketSize = 65535
+)
+
+// DefaultPercentiles returns the default percentiles of the timers.
+func DefaultPercentiles() []float64 {
+	return []float64{90}
+}
+
+// Stats represents the statistics of a server.
+type Stats struct {
+	Samples  int64
+	BadLines int64
+	Flushes  int64
+}
+
+// Server is a StatsD server which receives the samples with UDP and TCP, and flushes the aggregated values into the listener.
+type Server struct {
+	sync.Mutex
+	addr                  string
+	port                  int
+	flushInterval         time.Duration
+	percentiles           []float64
+	deleteIdleStats       bool
+	connectionWaitTimeout time.Duration
+	carbonListener        graphite.CarbonListener
+	buckets               *buckets
+	udpConn               net.PacketConn
+	tcpListener           net.Listener
+	done                  chan struct{}
+	wg                    sync.WaitGroup
+	samples               atomic.Int64
+	badLines              atomic.Int64
+	flushes               atomic.Int64
+}
+
+// NewServer returns a new StatsD server.
+func NewServer() *Server {
+	return &Server{
+		Mutex:                 sync.Mutex{},
+		addr:                  "",
+		port:                  DefaultPort,
+		flushInterval:         DefaultFlushInterval,
+		percentiles:           DefaultPercentiles(),
+		deleteIdleStats:       false,
+		connectionWaitTimeout: DefaultConnectionWaitTimeout,
+		carbonListener:        nil,
+		buckets:               newBuckets(),
+		udpConn:               nil,
+		tcpListener:           nil,
+		done:                  nil,
+		wg:                    sync.WaitGroup{},
+		samples:               atomic.Int64{},
+		badLines:              atomic.Int64{},
+		flushes:               atomic.Int64{},
+	}
+}
+
+// SetAddress sets a bind address to the server.
+func (server *Server) SetAddress(addr string) {
+	server.Lock()
+	defer server.Unlock()
+	server.addr = addr
+}
+
+// GetAddress returns a bound address.
+func (server *Server) GetAddress() string {
+	server.Lock()
+	defer server.Unlock()
+	return server.addr
+}
+
+// SetPort sets a bind port of UDP and TCP to the server.
+func (server *Server) SetPort(port int) {
+	server.Lock()
+	defer server.Unlock()
+	server.port = port
+}
+
+// GetPort returns a bound port.
+func (server *Server) GetPort() int {
+	server.Lock()
+	defer server.Unlock()
+	return server.port
+}
+
+// SetFlushInterval sets the interval to flush the aggregated values. Set it before Start.
+func (server *Server) SetFlushInterval(d time.Duration) {
+	server.Lock()
+	defer server.Unlock()
+	server.flushInterval = d
+}
+
+// GetFlushInterval returns the interval to flush the aggregated values.
+func (server *Server) GetFlushInterval() time.Duration {
+	server.Lock()
+	defer server.Unlock()
+	return server.flushInterval
+}
+
+// SetPercentiles sets the percentiles of the timers such as 90 and 99.9. The negative percentiles are calculated from the highest values.
+func (server *Server) SetPercentiles(percentiles []float64) {
+	server.Lock()
+	defer server.Unlock()
+	server.percentiles = percentiles
+}
+
+// SetDeleteIdleStats sets whether the counters, timers, gauges and sets which receive no sample in an interval are deleted instead of reported.
+func (server *Server) SetDeleteIdleStats(flag bool) {
+	server.Lock()
+	defer server.Unlock()
+	server.deleteIdleStats = flag
+}
+
+// SetConnectionWaitTimeout sets the read timeout of the TCP connections.
+func (server *Server) SetConnectionWaitTimeout(d time.Duration) {
+	server.Lock()
+	defer server.Unlock()
+	server.connectionWaitTimeout = d
+}
+
+// SetCarbonListener sets the listener of the metrics flushed at each interval.
+// Set the listener of Carbon.GetFeedListener() to pass the counters, gauges, timers and sets through the rewrite rules, filters and index of the Carbon server.
+func (server *Server) SetCarbonListener(listener graphite.CarbonListener) {
+	server.Lock()
+	defer server.Unlock()
+	server.carbonListener = listener
+}
+
+// Stats returns the statistics of the server.
+func (server *Server) Stats() *Stats {
+	return &Stats{
+		Samples:  server.samples.Load(),
+		BadLines: server.badLines.Load(),
+		Flushes:  server.flushes.Load(),
+	}
+}
+
+// FeedString aggregates the samples of the specified lines, and returns the first error of the invalid lines.
+func (server *Server) FeedString(text string) error {
+	var firstErr error
+	samples := []*Sample{}
+	for _, line := range strings.Split(text, sampleLineSep) {
+		if len(strings.Trim(line, sampleLineTrim)) == 0 {
+			continue
+		}
+		sample, err := ParseSample(line)
+		if err != nil {
+			server.badLines.Add(1)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		samples = append(samples, sample)
+	}
+
+	server.Lock()
+	defer server.Unlock()
+	for _, sample := range samples {
+		server.buckets.add(sample)
+	}
+	server.samples.Add(int64(len(samples)))
+
+	return firstErr
+}
+
+// FeedBytes aggregates the samples of the specified bytes.
+func (server *Server) FeedBytes(b []byte) error {
+	return server.FeedString(string(b))
+}
+
+// Flush passes the aggregated values of the current interval to the listener as the metrics at the current time.
+func (server *Server) Flush() []*graphite.Metrics {
+	return server.flush(time.Now().Truncate(time.Second))
+}
+
+func (server *Server) flush(now time.Time) []*graphite.Metrics {
+	server.Lock()
+	ms := server.buckets.metrics(now, server.flushInterval, server.percentiles)
+	server.buckets.reset(server.deleteIdleStats)
+	listener := server.carbonListener
+	server.Unlock()
+
+	server.flushes.Add(1)
+	if listener != nil && 0 < len(ms) {
+		listener.InsertMetricsRequestReceived(ms, nil)
+	}
+
+	return ms
+}
+
+// Start starts the UDP and TCP listeners and the flush loop.
+func (server *Server) Start() error {
+	err := server.Stop()
+	if err != nil {
+		return err
+	}
+
+	server.Lock()
+	defer server.Unlock()
+
+	addr := net.JoinHostPort(server.addr, strconv.Itoa(server.port))
+	server.udpConn, err = net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+	server.tcpListener, err = net.Listen("tcp", addr)
+	if err != nil {
+		server.udpConn.Close()
+		server.udpConn = nil
+		return err
+	}
+
+	server.done = make(chan struct{})
+	server.wg.Add(3)
+	go server.serveUDP(server.udpConn)
+	go server.serveTCP(server.tcpListener)
+	go server.run(server.done, server.flushInterval)
+
+	return nil
+}
+
+// Stop stops the listeners, and flushes the aggregated values.
+func (server *Server) Stop() error {
+	server.Lock()
+	udpConn := server.udpConn
+	tcpListener := server.tcpListener
+	done := server.done
+	server.udpConn = nil
+	server.tcpListener = nil
+	server.done = nil
+	server.Unlock()
+
+	if done == nil {
+		return nil
+	}
+
+	var lastErr error
+	if udpConn != nil {
+		err := udpConn.Close()
+		if err != nil {
+			lastErr = err
+		}
+	}
+	if tcpListener != nil {
+		err := tcpListener.Close()
+		if err != nil {
+			lastErr = err
+		}
+	}
+	close(done)
+	server.wg.Wait()
+
+	server.Flush()
+
+	return lastErr
+}
+
+func (server *Server) run(done chan struct{}, interval time.Duration) {
+	defer server.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			server.Flush()
+		}
+	}
+}
+
+func (server *Server) serveUDP(conn net.PacketConn) {
+	defer server.wg.Done()
+	buf := make([]byte, MaxPacketSize)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		server.FeedBytes(buf[:n])
+	}
+}
+
+func (server *Server) serveTCP(l net.Listener) {
+	defer server.wg.Done()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go server.receive(conn)
+	}
+}
+
+func (server *Server) receive(conn net.Conn) error {
+	defer conn.Close()
+
+	server.Lock()
+	timeout := server.connectionWaitTimeout
+	server.Unlock()
+
+	reqBytes := make([]byte, 0)
+	readBytes := make([]byte, 1024)
+	for {
+		conn.SetReadDeadline(time.Now().Add(timeout))
+
+		n, err := conn.Read(readBytes)
+		if err == nil {
+			reqBytes = append(reqBytes, readBytes[:n]...)
+			lastSep := bytes.LastIndex(reqBytes, []byte(sampleLineSep))
+			if 0 <= lastSep {
+				server.FeedBytes(reqBytes[:lastSep+1])
+				reqBytes = append(reqBytes[:0], reqBytes[lastSep+1:]...)
+			}
+			continue
+		}
+
+		if 0 < len(reqBytes) {
+			server.FeedBytes(reqBytes)
+		}
+
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		return err
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cybergarage/go-graphite/net/graphite"
+)
+
+type testListener struct {
+	sync.Mutex
+	values map[string]float64
+}
+
+func newTestListener() *testListener {
+	return &testListener{values: map[string]float64{}}
+}
+
+func (l *testListener) InsertMetricsRequestReceived(ms []*graphite.Metrics, err error) {
+	l.Lock()
+	defer l.Unlock()
+	for _, m := range ms {
+		l.values[m.Name] = m.DataPoints[0].Value
+	}
+}
+
+func (l *testListener) get(name string) (float64, bool) {
+	l.Lock()
+	defer l.Unlock()
+	v, ok := l.values[name]
+	return v, ok
+}
+
+func metricValues(ms []*graphite.Metrics) map[string]float64 {
+	values := map[string]float64{}
+	for _, m := range ms {
+		values[m.Name] = m.DataPoints[0].Value
+	}
+	return values
+}
+
+func TestServerFlush(t *testing.T) {
+	server := NewServer()
+	server.SetFlushInterval(time.Second * 10)
+	server.SetPercentiles([]float64{90, -10})
+
+	lines := "requests:1|c\nrequests:2|c|@0.5\n" +
+		"gauge:10|g\ngauge:+5|g\ngauge:-3|g\n" +
+		"users:alice|s\nusers:bob|s\nusers:alice|s\n" +
+		"tagged:1|c|#env:prod\n" +
+		"bad line\n"
+	for n := 1; n <= 10; n++ {
+		lines += "latency:" + strconv.Itoa(n) + "|ms\n"
+	}
+	err := server.FeedString(lines)
+	if err == nil {
+		t.Error(fmt.Errorf("bad line is accepted"))
+	}
+
+	values := metricValues(server.Flush())
+	expected := map[string]float64{
+		"stats.requests":                   0.5,
+		"stats_counts.requests":            5,
+		"stats.tagged;env=prod":            0.1,
+		"stats_counts.tagged;env=prod":     1,
+		"stats.gauges.gauge":               12,
+		"stats.sets.users.count":           2,
+		"stats.timers.latency.count":       10,
+		"stats.timers.latency.count_ps":    1,
+		"stats.timers.latency.lower":       1,
+		"stats.timers.latency.upper":       10,
+		"stats.timers.latency.sum":         55,
+		"stats.timers.latency.sum_squares": 385,
+		"stats.timers.latency.mean":        5.5,
+		"stats.timers.latency.median":      5.5,
+		"stats.timers.latency.upper_90":    9,
+		"stats.timers.latency.count_90":    9,
+		"stats.timers.latency.mean_90":     5,
+		"stats.timers.latency.sum_90":      45,
+		"stats.timers.latency.lower_top10": 10,
+		"stats.timers.latency.count_top10": 1,
+		"stats.timers.latency.mean_top10":  10,
+	}
+	for name, value := range expected {
+		v, ok := values[name]
+		if !ok {
+			t.Error(fmt.Errorf("%s is not found", name))
+			continue
+		}
+		if math.Abs(v-value) > 1e-9 {
+			t.Error(fmt.Errorf("%s : %f != %f", name, v, value))
+		}
+	}
+	if std := values["stats.timers.latency.std"]; math.Abs(std-math.Sqrt(8.25)) > 1e-9 {
+		t.Error(fmt.Errorf("%f != %f", std, math.Sqrt(8.25)))
+	}
+
+	stats := server.Stats()
+	if stats.BadLines != 1 {
+		t.Error(fmt.Errorf("%d != %d", stats.BadLines, 1))
+	}
+
+	// The idle counters and sets are reported as zero, and the gauges keep the last values.
+	values = metricValues(server.Flush())
+	for name, value := range map[string]float64{
+		"stats_counts.requests":      0,
+		"stats.sets.users.count":     0,
+		"stats.timers.latency.count": 0,
+		"stats.gauges.gauge":         12,
+	} {
+		if values[name] != value {
+			t.Error(fmt.Errorf("%s : %f != %f", name, values[name], value))
+		}
+	}
+
+	server.SetDeleteIdleStats(true)
+	server.Flush()
+	if ms := server.Flush(); len(ms) != 0 {
+		t.Error(fmt.Errorf("%d != %d", len(ms), 0))
+	}
+}
+
+func TestServerUDPAndTCP(t *testing.T) {
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	listener := newTestListener()
+	server := NewServer()
+	server.SetAddress("localhost")
+	server.SetPort(port)
+	server.SetFlushInterval(time.Hour)
+	server.SetCarbonListener(listener)
+	err = server.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addr := net.JoinHostPort("localhost", strconv.Itoa(port))
+	udp, err := net.Dial("udp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	udp.Write([]byte("udp.requests:3|c\nudp.requests:4|c"))
+	udp.Close()
+
+	tcp, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tcp.Write([]byte("tcp.gauge:42|g\n"))
+	tcp.Close()
+
+	deadline := time.Now().Add(time.Second * 5)
+	for server.Stats().Samples < 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	// Stop flushes the aggregated values.
+	err = server.Stop()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if v, _ := listener.get("stats_counts.udp.requests"); v != 7 {
+		t.Error(fmt.Errorf("%f != %d", v, 7))
+	}
+	if v, _ := listener.get("stats.gauges.tcp.gauge"); v != 42 {
+		t.Error(fmt.Errorf("%f != %d", v, 42))
+	}
+}
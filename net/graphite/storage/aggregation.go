@@ -0,0 +1,128 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+)
+
+const (
+	aggregationOptionPattern      = "pattern"
+	aggregationOptionXFilesFactor = "xFilesFactor"
+	aggregationOptionMethod       = "aggregationMethod"
+)
+
+const (
+	// DefaultXFilesFactor is the default ratio of known points to aggregate into lower precision archives.
+	DefaultXFilesFactor = 0.5
+	// DefaultAggregationMethod is the default aggregation method.
+	DefaultAggregationMethod = "average"
+)
+
+// AggregationMethods are the aggregation method names of Whisper.
+var AggregationMethods = []string{
+	"average",
+	"sum",
+	"last",
+	"max",
+	"min",
+	"avg_zero",
+	"absmax",
+	"absmin",
+}
+
+// Aggregation represents a rule of storage-aggregation.conf.
+// See : storage-aggregation.conf (https://graphite.readthedocs.io/en/latest/config-carbon.html#storage-aggregation-conf)
+type Aggregation struct {
+	Name              string
+	Pattern           *regexp.Regexp
+	XFilesFactor      float64
+	AggregationMethod string
+}
+
+// NewDefaultAggregation returns the default aggregation of Carbon.
+func NewDefaultAggregation() *Aggregation {
+	return NewAggregation(defaultSchemaName, DefaultXFilesFactor, DefaultAggregationMethod)
+}
+
+// NewAggregation returns a new aggregation rule of the specified xFilesFactor and method which matches all metrics.
+func NewAggregation(name string, xff float64, method string) *Aggregation {
+	return &Aggregation{
+		Name:              name,
+		Pattern:           nil,
+		XFilesFactor:      xff,
+		AggregationMethod: method,
+	}
+}
+
+// Match returns true whether the specified metric name matches the rule, otherwise false.
+func (agg *Aggregation) Match(name string) bool {
+	if agg.Pattern == nil {
+		return true
+	}
+	return agg.Pattern.MatchString(name)
+}
+
+// LoadAggregations loads the specified storage-aggregation.conf.
+func LoadAggregations(path string) ([]*Aggregation, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ParseAggregations(file)
+}
+
+// ParseAggregations parses the rules of storage-aggregation.conf in the order of appearance.
+func ParseAggregations(reader io.Reader) ([]*Aggregation, error) {
+	sections, err := ParseConfig(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	aggs := []*Aggregation{}
+	for _, section := range sections {
+		pattern, ok := section.Option(aggregationOptionPattern)
+		if !ok {
+			return nil, fmt.Errorf(errorMissingOption, section.Name, aggregationOptionPattern)
+		}
+		regex, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf(errorInvalidPattern, section.Name, pattern)
+		}
+
+		agg := &Aggregation{
+			Name:              section.Name,
+			Pattern:           regex,
+			XFilesFactor:      DefaultXFilesFactor,
+			AggregationMethod: DefaultAggregationMethod,
+		}
+
+		xff, ok := section.Option(aggregationOptionXFilesFactor)
+		if ok {
+			agg.XFilesFactor, err = strconv.ParseFloat(xff, 64)
+			if err != nil || agg.XFilesFactor < 0 || 1 < agg.XFilesFactor {
+				return nil, fmt.Errorf(errorInvalidXFilesFactor, section.Name, xff)
+			}
+		}
+
+		method, ok := section.Option(aggregationOptionMethod)
+		if ok {
+			if !slices.Contains(AggregationMethods, method) {
+				return nil, fmt.Errorf(errorInvalidAggregationMethod, section.Name, method)
+			}
+			agg.AggregationMethod = method
+		}
+
+		aggs = append(aggs, agg)
+	}
+
+	return aggs, nil
+}
@@ -0,0 +1,81 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ConfigSection represents a section of the INI style configuration files of Carbon.
+type ConfigSection struct {
+	Name    string
+	Options map[string]string
+}
+
+// Option returns the value of the specified option, and the option names are case-insensitive.
+func (section *ConfigSection) Option(name string) (string, bool) {
+	value, ok := section.Options[strings.ToLower(name)]
+	return value, ok
+}
+
+// ParseConfig parses the INI style configuration of Carbon, and returns the sections in the order of appearance.
+func ParseConfig(reader io.Reader) ([]*ConfigSection, error) {
+	sections := []*ConfigSection{}
+
+	var section *ConfigSection
+	var lastOption string
+
+	scanner := bufio.NewScanner(reader)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		rawLine := scanner.Text()
+		line := strings.TrimSpace(rawLine)
+
+		if len(line) == 0 || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		// Continuation lines of the previous option
+		if section != nil && 0 < len(lastOption) && (rawLine[0] == ' ' || rawLine[0] == '\t') {
+			section.Options[lastOption] += "\n" + line
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf(errorInvalidConfigLine, lineNo, rawLine)
+			}
+			section = &ConfigSection{
+				Name:    strings.TrimSpace(line[1 : len(line)-1]),
+				Options: map[string]string{},
+			}
+			sections = append(sections, section)
+			lastOption = ""
+			continue
+		}
+
+		if section == nil {
+			return nil, fmt.Errorf(errorInvalidConfigLine, lineNo, rawLine)
+		}
+
+		sepIdx := strings.IndexAny(line, "=:")
+		if sepIdx <= 0 {
+			return nil, fmt.Errorf(errorInvalidConfigLine, lineNo, rawLine)
+		}
+		lastOption = strings.ToLower(strings.TrimSpace(line[:sepIdx]))
+		section.Options[lastOption] = strings.TrimSpace(line[sepIdx+1:])
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return sections, nil
+}
@@ -0,0 +1,15 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package storage
+
+const (
+	errorInvalidRetention         = "invalid retention : %s"
+	errorInvalidRetentionList     = "invalid retention list : %s"
+	errorInvalidConfigLine        = "invalid line (%d) : %s"
+	errorMissingOption            = "section [%s] is missing '%s'"
+	errorInvalidPattern           = "section [%s] has an invalid pattern : %s"
+	errorInvalidXFilesFactor      = "section [%s] has an invalid xFilesFactor : %s"
+	errorInvalidAggregationMethod = "section [%s] has an invalid aggregationMethod : %s"
+)
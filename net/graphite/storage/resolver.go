@@ -0,0 +1,100 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package storage provides the storage-schemas.conf and storage-aggregation.conf rules of Carbon for storage backends.
+package storage
+
+// Policy represents the storage policy of a metric.
+type Policy struct {
+	Schema      *Schema
+	Aggregation *Aggregation
+}
+
+// Retentions returns the retentions of the policy.
+func (policy *Policy) Retentions() []*Retention {
+	return policy.Schema.Retentions
+}
+
+// XFilesFactor returns the xFilesFactor of the policy.
+func (policy *Policy) XFilesFactor() float64 {
+	return policy.Aggregation.XFilesFactor
+}
+
+// AggregationMethod returns the aggregation method name of the policy.
+func (policy *Policy) AggregationMethod() string {
+	return policy.Aggregation.AggregationMethod
+}
+
+// Resolver resolves the storage policy of metrics by the first matched rules like Carbon.
+type Resolver struct {
+	Schemas      []*Schema
+	Aggregations []*Aggregation
+}
+
+// NewResolver returns a new resolver which has only the default rules.
+func NewResolver() *Resolver {
+	return &Resolver{
+		Schemas:      []*Schema{},
+		Aggregations: []*Aggregation{},
+	}
+}
+
+// LoadResolver returns a new resolver with the specified storage-schemas.conf and storage-aggregation.conf.
+// The storage-aggregation.conf is optional, and it is skipped when the path is empty.
+func LoadResolver(schemasPath string, aggregationPath string) (*Resolver, error) {
+	resolver := NewResolver()
+
+	schemas, err := LoadSchemas(schemasPath)
+	if err != nil {
+		return nil, err
+	}
+	resolver.Schemas = schemas
+
+	if 0 < len(aggregationPath) {
+		aggs, err := LoadAggregations(aggregationPath)
+		if err != nil {
+			return nil, err
+		}
+		resolver.Aggregations = aggs
+	}
+
+	return resolver, nil
+}
+
+// WithDefaults returns a new resolver which has the rules of the resolver followed by the specified rules,
+// so that the unmatched metrics use the specified rules instead of the default rules of Carbon.
+func (resolver *Resolver) WithDefaults(schema *Schema, agg *Aggregation) *Resolver {
+	return &Resolver{
+		Schemas:      append(append([]*Schema{}, resolver.Schemas...), schema),
+		Aggregations: append(append([]*Aggregation{}, resolver.Aggregations...), agg),
+	}
+}
+
+// Schema returns the first schema which matches the specified metric name, or the default schema.
+func (resolver *Resolver) Schema(name string) *Schema {
+	for _, schema := range resolver.Schemas {
+		if schema.Match(name) {
+			return schema
+		}
+	}
+	return NewDefaultSchema()
+}
+
+// Aggregation returns the first aggregation rule which matches the specified metric name, or the default rule.
+func (resolver *Resolver) Aggregation(name string) *Aggregation {
+	for _, agg := range resolver.Aggregations {
+		if agg.Match(name) {
+			return agg
+		}
+	}
+	return NewDefaultAggregation()
+}
+
+// Resolve returns the storage policy of the specified metric name.
+func (resolver *Resolver) Resolve(name string) *Policy {
+	return &Policy{
+		Schema:      resolver.Schema(name),
+		Aggregation: resolver.Aggregation(name),
+	}
+}
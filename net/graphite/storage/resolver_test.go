@@ -0,0 +1,137 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testStorageSchemas = `
+# Schema definitions for Whisper files. Entries are scanned in order,
+# and first match wins.
+[carbon]
+pattern = ^carbon\.
+retentions = 60:90d
+
+[collectd]
+Pattern = ^collectd\.
+retentions = 10s:6h,
+  1m:7d
+
+[default_1min_for_1day]
+pattern = .*
+retentions = 60s:1d
+`
+
+const testStorageAggregation = `
+[min]
+pattern = \.min$
+xFilesFactor = 0.1
+aggregationMethod = min
+
+[count]
+pattern = \.count$
+aggregationMethod = sum
+
+[default_average]
+pattern = .*
+xFilesFactor = 0.5
+aggregationMethod = average
+`
+
+func TestResolver(t *testing.T) {
+	dir := t.TempDir()
+	schemasPath := filepath.Join(dir, "storage-schemas.conf")
+	aggPath := filepath.Join(dir, "storage-aggregation.conf")
+	err := os.WriteFile(schemasPath, []byte(testStorageSchemas), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(aggPath, []byte(testStorageAggregation), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resolver, err := LoadResolver(schemasPath, aggPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name       string
+		schema     string
+		retentions string
+		agg        string
+		xff        float64
+		method     string
+	}{
+		{"carbon.agents.cpu", "carbon", "60:129600", "default_average", 0.5, "average"},
+		{"collectd.host.load.min", "collectd", "10:2160,60:10080", "min", 0.1, "min"},
+		{"app.requests.count", "default_1min_for_1day", "60:1440", "count", 0.5, "sum"},
+	}
+
+	for _, tc := range testCases {
+		policy := resolver.Resolve(tc.name)
+		if policy.Schema.Name != tc.schema || policy.Aggregation.Name != tc.agg {
+			t.Error(fmt.Errorf("%s : %s %s", tc.name, policy.Schema.Name, policy.Aggregation.Name))
+		}
+		retentions := []string{}
+		for _, r := range policy.Retentions() {
+			retentions = append(retentions, r.String())
+		}
+		if strings.Join(retentions, ",") != tc.retentions {
+			t.Error(fmt.Errorf("%s : %v", tc.name, retentions))
+		}
+		if policy.XFilesFactor() != tc.xff || policy.AggregationMethod() != tc.method {
+			t.Error(fmt.Errorf("%s : %f %s", tc.name, policy.XFilesFactor(), policy.AggregationMethod()))
+		}
+	}
+
+	policy := NewResolver().Resolve("any")
+	if policy.Retentions()[0].String() != "60:10080" || policy.AggregationMethod() != DefaultAggregationMethod {
+		t.Error(fmt.Errorf("%v", policy))
+	}
+
+	// The specified defaults take place of the default rules of Carbon.
+	defaults := NewResolver().WithDefaults(NewSchema("store", []*Retention{NewRetention(10, 360)}), NewAggregation("store", 0, "max"))
+	policy = defaults.Resolve("any")
+	if policy.Retentions()[0].String() != "10:360" || policy.XFilesFactor() != 0 || policy.AggregationMethod() != "max" {
+		t.Error(fmt.Errorf("%v", policy))
+	}
+	policy = resolver.WithDefaults(NewSchema("store", []*Retention{NewRetention(10, 360)}), NewAggregation("store", 0, "max")).Resolve("carbon.agents.cpu")
+	if policy.Schema.Name != "carbon" {
+		t.Error(fmt.Errorf("%s", policy.Schema.Name))
+	}
+}
+
+func TestParseInvalidConfigs(t *testing.T) {
+	badSchemas := []string{
+		"[a]\nretentions = 60:1d\n",
+		"[a]\npattern = .*\n",
+		"[a]\npattern = (\nretentions = 60:1d\n",
+		"pattern = .*\n",
+	}
+	for _, conf := range badSchemas {
+		_, err := ParseSchemas(strings.NewReader(conf))
+		if err == nil {
+			t.Error(fmt.Errorf("%s is parsed", conf))
+		}
+	}
+
+	badAggs := []string{
+		"[a]\npattern = .*\naggregationMethod = median\n",
+		"[a]\npattern = .*\nxFilesFactor = 2\n",
+	}
+	for _, conf := range badAggs {
+		_, err := ParseAggregations(strings.NewReader(conf))
+		if err == nil {
+			t.Error(fmt.Errorf("%s is parsed", conf))
+		}
+	}
+}
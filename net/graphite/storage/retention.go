@@ -0,0 +1,168 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	retentionSep     = ":"
+	retentionListSep = ","
+)
+
+var retentionUnitRegex = regexp.MustCompile(`^(\d+)([a-z]+)$`)
+
+var retentionUnits = []struct {
+	name    string
+	seconds int
+}{
+	{"seconds", 1},
+	{"minutes", 60},
+	{"hours", 3600},
+	{"days", 86400},
+	{"weeks", 86400 * 7},
+	{"years", 86400 * 365},
+}
+
+// Retention represents an archive of a retention policy such as '10s:6h'.
+type Retention struct {
+	SecondsPerPoint int
+	Points          int
+}
+
+// NewRetention returns a new retention.
+func NewRetention(secondsPerPoint int, points int) *Retention {
+	return &Retention{
+		SecondsPerPoint: secondsPerPoint,
+		Points:          points,
+	}
+}
+
+// ParseRetention parses the specified retention definition in the same way as Whisper,
+// such as '60:1440', '1m:1d' or '10s:6h'.
+func ParseRetention(def string) (*Retention, error) {
+	precisionStr, pointsStr, ok := strings.Cut(strings.TrimSpace(def), retentionSep)
+	if !ok {
+		return nil, fmt.Errorf(errorInvalidRetention, def)
+	}
+
+	precision, err := parseRetentionSeconds(precisionStr)
+	if err != nil || precision <= 0 {
+		return nil, fmt.Errorf(errorInvalidRetention, def)
+	}
+
+	points, err := strconv.Atoi(pointsStr)
+	if err != nil {
+		period, err := parseRetentionSeconds(pointsStr)
+		if err != nil {
+			return nil, fmt.Errorf(errorInvalidRetention, def)
+		}
+		points = period / precision
+	}
+	if points <= 0 {
+		return nil, fmt.Errorf(errorInvalidRetention, def)
+	}
+
+	return NewRetention(precision, points), nil
+}
+
+// ParseRetentions parses the specified comma separated retention definitions such as '10s:6h,1m:7d'.
+func ParseRetentions(defs string) ([]*Retention, error) {
+	retentions := []*Retention{}
+	for def := range strings.SplitSeq(defs, retentionListSep) {
+		if len(strings.TrimSpace(def)) == 0 {
+			continue
+		}
+		r, err := ParseRetention(def)
+		if err != nil {
+			return nil, err
+		}
+		retentions = append(retentions, r)
+	}
+	if len(retentions) == 0 {
+		return nil, fmt.Errorf(errorInvalidRetention, defs)
+	}
+	return retentions, nil
+}
+
+// ValidateRetentions sorts the specified retentions from the highest precision,
+// and checks them with the same rules as the Whisper archives.
+func ValidateRetentions(retentions []*Retention) error {
+	if len(retentions) == 0 {
+		return fmt.Errorf(errorInvalidRetentionList, "no retentions")
+	}
+
+	sort.SliceStable(retentions, func(i, j int) bool {
+		return retentions[i].SecondsPerPoint < retentions[j].SecondsPerPoint
+	})
+
+	for n, r := range retentions {
+		if r.SecondsPerPoint <= 0 || r.Points <= 0 {
+			return fmt.Errorf(errorInvalidRetentionList, r.String())
+		}
+		if n == (len(retentions) - 1) {
+			break
+		}
+		next := retentions[n+1]
+		if r.SecondsPerPoint == next.SecondsPerPoint {
+			return fmt.Errorf(errorInvalidRetentionList, "a precision is duplicated : "+r.String())
+		}
+		if (next.SecondsPerPoint % r.SecondsPerPoint) != 0 {
+			return fmt.Errorf(errorInvalidRetentionList, "a lower precision must evenly divide a higher precision : "+next.String())
+		}
+		if next.Period() <= r.Period() {
+			return fmt.Errorf(errorInvalidRetentionList, "a lower precision must cover a larger time interval : "+next.String())
+		}
+		if r.Points < (next.SecondsPerPoint / r.SecondsPerPoint) {
+			return fmt.Errorf(errorInvalidRetentionList, "a retention must have enough points to consolidate into the next retention : "+r.String())
+		}
+	}
+
+	return nil
+}
+
+func parseRetentionSeconds(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err == nil {
+		return n, nil
+	}
+
+	match := retentionUnitRegex.FindStringSubmatch(s)
+	if match == nil {
+		return 0, fmt.Errorf(errorInvalidRetention, s)
+	}
+	n, err = strconv.Atoi(match[1])
+	if err != nil {
+		return 0, err
+	}
+	for _, unit := range retentionUnits {
+		if strings.HasPrefix(unit.name, match[2]) {
+			return n * unit.seconds, nil
+		}
+	}
+
+	return 0, fmt.Errorf(errorInvalidRetention, s)
+}
+
+// Precision returns the duration of a point.
+func (r *Retention) Precision() time.Duration {
+	return time.Duration(r.SecondsPerPoint) * time.Second
+}
+
+// Period returns the retention period.
+func (r *Retention) Period() time.Duration {
+	return time.Duration(r.SecondsPerPoint*r.Points) * time.Second
+}
+
+// String returns the retention definition such as '60:1440'.
+func (r *Retention) String() string {
+	return fmt.Sprintf("%d:%d", r.SecondsPerPoint, r.Points)
+}
@@ -0,0 +1,84 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParseRetention(t *testing.T) {
+	testCases := []struct {
+		def             string
+		secondsPerPoint int
+		points          int
+	}{
+		{"60:1440", 60, 1440},
+		{"10s:6h", 10, 2160},
+		{"1m:7d", 60, 10080},
+		{"1min:1d", 60, 1440},
+		{"1h:1y", 3600, 8760},
+		{"15m:2w", 900, 1344},
+		{" 10:360 ", 10, 360},
+	}
+
+	for _, tc := range testCases {
+		r, err := ParseRetention(tc.def)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if r.SecondsPerPoint != tc.secondsPerPoint || r.Points != tc.points {
+			t.Error(fmt.Errorf("%s : %s", tc.def, r.String()))
+		}
+	}
+
+	badDefs := []string{"", "60", "x:1d", "10s:1q", "0:10", "10s:5s"}
+	for _, def := range badDefs {
+		_, err := ParseRetention(def)
+		if err == nil {
+			t.Error(fmt.Errorf("%s is parsed", def))
+		}
+	}
+
+	rs, err := ParseRetentions("10s:6h,1m:7d, 10m:5y")
+	if err != nil {
+		t.Error(err)
+	}
+	if len(rs) != 3 {
+		t.Error(fmt.Errorf("%v", rs))
+	}
+}
+
+func TestValidateRetentions(t *testing.T) {
+	goodDefs := []string{"10s:6h", "1m:7d,10s:6h", "10s:6h,1m:7d,10m:30d,1h:1y"}
+	for _, defs := range goodDefs {
+		rs, err := ParseRetentions(defs)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		err = ValidateRetentions(rs)
+		if err != nil {
+			t.Error(fmt.Errorf("%s : %w", defs, err))
+		}
+		if rs[0].SecondsPerPoint != 10 {
+			t.Error(fmt.Errorf("%s : %v", defs, rs))
+		}
+	}
+
+	badDefs := []string{"10s:6h,10s:1d", "10s:6h,15s:7d", "10s:6h,1m:1h", "10s:3,1m:1d"}
+	for _, defs := range badDefs {
+		rs, err := ParseRetentions(defs)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		err = ValidateRetentions(rs)
+		if err == nil {
+			t.Error(fmt.Errorf("%s is validated", defs))
+		}
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+)
+
+const (
+	schemaOptionPattern    = "pattern"
+	schemaOptionRetentions = "retentions"
+	defaultSchemaName      = "default"
+)
+
+// Schema represents a rule of storage-schemas.conf.
+// See : storage-schemas.conf (https://graphite.readthedocs.io/en/latest/config-carbon.html#storage-schemas-conf)
+type Schema struct {
+	Name       string
+	Pattern    *regexp.Regexp
+	Retentions []*Retention
+}
+
+// NewDefaultSchema returns the default schema of Carbon which keeps 1 minute points for 7 days for all metrics.
+func NewDefaultSchema() *Schema {
+	return NewSchema(defaultSchemaName, []*Retention{NewRetention(60, 60*24*7)})
+}
+
+// NewSchema returns a new schema of the specified retentions which matches all metrics.
+func NewSchema(name string, retentions []*Retention) *Schema {
+	return &Schema{
+		Name:       name,
+		Pattern:    nil,
+		Retentions: retentions,
+	}
+}
+
+// Match returns true whether the specified metric name matches the schema, otherwise false.
+func (schema *Schema) Match(name string) bool {
+	if schema.Pattern == nil {
+		return true
+	}
+	return schema.Pattern.MatchString(name)
+}
+
+// LoadSchemas loads the specified storage-schemas.conf.
+func LoadSchemas(path string) ([]*Schema, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ParseSchemas(file)
+}
+
+// ParseSchemas parses the rules of storage-schemas.conf in the order of appearance.
+func ParseSchemas(reader io.Reader) ([]*Schema, error) {
+	sections, err := ParseConfig(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	schemas := []*Schema{}
+	for _, section := range sections {
+		pattern, ok := section.Option(schemaOptionPattern)
+		if !ok {
+			return nil, fmt.Errorf(errorMissingOption, section.Name, schemaOptionPattern)
+		}
+		regex, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf(errorInvalidPattern, section.Name, pattern)
+		}
+		defs, ok := section.Option(schemaOptionRetentions)
+		if !ok {
+			return nil, fmt.Errorf(errorMissingOption, section.Name, schemaOptionRetentions)
+		}
+		retentions, err := ParseRetentions(defs)
+		if err != nil {
+			return nil, err
+		}
+		schemas = append(schemas, &Schema{
+			Name:       section.Name,
+			Pattern:    regex,
+			Retentions: retentions,
+		})
+	}
+
+	return schemas, nil
+}
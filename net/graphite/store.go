@@ -0,0 +1,26 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package graphite
+
+// Store represents a storage backend which handles all requests of Carbon and Render.
+type Store interface {
+	CarbonListener
+	RenderRequestListener
+}
+
+// SetStore sets the specified store as the Carbon and Render listeners.
+func (server *Server) SetStore(store Store) {
+	server.SetCarbonListener(store)
+	server.SetRenderListener(store)
+}
+
+// SetStore sets the specified store as the Carbon and Render listeners of all servers.
+func (mgr *Manager) SetStore(store Store) error {
+	err := mgr.SetCarbonListener(store)
+	if err != nil {
+		return err
+	}
+	return mgr.SetRenderListener(store)
+}
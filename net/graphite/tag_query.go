@@ -0,0 +1,210 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package graphite
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// TagOperator represents an operator of the tag expressions.
+type TagOperator string
+
+const (
+	// TagEqual matches the series whose tag value is equal to the value.
+	TagEqual TagOperator = "="
+	// TagNotEqual matches the series whose tag value isn't equal to the value.
+	TagNotEqual TagOperator = "!="
+	// TagMatch matches the series whose tag value matches the regular expression from the beginning.
+	TagMatch TagOperator = "=~"
+	// TagNotMatch matches the series whose tag value doesn't match the regular expression.
+	TagNotMatch TagOperator = "!=~"
+)
+
+const (
+	// TagNameKey is the tag key of the series names in the tag expressions.
+	TagNameKey = "name"
+	// TagQueryFunction is the function name of the tag queries.
+	TagQueryFunction = "seriesByTag"
+	tagSep           = ";"
+	tagValueSep      = "="
+)
+
+// TagExpression is a compiled tag expression such as 'name=cpu', 'host!=web01', 'dc=~us-.*' and 'env!=~dev'.
+// The missing tags are matched as the empty values.
+type TagExpression struct {
+	Key      string
+	Operator TagOperator
+	Value    string
+	regex    *regexp.Regexp
+}
+
+// NewTagExpression returns a new tag expression of the specified key, operator and value.
+func NewTagExpression(key string, op TagOperator, value string) (*TagExpression, error) {
+	expr := &TagExpression{
+		Key:      key,
+		Operator: op,
+		Value:    value,
+		regex:    nil,
+	}
+	if len(key) == 0 {
+		return nil, fmt.Errorf(errorInvalidTagExpression, expr.String())
+	}
+	switch op {
+	case TagEqual, TagNotEqual:
+	case TagMatch, TagNotMatch:
+		// The regular expressions are matched from the beginning like Graphite.
+		regex, err := regexp.Compile("^(?:" + value + ")")
+		if err != nil {
+			return nil, fmt.Errorf(errorInvalidTagExpression, expr.String())
+		}
+		expr.regex = regex
+	default:
+		return nil, fmt.Errorf(errorInvalidTagExpression, expr.String())
+	}
+	return expr, nil
+}
+
+// ParseTagExpression returns a tag expression of the specified string such as 'host!=web01'.
+func ParseTagExpression(s string) (*TagExpression, error) {
+	idx := strings.IndexAny(s, "!=")
+	if idx <= 0 {
+		return nil, fmt.Errorf(errorInvalidTagExpression, s)
+	}
+	key := s[:idx]
+	rest := s[idx:]
+	for _, op := range []TagOperator{TagNotMatch, TagMatch, TagNotEqual, TagEqual} {
+		if strings.HasPrefix(rest, string(op)) {
+			return NewTagExpression(key, op, rest[len(op):])
+		}
+	}
+	return nil, fmt.Errorf(errorInvalidTagExpression, s)
+}
+
+// String returns the string of the expression such as 'host!=web01'.
+func (expr *TagExpression) String() string {
+	return expr.Key + string(expr.Operator) + expr.Value
+}
+
+// Match returns true when the specified tag value matches the expression.
+func (expr *TagExpression) Match(value string) bool {
+	switch expr.Operator {
+	case TagEqual:
+		return value == expr.Value
+	case TagNotEqual:
+		return value != expr.Value
+	case TagMatch:
+		return expr.regex.MatchString(value)
+	case TagNotMatch:
+		return !expr.regex.MatchString(value)
+	}
+	return false
+}
+
+// ParseTaggedName returns the name and the tags of the specified series such as 'cpu;host=web01;dc=us-east'.
+// The name is also set as the 'name' tag, and the series which have no tags return only it.
+func ParseTaggedName(series string) (string, map[string]string) {
+	parts := strings.Split(series, tagSep)
+	tags := map[string]string{TagNameKey: parts[0]}
+	for _, part := range parts[1:] {
+		key, value, ok := strings.Cut(part, tagValueSep)
+		if !ok || len(key) == 0 {
+			continue
+		}
+		tags[key] = value
+	}
+	return parts[0], tags
+}
+
+// TaggedName returns the series of the specified name and tags such as 'cpu;dc=us-east;host=web01'.
+// The tags are sorted by the keys, and the name returns as it is when it has no tags.
+func TaggedName(name string, tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	series := name
+	for _, key := range keys {
+		series += tagSep + key + tagValueSep + tags[key]
+	}
+	return series
+}
+
+// TagQuery is a compiled tag query of the series such as "seriesByTag('name=cpu','host=~web.*')".
+type TagQuery struct {
+	Expressions []*TagExpression
+}
+
+// NewTagQuery returns a new tag query of the specified expressions.
+func NewTagQuery(exprs ...*TagExpression) *TagQuery {
+	return &TagQuery{
+		Expressions: exprs,
+	}
+}
+
+// IsTagQuery returns true when the specified target is a tag query.
+func IsTagQuery(target string) bool {
+	return strings.HasPrefix(strings.TrimSpace(target), TagQueryFunction+"(")
+}
+
+// ParseTagQuery returns a tag query of the specified target such as "seriesByTag('name=cpu','host=~web.*')".
+func ParseTagQuery(target string) (*TagQuery, error) {
+	target = strings.TrimSpace(target)
+	if !IsTagQuery(target) || !strings.HasSuffix(target, ")") {
+		return nil, fmt.Errorf(errorInvalidTagQuery, target)
+	}
+	args := strings.TrimSpace(target[len(TagQueryFunction)+1 : len(target)-1])
+
+	q := NewTagQuery()
+	for 0 < len(args) {
+		quote := args[0]
+		if quote != '\'' && quote != '"' {
+			return nil, fmt.Errorf(errorInvalidTagQuery, target)
+		}
+		end := strings.IndexByte(args[1:], quote)
+		if end < 0 {
+			return nil, fmt.Errorf(errorInvalidTagQuery, target)
+		}
+		expr, err := ParseTagExpression(args[1 : end+1])
+		if err != nil {
+			return nil, err
+		}
+		q.Expressions = append(q.Expressions, expr)
+		args = strings.TrimSpace(args[end+2:])
+		if strings.HasPrefix(args, ",") {
+			args = strings.TrimSpace(args[1:])
+		} else if 0 < len(args) {
+			return nil, fmt.Errorf(errorInvalidTagQuery, target)
+		}
+	}
+	if len(q.Expressions) == 0 {
+		return nil, fmt.Errorf(errorInvalidTagQuery, target)
+	}
+
+	return q, nil
+}
+
+// String returns the target string of the query.
+func (q *TagQuery) String() string {
+	exprs := make([]string, 0, len(q.Expressions))
+	for _, expr := range q.Expressions {
+		exprs = append(exprs, "'"+expr.String()+"'")
+	}
+	return TagQueryFunction + "(" + strings.Join(exprs, ",") + ")"
+}
+
+// Match returns true when the specified series matches all expressions.
+func (q *TagQuery) Match(series string) bool {
+	_, tags := ParseTaggedName(series)
+	for _, expr := range q.Expressions {
+		if !expr.Match(tags[expr.Key]) {
+			return false
+		}
+	}
+	return true
+}
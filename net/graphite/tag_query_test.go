@@ -0,0 +1,74 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package graphite
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParseTagQuery(t *testing.T) {
+	target := "seriesByTag('name=cpu', \"host=~web.*\",'dc!=us-west','env!=~dev')"
+	q, err := ParseTagQuery(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "seriesByTag('name=cpu','host=~web.*','dc!=us-west','env!=~dev')"
+	if q.String() != expected {
+		t.Error(fmt.Errorf("%s != %s", q.String(), expected))
+	}
+
+	invalidTargets := []string{
+		"seriesByTag()",
+		"seriesByTag('name')",
+		"seriesByTag('=cpu')",
+		"seriesByTag('host=~(')",
+		"seriesByTag('name=cpu' 'host=web01')",
+		"seriesByTag('name=cpu'",
+	}
+	for _, target := range invalidTargets {
+		_, err := ParseTagQuery(target)
+		if err == nil {
+			t.Error(fmt.Errorf("%s is parsed", target))
+		}
+	}
+}
+
+func TestTagQueryMatch(t *testing.T) {
+	idx := NewMetricIndex()
+	names := []string{
+		"cpu;dc=us-east;host=web01",
+		"cpu;dc=us-west;host=web02",
+		"cpu;dc=us-east;host=db01;env=dev",
+		"mem;dc=us-east;host=web01",
+		"servers.web01.cpu",
+	}
+	for _, name := range names {
+		idx.Insert(name)
+	}
+
+	testCases := []struct {
+		target   string
+		expected int
+	}{
+		{"seriesByTag('name=cpu')", 3},
+		{"seriesByTag('name=cpu','host=~web')", 2},
+		{"seriesByTag('name=cpu','dc!=us-west')", 2},
+		{"seriesByTag('name=cpu','env!=~dev')", 2},
+		{"seriesByTag('name=cpu','env=')", 2},
+		{"seriesByTag('host=web01')", 2},
+		{"seriesByTag('name=servers.web01.cpu')", 1},
+	}
+	for _, tc := range testCases {
+		ms, err := idx.FindMetrics(tc.target)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if len(ms) != tc.expected {
+			t.Error(fmt.Errorf("%s : %d != %d", tc.target, len(ms), tc.expected))
+		}
+	}
+}
@@ -0,0 +1,332 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package graphite
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// TenantMode represents how the metric names of the tenants are separated.
+type TenantMode int
+
+const (
+	// TenantPrefix prefixes the metric names with the tenants such as 'team-x.cpu' for the listeners, and strips the prefixes from the responses.
+	TenantPrefix TenantMode = iota
+	// TenantIsolate passes the metric names as they are, and the tenant-aware listeners isolate the metrics of the tenants.
+	TenantIsolate
+)
+
+const (
+	// DefaultTenantHeader is the default HTTP header of the tenants of the Render requests.
+	DefaultTenantHeader = "X-Graphite-Tenant"
+	tenantNameSep       = "."
+)
+
+var tenantNameRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// TenantCarbonListener represents a listener for Carbon protocol which also receives the tenants of the metrics.
+// The tenant-aware methods are called instead of the CarbonListener methods for the metrics of the tenants.
+type TenantCarbonListener interface {
+	CarbonListener
+	InsertTenantMetricsRequestReceived(tenant string, ms []*Metrics, err error)
+}
+
+// TenantRenderRequestListener represents a listener for Render protocol which also receives the tenants of the queries.
+// The tenant-aware methods are called instead of the RenderRequestListener methods for the queries of the tenants.
+type TenantRenderRequestListener interface {
+	RenderRequestListener
+	FindTenantMetricsRequestReceived(tenant string, query *Query, err error) ([]*Metrics, error)
+	QueryTenantMetricsRequestReceived(tenant string, query *Query, err error) ([]*Metrics, error)
+}
+
+// Tenancy resolves the tenants of the Carbon connections and the Render requests, and separates the metric names of the tenants.
+// The tenants are resolved from the Carbon listener ports, the common names of the TLS client certificates,
+// the principals of the Render authenticators and the HTTP header in this order, and the unresolved ones are the default tenant.
+// The connections and requests are rejected when no tenant is resolved.
+type Tenancy struct {
+	sync.RWMutex
+	mode               TenantMode
+	portTenants        map[int]string
+	principalTenants   map[string]string
+	certificateEnabled bool
+	header             string
+	defaultTenant      string
+}
+
+// NewTenancy returns a new tenancy of the specified mode which has no rules to resolve the tenants.
+func NewTenancy(mode TenantMode) *Tenancy {
+	tenancy := &Tenancy{
+		RWMutex:            sync.RWMutex{},
+		mode:               mode,
+		portTenants:        map[int]string{},
+		principalTenants:   map[string]string{},
+		certificateEnabled: false,
+		header:             "",
+		defaultTenant:      "",
+	}
+	return tenancy
+}
+
+// IsValidTenant returns true when the specified tenant consists of the alphanumerics, '_' and '-'.
+func IsValidTenant(tenant string) bool {
+	return tenantNameRegexp.MatchString(tenant)
+}
+
+// GetMode returns the mode of the tenancy.
+func (tenancy *Tenancy) GetMode() TenantMode {
+	return tenancy.mode
+}
+
+// SetPortTenant sets the tenant of the specified Carbon listener port, and Carbon listens on the port in addition to the default port.
+func (tenancy *Tenancy) SetPortTenant(port int, tenant string) error {
+	if !IsValidTenant(tenant) {
+		return fmt.Errorf(errorInvalidTenant, tenant)
+	}
+	tenancy.Lock()
+	defer tenancy.Unlock()
+	tenancy.portTenants[port] = tenant
+	return nil
+}
+
+// GetPortTenants returns the tenants of the Carbon listener ports.
+func (tenancy *Tenancy) GetPortTenants() map[int]string {
+	tenancy.RLock()
+	defer tenancy.RUnlock()
+	ports := map[int]string{}
+	for port, tenant := range tenancy.portTenants {
+		ports[port] = tenant
+	}
+	return ports
+}
+
+// SetPrincipalTenant sets the tenant of the specified principal which is authenticated by Render such as a user or a token.
+func (tenancy *Tenancy) SetPrincipalTenant(principal string, tenant string) error {
+	if !IsValidTenant(tenant) {
+		return fmt.Errorf(errorInvalidTenant, tenant)
+	}
+	tenancy.Lock()
+	defer tenancy.Unlock()
+	tenancy.principalTenants[principal] = tenant
+	return nil
+}
+
+// SetCertificateEnabled sets whether the common names of the TLS client certificates are used as the tenants.
+func (tenancy *Tenancy) SetCertificateEnabled(flag bool) {
+	tenancy.Lock()
+	defer tenancy.Unlock()
+	tenancy.certificateEnabled = flag
+}
+
+// SetHeader sets the HTTP header of the tenants of the Render requests such as DefaultTenantHeader.
+// The header should be set only by the trusted proxies in front of Render, and the empty header disables it.
+func (tenancy *Tenancy) SetHeader(header string) {
+	tenancy.Lock()
+	defer tenancy.Unlock()
+	tenancy.header = header
+}
+
+// SetDefaultTenant sets the tenant of the connections and requests which have no other tenants. The empty tenant rejects them.
+func (tenancy *Tenancy) SetDefaultTenant(tenant string) error {
+	if 0 < len(tenant) && !IsValidTenant(tenant) {
+		return fmt.Errorf(errorInvalidTenant, tenant)
+	}
+	tenancy.Lock()
+	defer tenancy.Unlock()
+	tenancy.defaultTenant = tenant
+	return nil
+}
+
+// certificateTenant returns the common name of the client certificate of the specified connection state.
+func (tenancy *Tenancy) certificateTenant(state *tls.ConnectionState) (string, bool) {
+	if !tenancy.certificateEnabled || state == nil || len(state.PeerCertificates) == 0 {
+		return "", false
+	}
+	cn := state.PeerCertificates[0].Subject.CommonName
+	return cn, IsValidTenant(cn)
+}
+
+// ConnTenant returns the tenant of the specified Carbon connection.
+func (tenancy *Tenancy) ConnTenant(conn net.Conn) (string, bool) {
+	tenancy.RLock()
+	defer tenancy.RUnlock()
+
+	addr, ok := conn.LocalAddr().(*net.TCPAddr)
+	if ok {
+		tenant, ok := tenancy.portTenants[addr.Port]
+		if ok {
+			return tenant, true
+		}
+	}
+
+	tlsConn, ok := conn.(*tls.Conn)
+	if ok {
+		state := tlsConn.ConnectionState()
+		tenant, ok := tenancy.certificateTenant(&state)
+		if ok {
+			return tenant, true
+		}
+	}
+
+	return tenancy.defaultTenant, 0 < len(tenancy.defaultTenant)
+}
+
+// RequestTenant returns the tenant of the specified Render request.
+func (tenancy *Tenancy) RequestTenant(r *http.Request) (string, bool) {
+	tenancy.RLock()
+	defer tenancy.RUnlock()
+
+	tenant, ok := tenancy.certificateTenant(r.TLS)
+	if ok {
+		return tenant, true
+	}
+
+	principal, ok := PrincipalFromRequest(r)
+	if ok {
+		tenant, ok := tenancy.principalTenants[principal]
+		if ok {
+			return tenant, true
+		}
+	}
+
+	if 0 < len(tenancy.header) {
+		tenant := r.Header.Get(tenancy.header)
+		if IsValidTenant(tenant) {
+			return tenant, true
+		}
+	}
+
+	return tenancy.defaultTenant, 0 < len(tenancy.defaultTenant)
+}
+
+// TenantName returns the metric name of the specified tenant for the listeners.
+func (tenancy *Tenancy) TenantName(tenant string, name string) string {
+	if tenancy.mode != TenantPrefix {
+		return name
+	}
+	return tenant + tenantNameSep + name
+}
+
+// StripTenantName returns the metric name of the specified tenant for the clients, and false when the name isn't of the tenant.
+func (tenancy *Tenancy) StripTenantName(tenant string, name string) (string, bool) {
+	if tenancy.mode != TenantPrefix {
+		return name, true
+	}
+	prefix := tenant + tenantNameSep
+	if !strings.HasPrefix(name, prefix) {
+		return "", false
+	}
+	return name[len(prefix):], true
+}
+
+// TenantMetrics returns the metrics whose names are of the specified tenant for the listeners.
+func (tenancy *Tenancy) TenantMetrics(tenant string, ms []*Metrics) []*Metrics {
+	if tenancy.mode != TenantPrefix {
+		return ms
+	}
+	for _, m := range ms {
+		m.Name = tenancy.TenantName(tenant, m.Name)
+	}
+	return ms
+}
+
+// StripTenantMetrics returns the metrics of the specified tenant whose names are for the clients, and drops the metrics of the other tenants.
+func (tenancy *Tenancy) StripTenantMetrics(tenant string, ms []*Metrics) []*Metrics {
+	if tenancy.mode != TenantPrefix {
+		return ms
+	}
+	stripped := make([]*Metrics, 0, len(ms))
+	for _, m := range ms {
+		if m == nil {
+			continue
+		}
+		name, ok := tenancy.StripTenantName(tenant, m.Name)
+		if !ok {
+			continue
+		}
+		m.Name = name
+		stripped = append(stripped, m)
+	}
+	return stripped
+}
+
+// TenantTarget returns the query target of the specified tenant for the listeners, which is a path pattern or a tag query.
+// The targets which have the functions aren't supported with the prefixes, and the brace patterns such as 'a.{b,c}.d' are path patterns.
+func (tenancy *Tenancy) TenantTarget(tenant string, target string) (string, error) {
+	if tenancy.mode != TenantPrefix {
+		return target, nil
+	}
+
+	if !IsTagQuery(target) {
+		if strings.ContainsAny(target, "('\"") {
+			return "", fmt.Errorf(errorUnsupportedTenantTarget, target)
+		}
+		return tenancy.TenantName(tenant, target), nil
+	}
+
+	q, err := ParseTagQuery(target)
+	if err != nil {
+		return "", err
+	}
+	prefix := tenancy.TenantName(tenant, "")
+	exprs := []*TagExpression{}
+	hasName := false
+	for _, expr := range q.Expressions {
+		if expr.Key != TagNameKey {
+			exprs = append(exprs, expr)
+			continue
+		}
+		value := prefix + expr.Value
+		if expr.Operator == TagMatch || expr.Operator == TagNotMatch {
+			value = regexp.QuoteMeta(prefix) + "(?:" + expr.Value + ")"
+		}
+		nameExpr, err := NewTagExpression(expr.Key, expr.Operator, value)
+		if err != nil {
+			return "", err
+		}
+		exprs = append(exprs, nameExpr)
+		hasName = hasName || expr.Operator == TagEqual || expr.Operator == TagMatch
+	}
+	if !hasName {
+		// The queries which have no positive name expressions are limited to the names of the tenant.
+		nameExpr, err := NewTagExpression(TagNameKey, TagMatch, regexp.QuoteMeta(prefix))
+		if err != nil {
+			return "", err
+		}
+		exprs = append(exprs, nameExpr)
+	}
+
+	return NewTagQuery(exprs...).String(), nil
+}
+
+type tenantKey struct{}
+
+// WithTenant returns a copy of the specified request which carries the tenant.
+func WithTenant(r *http.Request, tenant string) *http.Request {
+	return r.WithContext(WithTenantContext(r.Context(), tenant))
+}
+
+// WithTenantContext returns a copy of the specified context which carries the tenant,
+// so the listeners which wrap the context-aware listeners can also pass the tenants to them.
+func WithTenantContext(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFromRequest returns the tenant which is resolved by Render, so the extra HTTP request listeners can also use it.
+func TenantFromRequest(r *http.Request) (string, bool) {
+	return TenantFromContext(r.Context())
+}
+
+// TenantFromContext returns the tenant which is passed to the context-aware listeners.
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tenant, ok := ctx.Value(tenantKey{}).(string)
+	return tenant, ok
+}
@@ -0,0 +1,268 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package graphite
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	tenantTestCarbonPort = 12004
+	tenantTestTenantPort = 12005
+)
+
+type testTenantListener struct {
+	sync.Mutex
+	tenants map[string][]string
+	queries map[string]string
+}
+
+func newTestTenantListener() *testTenantListener {
+	return &testTenantListener{
+		tenants: map[string][]string{},
+		queries: map[string]string{},
+	}
+}
+
+func (l *testTenantListener) InsertMetricsRequestReceived(ms []*Metrics, err error) {
+	l.InsertTenantMetricsRequestReceived("", ms, err)
+}
+
+func (l *testTenantListener) InsertTenantMetricsRequestReceived(tenant string, ms []*Metrics, err error) {
+	l.Lock()
+	defer l.Unlock()
+	for _, m := range ms {
+		l.tenants[tenant] = append(l.tenants[tenant], m.Name)
+	}
+}
+
+func (l *testTenantListener) names(tenant string) []string {
+	l.Lock()
+	defer l.Unlock()
+	return l.tenants[tenant]
+}
+
+func (l *testTenantListener) FindMetricsRequestReceived(query *Query, err error) ([]*Metrics, error) {
+	return l.FindTenantMetricsRequestReceived("", query, err)
+}
+
+func (l *testTenantListener) QueryMetricsRequestReceived(query *Query, err error) ([]*Metrics, error) {
+	return l.QueryTenantMetricsRequestReceived("", query, err)
+}
+
+func (l *testTenantListener) FindTenantMetricsRequestReceived(tenant string, query *Query, err error) ([]*Metrics, error) {
+	return l.QueryTenantMetricsRequestReceived(tenant, query, err)
+}
+
+func (l *testTenantListener) QueryTenantMetricsRequestReceived(tenant string, query *Query, err error) ([]*Metrics, error) {
+	l.Lock()
+	defer l.Unlock()
+	l.queries[tenant] = query.Target
+	ms := []*Metrics{}
+	for _, name := range l.tenants[tenant] {
+		m := NewMetrics()
+		m.SetName(name)
+		ms = append(ms, m)
+	}
+	return ms, nil
+}
+
+func TestTenancyTarget(t *testing.T) {
+	tenancy := NewTenancy(TenantPrefix)
+
+	testCases := []struct {
+		target   string
+		expected string
+	}{
+		{"servers.*.cpu", "team-a.servers.*.cpu"},
+		{"servers.{web01,web02}.cpu", "team-a.servers.{web01,web02}.cpu"},
+		{"seriesByTag('name=cpu','host=web01')", "seriesByTag('name=team-a.cpu','host=web01')"},
+		{"seriesByTag('name=~cpu.*')", "seriesByTag('name=~team-a\\.(?:cpu.*)')"},
+		{"seriesByTag('host=web01')", "seriesByTag('host=web01','name=~team-a\\.')"},
+	}
+	for _, tc := range testCases {
+		target, err := tenancy.TenantTarget("team-a", tc.target)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if target != tc.expected {
+			t.Error(fmt.Errorf("%s != %s", target, tc.expected))
+		}
+	}
+
+	_, err := tenancy.TenantTarget("team-a", "sumSeries(servers.*.cpu)")
+	if err == nil {
+		t.Error(fmt.Errorf("function target is prefixed"))
+	}
+
+	q, _ := ParseTagQuery("seriesByTag('host=web01','name=~team-a\\.')")
+	if !q.Match("team-a.cpu;host=web01") || q.Match("team-b.cpu;host=web01") {
+		t.Error(fmt.Errorf("%s matches other tenants", q.String()))
+	}
+
+	name, ok := tenancy.StripTenantName("team-a", "team-a.cpu;host=web01")
+	if !ok || name != "cpu;host=web01" {
+		t.Error(fmt.Errorf("%s != %s", name, "cpu;host=web01"))
+	}
+	_, ok = tenancy.StripTenantName("team-a", "team-b.cpu")
+	if ok {
+		t.Error(fmt.Errorf("other tenant is stripped"))
+	}
+}
+
+func TestTenancyRequestTenant(t *testing.T) {
+	tenancy := NewTenancy(TenantPrefix)
+	tenancy.SetHeader(DefaultTenantHeader)
+	tenancy.SetPrincipalTenant("alice", "team-a")
+
+	req := httptest.NewRequest(http.MethodGet, "/render", nil)
+	_, ok := tenancy.RequestTenant(req)
+	if ok {
+		t.Error(fmt.Errorf("request without tenant is resolved"))
+	}
+
+	req.Header.Set(DefaultTenantHeader, "team-b")
+	tenant, _ := tenancy.RequestTenant(req)
+	if tenant != "team-b" {
+		t.Error(fmt.Errorf("%s != %s", tenant, "team-b"))
+	}
+
+	// The principals take precedence over the header.
+	tenant, _ = tenancy.RequestTenant(WithPrincipal(req, "alice"))
+	if tenant != "team-a" {
+		t.Error(fmt.Errorf("%s != %s", tenant, "team-a"))
+	}
+
+	req.Header.Set(DefaultTenantHeader, "team.b")
+	tenancy.SetDefaultTenant("public")
+	tenant, _ = tenancy.RequestTenant(req)
+	if tenant != "public" {
+		t.Error(fmt.Errorf("%s != %s", tenant, "public"))
+	}
+}
+
+func TestRenderTenancy(t *testing.T) {
+	tenancy := NewTenancy(TenantPrefix)
+	tenancy.SetHeader(DefaultTenantHeader)
+
+	listener := newTestTenantListener()
+	server := NewServer()
+	server.SetCarbonListener(listener)
+	server.SetMetricIndex(NewMetricIndex())
+	server.SetTenancy(tenancy)
+
+	serve := func(method string, path string, tenant string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		if 0 < len(tenant) {
+			req.Header.Set(DefaultTenantHeader, tenant)
+		}
+		res := httptest.NewRecorder()
+		server.ServeHTTP(res, req)
+		return res
+	}
+
+	res := serve(http.MethodGet, renderDefaultIndexRequestPath, "", "")
+	if res.Code != http.StatusForbidden {
+		t.Error(fmt.Errorf("%d != %d", res.Code, http.StatusForbidden))
+	}
+
+	serve(http.MethodPost, renderDefaultIngestRequestPath, "team-a", "servers.web01.cpu 1 1500000000\n")
+	serve(http.MethodPost, renderDefaultIngestRequestPath, "team-b", "servers.web02.cpu 1 1500000000\n")
+
+	if names := listener.names("team-a"); !reflect.DeepEqual(names, []string{"team-a.servers.web01.cpu"}) {
+		t.Error(fmt.Errorf("%v", names))
+	}
+
+	res = serve(http.MethodGet, renderDefaultIndexRequestPath, "team-a", "")
+	names := []string{}
+	json.Unmarshal(res.Body.Bytes(), &names)
+	if !reflect.DeepEqual(names, []string{"servers.web01.cpu"}) {
+		t.Error(fmt.Errorf("%v", names))
+	}
+
+	res = serve(http.MethodGet, renderDefaultFindRequestPath+"?query=servers.*", "team-b", "")
+	find := renderFindMetricJSONResponse{}
+	json.Unmarshal(res.Body.Bytes(), &find)
+	if len(find.Metrics) != 1 || find.Metrics[0].Path != "servers.web02" {
+		t.Error(fmt.Errorf("%v", find.Metrics))
+	}
+}
+
+func TestRenderTenancyIsolate(t *testing.T) {
+	tenancy := NewTenancy(TenantIsolate)
+	tenancy.SetHeader(DefaultTenantHeader)
+
+	listener := newTestTenantListener()
+	render := NewRender()
+	render.SetIngestListener(listener)
+	render.SetRenderListener(listener)
+	render.SetMetricIndex(NewMetricIndex())
+	render.SetTenancy(tenancy)
+
+	for _, tenant := range []string{"team-a", "team-b"} {
+		req := httptest.NewRequest(http.MethodPost, renderDefaultIngestRequestPath, bytes.NewBufferString(tenant+".cpu 1 1500000000\n"))
+		req.Header.Set(DefaultTenantHeader, tenant)
+		render.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, renderDefaultIndexRequestPath, nil)
+	req.Header.Set(DefaultTenantHeader, "team-a")
+	res := httptest.NewRecorder()
+	render.ServeHTTP(res, req)
+	if !bytes.Contains(res.Body.Bytes(), []byte("team-a.cpu")) || bytes.Contains(res.Body.Bytes(), []byte("team-b.cpu")) {
+		t.Error(fmt.Errorf("%s", res.Body.String()))
+	}
+	if listener.queries["team-a"] != renderMetricsAsterisk {
+		t.Error(fmt.Errorf("%s != %s", listener.queries["team-a"], renderMetricsAsterisk))
+	}
+}
+
+func TestCarbonTenantPort(t *testing.T) {
+	tenancy := NewTenancy(TenantPrefix)
+	tenancy.SetPortTenant(tenantTestTenantPort, "team-a")
+
+	listener := newTestTenantListener()
+	carbon := NewCarbon()
+	carbon.SetPort(tenantTestCarbonPort)
+	carbon.SetCarbonListener(listener)
+	carbon.SetTenancy(tenancy)
+	err := carbon.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer carbon.Stop()
+
+	cli := NewClient()
+	for _, port := range []int{tenantTestTenantPort, tenantTestCarbonPort} {
+		cli.SetCarbonPort(port)
+		err = cli.FeedString("servers.web01.cpu 1 1500000000\n")
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	// The connections of the default port are rejected without the default tenant.
+	for range 50 {
+		if 0 < len(listener.names("team-a")) {
+			break
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	if names := listener.names("team-a"); !reflect.DeepEqual(names, []string{"team-a.servers.web01.cpu"}) {
+		t.Error(fmt.Errorf("%v", names))
+	}
+	if names := listener.names(""); len(names) != 0 {
+		t.Error(fmt.Errorf("%v", names))
+	}
+}
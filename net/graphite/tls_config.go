@@ -0,0 +1,205 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package graphite
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	// DefaultTLSMinVersion is the default minimum TLS version of Carbon and Render.
+	DefaultTLSMinVersion = "1.2"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// TLSConfig represents a TLS configuration of Carbon and Render.
+// The certificate files are loaded whenever the listeners are opened, and Server::ReloadTLS() reloads the renewed certificates without closing the listeners.
+type TLSConfig struct {
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string
+	MinVersion   string
+	CipherSuites []string
+}
+
+// NewTLSConfig returns a new TLS configuration of the specified certificate and key files.
+func NewTLSConfig(certFile string, keyFile string) *TLSConfig {
+	conf := &TLSConfig{
+		CertFile:     certFile,
+		KeyFile:      keyFile,
+		ClientCAFile: "",
+		MinVersion:   DefaultTLSMinVersion,
+		CipherSuites: []string{},
+	}
+	return conf
+}
+
+// SetClientCAFile sets the CA file to verify the client certificates for the mutual TLS.
+func (conf *TLSConfig) SetClientCAFile(file string) {
+	conf.ClientCAFile = file
+}
+
+// SetMinVersion sets the minimum TLS version such as '1.2' and '1.3'.
+func (conf *TLSConfig) SetMinVersion(version string) {
+	conf.MinVersion = version
+}
+
+// SetCipherSuites sets the cipher suite names such as 'TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256' of TLS 1.2 and earlier.
+// The cipher suites of TLS 1.3 aren't configurable.
+func (conf *TLSConfig) SetCipherSuites(names []string) {
+	conf.CipherSuites = names
+}
+
+// IsEnabled returns true when the configuration has the certificate and key files.
+func (conf *TLSConfig) IsEnabled() bool {
+	return conf != nil && 0 < len(conf.CertFile) && 0 < len(conf.KeyFile)
+}
+
+// IsMutual returns true when the client certificates are verified.
+func (conf *TLSConfig) IsMutual() bool {
+	return conf.IsEnabled() && 0 < len(conf.ClientCAFile)
+}
+
+// ParseTLSVersion returns the TLS version of the specified string such as '1.2'.
+func ParseTLSVersion(version string) (uint16, error) {
+	v, ok := tlsVersions[strings.TrimPrefix(strings.ToUpper(version), "TLS")]
+	if !ok {
+		return 0, fmt.Errorf(errorInvalidTLSVersion, version)
+	}
+	return v, nil
+}
+
+// ParseCipherSuites returns the IDs of the specified cipher suite names.
+// The insecure cipher suites of the crypto/tls package aren't accepted.
+func ParseCipherSuites(names []string) ([]uint16, error) {
+	suites := map[string]uint16{}
+	for _, suite := range tls.CipherSuites() {
+		suites[suite.Name] = suite.ID
+	}
+	ids := []uint16{}
+	for _, name := range names {
+		id, ok := suites[name]
+		if !ok {
+			return nil, fmt.Errorf(errorInvalidTLSCipherSuite, name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// LoadCertPool returns a certificate pool of the specified PEM file.
+func LoadCertPool(file string) (*x509.CertPool, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, fmt.Errorf(errorInvalidTLSCertificate, file)
+	}
+	return pool, nil
+}
+
+// ServerConfig loads the certificate files, and returns a new server configuration of the crypto/tls package.
+func (conf *TLSConfig) ServerConfig() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(conf.CertFile, conf.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConf := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if 0 < len(conf.MinVersion) {
+		tlsConf.MinVersion, err = ParseTLSVersion(conf.MinVersion)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if 0 < len(conf.CipherSuites) {
+		tlsConf.CipherSuites, err = ParseCipherSuites(conf.CipherSuites)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if conf.IsMutual() {
+		tlsConf.ClientCAs, err = LoadCertPool(conf.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConf.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return tlsConf, nil
+}
+
+// tlsServerConfig is a server configuration of the listeners whose certificates can be replaced while the listeners are open.
+type tlsServerConfig struct {
+	conf atomic.Pointer[tls.Config]
+}
+
+// newTLSServerConfig returns a new server configuration which starts with the specified configuration.
+func newTLSServerConfig(conf *tls.Config) *tlsServerConfig {
+	serverConf := &tlsServerConfig{}
+	serverConf.conf.Store(conf)
+	return serverConf
+}
+
+// Store replaces the configuration of the new connections.
+func (serverConf *tlsServerConfig) Store(conf *tls.Config) {
+	serverConf.conf.Store(conf)
+}
+
+// ListenerConfig returns a configuration of the listeners which uses the current configuration for each new connection.
+func (serverConf *tlsServerConfig) ListenerConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: serverConf.conf.Load().MinVersion,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return serverConf.conf.Load(), nil
+		},
+	}
+}
+
+// NewClientTLSConfig returns a new client configuration of the crypto/tls package to connect to Carbon and Render.
+// The CA file verifies the server certificates instead of the system roots, and the certificate and key files are sent for the mutual TLS.
+// The empty files are ignored.
+func NewClientTLSConfig(caFile string, certFile string, keyFile string) (*tls.Config, error) {
+	tlsConf := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if 0 < len(caFile) {
+		pool, err := LoadCertPool(caFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConf.RootCAs = pool
+	}
+
+	if 0 < len(certFile) && 0 < len(keyFile) {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConf.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConf, nil
+}
@@ -0,0 +1,252 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package graphite
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+const (
+	tlsTestCarbonPort = 12003
+	tlsTestRenderPort = 18080
+)
+
+// writeTestCertificate writes a certificate and key files which are signed by the specified parent, or self-signed without it.
+func writeTestCertificate(t *testing.T, dir string, name string, isCA bool, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("::1")},
+	}
+	if parent == nil {
+		parent = tmpl
+		parentKey = key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile := filepath.Join(dir, name+".crt")
+	keyFile := filepath.Join(dir, name+".key")
+	err = os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert, key, certFile, keyFile
+}
+
+func TestParseTLSVersion(t *testing.T) {
+	testCases := []struct {
+		version  string
+		expected uint16
+	}{
+		{"1.2", tls.VersionTLS12},
+		{"1.3", tls.VersionTLS13},
+		{"TLS1.2", tls.VersionTLS12},
+	}
+	for _, tc := range testCases {
+		v, err := ParseTLSVersion(tc.version)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if v != tc.expected {
+			t.Error(fmt.Errorf("%s : %d != %d", tc.version, v, tc.expected))
+		}
+	}
+
+	_, err := ParseTLSVersion("2.0")
+	if err == nil {
+		t.Error(fmt.Errorf("invalid version is parsed"))
+	}
+
+	_, err = ParseCipherSuites([]string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"})
+	if err != nil {
+		t.Error(err)
+	}
+	_, err = ParseCipherSuites([]string{"TLS_RSA_WITH_RC4_128_SHA"})
+	if err == nil {
+		t.Error(fmt.Errorf("insecure cipher suite is parsed"))
+	}
+}
+
+func TestServerMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey, caFile, _ := writeTestCertificate(t, dir, "ca", true, nil, nil)
+	_, _, serverCert, serverKey := writeTestCertificate(t, dir, "server", false, ca, caKey)
+	_, _, clientCert, clientKey := writeTestCertificate(t, dir, "client", false, ca, caKey)
+
+	tlsConf := NewTLSConfig(serverCert, serverKey)
+	tlsConf.SetClientCAFile(caFile)
+	tlsConf.SetMinVersion("1.2")
+
+	conf := NewDefaultConfig()
+	conf.SetCarbonPort(tlsTestCarbonPort)
+	conf.SetRenderPort(tlsTestRenderPort)
+	conf.SetTLSConfig(tlsConf)
+
+	server := newTestServer()
+	server.SetConfig(conf)
+	render := NewTestRender()
+	server.SetRenderListener(render)
+	err := server.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Stop()
+
+	cli := NewClient()
+	cli.SetCarbonPort(tlsTestCarbonPort)
+	cli.SetRenderPort(tlsTestRenderPort)
+	cli.SetTimeout(time.Second * 5)
+
+	// The clients without the certificates are rejected.
+	cliConf, err := NewClientTLSConfig(caFile, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cli.SetTLSConfig(cliConf)
+	q := NewQuery()
+	q.Target = "path"
+	_, err = cli.QueryRender(q)
+	if err == nil {
+		t.Error(fmt.Errorf("client without certificate is accepted"))
+	}
+
+	cliConf, err = NewClientTLSConfig(caFile, clientCert, clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cli.SetTLSConfig(cliConf)
+
+	m := NewMetrics()
+	m.SetName("path")
+	dp := NewDataPoint()
+	dp.SetTimestamp(time.Now())
+	dp.SetValue(1)
+	m.AddDataPoint(dp)
+	err = cli.FeedMetrics(m)
+	if err != nil {
+		t.Error(err)
+	}
+
+	_, err = cli.QueryRender(q)
+	if err != nil {
+		t.Error(err)
+	}
+	if render.QueryCount != 1 {
+		t.Error(fmt.Errorf("%d != %d", render.QueryCount, 1))
+	}
+
+	// The renewed certificates are reloaded, and the invalid ones are rejected without restarting.
+	renewed, _, serverCert, serverKey := writeTestCertificate(t, dir, "server", false, ca, caKey)
+	err = server.ReloadTLS()
+	if err != nil {
+		t.Error(err)
+	}
+	_, err = cli.QueryRender(q)
+	if err != nil {
+		t.Error(err)
+	}
+
+	// Carbon keeps receiving the metrics after reloading, and the new connections get the renewed certificate.
+	conn, err := tls.Dial("tcp", fmt.Sprintf("localhost:%d", tlsTestCarbonPort), cliConf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	peerCerts := conn.ConnectionState().PeerCertificates
+	conn.Close()
+	if len(peerCerts) == 0 || peerCerts[0].SerialNumber.Cmp(renewed.SerialNumber) != 0 {
+		t.Error(fmt.Errorf("certificate isn't renewed"))
+	}
+	err = cli.FeedMetrics(m)
+	if err != nil {
+		t.Error(err)
+	}
+	for range 50 {
+		if server.MetricsCount == 2 {
+			break
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	if server.MetricsCount != 2 {
+		t.Error(fmt.Errorf("%d != %d", server.MetricsCount, 2))
+	}
+
+	err = os.WriteFile(serverCert, []byte("invalid"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = server.ReloadTLS()
+	if err == nil {
+		t.Error(fmt.Errorf("invalid certificate is reloaded"))
+	}
+	_, err = cli.QueryRender(q)
+	if err != nil {
+		t.Error(err)
+	}
+
+	// The reload errors on SIGHUP are passed to the function.
+	reloadErrs := make(chan error, 1)
+	server.StartReloadSignal(func(err error) {
+		reloadErrs <- err
+	})
+	defer server.StopReloadSignal()
+	err = syscall.Kill(os.Getpid(), syscall.SIGHUP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-reloadErrs:
+		if err == nil {
+			t.Error(fmt.Errorf("reload error is nil"))
+		}
+	case <-time.After(time.Second * 5):
+		t.Error(fmt.Errorf("reload error isn't passed"))
+	}
+}
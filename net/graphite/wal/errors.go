@@ -0,0 +1,14 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package wal
+
+const (
+	errorInvalidRecordLength = "invalid record length : %d"
+	errorInvalidRecordCRC    = "invalid record CRC : %08x != %08x"
+	errorInvalidRecordData   = "invalid record data : %s"
+	errorInvalidSegmentName  = "invalid segment name : %s"
+	errorInvalidSyncPolicy   = "invalid sync policy : %s"
+	errorNotOpened           = "write-ahead log is not opened : %s"
+)
@@ -0,0 +1,194 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package wal
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"time"
+
+	"github.com/cybergarage/go-graphite/net/graphite"
+)
+
+const (
+	// recordHeaderSize is the size of the record header which has the payload length and the CRC32C of the payload.
+	recordHeaderSize = 8
+	// MaxRecordSize is the maximum payload size of a record.
+	MaxRecordSize = 64 * 1024 * 1024
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// errRecordCorrupted is returned when a record is torn or its checksum doesn't match.
+var errRecordCorrupted = errors.New("record corrupted")
+
+// encodeRecord returns a framed record of the specified metrics of the tenant, and the empty tenant represents the metrics without tenants.
+// The record is laid out as the following, and all integers in the payload are varints:
+//
+//	uint32 payload length (big endian)
+//	uint32 CRC32C of the payload (big endian)
+//	payload : tenant length, tenant, metrics count, { name length, name, datapoint count, { timestamp (ns), value (float64 bits) } }
+func encodeRecord(tenant string, ms []*graphite.Metrics) []byte {
+	valid := make([]*graphite.Metrics, 0, len(ms))
+	for _, m := range ms {
+		if m != nil {
+			valid = append(valid, m)
+		}
+	}
+
+	buf := make([]byte, recordHeaderSize, recordHeaderSize+len(tenant)+(len(valid)*64))
+	buf = binary.AppendUvarint(buf, uint64(len(tenant)))
+	buf = append(buf, tenant...)
+	buf = binary.AppendUvarint(buf, uint64(len(valid)))
+	for _, m := range valid {
+		dps := make([]*graphite.DataPoint, 0, len(m.DataPoints))
+		for _, dp := range m.DataPoints {
+			if dp != nil {
+				dps = append(dps, dp)
+			}
+		}
+		buf = binary.AppendUvarint(buf, uint64(len(m.Name)))
+		buf = append(buf, m.Name...)
+		buf = binary.AppendUvarint(buf, uint64(len(dps)))
+		for _, dp := range dps {
+			buf = binary.AppendVarint(buf, dp.Timestamp.UnixNano())
+			buf = binary.BigEndian.AppendUint64(buf, math.Float64bits(dp.Value))
+		}
+	}
+	payload := buf[recordHeaderSize:]
+	binary.BigEndian.PutUint32(buf[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(buf[4:8], crc32.Checksum(payload, crcTable))
+	return buf
+}
+
+// readRecord reads a framed record from the specified reader, and returns the tenant, the metrics and the read size.
+// readRecord returns io.EOF at the clean end of the segment, and an error wrapping errRecordCorrupted for torn or broken records.
+func readRecord(r io.Reader) (string, []*graphite.Metrics, int64, error) {
+	header := make([]byte, recordHeaderSize)
+	n, err := io.ReadFull(r, header)
+	if err != nil {
+		if n == 0 && errors.Is(err, io.EOF) {
+			return "", nil, 0, io.EOF
+		}
+		return "", nil, 0, fmt.Errorf("%w : %s", errRecordCorrupted, err)
+	}
+
+	length := binary.BigEndian.Uint32(header[0:4])
+	if length == 0 || MaxRecordSize < length {
+		return "", nil, 0, fmt.Errorf("%w : "+errorInvalidRecordLength, errRecordCorrupted, length)
+	}
+
+	payload := make([]byte, length)
+	_, err = io.ReadFull(r, payload)
+	if err != nil {
+		return "", nil, 0, fmt.Errorf("%w : %s", errRecordCorrupted, err)
+	}
+
+	expected := binary.BigEndian.Uint32(header[4:8])
+	actual := crc32.Checksum(payload, crcTable)
+	if expected != actual {
+		return "", nil, 0, fmt.Errorf("%w : "+errorInvalidRecordCRC, errRecordCorrupted, actual, expected)
+	}
+
+	tenant, ms, err := decodePayload(payload)
+	if err != nil {
+		return "", nil, 0, fmt.Errorf("%w : %s", errRecordCorrupted, err)
+	}
+
+	return tenant, ms, int64(recordHeaderSize + length), nil
+}
+
+type payloadDecoder struct {
+	buf []byte
+	err error
+}
+
+func (d *payloadDecoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.buf)
+	if n <= 0 {
+		d.err = fmt.Errorf(errorInvalidRecordData, "uvarint")
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *payloadDecoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.buf)
+	if n <= 0 {
+		d.err = fmt.Errorf(errorInvalidRecordData, "varint")
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *payloadDecoder) bytes(n uint64) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if uint64(len(d.buf)) < n {
+		d.err = fmt.Errorf(errorInvalidRecordData, "short buffer")
+		return nil
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func decodePayload(payload []byte) (string, []*graphite.Metrics, error) {
+	d := &payloadDecoder{buf: payload, err: nil}
+
+	tenant := string(d.bytes(d.uvarint()))
+	count := d.uvarint()
+	// Each metrics has at least two bytes, so the count can't exceed the payload size.
+	if uint64(len(payload)) < count {
+		return "", nil, fmt.Errorf(errorInvalidRecordData, "metrics count")
+	}
+
+	ms := make([]*graphite.Metrics, 0, count)
+	for range count {
+		m := graphite.NewMetrics()
+		m.SetName(string(d.bytes(d.uvarint())))
+		dpCount := d.uvarint()
+		if uint64(len(d.buf)) < dpCount {
+			return "", nil, fmt.Errorf(errorInvalidRecordData, "datapoint count")
+		}
+		for range dpCount {
+			ts := d.varint()
+			value := d.bytes(8)
+			if d.err != nil {
+				return "", nil, d.err
+			}
+			dp := graphite.NewDataPoint()
+			dp.SetTimestamp(time.Unix(0, ts))
+			dp.SetValue(math.Float64frombits(binary.BigEndian.Uint64(value)))
+			m.AddDataPoint(dp)
+		}
+		if d.err != nil {
+			return "", nil, d.err
+		}
+		ms = append(ms, m)
+	}
+
+	if d.err != nil {
+		return "", nil, d.err
+	}
+	if len(d.buf) != 0 {
+		return "", nil, fmt.Errorf(errorInvalidRecordData, "trailing bytes")
+	}
+
+	return tenant, ms, nil
+}
@@ -0,0 +1,98 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package wal
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cybergarage/go-graphite/net/graphite"
+)
+
+const (
+	// SegmentExt is the file extension of the segment files.
+	SegmentExt = ".wal"
+)
+
+// segmentName returns the file name of the specified segment index such as '00000001.wal'.
+func segmentName(index int) string {
+	return fmt.Sprintf("%08d%s", index, SegmentExt)
+}
+
+// parseSegmentName returns the segment index of the specified file name.
+func parseSegmentName(name string) (int, error) {
+	if !strings.HasSuffix(name, SegmentExt) {
+		return 0, fmt.Errorf(errorInvalidSegmentName, name)
+	}
+	index, err := strconv.Atoi(strings.TrimSuffix(name, SegmentExt))
+	if err != nil || index < 0 {
+		return 0, fmt.Errorf(errorInvalidSegmentName, name)
+	}
+	return index, nil
+}
+
+// listSegments returns the segment indexes in the specified directory in ascending order.
+func listSegments(dir string) ([]int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	indexes := []int{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		index, err := parseSegmentName(entry.Name())
+		if err != nil {
+			continue
+		}
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	return indexes, nil
+}
+
+// replaySegment reads all records of the specified segment file, and passes their tenants and metrics to the specified function.
+// replaySegment returns the size of the valid records, and an error wrapping errRecordCorrupted when the segment has a broken record.
+func replaySegment(path string, fn func(string, []*graphite.Metrics)) (int64, int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+
+	r := bufio.NewReader(file)
+	offset := int64(0)
+	records := 0
+	for {
+		tenant, ms, n, err := readRecord(r)
+		if errors.Is(err, io.EOF) {
+			return offset, records, nil
+		}
+		if err != nil {
+			return offset, records, err
+		}
+		fn(tenant, ms)
+		offset += n
+		records++
+	}
+}
+
+// syncDir flushes the directory entries so that the created or removed segment files survive crashes.
+func syncDir(dir string) error {
+	d, err := os.Open(filepath.Clean(dir))
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,483 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package wal provides a segment-based write-ahead log which wraps any graphite.CarbonListener.
+// The log records all batches of the Carbon requests with their tenants before passing them to the wrapped listener,
+// and replays them into the listener on startup so that the buffered metrics survive crashes.
+package wal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/cybergarage/go-graphite/net/graphite"
+)
+
+// SyncPolicy represents when the written records are flushed to the disk.
+type SyncPolicy int
+
+const (
+	// SyncEveryBatch flushes each batch before passing it to the wrapped listener.
+	SyncEveryBatch SyncPolicy = iota
+	// SyncInterval flushes the written batches periodically.
+	SyncInterval
+	// SyncNever leaves flushing to the operating system.
+	SyncNever
+)
+
+const (
+	// DefaultSyncPolicy is the default sync policy.
+	DefaultSyncPolicy = SyncEveryBatch
+	// DefaultSyncInterval is the default flush interval of SyncInterval.
+	DefaultSyncInterval = time.Second
+	// DefaultSegmentSize is the default size to rotate the segments.
+	DefaultSegmentSize = 64 * 1024 * 1024
+)
+
+var syncPolicyNames = map[SyncPolicy]string{
+	SyncEveryBatch: "batch",
+	SyncInterval:   "interval",
+	SyncNever:      "never",
+}
+
+// ParseSyncPolicy returns the sync policy of the specified name, 'batch', 'interval' or 'never'.
+func ParseSyncPolicy(name string) (SyncPolicy, error) {
+	for policy, policyName := range syncPolicyNames {
+		if policyName == name {
+			return policy, nil
+		}
+	}
+	return 0, fmt.Errorf(errorInvalidSyncPolicy, name)
+}
+
+// String returns the name of the sync policy.
+func (policy SyncPolicy) String() string {
+	name, ok := syncPolicyNames[policy]
+	if !ok {
+		return fmt.Sprintf("unknown(%d)", int(policy))
+	}
+	return name
+}
+
+// ReplayStats represents the result of a replay.
+type ReplayStats struct {
+	Segments          int
+	Records           int
+	Metrics           int
+	CorruptedSegments int
+}
+
+// WAL is a write-ahead log which wraps a graphite.CarbonListener.
+type WAL struct {
+	sync.Mutex
+	dir          string
+	listener     graphite.CarbonListener
+	syncPolicy   SyncPolicy
+	syncInterval time.Duration
+	segmentSize  int64
+
+	// inflight is held for reading while a batch is written and passed to the listener,
+	// so that the checkpoints never remove the batches which the listener hasn't received yet.
+	inflight sync.RWMutex
+
+	segment      *os.File
+	segmentIndex int
+	segmentBytes int64
+	dirty        bool
+	lastErr      error
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewWAL returns a new write-ahead log in the specified directory which wraps the specified listener.
+func NewWAL(dir string, listener graphite.CarbonListener) *WAL {
+	return &WAL{
+		Mutex:        sync.Mutex{},
+		dir:          dir,
+		listener:     listener,
+		syncPolicy:   DefaultSyncPolicy,
+		syncInterval: DefaultSyncInterval,
+		segmentSize:  DefaultSegmentSize,
+		inflight:     sync.RWMutex{},
+		segment:      nil,
+		segmentIndex: 0,
+		segmentBytes: 0,
+		dirty:        false,
+		lastErr:      nil,
+		done:         nil,
+		wg:           sync.WaitGroup{},
+	}
+}
+
+// GetDirectory returns the directory of the segment files.
+func (wal *WAL) GetDirectory() string {
+	return wal.dir
+}
+
+// SetCarbonListener sets the wrapped listener.
+func (wal *WAL) SetCarbonListener(listener graphite.CarbonListener) {
+	wal.Lock()
+	defer wal.Unlock()
+	wal.listener = listener
+}
+
+// SetSyncPolicy sets the sync policy. Set it before Open.
+func (wal *WAL) SetSyncPolicy(policy SyncPolicy) {
+	wal.Lock()
+	defer wal.Unlock()
+	wal.syncPolicy = policy
+}
+
+// GetSyncPolicy returns the sync policy.
+func (wal *WAL) GetSyncPolicy() SyncPolicy {
+	wal.Lock()
+	defer wal.Unlock()
+	return wal.syncPolicy
+}
+
+// SetSyncInterval sets the flush interval of SyncInterval. Set it before Open.
+func (wal *WAL) SetSyncInterval(d time.Duration) {
+	wal.Lock()
+	defer wal.Unlock()
+	wal.syncInterval = d
+}
+
+// SetSegmentSize sets the size to rotate the segments.
+func (wal *WAL) SetSegmentSize(size int64) {
+	wal.Lock()
+	defer wal.Unlock()
+	wal.segmentSize = size
+}
+
+// GetLastError returns the last error which occurred while writing the batches.
+func (wal *WAL) GetLastError() error {
+	wal.Lock()
+	defer wal.Unlock()
+	return wal.lastErr
+}
+
+// Segments returns the indexes of the segment files in the directory.
+func (wal *WAL) Segments() ([]int, error) {
+	return listSegments(wal.dir)
+}
+
+// Open replays all segments in the directory into the wrapped listener, and starts a new segment to write.
+// The segments are read until the first broken record, which is usually the torn write of a crash,
+// and the broken tail is truncated so that the following replays don't report it again.
+func (wal *WAL) Open() (*ReplayStats, error) {
+	err := wal.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.MkdirAll(wal.dir, 0o755)
+	if err != nil {
+		return nil, err
+	}
+
+	stats, last, err := wal.replay()
+	if err != nil {
+		return stats, err
+	}
+
+	wal.Lock()
+	defer wal.Unlock()
+
+	err = wal.openSegment(last + 1)
+	if err != nil {
+		return stats, err
+	}
+
+	if wal.syncPolicy == SyncInterval && 0 < wal.syncInterval {
+		wal.done = make(chan struct{})
+		wal.wg.Add(1)
+		go wal.syncLoop(wal.done, wal.syncInterval)
+	}
+
+	return stats, nil
+}
+
+func (wal *WAL) replay() (*ReplayStats, int, error) {
+	stats := &ReplayStats{
+		Segments:          0,
+		Records:           0,
+		Metrics:           0,
+		CorruptedSegments: 0,
+	}
+
+	indexes, err := listSegments(wal.dir)
+	if err != nil {
+		return stats, 0, err
+	}
+
+	wal.Lock()
+	listener := wal.listener
+	wal.Unlock()
+
+	last := 0
+	for _, index := range indexes {
+		path := filepath.Join(wal.dir, segmentName(index))
+		offset, records, err := replaySegment(path, func(tenant string, ms []*graphite.Metrics) {
+			stats.Metrics += len(ms)
+			if listener != nil {
+				insertListenerMetrics(context.Background(), listener, tenant, ms, nil)
+			}
+		})
+		stats.Segments++
+		stats.Records += records
+		if err != nil {
+			if !errors.Is(err, errRecordCorrupted) {
+				return stats, last, err
+			}
+			stats.CorruptedSegments++
+			err = os.Truncate(path, offset)
+			if err != nil {
+				return stats, last, err
+			}
+		}
+		last = index
+	}
+
+	return stats, last, nil
+}
+
+func (wal *WAL) openSegment(index int) error {
+	path := filepath.Join(wal.dir, segmentName(index))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	err = syncDir(wal.dir)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	wal.segment = file
+	wal.segmentIndex = index
+	wal.segmentBytes = info.Size()
+	wal.dirty = false
+	return nil
+}
+
+func (wal *WAL) closeSegment() error {
+	if wal.segment == nil {
+		return nil
+	}
+	err := wal.segment.Sync()
+	if err != nil {
+		wal.segment.Close()
+		wal.segment = nil
+		return err
+	}
+	err = wal.segment.Close()
+	wal.segment = nil
+	wal.dirty = false
+	return err
+}
+
+// rotate closes the current segment and starts the next one.
+func (wal *WAL) rotate() error {
+	err := wal.closeSegment()
+	if err != nil {
+		return err
+	}
+	return wal.openSegment(wal.segmentIndex + 1)
+}
+
+func (wal *WAL) syncLoop(done chan struct{}, interval time.Duration) {
+	defer wal.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			err := wal.Sync()
+			if err != nil {
+				wal.Lock()
+				wal.lastErr = err
+				wal.Unlock()
+			}
+		}
+	}
+}
+
+// Sync flushes the written records of the current segment to the disk.
+func (wal *WAL) Sync() error {
+	wal.Lock()
+	defer wal.Unlock()
+	if wal.segment == nil || !wal.dirty {
+		return nil
+	}
+	err := wal.segment.Sync()
+	if err != nil {
+		return err
+	}
+	wal.dirty = false
+	return nil
+}
+
+// Append writes the specified metrics as a record into the current segment.
+func (wal *WAL) Append(ms []*graphite.Metrics) error {
+	return wal.AppendTenant("", ms)
+}
+
+// AppendTenant writes the specified metrics of the tenant as a record into the current segment.
+func (wal *WAL) AppendTenant(tenant string, ms []*graphite.Metrics) error {
+	record := encodeRecord(tenant, ms)
+
+	wal.Lock()
+	defer wal.Unlock()
+
+	if wal.segment == nil {
+		return fmt.Errorf(errorNotOpened, wal.dir)
+	}
+
+	if 0 < wal.segmentBytes && wal.segmentSize < wal.segmentBytes+int64(len(record)) {
+		err := wal.rotate()
+		if err != nil {
+			return err
+		}
+	}
+
+	n, err := wal.segment.Write(record)
+	wal.segmentBytes += int64(n)
+	if err != nil {
+		return err
+	}
+	wal.dirty = true
+
+	if wal.syncPolicy == SyncEveryBatch {
+		err = wal.segment.Sync()
+		if err != nil {
+			return err
+		}
+		wal.dirty = false
+	}
+
+	return nil
+}
+
+// Checkpoint removes all written segments, and starts a new segment.
+// Call Checkpoint after the wrapped listener persists all received metrics, such as after a snapshot of an in-memory store,
+// because the removed batches are never replayed again.
+func (wal *WAL) Checkpoint() error {
+	wal.inflight.Lock()
+	defer wal.inflight.Unlock()
+
+	wal.Lock()
+	defer wal.Unlock()
+
+	if wal.segment == nil {
+		return fmt.Errorf(errorNotOpened, wal.dir)
+	}
+
+	err := wal.rotate()
+	if err != nil {
+		return err
+	}
+
+	indexes, err := listSegments(wal.dir)
+	if err != nil {
+		return err
+	}
+	for _, index := range indexes {
+		if wal.segmentIndex <= index {
+			continue
+		}
+		err := os.Remove(filepath.Join(wal.dir, segmentName(index)))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	return syncDir(wal.dir)
+}
+
+// Close flushes and closes the current segment.
+func (wal *WAL) Close() error {
+	wal.Lock()
+	done := wal.done
+	wal.done = nil
+	wal.Unlock()
+
+	if done != nil {
+		close(done)
+		wal.wg.Wait()
+	}
+
+	wal.Lock()
+	defer wal.Unlock()
+	return wal.closeSegment()
+}
+
+// InsertMetricsRequestReceived writes the received metrics into the log, and passes them to the wrapped listener.
+// The metrics are passed to the listener even if the writing fails, and the error is kept as the last error.
+func (wal *WAL) InsertMetricsRequestReceived(ms []*graphite.Metrics, err error) {
+	wal.insertMetrics(context.Background(), "", ms, err)
+}
+
+// InsertTenantMetricsRequestReceived writes the received metrics with the tenant into the log, and passes them to the wrapped listener.
+func (wal *WAL) InsertTenantMetricsRequestReceived(tenant string, ms []*graphite.Metrics, err error) {
+	wal.insertMetrics(context.Background(), tenant, ms, err)
+}
+
+// InsertMetricsRequestReceivedContext writes the received metrics with the tenant of the context into the log,
+// and passes them to the wrapped listener with the context.
+func (wal *WAL) InsertMetricsRequestReceivedContext(ctx context.Context, ms []*graphite.Metrics, err error) {
+	tenant, _ := graphite.TenantFromContext(ctx)
+	wal.insertMetrics(ctx, tenant, ms, err)
+}
+
+func (wal *WAL) insertMetrics(ctx context.Context, tenant string, ms []*graphite.Metrics, err error) {
+	wal.inflight.RLock()
+	defer wal.inflight.RUnlock()
+
+	if err == nil && 0 < len(ms) {
+		appendErr := wal.AppendTenant(tenant, ms)
+		if appendErr != nil {
+			wal.Lock()
+			wal.lastErr = appendErr
+			wal.Unlock()
+		}
+	}
+
+	wal.Lock()
+	listener := wal.listener
+	wal.Unlock()
+
+	if listener != nil {
+		insertListenerMetrics(ctx, listener, tenant, ms, err)
+	}
+}
+
+// insertListenerMetrics calls the most specific method of the specified listener for the metrics of the tenant.
+func insertListenerMetrics(ctx context.Context, listener graphite.CarbonListener, tenant string, ms []*graphite.Metrics, err error) {
+	ctxListener, ok := listener.(graphite.ContextCarbonListener)
+	if ok {
+		if 0 < len(tenant) {
+			ctx = graphite.WithTenantContext(ctx, tenant)
+		}
+		ctxListener.InsertMetricsRequestReceivedContext(ctx, ms, err)
+		return
+	}
+	tenantListener, ok := listener.(graphite.TenantCarbonListener)
+	if ok && 0 < len(tenant) {
+		tenantListener.InsertTenantMetricsRequestReceived(tenant, ms, err)
+		return
+	}
+	listener.InsertMetricsRequestReceived(ms, err)
+}
@@ -0,0 +1,252 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package wal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cybergarage/go-graphite/net/graphite"
+	"github.com/cybergarage/go-graphite/net/graphite/memory"
+)
+
+func newTestMetrics(name string, ts int64, value float64) []*graphite.Metrics {
+	m := graphite.NewMetrics()
+	m.SetName(name)
+	dp := graphite.NewDataPoint()
+	dp.SetTimestamp(time.Unix(ts, 0))
+	dp.SetValue(value)
+	m.AddDataPoint(dp)
+	return []*graphite.Metrics{m}
+}
+
+func TestRecord(t *testing.T) {
+	ms := newTestMetrics("a.b.c", 1500000000, 1.5)
+	record := encodeRecord("team-a", ms)
+
+	tenant, decoded, n, err := readRecord(bytes.NewReader(record))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(record)) {
+		t.Error(fmt.Errorf("%d != %d", n, len(record)))
+	}
+	if tenant != "team-a" {
+		t.Error(fmt.Errorf("%s != %s", tenant, "team-a"))
+	}
+	if len(decoded) != 1 || decoded[0].Name != "a.b.c" || decoded[0].DataPoints[0].Value != 1.5 || decoded[0].DataPoints[0].UnixTimestamp() != 1500000000 {
+		t.Error(fmt.Errorf("%v", decoded))
+	}
+
+	// Torn and broken records
+
+	_, _, _, err = readRecord(bytes.NewReader(record[:len(record)-1]))
+	if !errors.Is(err, errRecordCorrupted) {
+		t.Error(err)
+	}
+
+	broken := append([]byte{}, record...)
+	broken[len(broken)-1] ^= 0xFF
+	_, _, _, err = readRecord(bytes.NewReader(broken))
+	if !errors.Is(err, errRecordCorrupted) {
+		t.Error(err)
+	}
+}
+
+func TestWALReplay(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now().Unix()
+
+	store := memory.NewStore()
+	store.SetResolution(time.Second)
+
+	wal := NewWAL(dir, store)
+	wal.SetSegmentSize(64)
+	_, err := wal.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := graphite.NewServer()
+	server.SetCarbonListener(wal)
+	for n := range 10 {
+		_, err := server.FeedPlainTextString(fmt.Sprintf("servers.host%d.cpu %d %d\n", n, n, now))
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	err = wal.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	segments, err := wal.Segments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segments) < 2 {
+		t.Error(fmt.Errorf("segments are not rotated : %v", segments))
+	}
+
+	// Simulate a torn write at the tail of the last segment
+	last := filepath.Join(dir, segmentName(segments[len(segments)-1]))
+	f, err := os.OpenFile(last, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write(encodeRecord("", newTestMetrics("torn", now, 0))[:12])
+	f.Close()
+
+	// Replay into a new store after the crash
+
+	recovered := memory.NewStore()
+	recovered.SetResolution(time.Second)
+
+	wal = NewWAL(dir, recovered)
+	stats, err := wal.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wal.Close()
+
+	if stats.Records != 10 || stats.Metrics != 10 || stats.CorruptedSegments != 1 {
+		t.Error(fmt.Errorf("%v", stats))
+	}
+	if recovered.GetSeriesCount() != 10 {
+		t.Error(fmt.Errorf("%d != %d", recovered.GetSeriesCount(), 10))
+	}
+
+	// Checkpoint removes the replayed segments
+
+	err = wal.Checkpoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	segments, err = wal.Segments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segments) != 1 {
+		t.Error(fmt.Errorf("%v", segments))
+	}
+
+	wal.InsertMetricsRequestReceived(newTestMetrics("after.checkpoint", now, 1), nil)
+	wal.Close()
+
+	stats, err = NewWAL(dir, nil).Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Records != 1 || stats.CorruptedSegments != 0 {
+		t.Error(fmt.Errorf("%v", stats))
+	}
+}
+
+type testTenantListener struct {
+	sync.Mutex
+	tenants map[string][]string
+}
+
+func newTestTenantListener() *testTenantListener {
+	return &testTenantListener{
+		Mutex:   sync.Mutex{},
+		tenants: map[string][]string{},
+	}
+}
+
+func (l *testTenantListener) InsertMetricsRequestReceived(ms []*graphite.Metrics, err error) {
+	l.InsertTenantMetricsRequestReceived("", ms, err)
+}
+
+func (l *testTenantListener) InsertTenantMetricsRequestReceived(tenant string, ms []*graphite.Metrics, err error) {
+	l.Lock()
+	defer l.Unlock()
+	for _, m := range ms {
+		l.tenants[tenant] = append(l.tenants[tenant], m.Name)
+	}
+}
+
+func TestWALTenantReplay(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now().Unix()
+
+	listener := newTestTenantListener()
+	wal := NewWAL(dir, listener)
+	_, err := wal.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The isolated tenants require the tenant-aware listeners
+	carbon := graphite.NewCarbon()
+	carbon.SetTenancy(graphite.NewTenancy(graphite.TenantIsolate))
+	carbon.SetCarbonListener(wal)
+	_, err = carbon.FeedTenantMetrics("team-a", newTestMetrics("servers.web01.cpu", now, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = carbon.FeedMetrics(newTestMetrics("servers.web02.cpu", now, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wal.Close()
+
+	expected := map[string][]string{
+		"team-a": {"servers.web01.cpu"},
+		"":       {"servers.web02.cpu"},
+	}
+	if !reflect.DeepEqual(listener.tenants, expected) {
+		t.Error(fmt.Errorf("%v != %v", listener.tenants, expected))
+	}
+
+	// The tenants of the records are replayed
+	recovered := newTestTenantListener()
+	wal = NewWAL(dir, recovered)
+	stats, err := wal.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wal.Close()
+	if stats.Records != 2 {
+		t.Error(fmt.Errorf("%v", stats))
+	}
+	if !reflect.DeepEqual(recovered.tenants, expected) {
+		t.Error(fmt.Errorf("%v != %v", recovered.tenants, expected))
+	}
+}
+
+func TestWALSyncPolicy(t *testing.T) {
+	for _, policy := range []SyncPolicy{SyncEveryBatch, SyncInterval, SyncNever} {
+		p, err := ParseSyncPolicy(policy.String())
+		if err != nil || p != policy {
+			t.Error(fmt.Errorf("%s : %v", policy, err))
+		}
+
+		wal := NewWAL(t.TempDir(), nil)
+		wal.SetSyncPolicy(policy)
+		wal.SetSyncInterval(time.Millisecond)
+		_, err = wal.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		wal.InsertMetricsRequestReceived(newTestMetrics("a", time.Now().Unix(), 1), nil)
+		time.Sleep(time.Millisecond * 5)
+		err = wal.Close()
+		if err != nil {
+			t.Error(err)
+		}
+		if wal.GetLastError() != nil {
+			t.Error(wal.GetLastError())
+		}
+	}
+}
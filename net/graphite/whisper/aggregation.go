@@ -0,0 +1,132 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package whisper
+
+import (
+	"fmt"
+	"math"
+)
+
+// AggregationMethod represents a method to consolidate datapoints into lower precision archives.
+type AggregationMethod uint32
+
+const (
+	// Average is the aggregation method which uses the mean of the known values.
+	Average AggregationMethod = 1
+	// Sum is the aggregation method which uses the sum of the known values.
+	Sum AggregationMethod = 2
+	// Last is the aggregation method which uses the latest known value.
+	Last AggregationMethod = 3
+	// Max is the aggregation method which uses the maximum known value.
+	Max AggregationMethod = 4
+	// Min is the aggregation method which uses the minimum known value.
+	Min AggregationMethod = 5
+	// AvgZero is the aggregation method which uses the mean treating unknown values as zero.
+	AvgZero AggregationMethod = 6
+	// AbsMax is the aggregation method which uses the known value of the maximum absolute value.
+	AbsMax AggregationMethod = 7
+	// AbsMin is the aggregation method which uses the known value of the minimum absolute value.
+	AbsMin AggregationMethod = 8
+)
+
+var aggregationMethodNames = map[AggregationMethod]string{
+	Average: "average",
+	Sum:     "sum",
+	Last:    "last",
+	Max:     "max",
+	Min:     "min",
+	AvgZero: "avg_zero",
+	AbsMax:  "absmax",
+	AbsMin:  "absmin",
+}
+
+// ParseAggregationMethod returns the aggregation method of the specified name such as 'average' or 'sum'.
+func ParseAggregationMethod(name string) (AggregationMethod, error) {
+	for method, methodName := range aggregationMethodNames {
+		if methodName == name {
+			return method, nil
+		}
+	}
+	return 0, fmt.Errorf(errorInvalidAggregationMethod, name)
+}
+
+// IsValid returns true whether the method is a known aggregation method, otherwise false.
+func (method AggregationMethod) IsValid() bool {
+	_, ok := aggregationMethodNames[method]
+	return ok
+}
+
+// String returns the name of the aggregation method.
+func (method AggregationMethod) String() string {
+	name, ok := aggregationMethodNames[method]
+	if !ok {
+		return fmt.Sprintf("unknown(%d)", uint32(method))
+	}
+	return name
+}
+
+// Aggregate consolidates the specified values with the method.
+// The values are all neighbor values of a lower precision interval, and unknown values are NaN.
+// Aggregate returns NaN when no value is known.
+func (method AggregationMethod) Aggregate(values []float64) float64 {
+	known := make([]float64, 0, len(values))
+	for _, v := range values {
+		if !math.IsNaN(v) {
+			known = append(known, v)
+		}
+	}
+	if len(known) == 0 {
+		return math.NaN()
+	}
+
+	switch method {
+	case Average:
+		return sumValues(known) / float64(len(known))
+	case Sum:
+		return sumValues(known)
+	case Last:
+		return known[len(known)-1]
+	case Max:
+		v := known[0]
+		for _, k := range known[1:] {
+			v = math.Max(v, k)
+		}
+		return v
+	case Min:
+		v := known[0]
+		for _, k := range known[1:] {
+			v = math.Min(v, k)
+		}
+		return v
+	case AvgZero:
+		return sumValues(known) / float64(len(values))
+	case AbsMax:
+		v := known[0]
+		for _, k := range known[1:] {
+			if math.Abs(v) < math.Abs(k) {
+				v = k
+			}
+		}
+		return v
+	case AbsMin:
+		v := known[0]
+		for _, k := range known[1:] {
+			if math.Abs(k) < math.Abs(v) {
+				v = k
+			}
+		}
+		return v
+	}
+
+	return math.NaN()
+}
+
+func sumValues(values []float64) float64 {
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum
+}
@@ -0,0 +1,19 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package whisper
+
+const (
+	errorInvalidArchiveList       = "invalid archive list : %s"
+	errorInvalidAggregationMethod = "invalid aggregation method : %s"
+	errorInvalidXFilesFactor      = "invalid xFilesFactor : %f"
+	errorInvalidHeader            = "invalid header : %s"
+	errorArchiveCountOutOfFile    = "%d archives are out of the file size %d"
+	errorInvalidArchive           = "archive %d has no points : %s"
+	errorArchiveOutOfFile         = "archive %d (%d-%d) is out of the file size %d"
+	errorInvalidTimeInterval      = "invalid time interval : from time %d is after until time %d"
+	errorTimestampNotCovered      = "timestamp %d is not covered by any archives in this database"
+	errorFileAlreadyExists        = "file already exists : %s"
+	errorInvalidMetricName        = "invalid metric name : %s"
+)
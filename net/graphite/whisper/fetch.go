@@ -0,0 +1,108 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package whisper
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Fetch returns the values between the specified times from the highest precision archive which covers the range.
+// Fetch returns nil when the range is in the future or beyond the retention of the file.
+func (w *Whisper) Fetch(from int64, until int64) (*TimeSeries, error) {
+	return w.fetch(from, until, time.Now().Unix())
+}
+
+func (w *Whisper) fetch(from int64, until int64, now int64) (*TimeSeries, error) {
+	if until < from {
+		return nil, fmt.Errorf(errorInvalidTimeInterval, from, until)
+	}
+
+	w.Lock()
+	defer w.Unlock()
+
+	header := w.header
+	oldest := now - int64(header.MaxRetention)
+
+	if now < from {
+		return nil, nil
+	}
+	if until < oldest {
+		return nil, nil
+	}
+	from = max(from, oldest)
+	until = min(until, now)
+
+	diff := now - from
+	archive := header.Archives[len(header.Archives)-1]
+	for _, a := range header.Archives {
+		if diff <= int64(a.Retention()) {
+			archive = a
+			break
+		}
+	}
+
+	return w.archiveFetch(archive, from, until)
+}
+
+// FetchArchive returns the values between the specified times from the specified archive.
+func (w *Whisper) FetchArchive(archive *ArchiveInfo, from int64, until int64) (*TimeSeries, error) {
+	if until < from {
+		return nil, fmt.Errorf(errorInvalidTimeInterval, from, until)
+	}
+
+	w.Lock()
+	defer w.Unlock()
+
+	return w.archiveFetch(archive, from, until)
+}
+
+func (w *Whisper) archiveFetch(archive *ArchiveInfo, from int64, until int64) (*TimeSeries, error) {
+	step := int64(archive.SecondsPerPoint)
+	fromInterval := alignInterval(from, step) + step
+	untilInterval := alignInterval(until, step) + step
+	if fromInterval == untilInterval {
+		// Zero-length time range: always include the next point
+		untilInterval += step
+	}
+
+	count := (untilInterval - fromInterval) / step
+	series := &TimeSeries{
+		From:   fromInterval,
+		Until:  untilInterval,
+		Step:   step,
+		Values: make([]float64, count),
+	}
+	for n := range series.Values {
+		series.Values[n] = math.NaN()
+	}
+
+	baseInterval, _, err := w.readPoint(int64(archive.Offset))
+	if err != nil {
+		return nil, err
+	}
+	if baseInterval == 0 {
+		return series, nil
+	}
+
+	// The range can't be longer than the archive, and the points over the archive size are unknown.
+	readCount := min(count, int64(archive.Points))
+	first := pointIndex(archive, baseInterval, fromInterval)
+	intervals, values, err := w.readPoints(archive, first, readCount)
+	if err != nil {
+		return nil, err
+	}
+
+	currentInterval := fromInterval
+	for n := range intervals {
+		if int64(intervals[n]) == currentInterval {
+			series.Values[n] = values[n]
+		}
+		currentInterval += step
+	}
+
+	return series, nil
+}
@@ -0,0 +1,363 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package whisper
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cybergarage/go-graphite/net/graphite"
+	"github.com/cybergarage/go-graphite/net/graphite/storage"
+)
+
+const (
+	// FileExt is the file extension of Whisper files.
+	FileExt = ".wsp"
+	// DefaultQueryRange is the default range of the queries which have no from or until times.
+	DefaultQueryRange = time.Hour * 24
+)
+
+const (
+	metricNameSep = "."
+	storeRuleName = "store"
+)
+
+// DefaultArchives returns the default archives of the new files, 1 minute for 1 day.
+func DefaultArchives() []*ArchiveInfo {
+	return []*ArchiveInfo{
+		NewArchiveInfo(60, 1440),
+	}
+}
+
+// Store is a storage backend which maps dotted metric names to a directory tree of Whisper files
+// such as 'a.b.c' to '<root>/a/b/c.wsp' like Carbon, and implements graphite.CarbonListener and graphite.RenderRequestListener.
+type Store struct {
+	sync.Mutex
+	rootDir           string
+	archives          []*ArchiveInfo
+	xFilesFactor      float32
+	aggregationMethod AggregationMethod
+	resolver          *storage.Resolver
+	index             *graphite.MetricIndex
+	fileLocks         map[string]*sync.Mutex
+}
+
+// NewStore returns a new store for the specified root directory.
+func NewStore(rootDir string) *Store {
+	store := &Store{
+		Mutex:             sync.Mutex{},
+		rootDir:           rootDir,
+		archives:          DefaultArchives(),
+		xFilesFactor:      DefaultXFilesFactor,
+		aggregationMethod: DefaultAggregationMethod,
+		resolver:          storage.NewResolver(),
+		index:             graphite.NewMetricIndex(),
+		fileLocks:         map[string]*sync.Mutex{},
+	}
+	return store
+}
+
+// GetRootDirectory returns the root directory of the store.
+func (store *Store) GetRootDirectory() string {
+	return store.rootDir
+}
+
+// SetArchives sets the archives of the new files.
+func (store *Store) SetArchives(archives []*ArchiveInfo) error {
+	err := ValidateArchiveList(archives)
+	if err != nil {
+		return err
+	}
+	store.Lock()
+	defer store.Unlock()
+	store.archives = archives
+	return nil
+}
+
+// SetXFilesFactor sets the xFilesFactor of the new files.
+func (store *Store) SetXFilesFactor(xff float32) error {
+	if xff < 0 || 1 < xff {
+		return fmt.Errorf(errorInvalidXFilesFactor, xff)
+	}
+	store.Lock()
+	defer store.Unlock()
+	store.xFilesFactor = xff
+	return nil
+}
+
+// SetAggregationMethod sets the aggregation method of the new files.
+func (store *Store) SetAggregationMethod(method AggregationMethod) {
+	store.Lock()
+	defer store.Unlock()
+	store.aggregationMethod = method
+}
+
+// SetResolver sets the storage-schemas.conf and storage-aggregation.conf rules to create the new files.
+// The rules take precedence over the default archives, xFilesFactor and aggregation method of the store, which are the rules of the unmatched files.
+func (store *Store) SetResolver(resolver *storage.Resolver) {
+	store.Lock()
+	defer store.Unlock()
+	if resolver == nil {
+		resolver = storage.NewResolver()
+	}
+	store.resolver = resolver
+}
+
+// GetMetricIndex returns the index of the metric names in the root directory.
+func (store *Store) GetMetricIndex() *graphite.MetricIndex {
+	store.Lock()
+	defer store.Unlock()
+	return store.index
+}
+
+// Open creates the root directory when it doesn't exist, and indexes all Whisper files in it.
+func (store *Store) Open() error {
+	err := os.MkdirAll(store.rootDir, 0o755)
+	if err != nil {
+		return err
+	}
+	return store.Reload()
+}
+
+// Reload rescans the root directory to index the Whisper files which are added by other processes.
+func (store *Store) Reload() error {
+	idx := graphite.NewMetricIndex()
+	err := filepath.WalkDir(store.rootDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(d.Name(), FileExt) {
+			return nil
+		}
+		name, err := store.MetricName(path)
+		if err != nil {
+			return err
+		}
+		idx.Insert(name)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	store.Lock()
+	defer store.Unlock()
+	store.index = idx
+
+	return nil
+}
+
+// FilePath returns the Whisper file path of the specified metric name.
+func (store *Store) FilePath(name string) string {
+	nodes := strings.Split(name, metricNameSep)
+	return filepath.Join(store.rootDir, filepath.Join(nodes...)+FileExt)
+}
+
+// MetricName returns the metric name of the specified Whisper file path.
+func (store *Store) MetricName(path string) (string, error) {
+	rel, err := filepath.Rel(store.rootDir, path)
+	if err != nil {
+		return "", err
+	}
+	rel = strings.TrimSuffix(rel, FileExt)
+	return strings.Join(strings.Split(rel, string(filepath.Separator)), metricNameSep), nil
+}
+
+func (store *Store) fileLock(name string) *sync.Mutex {
+	store.Lock()
+	defer store.Unlock()
+	l, ok := store.fileLocks[name]
+	if !ok {
+		l = &sync.Mutex{}
+		store.fileLocks[name] = l
+	}
+	return l
+}
+
+// isValidMetricName returns false for the names which escape from the root directory.
+func isValidMetricName(name string) bool {
+	if len(name) == 0 {
+		return false
+	}
+	for _, node := range strings.Split(name, metricNameSep) {
+		if len(node) == 0 || node == ".." || strings.ContainsAny(node, "/\\") {
+			return false
+		}
+	}
+	return true
+}
+
+// AddMetrics writes all datapoints of the specified metrics, and creates the file when it doesn't exist.
+func (store *Store) AddMetrics(m *graphite.Metrics) error {
+	if !isValidMetricName(m.Name) {
+		return fmt.Errorf(errorInvalidMetricName, m.Name)
+	}
+
+	l := store.fileLock(m.Name)
+	l.Lock()
+	defer l.Unlock()
+
+	path := store.FilePath(m.Name)
+	w, err := Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		w, err = store.create(m.Name, path)
+	}
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	points := make([]*Point, 0, len(m.DataPoints))
+	for _, dp := range m.DataPoints {
+		if dp == nil {
+			continue
+		}
+		points = append(points, NewPoint(dp.UnixTimestamp(), dp.Value))
+	}
+
+	return w.UpdateMany(points)
+}
+
+func (store *Store) create(name string, path string) (*Whisper, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return nil, err
+	}
+
+	store.Lock()
+	resolver := store.policyResolver()
+	idx := store.index
+	store.Unlock()
+
+	archives, xff, method, err := newPolicyParameters(resolver.Resolve(name))
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := Create(path, archives, xff, method)
+	if err != nil {
+		return nil, err
+	}
+
+	idx.Insert(name)
+
+	return w, nil
+}
+
+// policyResolver returns the resolver set by SetResolver, which creates the unmatched files
+// with the default archives, xFilesFactor and aggregation method of the store.
+func (store *Store) policyResolver() *storage.Resolver {
+	retentions := []*storage.Retention{}
+	for _, archive := range store.archives {
+		retentions = append(retentions, storage.NewRetention(int(archive.SecondsPerPoint), int(archive.Points)))
+	}
+	return store.resolver.WithDefaults(
+		storage.NewSchema(storeRuleName, retentions),
+		storage.NewAggregation(storeRuleName, float64(store.xFilesFactor), store.aggregationMethod.String()))
+}
+
+func newPolicyParameters(policy *storage.Policy) ([]*ArchiveInfo, float32, AggregationMethod, error) {
+	method, err := ParseAggregationMethod(policy.AggregationMethod())
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	archives := []*ArchiveInfo{}
+	for _, r := range policy.Retentions() {
+		archives = append(archives, NewArchiveInfo(uint32(r.SecondsPerPoint), uint32(r.Points)))
+	}
+	return archives, float32(policy.XFilesFactor()), method, nil
+}
+
+// InsertMetricsRequestReceived writes the ingested metrics into the Whisper files.
+func (store *Store) InsertMetricsRequestReceived(ms []*graphite.Metrics, err error) {
+	for _, m := range ms {
+		if m == nil {
+			continue
+		}
+		store.AddMetrics(m)
+	}
+}
+
+// FindMetricsRequestReceived returns the metric names which match the query target.
+func (store *Store) FindMetricsRequestReceived(query *graphite.Query, err error) ([]*graphite.Metrics, error) {
+	if err != nil {
+		return nil, err
+	}
+	return store.GetMetricIndex().FindMetrics(query.Target)
+}
+
+// QueryMetricsRequestReceived returns the datapoints of the Whisper files which match the query target.
+func (store *Store) QueryMetricsRequestReceived(query *graphite.Query, err error) ([]*graphite.Metrics, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	until := time.Now()
+	if query.Until != nil {
+		until = *query.Until
+	}
+	from := until.Add(-DefaultQueryRange)
+	if query.From != nil {
+		from = *query.From
+	}
+
+	names, err := store.GetMetricIndex().FindMetrics(query.Target)
+	if err != nil {
+		return nil, err
+	}
+
+	ms := []*graphite.Metrics{}
+	for _, name := range names {
+		m, err := store.fetchMetrics(name.Name, from.Unix(), until.Unix())
+		if err != nil {
+			return nil, err
+		}
+		if m != nil {
+			ms = append(ms, m)
+		}
+	}
+
+	return ms, nil
+}
+
+func (store *Store) fetchMetrics(name string, from int64, until int64) (*graphite.Metrics, error) {
+	l := store.fileLock(name)
+	l.Lock()
+	defer l.Unlock()
+
+	w, err := Open(store.FilePath(name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer w.Close()
+
+	series, err := w.Fetch(from, until)
+	if err != nil {
+		return nil, err
+	}
+
+	m := graphite.NewMetrics()
+	m.SetName(name)
+	if series == nil {
+		return m, nil
+	}
+	for n, v := range series.Values {
+		dp := graphite.NewDataPoint()
+		dp.SetTimestamp(time.Unix(series.Timestamp(n), 0))
+		dp.SetValue(v)
+		m.AddDataPoint(dp)
+	}
+
+	return m, nil
+}
@@ -0,0 +1,158 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package whisper
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cybergarage/go-graphite/net/graphite"
+	"github.com/cybergarage/go-graphite/net/graphite/storage"
+)
+
+func TestStore(t *testing.T) {
+	rootDir := t.TempDir()
+
+	store := NewStore(rootDir)
+	err := store.SetArchives([]*ArchiveInfo{NewArchiveInfo(1, 3600)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, xff := range []float32{-0.1, 1.1} {
+		if store.SetXFilesFactor(xff) == nil {
+			t.Error(fmt.Errorf("an invalid xFilesFactor %f is set", xff))
+		}
+	}
+	err = store.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	carbon := graphite.NewCarbon()
+	carbon.SetCarbonListener(store)
+
+	now := time.Now().Unix() - 1
+	for n := range 3 {
+		line := fmt.Sprintf("servers.host%d.cpu %d %d\n", n, n, now)
+		_, err := carbon.FeedPlainTextString(line)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	_, err = os.Stat(filepath.Join(rootDir, "servers", "host1", "cpu"+FileExt))
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = store.AddMetrics(&graphite.Metrics{Name: "servers...cpu"})
+	if err == nil {
+		t.Error(fmt.Errorf("an invalid name is stored"))
+	}
+
+	// Reindex the existing files
+	store = NewStore(rootDir)
+	err = store.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q := graphite.NewQuery()
+	q.Target = "servers.*.cpu"
+	ms, err := store.FindMetricsRequestReceived(q, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(ms) != 3 {
+		t.Error(fmt.Errorf("%d != %d", len(ms), 3))
+	}
+
+	from := time.Unix(now-10, 0)
+	until := time.Unix(now, 0)
+	q.Target = "servers.host2.cpu"
+	q.From = &from
+	q.Until = &until
+	ms, err = store.QueryMetricsRequestReceived(q, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ms) != 1 {
+		t.Fatal(fmt.Errorf("%d != %d", len(ms), 1))
+	}
+
+	found := false
+	for _, dp := range ms[0].DataPoints {
+		if dp.Value == 2 {
+			found = true
+		}
+	}
+	if !found {
+		t.Error(fmt.Errorf("%v", ms[0].DataPoints))
+	}
+}
+
+func TestStoreWithResolver(t *testing.T) {
+	schemas, err := storage.ParseSchemas(strings.NewReader("[test]\npattern = ^test\\.\nretentions = 1s:1m,10s:10m\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	aggs, err := storage.ParseAggregations(strings.NewReader("[test]\npattern = \\.count$\nxFilesFactor = 0\naggregationMethod = sum\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolver := storage.NewResolver()
+	resolver.Schemas = schemas
+	resolver.Aggregations = aggs
+
+	store := NewStore(t.TempDir())
+	store.SetResolver(resolver)
+	err = store.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := graphite.NewMetrics()
+	m.SetName("test.requests.count")
+	dp := graphite.NewDataPoint()
+	dp.SetValue(1)
+	dp.SetTimestamp(time.Now())
+	m.AddDataPoint(dp)
+	err = store.AddMetrics(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := Open(store.FilePath(m.Name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	header := w.Header()
+	if header.AggregationMethod != Sum || header.XFilesFactor != 0 || len(header.Archives) != 2 || header.MaxRetention != 600 {
+		t.Error(fmt.Errorf("%v", header))
+	}
+
+	// The unmatched files use the archives of the store.
+	m.SetName("other.requests")
+	err = store.AddMetrics(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := Open(store.FilePath(m.Name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+
+	header = other.Header()
+	if header.AggregationMethod != DefaultAggregationMethod || len(header.Archives) != 1 || header.Archives[0].String() != DefaultArchives()[0].String() {
+		t.Error(fmt.Errorf("%v", header))
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package whisper
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Update writes the specified value at the timestamp into the highest precision archive which covers it,
+// and propagates the value to the lower precision archives.
+func (w *Whisper) Update(value float64, ts int64) error {
+	return w.update(value, ts, time.Now().Unix())
+}
+
+func (w *Whisper) update(value float64, ts int64, now int64) error {
+	w.Lock()
+	defer w.Unlock()
+
+	header := w.header
+
+	timeDistance := now - ts
+	if timeDistance < 0 || int64(header.MaxRetention) < timeDistance {
+		return fmt.Errorf(errorTimestampNotCovered, ts)
+	}
+
+	archiveIdx := 0
+	for n, archive := range header.Archives {
+		if timeDistance <= int64(archive.Retention()) {
+			archiveIdx = n
+			break
+		}
+	}
+
+	archive := header.Archives[archiveIdx]
+	interval := alignInterval(ts, int64(archive.SecondsPerPoint))
+	err := w.writeArchivePoint(archive, interval, value)
+	if err != nil {
+		return err
+	}
+
+	higher := archive
+	for _, lower := range header.Archives[archiveIdx+1:] {
+		propagated, err := w.propagate(interval, higher, lower)
+		if err != nil {
+			return err
+		}
+		if !propagated {
+			break
+		}
+		higher = lower
+	}
+
+	return nil
+}
+
+// UpdateMany writes the specified points into the archives which cover them, and propagates them to the lower precision archives.
+// The points which aren't covered by any archives are dropped.
+func (w *Whisper) UpdateMany(points []*Point) error {
+	return w.updateMany(points, time.Now().Unix())
+}
+
+func (w *Whisper) updateMany(points []*Point, now int64) error {
+	w.Lock()
+	defer w.Unlock()
+
+	sorted := make([]*Point, 0, len(points))
+	for _, p := range points {
+		if p != nil {
+			sorted = append(sorted, p)
+		}
+	}
+	// Newest points first
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[j].Timestamp < sorted[i].Timestamp
+	})
+
+	archives := w.header.Archives
+	archiveIdx := 0
+	current := []*Point{}
+	for _, p := range sorted {
+		age := now - p.Timestamp
+		for archiveIdx < len(archives) && int64(archives[archiveIdx].Retention()) < age {
+			if 0 < len(current) {
+				err := w.archiveUpdateMany(archiveIdx, current)
+				if err != nil {
+					return err
+				}
+				current = []*Point{}
+			}
+			archiveIdx++
+		}
+		if len(archives) <= archiveIdx {
+			break
+		}
+		current = append(current, p)
+	}
+
+	if archiveIdx < len(archives) && 0 < len(current) {
+		return w.archiveUpdateMany(archiveIdx, current)
+	}
+
+	return nil
+}
+
+// archiveUpdateMany writes the specified points, which are ordered from the newest, into the archive.
+func (w *Whisper) archiveUpdateMany(archiveIdx int, points []*Point) error {
+	archive := w.header.Archives[archiveIdx]
+	step := int64(archive.SecondsPerPoint)
+
+	// Write in the chronological order so that the latest value wins for each interval.
+	intervals := make([]int64, 0, len(points))
+	for n := len(points) - 1; 0 <= n; n-- {
+		p := points[n]
+		interval := alignInterval(p.Timestamp, step)
+		err := w.writeArchivePoint(archive, interval, p.Value)
+		if err != nil {
+			return err
+		}
+		intervals = append(intervals, interval)
+	}
+
+	higher := archive
+	for _, lower := range w.header.Archives[archiveIdx+1:] {
+		lowerStep := int64(lower.SecondsPerPoint)
+		lowerIntervals := map[int64]bool{}
+		for _, interval := range intervals {
+			lowerIntervals[alignInterval(interval, lowerStep)] = true
+		}
+		propagateFurther := false
+		for lowerInterval := range lowerIntervals {
+			propagated, err := w.propagate(lowerInterval, higher, lower)
+			if err != nil {
+				return err
+			}
+			if propagated {
+				propagateFurther = true
+			}
+		}
+		if !propagateFurther {
+			break
+		}
+		higher = lower
+	}
+
+	return nil
+}
+
+func (w *Whisper) writeArchivePoint(archive *ArchiveInfo, interval int64, value float64) error {
+	baseInterval, _, err := w.readPoint(int64(archive.Offset))
+	if err != nil {
+		return err
+	}
+	idx := pointIndex(archive, baseInterval, interval)
+	return w.writePoint(int64(archive.Offset)+(idx*pointSize), uint32(interval), value)
+}
+
+// propagate aggregates the points of the higher archive in the lower interval of the specified timestamp,
+// and returns true when the aggregated value is written into the lower archive.
+func (w *Whisper) propagate(ts int64, higher *ArchiveInfo, lower *ArchiveInfo) (bool, error) {
+	lowerStep := int64(lower.SecondsPerPoint)
+	higherStep := int64(higher.SecondsPerPoint)
+	lowerIntervalStart := alignInterval(ts, lowerStep)
+
+	higherBaseInterval, _, err := w.readPoint(int64(higher.Offset))
+	if err != nil {
+		return false, err
+	}
+
+	higherPoints := lowerStep / higherStep
+	first := pointIndex(higher, higherBaseInterval, lowerIntervalStart)
+	intervals, values, err := w.readPoints(higher, first, higherPoints)
+	if err != nil {
+		return false, err
+	}
+
+	neighborValues := make([]float64, higherPoints)
+	knownCount := 0
+	currentInterval := lowerIntervalStart
+	for n := range intervals {
+		neighborValues[n] = math.NaN()
+		if int64(intervals[n]) == currentInterval {
+			neighborValues[n] = values[n]
+			knownCount++
+		}
+		currentInterval += higherStep
+	}
+
+	if knownCount == 0 {
+		return false, nil
+	}
+
+	knownPercent := float64(knownCount) / float64(len(neighborValues))
+	if knownPercent < float64(w.header.XFilesFactor) {
+		return false, nil
+	}
+
+	aggregated := w.header.AggregationMethod.Aggregate(neighborValues)
+	err = w.writeArchivePoint(lower, lowerIntervalStart, aggregated)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
@@ -0,0 +1,417 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package whisper reads and writes Whisper database files of Graphite.
+// See : The Whisper Database (https://graphite.readthedocs.io/en/latest/whisper.html)
+package whisper
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"sync"
+)
+
+const (
+	metadataSize    = 16
+	archiveInfoSize = 12
+	pointSize       = 12
+)
+
+const (
+	// DefaultXFilesFactor is the default ratio of known datapoints to propagate to lower precision archives.
+	DefaultXFilesFactor float32 = 0.5
+	// DefaultAggregationMethod is the default aggregation method.
+	DefaultAggregationMethod = Average
+)
+
+// ArchiveInfo represents an archive of a Whisper file.
+type ArchiveInfo struct {
+	Offset          uint32
+	SecondsPerPoint uint32
+	Points          uint32
+}
+
+// NewArchiveInfo returns a new archive which keeps the specified number of points at the specified precision.
+func NewArchiveInfo(secondsPerPoint uint32, points uint32) *ArchiveInfo {
+	return &ArchiveInfo{
+		Offset:          0,
+		SecondsPerPoint: secondsPerPoint,
+		Points:          points,
+	}
+}
+
+// Retention returns the retention period of the archive in seconds.
+func (archive *ArchiveInfo) Retention() uint32 {
+	return archive.SecondsPerPoint * archive.Points
+}
+
+// Size returns the byte size of the archive.
+func (archive *ArchiveInfo) Size() uint32 {
+	return archive.Points * pointSize
+}
+
+// String returns a string representation of the archive such as '60:1440'.
+func (archive *ArchiveInfo) String() string {
+	return fmt.Sprintf("%d:%d", archive.SecondsPerPoint, archive.Points)
+}
+
+// Header represents the metadata and archives of a Whisper file.
+type Header struct {
+	AggregationMethod AggregationMethod
+	MaxRetention      uint32
+	XFilesFactor      float32
+	Archives          []*ArchiveInfo
+}
+
+// Size returns the byte size of the header.
+func (header *Header) Size() uint32 {
+	return metadataSize + (archiveInfoSize * uint32(len(header.Archives)))
+}
+
+// FileSize returns the byte size of the whole file.
+func (header *Header) FileSize() int64 {
+	size := int64(header.Size())
+	for _, archive := range header.Archives {
+		size += int64(archive.Size())
+	}
+	return size
+}
+
+// Point represents a datapoint of a Whisper file.
+type Point struct {
+	Timestamp int64
+	Value     float64
+}
+
+// NewPoint returns a new point.
+func NewPoint(ts int64, value float64) *Point {
+	return &Point{
+		Timestamp: ts,
+		Value:     value,
+	}
+}
+
+// TimeSeries represents a fetched range of an archive.
+// Values has a value for each step from From until Until, and the unknown values are NaN.
+type TimeSeries struct {
+	From   int64
+	Until  int64
+	Step   int64
+	Values []float64
+}
+
+// Timestamp returns the timestamp of the specified value index.
+func (ts *TimeSeries) Timestamp(n int) int64 {
+	return ts.From + (int64(n) * ts.Step)
+}
+
+// Whisper represents an opened Whisper file.
+type Whisper struct {
+	sync.Mutex
+	file   *os.File
+	header *Header
+}
+
+// ValidateArchiveList returns an error when the specified archives can't be used in a Whisper file.
+// The archives are sorted by the precision.
+func ValidateArchiveList(archives []*ArchiveInfo) error {
+	if len(archives) == 0 {
+		return fmt.Errorf(errorInvalidArchiveList, "no archives")
+	}
+
+	sort.SliceStable(archives, func(i, j int) bool {
+		return archives[i].SecondsPerPoint < archives[j].SecondsPerPoint
+	})
+
+	for n, archive := range archives {
+		if archive.SecondsPerPoint == 0 || archive.Points == 0 {
+			return fmt.Errorf(errorInvalidArchiveList, archive.String())
+		}
+		if n == (len(archives) - 1) {
+			break
+		}
+		next := archives[n+1]
+		if archive.SecondsPerPoint == next.SecondsPerPoint {
+			return fmt.Errorf(errorInvalidArchiveList, "a precision is duplicated : "+archive.String())
+		}
+		if (next.SecondsPerPoint % archive.SecondsPerPoint) != 0 {
+			return fmt.Errorf(errorInvalidArchiveList, "a lower precision must evenly divide a higher precision : "+next.String())
+		}
+		if next.Retention() <= archive.Retention() {
+			return fmt.Errorf(errorInvalidArchiveList, "a lower precision must cover a larger time interval : "+next.String())
+		}
+		pointsPerConsolidation := next.SecondsPerPoint / archive.SecondsPerPoint
+		if archive.Points < pointsPerConsolidation {
+			return fmt.Errorf(errorInvalidArchiveList, "an archive must have enough points to consolidate into the next archive : "+archive.String())
+		}
+	}
+
+	return nil
+}
+
+// Create creates a new Whisper file with the specified archives, and returns the opened file.
+func Create(path string, archives []*ArchiveInfo, xFilesFactor float32, method AggregationMethod) (*Whisper, error) {
+	if xFilesFactor < 0 || 1 < xFilesFactor {
+		return nil, fmt.Errorf(errorInvalidXFilesFactor, xFilesFactor)
+	}
+	if !method.IsValid() {
+		return nil, fmt.Errorf(errorInvalidAggregationMethod, method.String())
+	}
+
+	copied := make([]*ArchiveInfo, len(archives))
+	for n, archive := range archives {
+		copied[n] = NewArchiveInfo(archive.SecondsPerPoint, archive.Points)
+	}
+	err := ValidateArchiveList(copied)
+	if err != nil {
+		return nil, err
+	}
+
+	header := &Header{
+		AggregationMethod: method,
+		MaxRetention:      0,
+		XFilesFactor:      xFilesFactor,
+		Archives:          copied,
+	}
+	offset := header.Size()
+	for _, archive := range header.Archives {
+		header.MaxRetention = max(header.MaxRetention, archive.Retention())
+		archive.Offset = offset
+		offset += archive.Size()
+	}
+
+	_, err = os.Stat(path)
+	if err == nil {
+		return nil, fmt.Errorf(errorFileAlreadyExists, path)
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Whisper{
+		Mutex:  sync.Mutex{},
+		file:   file,
+		header: header,
+	}
+
+	err = w.writeHeader()
+	if err == nil {
+		err = file.Truncate(header.FileSize())
+	}
+	if err != nil {
+		file.Close()
+		os.Remove(path)
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// Open opens the specified Whisper file.
+func Open(path string) (*Whisper, error) {
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Whisper{
+		Mutex:  sync.Mutex{},
+		file:   file,
+		header: nil,
+	}
+
+	err = w.readHeader()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// Close closes the file.
+func (w *Whisper) Close() error {
+	w.Lock()
+	defer w.Unlock()
+	return w.file.Close()
+}
+
+// Header returns the header of the file.
+func (w *Whisper) Header() *Header {
+	return w.header
+}
+
+// Archives returns the archives of the file in the precision order.
+func (w *Whisper) Archives() []*ArchiveInfo {
+	return w.header.Archives
+}
+
+// SetAggregationMethod updates the aggregation method and the xFilesFactor of the file.
+func (w *Whisper) SetAggregationMethod(method AggregationMethod, xFilesFactor float32) error {
+	if xFilesFactor < 0 || 1 < xFilesFactor {
+		return fmt.Errorf(errorInvalidXFilesFactor, xFilesFactor)
+	}
+	if !method.IsValid() {
+		return fmt.Errorf(errorInvalidAggregationMethod, method.String())
+	}
+
+	w.Lock()
+	defer w.Unlock()
+
+	w.header.AggregationMethod = method
+	w.header.XFilesFactor = xFilesFactor
+
+	return w.writeHeader()
+}
+
+func (w *Whisper) writeHeader() error {
+	header := w.header
+	buf := make([]byte, header.Size())
+	binary.BigEndian.PutUint32(buf[0:], uint32(header.AggregationMethod))
+	binary.BigEndian.PutUint32(buf[4:], header.MaxRetention)
+	binary.BigEndian.PutUint32(buf[8:], math.Float32bits(header.XFilesFactor))
+	binary.BigEndian.PutUint32(buf[12:], uint32(len(header.Archives)))
+	for n, archive := range header.Archives {
+		offset := metadataSize + (n * archiveInfoSize)
+		binary.BigEndian.PutUint32(buf[offset:], archive.Offset)
+		binary.BigEndian.PutUint32(buf[offset+4:], archive.SecondsPerPoint)
+		binary.BigEndian.PutUint32(buf[offset+8:], archive.Points)
+	}
+	_, err := w.file.WriteAt(buf, 0)
+	return err
+}
+
+func (w *Whisper) readHeader() error {
+	buf := make([]byte, metadataSize)
+	_, err := w.file.ReadAt(buf, 0)
+	if err != nil {
+		return fmt.Errorf(errorInvalidHeader, err.Error())
+	}
+
+	header := &Header{
+		AggregationMethod: AggregationMethod(binary.BigEndian.Uint32(buf[0:])),
+		MaxRetention:      binary.BigEndian.Uint32(buf[4:]),
+		XFilesFactor:      math.Float32frombits(binary.BigEndian.Uint32(buf[8:])),
+		Archives:          []*ArchiveInfo{},
+	}
+
+	stat, err := w.file.Stat()
+	if err != nil {
+		return fmt.Errorf(errorInvalidHeader, err.Error())
+	}
+	fileSize := stat.Size()
+
+	// The sizes are checked in int64 against the file size not to allocate or read beyond the corrupt files.
+	archiveCount := int64(binary.BigEndian.Uint32(buf[12:]))
+	if archiveCount == 0 {
+		return fmt.Errorf(errorInvalidHeader, "no archives")
+	}
+	if fileSize < metadataSize+(archiveCount*archiveInfoSize) {
+		return fmt.Errorf(errorInvalidHeader, fmt.Sprintf(errorArchiveCountOutOfFile, archiveCount, fileSize))
+	}
+
+	buf = make([]byte, archiveCount*archiveInfoSize)
+	_, err = w.file.ReadAt(buf, metadataSize)
+	if err != nil {
+		return fmt.Errorf(errorInvalidHeader, err.Error())
+	}
+	for n := range archiveCount {
+		offset := n * archiveInfoSize
+		archive := &ArchiveInfo{
+			Offset:          binary.BigEndian.Uint32(buf[offset:]),
+			SecondsPerPoint: binary.BigEndian.Uint32(buf[offset+4:]),
+			Points:          binary.BigEndian.Uint32(buf[offset+8:]),
+		}
+		if archive.SecondsPerPoint == 0 || archive.Points == 0 {
+			return fmt.Errorf(errorInvalidHeader, fmt.Sprintf(errorInvalidArchive, n, archive.String()))
+		}
+		archiveEnd := int64(archive.Offset) + (int64(archive.Points) * pointSize)
+		if int64(archive.Offset) < metadataSize+(archiveCount*archiveInfoSize) || fileSize < archiveEnd {
+			return fmt.Errorf(errorInvalidHeader, fmt.Sprintf(errorArchiveOutOfFile, n, archive.Offset, archiveEnd, fileSize))
+		}
+		header.Archives = append(header.Archives, archive)
+	}
+
+	w.header = header
+
+	return nil
+}
+
+func (w *Whisper) readPoint(offset int64) (uint32, float64, error) {
+	buf := make([]byte, pointSize)
+	_, err := w.file.ReadAt(buf, offset)
+	if err != nil {
+		return 0, 0, err
+	}
+	return binary.BigEndian.Uint32(buf[0:]), math.Float64frombits(binary.BigEndian.Uint64(buf[4:])), nil
+}
+
+func (w *Whisper) writePoint(offset int64, interval uint32, value float64) error {
+	buf := make([]byte, pointSize)
+	binary.BigEndian.PutUint32(buf[0:], interval)
+	binary.BigEndian.PutUint64(buf[4:], math.Float64bits(value))
+	_, err := w.file.WriteAt(buf, offset)
+	return err
+}
+
+// readPoints reads the specified number of points from the relative point index of the archive wrapping around the end.
+func (w *Whisper) readPoints(archive *ArchiveInfo, first int64, count int64) ([]uint32, []float64, error) {
+	intervals := make([]uint32, count)
+	values := make([]float64, count)
+
+	buf := make([]byte, count*pointSize)
+	head := min(count, int64(archive.Points)-first)
+	_, err := w.file.ReadAt(buf[:head*pointSize], int64(archive.Offset)+(first*pointSize))
+	if err != nil {
+		return nil, nil, err
+	}
+	if head < count {
+		_, err = w.file.ReadAt(buf[head*pointSize:], int64(archive.Offset))
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for n := range count {
+		offset := n * pointSize
+		intervals[n] = binary.BigEndian.Uint32(buf[offset:])
+		values[n] = math.Float64frombits(binary.BigEndian.Uint64(buf[offset+4:]))
+	}
+
+	return intervals, values, nil
+}
+
+// pointIndex returns the relative point index of the specified interval in the archive which starts at the base interval.
+func pointIndex(archive *ArchiveInfo, baseInterval uint32, interval int64) int64 {
+	if baseInterval == 0 {
+		return 0
+	}
+	pointDistance := floorDiv(interval-int64(baseInterval), int64(archive.SecondsPerPoint))
+	return floorMod(pointDistance, int64(archive.Points))
+}
+
+func alignInterval(ts int64, step int64) int64 {
+	return ts - floorMod(ts, step)
+}
+
+func floorDiv(a, b int64) int64 {
+	q := a / b
+	if (a%b != 0) && ((a < 0) != (b < 0)) {
+		q--
+	}
+	return q
+}
+
+func floorMod(a, b int64) int64 {
+	m := a % b
+	if m < 0 {
+		m += b
+	}
+	return m
+}
@@ -0,0 +1,295 @@
+// Copyright (C) 2017 The go-graphite Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package whisper
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testCreateWhisper(t *testing.T, xff float32, method AggregationMethod) *Whisper {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "test"+FileExt)
+	archives := []*ArchiveInfo{
+		NewArchiveInfo(10, 12),
+		NewArchiveInfo(1, 60),
+	}
+	w, err := Create(path, archives, xff, method)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+func TestValidateArchiveList(t *testing.T) {
+	badArchives := [][]*ArchiveInfo{
+		{},
+		{NewArchiveInfo(1, 60), NewArchiveInfo(1, 120)},
+		{NewArchiveInfo(7, 60), NewArchiveInfo(10, 120)},
+		{NewArchiveInfo(1, 60), NewArchiveInfo(10, 6)},
+		{NewArchiveInfo(1, 5), NewArchiveInfo(10, 60)},
+	}
+	for n, archives := range badArchives {
+		if ValidateArchiveList(archives) == nil {
+			t.Error(fmt.Errorf("[%d] %v", n, archives))
+		}
+	}
+
+	archives := []*ArchiveInfo{NewArchiveInfo(60, 1440), NewArchiveInfo(10, 360)}
+	err := ValidateArchiveList(archives)
+	if err != nil {
+		t.Error(err)
+	}
+	if archives[0].SecondsPerPoint != 10 {
+		t.Error(fmt.Errorf("%v", archives))
+	}
+}
+
+func TestCreateAndOpen(t *testing.T) {
+	w := testCreateWhisper(t, 0.5, Sum)
+	path := w.file.Name()
+	w.Close()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != (16 + 12*2 + 12*(60+12)) {
+		t.Error(fmt.Errorf("%d", info.Size()))
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if binary.BigEndian.Uint32(raw[0:]) != uint32(Sum) || binary.BigEndian.Uint32(raw[4:]) != 120 || binary.BigEndian.Uint32(raw[12:]) != 2 {
+		t.Error(fmt.Errorf("%v", raw[:16]))
+	}
+	if binary.BigEndian.Uint32(raw[16:]) != 40 || binary.BigEndian.Uint32(raw[20:]) != 1 || binary.BigEndian.Uint32(raw[24:]) != 60 {
+		t.Error(fmt.Errorf("%v", raw[16:28]))
+	}
+	if binary.BigEndian.Uint32(raw[28:]) != 40+720 || binary.BigEndian.Uint32(raw[32:]) != 10 {
+		t.Error(fmt.Errorf("%v", raw[28:40]))
+	}
+
+	w, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	header := w.Header()
+	if header.AggregationMethod != Sum || header.MaxRetention != 120 || header.XFilesFactor != 0.5 || len(header.Archives) != 2 {
+		t.Error(fmt.Errorf("%v", header))
+	}
+
+	err = w.SetAggregationMethod(Max, 0.2)
+	if err != nil {
+		t.Error(err)
+	}
+	if w.Header().AggregationMethod != Max {
+		t.Error(fmt.Errorf("%v", w.Header()))
+	}
+}
+
+func TestOpenCorruptHeader(t *testing.T) {
+	w := testCreateWhisper(t, 0.5, Sum)
+	path := w.file.Name()
+	w.Close()
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		offset int
+		value  uint32
+	}{
+		{12, 0},              // no archives
+		{12, math.MaxUint32}, // archive count overflows the header size
+		{12, 3},              // archive count beyond the file
+		{16, math.MaxUint32}, // archive offset beyond the file
+		{16, 0},              // archive offset in the header
+		{24, math.MaxUint32}, // archive points beyond the file
+		{20, 0},              // archive without precision
+	}
+	for _, tc := range testCases {
+		corrupt := append([]byte{}, raw...)
+		binary.BigEndian.PutUint32(corrupt[tc.offset:], tc.value)
+		corruptPath := filepath.Join(t.TempDir(), "corrupt"+FileExt)
+		err := os.WriteFile(corruptPath, corrupt, 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w, err := Open(corruptPath)
+		if err == nil {
+			w.Close()
+			t.Error(fmt.Errorf("corrupt header (%d : %d) is opened", tc.offset, tc.value))
+		}
+	}
+
+	w, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+}
+
+func TestUpdateAndFetch(t *testing.T) {
+	w := testCreateWhisper(t, 0.5, Average)
+	defer w.Close()
+
+	now := int64(1500000000)
+	for n := range 10 {
+		err := w.update(float64(n), now-9+int64(n), now)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	err := w.update(0, now-200, now)
+	if err == nil {
+		t.Error(fmt.Errorf("an uncovered timestamp is updated"))
+	}
+
+	// The timestamp at the retention of the highest precision archive is still written into it
+	err = w.update(100, now-60, now)
+	if err != nil {
+		t.Error(err)
+	}
+	higher := w.Archives()[0]
+	series, err := w.FetchArchive(higher, now-61, now-59)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(series.Values) == 0 || series.Values[0] != 100 {
+		t.Error(fmt.Errorf("%v", series))
+	}
+
+	series, err = w.fetch(now-10, now-1, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if series.Step != 1 || series.From != now-9 || len(series.Values) != 9 {
+		t.Fatal(fmt.Errorf("%v", series))
+	}
+	for n, v := range series.Values {
+		if v != float64(n) {
+			t.Error(fmt.Errorf("[%d] %f != %f", n, v, float64(n)))
+		}
+	}
+
+	// The lower archive has the average of each 10 seconds which has enough known values
+	lower := w.Archives()[1]
+	series, err = w.FetchArchive(lower, now-20, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for n, v := range series.Values {
+		ts := series.Timestamp(n)
+		switch ts {
+		case alignInterval(now-9, 10):
+			if v != 4 {
+				t.Error(fmt.Errorf("%d : %f", ts, v))
+			}
+		default:
+			if !math.IsNaN(v) {
+				t.Error(fmt.Errorf("%d : %f", ts, v))
+			}
+		}
+	}
+
+	series, err = w.fetch(now+10, now+20, now)
+	if err != nil || series != nil {
+		t.Error(fmt.Errorf("%v %v", series, err))
+	}
+}
+
+func TestUpdateManyAndXFilesFactor(t *testing.T) {
+	w := testCreateWhisper(t, 0.5, Sum)
+	defer w.Close()
+
+	now := int64(1500000000)
+	base := alignInterval(now-30, 10)
+
+	points := []*Point{}
+	// 6 of 10 points are known in the first interval, and 4 of 10 in the second one
+	for n := range 6 {
+		points = append(points, NewPoint(base+int64(n), 1))
+	}
+	for n := range 4 {
+		points = append(points, NewPoint(base+10+int64(n), 1))
+	}
+	// Covered by the lower archive only
+	points = append(points, NewPoint(now-70, 100))
+
+	err := w.updateMany(points, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lower := w.Archives()[1]
+	series, err := w.FetchArchive(lower, base-10, base+10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for n, v := range series.Values {
+		ts := series.Timestamp(n)
+		switch ts {
+		case base:
+			if v != 6 {
+				t.Error(fmt.Errorf("%d : %f", ts, v))
+			}
+		default:
+			if !math.IsNaN(v) {
+				t.Error(fmt.Errorf("%d : %f", ts, v))
+			}
+		}
+	}
+
+	series, err = w.FetchArchive(lower, now-80, now-70)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if series.Values[0] != 100 {
+		t.Error(fmt.Errorf("%v", series.Values))
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	nan := math.NaN()
+	values := []float64{1, nan, -4, 2}
+
+	testCases := []struct {
+		method   AggregationMethod
+		expected float64
+	}{
+		{Average, -1.0 / 3.0},
+		{Sum, -1},
+		{Last, 2},
+		{Max, 2},
+		{Min, -4},
+		{AvgZero, -0.25},
+		{AbsMax, -4},
+		{AbsMin, 1},
+	}
+
+	for _, tc := range testCases {
+		v := tc.method.Aggregate(values)
+		if math.Abs(v-tc.expected) > 1e-9 {
+			t.Error(fmt.Errorf("%s : %f != %f", tc.method, v, tc.expected))
+		}
+		method, err := ParseAggregationMethod(tc.method.String())
+		if err != nil || method != tc.method {
+			t.Error(fmt.Errorf("%s : %v", tc.method, err))
+		}
+	}
+}